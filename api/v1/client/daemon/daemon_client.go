@@ -116,6 +116,39 @@ func (a *Client) GetHealthz(params *GetHealthzParams) (*GetHealthzOK, error) {
 
 }
 
+/*
+GetKvstoreSnapshot exports the state stored in the kvstore
+
+Returns a snapshot of all keys stored by Cilium in the kvstore below
+the cilium/state prefix. The snapshot can be imported into a new
+kvstore cluster for disaster recovery.
+
+*/
+func (a *Client) GetKvstoreSnapshot(params *GetKvstoreSnapshotParams) (*GetKvstoreSnapshotOK, error) {
+	// TODO: Validate the params before sending
+	if params == nil {
+		params = NewGetKvstoreSnapshotParams()
+	}
+
+	result, err := a.transport.Submit(&runtime.ClientOperation{
+		ID:                 "GetKvstoreSnapshot",
+		Method:             "GET",
+		PathPattern:        "/kvstore/snapshot",
+		ProducesMediaTypes: []string{"application/json"},
+		ConsumesMediaTypes: []string{"application/json"},
+		Schemes:            []string{"http"},
+		Params:             params,
+		Reader:             &GetKvstoreSnapshotReader{formats: a.formats},
+		Context:            params.Context,
+		Client:             params.HTTPClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*GetKvstoreSnapshotOK), nil
+
+}
+
 /*
 PatchConfig modifies daemon configuration
 
@@ -149,6 +182,41 @@ func (a *Client) PatchConfig(params *PatchConfigParams) (*PatchConfigOK, error)
 
 }
 
+/*
+PutKvstoreSnapshot imports the state stored in the kvstore
+
+Imports a snapshot previously created by exporting the state of the
+kvstore. The snapshot is validated for consistency with itself and
+with the current content of the kvstore before any key is written.
+Existing keys are never overwritten. Keys which are protected by a
+lease are attached to the lease of the agent.
+
+*/
+func (a *Client) PutKvstoreSnapshot(params *PutKvstoreSnapshotParams) (*PutKvstoreSnapshotOK, error) {
+	// TODO: Validate the params before sending
+	if params == nil {
+		params = NewPutKvstoreSnapshotParams()
+	}
+
+	result, err := a.transport.Submit(&runtime.ClientOperation{
+		ID:                 "PutKvstoreSnapshot",
+		Method:             "PUT",
+		PathPattern:        "/kvstore/snapshot",
+		ProducesMediaTypes: []string{"application/json"},
+		ConsumesMediaTypes: []string{"application/json"},
+		Schemes:            []string{"http"},
+		Params:             params,
+		Reader:             &PutKvstoreSnapshotReader{formats: a.formats},
+		Context:            params.Context,
+		Client:             params.HTTPClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*PutKvstoreSnapshotOK), nil
+
+}
+
 // SetTransport changes the transport on the client
 func (a *Client) SetTransport(transport runtime.ClientTransport) {
 	a.transport = transport
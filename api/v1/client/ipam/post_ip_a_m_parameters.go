@@ -16,6 +16,8 @@ import (
 	cr "github.com/go-openapi/runtime/client"
 
 	strfmt "github.com/go-openapi/strfmt"
+
+	"github.com/cilium/cilium/api/v1/models"
 )
 
 // NewPostIPAMParams creates a new PostIPAMParams object
@@ -64,6 +66,11 @@ type PostIPAMParams struct {
 
 	/*Family*/
 	Family *string
+	/*Owner
+	  Owner of the addresses, used to select the IPAM pool
+
+	*/
+	Owner *models.IPAMOwner
 
 	timeout    time.Duration
 	Context    context.Context
@@ -114,6 +121,17 @@ func (o *PostIPAMParams) SetFamily(family *string) {
 	o.Family = family
 }
 
+// WithOwner adds the owner to the post IP a m params
+func (o *PostIPAMParams) WithOwner(owner *models.IPAMOwner) *PostIPAMParams {
+	o.SetOwner(owner)
+	return o
+}
+
+// SetOwner adds the owner to the post IP a m params
+func (o *PostIPAMParams) SetOwner(owner *models.IPAMOwner) {
+	o.Owner = owner
+}
+
 // WriteToRequest writes these params to a swagger request
 func (o *PostIPAMParams) WriteToRequest(r runtime.ClientRequest, reg strfmt.Registry) error {
 
@@ -138,6 +156,12 @@ func (o *PostIPAMParams) WriteToRequest(r runtime.ClientRequest, reg strfmt.Regi
 
 	}
 
+	if o.Owner != nil {
+		if err := r.SetBodyParam(o.Owner); err != nil {
+			return err
+		}
+	}
+
 	if len(res) > 0 {
 		return errors.CompositeValidationError(res...)
 	}
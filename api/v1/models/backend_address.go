@@ -6,6 +6,8 @@ package models
 // Editing this file might prove futile when you re-run the swagger generate command
 
 import (
+	"encoding/json"
+
 	strfmt "github.com/go-openapi/strfmt"
 
 	"github.com/go-openapi/errors"
@@ -18,6 +20,15 @@ import (
 
 type BackendAddress struct {
 
+	// Cluster the backend belongs to, only set for global services
+	Cluster string `json:"cluster,omitempty"`
+
+	// Backend was removed and only serves its established connections
+	Draining bool `json:"draining,omitempty"`
+
+	// Result of the health checks of the backend
+	Health string `json:"health,omitempty"`
+
 	// Layer 3 address
 	// Required: true
 	IP *string `json:"ip"`
@@ -25,31 +36,95 @@ type BackendAddress struct {
 	// Layer 4 port number
 	Port uint16 `json:"port,omitempty"`
 
+	// Traffic statistics of the backend
+	Statistics *ServiceStatistics `json:"statistics,omitempty"`
+
 	// Weight for Round Robin
 	Weight uint16 `json:"weight,omitempty"`
 }
 
+/* polymorph BackendAddress cluster false */
+
+/* polymorph BackendAddress draining false */
+
+/* polymorph BackendAddress health false */
+
 /* polymorph BackendAddress ip false */
 
 /* polymorph BackendAddress port false */
 
+/* polymorph BackendAddress statistics false */
+
 /* polymorph BackendAddress weight false */
 
 // Validate validates this backend address
 func (m *BackendAddress) Validate(formats strfmt.Registry) error {
 	var res []error
 
+	if err := m.validateHealth(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
 	if err := m.validateIP(formats); err != nil {
 		// prop
 		res = append(res, err)
 	}
 
+	if err := m.validateStatistics(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
 	if len(res) > 0 {
 		return errors.CompositeValidationError(res...)
 	}
 	return nil
 }
 
+var backendAddressTypeHealthPropEnum []interface{}
+
+func init() {
+	var res []string
+	if err := json.Unmarshal([]byte(`["unknown","healthy","unhealthy"]`), &res); err != nil {
+		panic(err)
+	}
+	for _, v := range res {
+		backendAddressTypeHealthPropEnum = append(backendAddressTypeHealthPropEnum, v)
+	}
+}
+
+const (
+	// BackendAddressHealthUnknown captures enum value "unknown"
+	BackendAddressHealthUnknown string = "unknown"
+	// BackendAddressHealthHealthy captures enum value "healthy"
+	BackendAddressHealthHealthy string = "healthy"
+	// BackendAddressHealthUnhealthy captures enum value "unhealthy"
+	BackendAddressHealthUnhealthy string = "unhealthy"
+)
+
+// prop value enum
+func (m *BackendAddress) validateHealthEnum(path, location string, value string) error {
+	if err := validate.Enum(path, location, value, backendAddressTypeHealthPropEnum); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *BackendAddress) validateHealth(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.Health) { // not required
+		return nil
+	}
+
+	// value enum
+	if err := m.validateHealthEnum("health", "body", m.Health); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (m *BackendAddress) validateIP(formats strfmt.Registry) error {
 
 	if err := validate.Required("ip", "body", m.IP); err != nil {
@@ -59,6 +134,25 @@ func (m *BackendAddress) validateIP(formats strfmt.Registry) error {
 	return nil
 }
 
+func (m *BackendAddress) validateStatistics(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.Statistics) { // not required
+		return nil
+	}
+
+	if m.Statistics != nil {
+
+		if err := m.Statistics.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("statistics")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 // MarshalBinary interface implementation
 func (m *BackendAddress) MarshalBinary() ([]byte, error) {
 	if m == nil {
@@ -20,7 +20,9 @@ import (
 
 type FrontendAddress struct {
 
-	// Layer 3 address
+	// Layer 3 address, a virtual IP of the service range is allocated
+	// to services if empty or unspecified
+	//
 	IP string `json:"ip,omitempty"`
 
 	// Layer 4 port number
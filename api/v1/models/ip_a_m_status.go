@@ -6,6 +6,8 @@ package models
 // Editing this file might prove futile when you re-run the swagger generate command
 
 import (
+	"strconv"
+
 	strfmt "github.com/go-openapi/strfmt"
 
 	"github.com/go-openapi/errors"
@@ -17,21 +19,36 @@ import (
 
 type IPAMStatus struct {
 
+	// checkpoint
+	Checkpoint *IPAMCheckpointStatus `json:"checkpoint,omitempty"`
+
 	// ipv4
 	IPV4 []string `json:"ipv4"`
 
 	// ipv6
 	IPV6 []string `json:"ipv6"`
+
+	// Utilization of the IPAM pools
+	Pools []*IPAMPoolStatus `json:"pools"`
 }
 
+/* polymorph IPAMStatus checkpoint false */
+
 /* polymorph IPAMStatus ipv4 false */
 
 /* polymorph IPAMStatus ipv6 false */
 
+/* polymorph IPAMStatus pools false */
+
 // Validate validates this IP a m status
 func (m *IPAMStatus) Validate(formats strfmt.Registry) error {
 	var res []error
 
+	if err := m.validateCheckpoint(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
 	if err := m.validateIPV4(formats); err != nil {
 		// prop
 		res = append(res, err)
@@ -42,12 +59,36 @@ func (m *IPAMStatus) Validate(formats strfmt.Registry) error {
 		res = append(res, err)
 	}
 
+	if err := m.validatePools(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
 	if len(res) > 0 {
 		return errors.CompositeValidationError(res...)
 	}
 	return nil
 }
 
+func (m *IPAMStatus) validateCheckpoint(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.Checkpoint) { // not required
+		return nil
+	}
+
+	if m.Checkpoint != nil {
+
+		if err := m.Checkpoint.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("checkpoint")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (m *IPAMStatus) validateIPV4(formats strfmt.Registry) error {
 
 	if swag.IsZero(m.IPV4) { // not required
@@ -66,6 +107,33 @@ func (m *IPAMStatus) validateIPV6(formats strfmt.Registry) error {
 	return nil
 }
 
+func (m *IPAMStatus) validatePools(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.Pools) { // not required
+		return nil
+	}
+
+	for i := 0; i < len(m.Pools); i++ {
+
+		if swag.IsZero(m.Pools[i]) { // not required
+			continue
+		}
+
+		if m.Pools[i] != nil {
+
+			if err := m.Pools[i].Validate(formats); err != nil {
+				if ve, ok := err.(*errors.Validation); ok {
+					return ve.ValidateName("pools" + "." + strconv.Itoa(i))
+				}
+				return err
+			}
+		}
+
+	}
+
+	return nil
+}
+
 // MarshalBinary interface implementation
 func (m *IPAMStatus) MarshalBinary() ([]byte, error) {
 	if m == nil {
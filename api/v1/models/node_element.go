@@ -30,6 +30,9 @@ type NodeElement struct {
 
 	// Alternative addresses assigned to the node
 	SecondaryAddresses []*NodeAddressingElement `json:"secondary-addresses"`
+
+	// Source from which the node was learned
+	Source string `json:"source,omitempty"`
 }
 
 /* polymorph NodeElement health-endpoint-address false */
@@ -40,6 +43,8 @@ type NodeElement struct {
 
 /* polymorph NodeElement secondary-addresses false */
 
+/* polymorph NodeElement source false */
+
 // Validate validates this node element
 func (m *NodeElement) Validate(formats strfmt.Registry) error {
 	var res []error
@@ -6,6 +6,7 @@ package models
 // Editing this file might prove futile when you re-run the swagger generate command
 
 import (
+	"encoding/json"
 	"strconv"
 
 	strfmt "github.com/go-openapi/strfmt"
@@ -26,22 +27,78 @@ type ServiceSpec struct {
 	// flags
 	Flags *ServiceSpecFlags `json:"flags,omitempty"`
 
+	// Forwarding mode of the service. In DSR mode, the frontend is
+	// carried to the backend node and replies are sent directly to
+	// the client. DSR mode requires the reverse translation enabled by
+	// the direct-server-return flag.
+	//
+	ForwardingMode string `json:"forwarding-mode,omitempty"`
+
 	// Frontend address
 	// Required: true
 	FrontendAddress *FrontendAddress `json:"frontend-address"`
 
+	// Type of the frontend
+	FrontendType string `json:"frontend-type,omitempty"`
+
+	// Health check of the backends
+	HealthCheck *ServiceHealthCheck `json:"health-check,omitempty"`
+
 	// Unique identification
 	ID int64 `json:"id,omitempty"`
+
+	// Labels of the service
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Name of the service
+	Name string `json:"name,omitempty"`
+
+	// Namespace of the service
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the service port of the frontend
+	PortName string `json:"port-name,omitempty"`
+
+	// Session affinity of the service
+	SessionAffinity string `json:"session-affinity,omitempty"`
+
+	// Idle time in seconds after which a client may be assigned a
+	// different backend when session affinity is ClientIP
+	//
+	SessionAffinityTimeout int64 `json:"session-affinity-timeout,omitempty"`
+
+	// Origin of the service
+	Source string `json:"source,omitempty"`
 }
 
 /* polymorph ServiceSpec backend-addresses false */
 
 /* polymorph ServiceSpec flags false */
 
+/* polymorph ServiceSpec forwarding-mode false */
+
 /* polymorph ServiceSpec frontend-address false */
 
+/* polymorph ServiceSpec frontend-type false */
+
+/* polymorph ServiceSpec health-check false */
+
 /* polymorph ServiceSpec id false */
 
+/* polymorph ServiceSpec labels false */
+
+/* polymorph ServiceSpec name false */
+
+/* polymorph ServiceSpec namespace false */
+
+/* polymorph ServiceSpec port-name false */
+
+/* polymorph ServiceSpec session-affinity false */
+
+/* polymorph ServiceSpec session-affinity-timeout false */
+
+/* polymorph ServiceSpec source false */
+
 // Validate validates this service spec
 func (m *ServiceSpec) Validate(formats strfmt.Registry) error {
 	var res []error
@@ -56,11 +113,36 @@ func (m *ServiceSpec) Validate(formats strfmt.Registry) error {
 		res = append(res, err)
 	}
 
+	if err := m.validateForwardingMode(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
 	if err := m.validateFrontendAddress(formats); err != nil {
 		// prop
 		res = append(res, err)
 	}
 
+	if err := m.validateFrontendType(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
+	if err := m.validateHealthCheck(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
+	if err := m.validateSessionAffinity(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
+	if err := m.validateSource(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
 	if len(res) > 0 {
 		return errors.CompositeValidationError(res...)
 	}
@@ -113,6 +195,47 @@ func (m *ServiceSpec) validateFlags(formats strfmt.Registry) error {
 	return nil
 }
 
+var serviceSpecTypeForwardingModePropEnum []interface{}
+
+func init() {
+	var res []string
+	if err := json.Unmarshal([]byte(`["NAT","DSR"]`), &res); err != nil {
+		panic(err)
+	}
+	for _, v := range res {
+		serviceSpecTypeForwardingModePropEnum = append(serviceSpecTypeForwardingModePropEnum, v)
+	}
+}
+
+const (
+	// ServiceSpecForwardingModeNAT captures enum value "NAT"
+	ServiceSpecForwardingModeNAT string = "NAT"
+	// ServiceSpecForwardingModeDSR captures enum value "DSR"
+	ServiceSpecForwardingModeDSR string = "DSR"
+)
+
+// prop value enum
+func (m *ServiceSpec) validateForwardingModeEnum(path, location string, value string) error {
+	if err := validate.Enum(path, location, value, serviceSpecTypeForwardingModePropEnum); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *ServiceSpec) validateForwardingMode(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.ForwardingMode) { // not required
+		return nil
+	}
+
+	// value enum
+	if err := m.validateForwardingModeEnum("forwarding-mode", "body", m.ForwardingMode); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (m *ServiceSpec) validateFrontendAddress(formats strfmt.Registry) error {
 
 	if err := validate.Required("frontend-address", "body", m.FrontendAddress); err != nil {
@@ -132,6 +255,152 @@ func (m *ServiceSpec) validateFrontendAddress(formats strfmt.Registry) error {
 	return nil
 }
 
+var serviceSpecTypeFrontendTypePropEnum []interface{}
+
+func init() {
+	var res []string
+	if err := json.Unmarshal([]byte(`["ClusterIP","NodePort","ExternalIP","LoadBalancer"]`), &res); err != nil {
+		panic(err)
+	}
+	for _, v := range res {
+		serviceSpecTypeFrontendTypePropEnum = append(serviceSpecTypeFrontendTypePropEnum, v)
+	}
+}
+
+const (
+	// ServiceSpecFrontendTypeClusterIP captures enum value "ClusterIP"
+	ServiceSpecFrontendTypeClusterIP string = "ClusterIP"
+	// ServiceSpecFrontendTypeNodePort captures enum value "NodePort"
+	ServiceSpecFrontendTypeNodePort string = "NodePort"
+	// ServiceSpecFrontendTypeExternalIP captures enum value "ExternalIP"
+	ServiceSpecFrontendTypeExternalIP string = "ExternalIP"
+	// ServiceSpecFrontendTypeLoadBalancer captures enum value "LoadBalancer"
+	ServiceSpecFrontendTypeLoadBalancer string = "LoadBalancer"
+)
+
+// prop value enum
+func (m *ServiceSpec) validateFrontendTypeEnum(path, location string, value string) error {
+	if err := validate.Enum(path, location, value, serviceSpecTypeFrontendTypePropEnum); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *ServiceSpec) validateFrontendType(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.FrontendType) { // not required
+		return nil
+	}
+
+	// value enum
+	if err := m.validateFrontendTypeEnum("frontend-type", "body", m.FrontendType); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *ServiceSpec) validateHealthCheck(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.HealthCheck) { // not required
+		return nil
+	}
+
+	if m.HealthCheck != nil {
+
+		if err := m.HealthCheck.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("health-check")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+var serviceSpecTypeSessionAffinityPropEnum []interface{}
+
+func init() {
+	var res []string
+	if err := json.Unmarshal([]byte(`["None","ClientIP"]`), &res); err != nil {
+		panic(err)
+	}
+	for _, v := range res {
+		serviceSpecTypeSessionAffinityPropEnum = append(serviceSpecTypeSessionAffinityPropEnum, v)
+	}
+}
+
+const (
+	// ServiceSpecSessionAffinityNone captures enum value "None"
+	ServiceSpecSessionAffinityNone string = "None"
+	// ServiceSpecSessionAffinityClientIP captures enum value "ClientIP"
+	ServiceSpecSessionAffinityClientIP string = "ClientIP"
+)
+
+// prop value enum
+func (m *ServiceSpec) validateSessionAffinityEnum(path, location string, value string) error {
+	if err := validate.Enum(path, location, value, serviceSpecTypeSessionAffinityPropEnum); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *ServiceSpec) validateSessionAffinity(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.SessionAffinity) { // not required
+		return nil
+	}
+
+	// value enum
+	if err := m.validateSessionAffinityEnum("session-affinity", "body", m.SessionAffinity); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+var serviceSpecTypeSourcePropEnum []interface{}
+
+func init() {
+	var res []string
+	if err := json.Unmarshal([]byte(`["k8s","api"]`), &res); err != nil {
+		panic(err)
+	}
+	for _, v := range res {
+		serviceSpecTypeSourcePropEnum = append(serviceSpecTypeSourcePropEnum, v)
+	}
+}
+
+const (
+	// ServiceSpecSourceK8s captures enum value "k8s"
+	ServiceSpecSourceK8s string = "k8s"
+	// ServiceSpecSourceAPI captures enum value "api"
+	ServiceSpecSourceAPI string = "api"
+)
+
+// prop value enum
+func (m *ServiceSpec) validateSourceEnum(path, location string, value string) error {
+	if err := validate.Enum(path, location, value, serviceSpecTypeSourcePropEnum); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *ServiceSpec) validateSource(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.Source) { // not required
+		return nil
+	}
+
+	// value enum
+	if err := m.validateSourceEnum("source", "body", m.Source); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // MarshalBinary interface implementation
 func (m *ServiceSpec) MarshalBinary() ([]byte, error) {
 	if m == nil {
@@ -158,7 +427,7 @@ type ServiceSpecFlags struct {
 	// Frontend to backend translation activated
 	ActiveFrontend bool `json:"active-frontend,omitempty"`
 
-	// Perform direct server return
+	// Install the reverse translation of the service
 	DirectServerReturn bool `json:"direct-server-return,omitempty"`
 }
 
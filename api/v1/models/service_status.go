@@ -19,10 +19,15 @@ type ServiceStatus struct {
 
 	// realized
 	Realized *ServiceSpec `json:"realized,omitempty"`
+
+	// Traffic statistics of the frontend
+	Statistics *ServiceStatistics `json:"statistics,omitempty"`
 }
 
 /* polymorph ServiceStatus realized false */
 
+/* polymorph ServiceStatus statistics false */
+
 // Validate validates this service status
 func (m *ServiceStatus) Validate(formats strfmt.Registry) error {
 	var res []error
@@ -32,6 +37,11 @@ func (m *ServiceStatus) Validate(formats strfmt.Registry) error {
 		res = append(res, err)
 	}
 
+	if err := m.validateStatistics(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
 	if len(res) > 0 {
 		return errors.CompositeValidationError(res...)
 	}
@@ -57,6 +67,25 @@ func (m *ServiceStatus) validateRealized(formats strfmt.Registry) error {
 	return nil
 }
 
+func (m *ServiceStatus) validateStatistics(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.Statistics) { // not required
+		return nil
+	}
+
+	if m.Statistics != nil {
+
+		if err := m.Statistics.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("statistics")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 // MarshalBinary interface implementation
 func (m *ServiceStatus) MarshalBinary() ([]byte, error) {
 	if m == nil {
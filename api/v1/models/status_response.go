@@ -23,6 +23,9 @@ type StatusResponse struct {
 	// Status of cluster
 	Cluster *ClusterStatus `json:"cluster,omitempty"`
 
+	// Status of ClusterMesh
+	ClusterMesh *ClusterMeshStatus `json:"cluster-mesh,omitempty"`
+
 	// Status of local container runtime
 	ContainerRuntime *Status `json:"container-runtime,omitempty"`
 
@@ -38,6 +41,12 @@ type StatusResponse struct {
 	// Status of key/value datastore
 	Kvstore *Status `json:"kvstore,omitempty"`
 
+	// Statistics of the operations performed on the key/value datastore
+	KvstoreOperations KvstoreOperationStatuses `json:"kvstore-operations"`
+
+	// Status of the leader election among all agents
+	LeaderElection *LeaderElectionStatus `json:"leader-election,omitempty"`
+
 	// Status of the node monitor
 	NodeMonitor *MonitorStatus `json:"nodeMonitor,omitempty"`
 
@@ -49,6 +58,8 @@ type StatusResponse struct {
 
 /* polymorph StatusResponse cluster false */
 
+/* polymorph StatusResponse cluster-mesh false */
+
 /* polymorph StatusResponse container-runtime false */
 
 /* polymorph StatusResponse controllers false */
@@ -59,6 +70,10 @@ type StatusResponse struct {
 
 /* polymorph StatusResponse kvstore false */
 
+/* polymorph StatusResponse kvstore-operations false */
+
+/* polymorph StatusResponse leader-election false */
+
 /* polymorph StatusResponse nodeMonitor false */
 
 /* polymorph StatusResponse proxy false */
@@ -77,6 +92,11 @@ func (m *StatusResponse) Validate(formats strfmt.Registry) error {
 		res = append(res, err)
 	}
 
+	if err := m.validateClusterMesh(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
 	if err := m.validateContainerRuntime(formats); err != nil {
 		// prop
 		res = append(res, err)
@@ -97,6 +117,11 @@ func (m *StatusResponse) Validate(formats strfmt.Registry) error {
 		res = append(res, err)
 	}
 
+	if err := m.validateLeaderElection(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
 	if err := m.validateNodeMonitor(formats); err != nil {
 		// prop
 		res = append(res, err)
@@ -151,6 +176,25 @@ func (m *StatusResponse) validateCluster(formats strfmt.Registry) error {
 	return nil
 }
 
+func (m *StatusResponse) validateClusterMesh(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.ClusterMesh) { // not required
+		return nil
+	}
+
+	if m.ClusterMesh != nil {
+
+		if err := m.ClusterMesh.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("cluster-mesh")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (m *StatusResponse) validateContainerRuntime(formats strfmt.Registry) error {
 
 	if swag.IsZero(m.ContainerRuntime) { // not required
@@ -227,6 +271,25 @@ func (m *StatusResponse) validateKvstore(formats strfmt.Registry) error {
 	return nil
 }
 
+func (m *StatusResponse) validateLeaderElection(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.LeaderElection) { // not required
+		return nil
+	}
+
+	if m.LeaderElection != nil {
+
+		if err := m.LeaderElection.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("leader-election")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (m *StatusResponse) validateNodeMonitor(formats strfmt.Registry) error {
 
 	if swag.IsZero(m.NodeMonitor) { // not required
@@ -561,6 +561,9 @@ func init() {
         "parameters": [
           {
             "$ref": "#/parameters/ipam-family"
+          },
+          {
+            "$ref": "#/parameters/ipam-owner"
           }
         ],
         "responses": {
@@ -651,6 +654,82 @@ func init() {
         }
       }
     },
+    "/kvstore/snapshot": {
+      "get": {
+        "description": "Returns a snapshot of all keys stored by Cilium in the kvstore below\nthe cilium/state prefix. The snapshot can be imported into a new\nkvstore cluster for disaster recovery.\n",
+        "tags": [
+          "daemon"
+        ],
+        "summary": "Export the state stored in the kvstore",
+        "responses": {
+          "200": {
+            "description": "Success",
+            "schema": {
+              "$ref": "#/definitions/KvstoreSnapshot"
+            }
+          },
+          "500": {
+            "description": "Snapshot could not be created",
+            "schema": {
+              "$ref": "#/definitions/Error"
+            },
+            "x-go-name": "Failure"
+          }
+        }
+      },
+      "put": {
+        "description": "Imports a snapshot previously created by exporting the state of the\nkvstore. The snapshot is validated for consistency with itself and\nwith the current content of the kvstore before any key is written.\nExisting keys are never overwritten. Keys which are protected by a\nlease are attached to the lease of the agent.\n",
+        "tags": [
+          "daemon"
+        ],
+        "summary": "Import the state stored in the kvstore",
+        "parameters": [
+          {
+            "name": "snapshot",
+            "in": "body",
+            "required": true,
+            "schema": {
+              "$ref": "#/definitions/KvstoreSnapshot"
+            }
+          },
+          {
+            "type": "boolean",
+            "description": "Validate the snapshot without writing any keys",
+            "name": "dry-run",
+            "in": "query"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Success",
+            "schema": {
+              "$ref": "#/definitions/KvstoreSnapshotImportResult"
+            }
+          },
+          "400": {
+            "description": "Invalid snapshot",
+            "schema": {
+              "$ref": "#/definitions/Error"
+            },
+            "x-go-name": "Invalid"
+          },
+          "409": {
+            "description": "Snapshot conflicts with itself or the kvstore",
+            "schema": {
+              "$ref": "#/definitions/Error"
+            },
+            "x-go-name": "Conflict"
+          },
+          "500": {
+            "description": "Snapshot could not be imported",
+            "schema": {
+              "$ref": "#/definitions/Error"
+            },
+            "x-go-name": "Failure"
+          }
+        }
+      }
+    },
     "/policy": {
       "get": {
         "description": "Returns the entire policy tree with all children.\n",
@@ -976,6 +1055,23 @@ func init() {
         "ip"
       ],
       "properties": {
+        "cluster": {
+          "description": "Cluster the backend belongs to, only set for global services",
+          "type": "string"
+        },
+        "draining": {
+          "description": "Backend was removed and only serves its established connections",
+          "type": "boolean"
+        },
+        "health": {
+          "description": "Result of the health checks of the backend",
+          "type": "string",
+          "enum": [
+            "unknown",
+            "healthy",
+            "unhealthy"
+          ]
+        },
         "ip": {
           "description": "Layer 3 address",
           "type": "string"
@@ -985,6 +1081,10 @@ func init() {
           "type": "integer",
           "format": "uint16"
         },
+        "statistics": {
+          "description": "Traffic statistics of the backend",
+          "$ref": "#/definitions/ServiceStatistics"
+        },
         "weight": {
           "description": "Weight for Round Robin",
           "type": "integer",
@@ -1027,6 +1127,19 @@ func init() {
         }
       }
     },
+    "ClusterMeshStatus": {
+      "description": "Status of ClusterMesh",
+      "type": "object",
+      "properties": {
+        "clusters": {
+          "description": "List of remote clusters",
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/RemoteCluster"
+          }
+        }
+      }
+    },
     "ClusterStatus": {
       "description": "Status of cluster",
       "properties": {
@@ -1613,7 +1726,7 @@ func init() {
       "type": "object",
       "properties": {
         "ip": {
-          "description": "Layer 3 address",
+          "description": "Layer 3 address, a virtual IP of the service range is allocated\nto services if empty or unspecified\n",
           "type": "string"
         },
         "port": {
@@ -1632,6 +1745,98 @@ func init() {
         }
       }
     },
+    "IPAMAllocation": {
+      "description": "Address allocated to a container",
+      "properties": {
+        "ip": {
+          "description": "Allocated address",
+          "type": "string"
+        },
+        "owner": {
+          "description": "ID of the container the address was allocated to",
+          "type": "string"
+        },
+        "timestamp": {
+          "description": "Time of the allocation",
+          "type": "string",
+          "format": "date-time"
+        }
+      }
+    },
+    "IPAMCheckpointStatus": {
+      "description": "Status of the checkpointed IPAM allocations and of the garbage\ncollection of orphaned allocations\n",
+      "properties": {
+        "allocations": {
+          "description": "Number of checkpointed allocations",
+          "type": "integer"
+        },
+        "last-gc": {
+          "description": "Time of the last garbage collection",
+          "type": "string",
+          "format": "date-time"
+        },
+        "released": {
+          "description": "Orphaned allocations released by the last garbage collection",
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/IPAMAllocation"
+          }
+        },
+        "released-total": {
+          "description": "Number of orphaned allocations released since the agent started",
+          "type": "integer"
+        }
+      }
+    },
+    "IPAMOwner": {
+      "description": "Owner of the addresses requested from the IPAM",
+      "type": "object",
+      "properties": {
+        "annotations": {
+          "description": "Annotations of the pod, retrieved from Kubernetes if not\nspecified\n",
+          "type": "object",
+          "additionalProperties": {
+            "type": "string"
+          }
+        },
+        "container-id": {
+          "description": "ID of the container, used to checkpoint the allocation\n",
+          "type": "string"
+        },
+        "namespace": {
+          "description": "Namespace of the pod",
+          "type": "string"
+        },
+        "pod-name": {
+          "description": "Name of the pod",
+          "type": "string"
+        }
+      }
+    },
+    "IPAMPoolStatus": {
+      "description": "Utilization of an IPAM pool",
+      "properties": {
+        "capacity": {
+          "description": "Number of allocatable addresses",
+          "type": "integer"
+        },
+        "cidrs": {
+          "description": "Prefixes of the pool",
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
+        },
+        "name": {
+          "description": "Name of the pool",
+          "type": "string"
+        },
+        "used": {
+          "description": "Number of allocated addresses",
+          "type": "integer"
+        }
+      }
+    },
     "IPAMResponse": {
       "description": "IPAM configuration of an endpoint",
       "type": "object",
@@ -1651,6 +1856,9 @@ func init() {
     "IPAMStatus": {
       "description": "Status of IP address management",
       "properties": {
+        "checkpoint": {
+          "$ref": "#/definitions/IPAMCheckpointStatus"
+        },
         "ipv4": {
           "type": "array",
           "items": {
@@ -1662,6 +1870,13 @@ func init() {
           "items": {
             "type": "string"
           }
+        },
+        "pools": {
+          "description": "Utilization of the IPAM pools",
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/IPAMPoolStatus"
+          }
         }
       }
     },
@@ -1725,6 +1940,124 @@ func init() {
         }
       }
     },
+    "KvstoreOperationStatus": {
+      "description": "Statistics of a class of kvstore operations",
+      "type": "object",
+      "properties": {
+        "burst": {
+          "description": "Number of operations allowed to exceed the rate limit",
+          "type": "integer"
+        },
+        "bytes": {
+          "description": "Number of bytes read or written",
+          "type": "integer"
+        },
+        "class": {
+          "description": "Name of the operation class",
+          "type": "string"
+        },
+        "count": {
+          "description": "Number of operations performed",
+          "type": "integer"
+        },
+        "errors": {
+          "description": "Number of operations which failed",
+          "type": "integer"
+        },
+        "mean-latency": {
+          "description": "Mean latency of an operation in seconds",
+          "type": "number"
+        },
+        "qps": {
+          "description": "Client-side rate limit in operations per second, 0 if not limited",
+          "type": "number"
+        },
+        "throttled": {
+          "description": "Number of operations delayed by the client-side rate limit",
+          "type": "integer"
+        }
+      }
+    },
+    "KvstoreOperationStatuses": {
+      "description": "Collection of kvstore operation statistics",
+      "type": "array",
+      "items": {
+        "$ref": "#/definitions/KvstoreOperationStatus"
+      }
+    },
+    "KvstoreSnapshot": {
+      "description": "Snapshot of the state stored by Cilium in the kvstore",
+      "type": "object",
+      "properties": {
+        "created": {
+          "description": "Time the snapshot was created",
+          "type": "string"
+        },
+        "entries": {
+          "description": "List of keys",
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/KvstoreSnapshotEntry"
+          }
+        },
+        "prefix": {
+          "description": "Key prefix covered by the snapshot",
+          "type": "string"
+        },
+        "version": {
+          "description": "Version of the snapshot format",
+          "type": "integer"
+        }
+      }
+    },
+    "KvstoreSnapshotEntry": {
+      "description": "Single key of a kvstore snapshot",
+      "type": "object",
+      "properties": {
+        "binary": {
+          "description": "Value of the key if it is binary",
+          "type": "string",
+          "format": "byte"
+        },
+        "key": {
+          "description": "Path of the key",
+          "type": "string"
+        },
+        "lease": {
+          "description": "Key is attached to a lease",
+          "type": "boolean"
+        },
+        "text": {
+          "description": "Value of the key if it is text",
+          "type": "string"
+        },
+        "value": {
+          "description": "Value of the key if it is a JSON document"
+        }
+      }
+    },
+    "KvstoreSnapshotImportResult": {
+      "description": "Result of a kvstore snapshot import",
+      "type": "object",
+      "properties": {
+        "created": {
+          "description": "Number of keys created",
+          "type": "integer"
+        },
+        "dry-run": {
+          "description": "No keys were written",
+          "type": "boolean"
+        },
+        "skipped": {
+          "description": "Number of keys which already existed with a different value",
+          "type": "integer"
+        },
+        "unchanged": {
+          "description": "Number of keys which already existed with the same value",
+          "type": "integer"
+        }
+      }
+    },
     "L4Policy": {
       "description": "L4 endpoint policy",
       "type": "object",
@@ -1798,6 +2131,24 @@ func init() {
         "type": "string"
       }
     },
+    "LeaderElectionStatus": {
+      "description": "Status of a leader election",
+      "type": "object",
+      "properties": {
+        "is-leader": {
+          "description": "Indicates whether this agent is the leader",
+          "type": "boolean"
+        },
+        "leader": {
+          "description": "Identity of the current leader, empty if unknown",
+          "type": "string"
+        },
+        "name": {
+          "description": "Name of the election",
+          "type": "string"
+        }
+      }
+    },
     "MessageForwardingStatistics": {
       "description": "Statistics of a message forwarding entity",
       "type": "object",
@@ -1899,6 +2250,10 @@ func init() {
           "items": {
             "$ref": "#/definitions/NodeAddressingElement"
           }
+        },
+        "source": {
+          "description": "Source from which the node was learned",
+          "type": "string"
         }
       }
     },
@@ -2047,6 +2402,36 @@ func init() {
         }
       }
     },
+    "RemoteCluster": {
+      "description": "Status of remote cluster",
+      "type": "object",
+      "properties": {
+        "connected": {
+          "description": "Indicates whether the connection to the remote kvstore is established",
+          "type": "boolean"
+        },
+        "name": {
+          "description": "Name of the cluster",
+          "type": "string"
+        },
+        "num-identities": {
+          "description": "Number of identities in the cluster",
+          "type": "integer"
+        },
+        "num-nodes": {
+          "description": "Number of nodes in the cluster",
+          "type": "integer"
+        },
+        "ready": {
+          "description": "Indicates readiness of the remote cluster",
+          "type": "boolean"
+        },
+        "status": {
+          "description": "Status of the control plane",
+          "type": "string"
+        }
+      }
+    },
     "RequestResponseStatistics": {
       "description": "Statistics of a proxy redirect",
       "type": "object",
@@ -2071,6 +2456,32 @@ func init() {
         }
       }
     },
+    "ServiceHealthCheck": {
+      "description": "Health check configuration of the backends of a service",
+      "type": "object",
+      "properties": {
+        "interval": {
+          "description": "Interval in seconds between two probes of a backend",
+          "type": "integer"
+        },
+        "path": {
+          "description": "Path requested by HTTP probes",
+          "type": "string"
+        },
+        "timeout": {
+          "description": "Timeout in seconds of a probe",
+          "type": "integer"
+        },
+        "type": {
+          "description": "Type of the probe",
+          "type": "string",
+          "enum": [
+            "tcp",
+            "http"
+          ]
+        }
+      }
+    },
     "ServiceSpec": {
       "description": "Configuration of a service",
       "type": "object",
@@ -2094,18 +2505,97 @@ func init() {
               "type": "boolean"
             },
             "direct-server-return": {
-              "description": "Perform direct server return",
+              "description": "Install the reverse translation of the service",
               "type": "boolean"
             }
           }
         },
+        "forwarding-mode": {
+          "description": "Forwarding mode of the service. In DSR mode, the frontend is\ncarried to the backend node and replies are sent directly to\nthe client. DSR mode requires the reverse translation enabled by\nthe direct-server-return flag.\n",
+          "type": "string",
+          "enum": [
+            "NAT",
+            "DSR"
+          ]
+        },
         "frontend-address": {
           "description": "Frontend address",
           "$ref": "#/definitions/FrontendAddress"
         },
+        "frontend-type": {
+          "description": "Type of the frontend",
+          "type": "string",
+          "enum": [
+            "ClusterIP",
+            "NodePort",
+            "ExternalIP",
+            "LoadBalancer"
+          ]
+        },
+        "health-check": {
+          "description": "Health check of the backends",
+          "$ref": "#/definitions/ServiceHealthCheck"
+        },
         "id": {
           "description": "Unique identification",
           "type": "integer"
+        },
+        "labels": {
+          "description": "Labels of the service",
+          "type": "object",
+          "additionalProperties": {
+            "type": "string"
+          }
+        },
+        "name": {
+          "description": "Name of the service",
+          "type": "string"
+        },
+        "namespace": {
+          "description": "Namespace of the service",
+          "type": "string"
+        },
+        "port-name": {
+          "description": "Name of the service port of the frontend",
+          "type": "string"
+        },
+        "session-affinity": {
+          "description": "Session affinity of the service",
+          "type": "string",
+          "enum": [
+            "None",
+            "ClientIP"
+          ]
+        },
+        "session-affinity-timeout": {
+          "description": "Idle time in seconds after which a client may be assigned a\ndifferent backend when session affinity is ClientIP\n",
+          "type": "integer"
+        },
+        "source": {
+          "description": "Origin of the service",
+          "type": "string",
+          "enum": [
+            "k8s",
+            "api"
+          ]
+        }
+      }
+    },
+    "ServiceStatistics": {
+      "description": "Traffic statistics of a service frontend or backend",
+      "type": "object",
+      "properties": {
+        "bytes": {
+          "description": "Number of bytes forwarded",
+          "type": "integer"
+        },
+        "connections": {
+          "description": "Number of new connections",
+          "type": "integer"
+        },
+        "packets": {
+          "description": "Number of packets forwarded",
+          "type": "integer"
         }
       }
     },
@@ -2115,6 +2605,10 @@ func init() {
       "properties": {
         "realized": {
           "$ref": "#/definitions/ServiceSpec"
+        },
+        "statistics": {
+          "description": "Traffic statistics of the frontend",
+          "$ref": "#/definitions/ServiceStatistics"
         }
       }
     },
@@ -2150,6 +2644,10 @@ func init() {
           "description": "Status of cluster",
           "$ref": "#/definitions/ClusterStatus"
         },
+        "cluster-mesh": {
+          "description": "Status of ClusterMesh",
+          "$ref": "#/definitions/ClusterMeshStatus"
+        },
         "container-runtime": {
           "description": "Status of local container runtime",
           "$ref": "#/definitions/Status"
@@ -2170,6 +2668,14 @@ func init() {
           "description": "Status of key/value datastore",
           "$ref": "#/definitions/Status"
         },
+        "kvstore-operations": {
+          "description": "Statistics of the operations performed on the key/value datastore",
+          "$ref": "#/definitions/KvstoreOperationStatuses"
+        },
+        "leader-election": {
+          "description": "Status of the leader election among all agents",
+          "$ref": "#/definitions/LeaderElectionStatus"
+        },
         "nodeMonitor": {
           "description": "Status of the node monitor",
           "$ref": "#/definitions/MonitorStatus"
@@ -2259,6 +2765,14 @@ func init() {
       "in": "path",
       "required": true
     },
+    "ipam-owner": {
+      "description": "Owner of the addresses, used to select the IPAM pool",
+      "name": "owner",
+      "in": "body",
+      "schema": {
+        "$ref": "#/definitions/IPAMOwner"
+      }
+    },
     "labels": {
       "description": "List of labels\n",
       "name": "labels",
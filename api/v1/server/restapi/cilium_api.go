@@ -87,6 +87,9 @@ func NewCiliumAPI(spec *loads.Document) *CiliumAPI {
 		PolicyGetIdentityIDHandler: policy.GetIdentityIDHandlerFunc(func(params policy.GetIdentityIDParams) middleware.Responder {
 			return middleware.NotImplemented("operation PolicyGetIdentityID has not yet been implemented")
 		}),
+		DaemonGetKvstoreSnapshotHandler: daemon.GetKvstoreSnapshotHandlerFunc(func(params daemon.GetKvstoreSnapshotParams) middleware.Responder {
+			return middleware.NotImplemented("operation DaemonGetKvstoreSnapshot has not yet been implemented")
+		}),
 		PolicyGetPolicyHandler: policy.GetPolicyHandlerFunc(func(params policy.GetPolicyParams) middleware.Responder {
 			return middleware.NotImplemented("operation PolicyGetPolicy has not yet been implemented")
 		}),
@@ -126,6 +129,9 @@ func NewCiliumAPI(spec *loads.Document) *CiliumAPI {
 		EndpointPutEndpointIDHandler: endpoint.PutEndpointIDHandlerFunc(func(params endpoint.PutEndpointIDParams) middleware.Responder {
 			return middleware.NotImplemented("operation EndpointPutEndpointID has not yet been implemented")
 		}),
+		DaemonPutKvstoreSnapshotHandler: daemon.PutKvstoreSnapshotHandlerFunc(func(params daemon.PutKvstoreSnapshotParams) middleware.Responder {
+			return middleware.NotImplemented("operation DaemonPutKvstoreSnapshot has not yet been implemented")
+		}),
 		PolicyPutPolicyHandler: policy.PutPolicyHandlerFunc(func(params policy.PutPolicyParams) middleware.Responder {
 			return middleware.NotImplemented("operation PolicyPutPolicy has not yet been implemented")
 		}),
@@ -191,6 +197,8 @@ type CiliumAPI struct {
 	PolicyGetIdentityHandler policy.GetIdentityHandler
 	// PolicyGetIdentityIDHandler sets the operation handler for the get identity ID operation
 	PolicyGetIdentityIDHandler policy.GetIdentityIDHandler
+	// DaemonGetKvstoreSnapshotHandler sets the operation handler for the get kvstore snapshot operation
+	DaemonGetKvstoreSnapshotHandler daemon.GetKvstoreSnapshotHandler
 	// PolicyGetPolicyHandler sets the operation handler for the get policy operation
 	PolicyGetPolicyHandler policy.GetPolicyHandler
 	// PolicyGetPolicyResolveHandler sets the operation handler for the get policy resolve operation
@@ -217,6 +225,8 @@ type CiliumAPI struct {
 	IPAMPostIPAMIPHandler ipam.PostIPAMIPHandler
 	// EndpointPutEndpointIDHandler sets the operation handler for the put endpoint ID operation
 	EndpointPutEndpointIDHandler endpoint.PutEndpointIDHandler
+	// DaemonPutKvstoreSnapshotHandler sets the operation handler for the put kvstore snapshot operation
+	DaemonPutKvstoreSnapshotHandler daemon.PutKvstoreSnapshotHandler
 	// PolicyPutPolicyHandler sets the operation handler for the put policy operation
 	PolicyPutPolicyHandler policy.PutPolicyHandler
 	// ServicePutServiceIDHandler sets the operation handler for the put service ID operation
@@ -344,6 +354,10 @@ func (o *CiliumAPI) Validate() error {
 		unregistered = append(unregistered, "policy.GetIdentityIDHandler")
 	}
 
+	if o.DaemonGetKvstoreSnapshotHandler == nil {
+		unregistered = append(unregistered, "daemon.GetKvstoreSnapshotHandler")
+	}
+
 	if o.PolicyGetPolicyHandler == nil {
 		unregistered = append(unregistered, "policy.GetPolicyHandler")
 	}
@@ -396,6 +410,10 @@ func (o *CiliumAPI) Validate() error {
 		unregistered = append(unregistered, "endpoint.PutEndpointIDHandler")
 	}
 
+	if o.DaemonPutKvstoreSnapshotHandler == nil {
+		unregistered = append(unregistered, "daemon.PutKvstoreSnapshotHandler")
+	}
+
 	if o.PolicyPutPolicyHandler == nil {
 		unregistered = append(unregistered, "policy.PutPolicyHandler")
 	}
@@ -569,6 +587,11 @@ func (o *CiliumAPI) initHandlerCache() {
 	}
 	o.handlers["GET"]["/identity/{id}"] = policy.NewGetIdentityID(o.context, o.PolicyGetIdentityIDHandler)
 
+	if o.handlers["GET"] == nil {
+		o.handlers["GET"] = make(map[string]http.Handler)
+	}
+	o.handlers["GET"]["/kvstore/snapshot"] = daemon.NewGetKvstoreSnapshot(o.context, o.DaemonGetKvstoreSnapshotHandler)
+
 	if o.handlers["GET"] == nil {
 		o.handlers["GET"] = make(map[string]http.Handler)
 	}
@@ -634,6 +657,11 @@ func (o *CiliumAPI) initHandlerCache() {
 	}
 	o.handlers["PUT"]["/endpoint/{id}"] = endpoint.NewPutEndpointID(o.context, o.EndpointPutEndpointIDHandler)
 
+	if o.handlers["PUT"] == nil {
+		o.handlers["PUT"] = make(map[string]http.Handler)
+	}
+	o.handlers["PUT"]["/kvstore/snapshot"] = daemon.NewPutKvstoreSnapshot(o.context, o.DaemonPutKvstoreSnapshotHandler)
+
 	if o.handlers["PUT"] == nil {
 		o.handlers["PUT"] = make(map[string]http.Handler)
 	}
@@ -3,6 +3,8 @@
 
 #define HAVE_SKB_CHANGE_TAIL
 
+#define HAVE_SKB_CHANGE_HEAD
+
 #define HAVE_MAP_VAL_ADJ
 
 #define HAVE_MARK_MAP_VALS
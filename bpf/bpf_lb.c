@@ -31,6 +31,8 @@
  *  - LB_DISABLE_IPV6 - Ignore IPv6 packets
  *  - LB_REDIRECT     - Redirect to an ifindex
  *  - LB_L4           - Enable L4 matching and mapping
+ *  - ENABLE_DSR      - Carry the frontend of services in direct server
+ *                      return mode to the backend node
  */
 
 #define DISABLE_LOOPBACK_LB
@@ -64,6 +66,7 @@ static inline int handle_ipv6(struct __sk_buff *skb)
 	struct csum_offset csum_off = {};
 	int l3_off, l4_off, ret;
 	union v6addr new_dst;
+	bool dsr = false;
 	__u8 nexthdr;
 	__u16 slave;
 
@@ -95,18 +98,32 @@ static inline int handle_ipv6(struct __sk_buff *skb)
 		return TC_ACT_OK;
 	}
 
+#ifdef ENABLE_DSR
+	dsr = lb6_dsr_lookup(&key);
+#endif
+
 	slave = lb6_select_slave(skb, &key, svc->count, svc->weight);
 	if (!(svc = lb6_lookup_slave(skb, &key, slave)))
 		return DROP_NO_SERVICE;
 
+	/* The backend node of a DSR service learns the frontend from the
+	 * packet instead of the reverse NAT index */
 	ipv6_addr_copy(&new_dst, &svc->target);
-	if (svc->rev_nat_index)
+	if (svc->rev_nat_index && !dsr)
 		new_dst.p4 |= svc->rev_nat_index;
 
 	ret = lb6_xlate(skb, &new_dst, nexthdr, l3_off, l4_off, &csum_off, &key, svc);
 	if (IS_ERR(ret))
 		return ret;
 
+#ifdef ENABLE_DSR
+	if (dsr) {
+		ret = lb6_dsr_encap(skb, &key);
+		if (IS_ERR(ret))
+			return ret;
+	}
+#endif
+
 	return TC_ACT_REDIRECT;
 }
 #endif
@@ -122,6 +139,7 @@ static inline int handle_ipv4(struct __sk_buff *skb)
 	struct csum_offset csum_off = {};
 	int l3_off, l4_off, ret;
 	__be32 new_dst;
+	bool dsr __maybe_unused = false;
 	__u8 nexthdr;
 	__u16 slave;
 
@@ -153,6 +171,10 @@ static inline int handle_ipv4(struct __sk_buff *skb)
 		return TC_ACT_OK;
 	}
 
+#ifdef ENABLE_DSR
+	dsr = lb4_dsr_lookup(&key);
+#endif
+
 	slave = lb4_select_slave(skb, &key, svc->count, svc->weight);
 	if (!(svc = lb4_lookup_slave(skb, &key, slave)))
 		return DROP_NO_SERVICE;
@@ -162,6 +184,14 @@ static inline int handle_ipv4(struct __sk_buff *skb)
 	if (IS_ERR(ret))
 		return ret;
 
+#ifdef ENABLE_DSR
+	if (dsr) {
+		ret = lb4_dsr_encap(skb, &key);
+		if (IS_ERR(ret))
+			return ret;
+	}
+#endif
+
 	return TC_ACT_REDIRECT;
 }
 #endif
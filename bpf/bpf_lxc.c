@@ -48,8 +48,6 @@
 #include "lib/conntrack.h"
 #include "lib/encap.h"
 
-#define POLICY_ID ((LXC_ID << 16) | SECLABEL)
-
 struct bpf_elf_map __section_maps CT_MAP6 = {
 #ifdef HAVE_LRU_MAP_TYPE
 	.type		= BPF_MAP_TYPE_LRU_HASH,
@@ -190,6 +188,10 @@ skip_service_lookup:
 		ret = ct_create6(&CT_MAP6, tuple, skb, CT_EGRESS, &ct_state_new);
 		if (IS_ERR(ret))
 			return ret;
+#ifdef ENABLE_LB_STATS
+		if (ct_state_new.rev_nat_index)
+			lb6_stats_conn(skb, l4_off, &key, &tuple->daddr);
+#endif
 		break;
 
 	case CT_ESTABLISHED:
@@ -209,6 +211,14 @@ skip_service_lookup:
 			 * on the local node in which case this marking is cleared again. */
 			policy_mark_skip(skb);
 		}
+#ifdef ENABLE_DSR
+		else {
+			/* Replies of DSR services are sent directly to the client */
+			ret = lb6_dsr_rev_nat(skb, l4_off, &csum_off, tuple);
+			if (IS_ERR(ret))
+				return ret;
+		}
+#endif
 		break;
 
 	default:
@@ -490,6 +500,11 @@ skip_service_lookup:
 		ret = ct_create4(&CT_MAP4, &tuple, skb, CT_EGRESS, &ct_state_new);
 		if (IS_ERR(ret))
 			return ret;
+#ifdef ENABLE_LB_STATS
+		if (ct_state_new.rev_nat_index)
+			lb4_stats_conn(skb, l4_off, &key, ct_state_new.loopback ?
+				       ct_state_new.svc_addr : ct_state_new.addr);
+#endif
 		break;
 
 	case CT_ESTABLISHED:
@@ -505,6 +520,15 @@ skip_service_lookup:
 			if (IS_ERR(ret))
 				return ret;
 		}
+#ifdef ENABLE_DSR
+		else {
+			/* Replies of DSR services are sent directly to the client */
+			ret = lb4_dsr_rev_nat(skb, l3_off, l4_off, &csum_off,
+					      &ct_state, &tuple);
+			if (IS_ERR(ret))
+				return ret;
+		}
+#endif
 		break;
 
 	default:
@@ -746,6 +770,9 @@ static inline int __inline__ ipv6_policy(struct __sk_buff *skb, int ifindex, __u
 			if (csum_l4_replace(skb, l4_off, &csum_off, 0, sum, BPF_F_PSEUDO_HDR) < 0)
 				return DROP_CSUM_L4;
 		}
+	} else {
+		/* Translated by the NodePort load balancer of the node */
+		ct_state_new.rev_nat_index = skb->cb[CB_CT_STATE];
 	}
 
 	ret = ct_lookup6(&CT_MAP6, &tuple, skb, l4_off, SECLABEL, CT_INGRESS,
@@ -755,7 +782,7 @@ static inline int __inline__ ipv6_policy(struct __sk_buff *skb, int ifindex, __u
 
 	*forwarding_reason = ret;
 
-	if (unlikely(ct_state.rev_nat_index)) {
+	if (unlikely(ret == CT_REPLY && ct_state.rev_nat_index)) {
 		int ret2;
 
 		ret2 = lb6_rev_nat(skb, l4_off, &csum_off,
@@ -783,6 +810,11 @@ static inline int __inline__ ipv6_policy(struct __sk_buff *skb, int ifindex, __u
 		verdict = 0;
 
 	if (ret == CT_NEW) {
+#ifdef ENABLE_DSR
+		ret = lb6_dsr_learn(skb, l4_off, tuple.nexthdr);
+		if (IS_ERR(ret))
+			return ret;
+#endif
 		ct_state_new.orig_dport = tuple.dport;
 		ct_state_new.src_sec_id = src_label;
 		ret = ct_create6(&CT_MAP6, &tuple, skb, CT_INGRESS, &ct_state_new);
@@ -893,6 +925,13 @@ static inline int __inline__ ipv4_policy(struct __sk_buff *skb, int ifindex, __u
 		verdict = 0;
 
 	if (ret == CT_NEW) {
+#ifdef ENABLE_DSR
+		ret = lb4_dsr_learn(skb, l4_off, tuple.nexthdr);
+		if (IS_ERR(ret))
+			return ret;
+#endif
+		/* Translated by the NodePort load balancer of the node */
+		ct_state_new.rev_nat_index = skb->cb[CB_CT_STATE];
 		ct_state_new.orig_dport = tuple.dport;
 		ct_state_new.src_sec_id = src_label;
 		ret = ct_create4(&CT_MAP4, &tuple, skb, CT_INGRESS, &ct_state_new);
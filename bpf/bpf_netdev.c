@@ -27,6 +27,11 @@
 /* Include policy_can_access_ingress() */
 #define REQUIRES_CAN_ACCESS
 
+#if defined ENABLE_NODEPORT && !defined FROM_HOST
+/* Services are looked up by frontend address and port */
+#define LB_L4
+#endif
+
 #include <bpf/api.h>
 
 #include <stdint.h>
@@ -46,6 +51,9 @@
 #include "lib/policy.h"
 #include "lib/drop.h"
 #include "lib/encap.h"
+#if defined ENABLE_NODEPORT && !defined FROM_HOST
+#include "lib/lb.h"
+#endif
 
 static inline __u32 derive_sec_ctx(struct __sk_buff *skb, const union v6addr *node_ip,
 				   struct ipv6hdr *ip6)
@@ -169,6 +177,76 @@ static inline int rewrite_dmac_to_host(struct __sk_buff *skb)
 }
 #endif
 
+#if defined ENABLE_NODEPORT && !defined FROM_HOST
+/** Translate the frontends of services for traffic entering the node
+ * @arg skb	packet
+ * @arg l4_off	offset to L4
+ * @arg nexthdr	L4 protocol
+ *
+ * The NodePort, external IP and LoadBalancer frontends of services are
+ * translated to a backend. Local backends reverse translate the replies
+ * with the reverse NAT index passed in cb[CB_CT_STATE]. The frontend is
+ * carried to remote backends of services in direct server return mode,
+ * packets to remote backends of other services are passed to the stack
+ * untranslated as their replies could not be reverse translated.
+ */
+static inline int __inline__ nodeport_lb6(struct __sk_buff *skb, int l4_off, __u8 nexthdr)
+{
+	struct csum_offset csum_off = {};
+	struct lb6_key key = {};
+	struct lb6_service *svc;
+	struct endpoint_info *ep;
+	union v6addr new_dst;
+	bool local, dsr = false;
+	__u16 slave;
+	int ret;
+
+	if (ipv6_load_daddr(skb, ETH_HLEN, &key.address) < 0)
+		return DROP_INVALID;
+
+	csum_l4_offset_and_flags(nexthdr, &csum_off);
+
+	ret = extract_l4_port(skb, nexthdr, l4_off, &key.dport);
+	if (IS_ERR(ret)) {
+		/* Pass unknown L4 to stack */
+		if (ret == DROP_UNKNOWN_L4)
+			return 0;
+		return ret;
+	}
+
+	svc = lb6_lookup_service(skb, &key);
+	if (svc == NULL)
+		return 0;
+
+#if defined ENABLE_DSR && defined HAVE_SKB_CHANGE_HEAD
+	dsr = lb6_dsr_lookup(&key);
+#endif
+
+	slave = lb6_select_slave(skb, &key, svc->count, svc->weight);
+	if (!(svc = lb6_lookup_slave(skb, &key, slave)))
+		return DROP_NO_SERVICE;
+
+	ipv6_addr_copy(&new_dst, &svc->target);
+	ep = __lookup_ip6_endpoint(&new_dst);
+	local = ep && !(ep->flags & ENDPOINT_F_HOST);
+	if (local)
+		skb->cb[CB_CT_STATE] = svc->rev_nat_index;
+	else if (!dsr)
+		return 0;
+
+	ret = lb6_xlate(skb, &new_dst, nexthdr, ETH_HLEN, l4_off, &csum_off, &key, svc);
+	if (IS_ERR(ret))
+		return ret;
+
+#ifdef ENABLE_DSR
+	if (!local)
+		return lb6_dsr_encap(skb, &key);
+#endif
+
+	return 0;
+}
+#endif
+
 static inline int handle_ipv6(struct __sk_buff *skb, __u32 src_identity)
 {
 	union v6addr node_ip = { };
@@ -219,6 +297,23 @@ static inline int handle_ipv6(struct __sk_buff *skb, __u32 src_identity)
 		return DROP_INVALID;
 #endif
 
+#if defined ENABLE_NODEPORT && !defined FROM_HOST
+	if (1) {
+		int ret;
+
+		ret = nodeport_lb6(skb, l4_off, nexthdr);
+		if (IS_ERR(ret))
+			return ret;
+
+		/* The frontend carried to remote backends adds an extension header */
+		if (!revalidate_data(skb, &data, &data_end, &ip6))
+			return DROP_INVALID;
+
+		nexthdr = ip6->nexthdr;
+		l4_off = l3_off + ipv6_hdrlen(skb, l3_off, &nexthdr);
+	}
+#endif
+
 	/* Lookup IPv4 address in list of local endpoints */
 	if ((ep = lookup_ip6_endpoint(ip6)) != NULL) {
 		/* Let through packets to the node-ip so they are
@@ -332,6 +427,76 @@ reverse_proxy(struct __sk_buff *skb, int l4_off, struct iphdr *ip4, __u8 nh)
 }
 #endif
 
+#if defined ENABLE_NODEPORT && !defined FROM_HOST
+/** Translate the frontends of services for traffic entering the node
+ * @arg skb	packet
+ * @arg l4_off	offset to L4
+ *
+ * See nodeport_lb6().
+ */
+static inline int __inline__ nodeport_lb4(struct __sk_buff *skb, int l4_off)
+{
+	struct csum_offset csum_off = {};
+	struct lb4_key key = {};
+	struct lb4_service *svc;
+	struct endpoint_info *ep;
+	void *data, *data_end;
+	struct iphdr *ip4;
+	bool local, dsr = false;
+	__be32 new_dst;
+	__u8 nexthdr;
+	__u16 slave;
+	int ret;
+
+	if (!revalidate_data(skb, &data, &data_end, &ip4))
+		return DROP_INVALID;
+
+	nexthdr = ip4->protocol;
+	key.address = ip4->daddr;
+	csum_l4_offset_and_flags(nexthdr, &csum_off);
+
+	ret = extract_l4_port(skb, nexthdr, l4_off, &key.dport);
+	if (IS_ERR(ret)) {
+		/* Pass unknown L4 to stack */
+		if (ret == DROP_UNKNOWN_L4)
+			return 0;
+		return ret;
+	}
+
+	svc = lb4_lookup_service(skb, &key);
+	if (svc == NULL)
+		return 0;
+
+#if defined ENABLE_DSR && defined HAVE_SKB_CHANGE_HEAD
+	dsr = lb4_dsr_lookup(&key);
+#endif
+
+	slave = lb4_select_slave(skb, &key, svc->count, svc->weight);
+	if (!(svc = lb4_lookup_slave(skb, &key, slave)))
+		return DROP_NO_SERVICE;
+
+	new_dst = svc->target;
+	ep = __lookup_ip4_endpoint(new_dst);
+	local = ep && !(ep->flags & ENDPOINT_F_HOST);
+	if (local)
+		skb->cb[CB_CT_STATE] = svc->rev_nat_index;
+	else if (!dsr)
+		return 0;
+
+	ret = lb4_xlate(skb, &new_dst, NULL, NULL, nexthdr, ETH_HLEN, l4_off,
+			&csum_off, &key, svc);
+	if (IS_ERR(ret))
+		return ret;
+
+#ifdef ENABLE_DSR
+	if (!local)
+		return lb4_dsr_encap(skb, &key);
+#endif
+
+	return 0;
+}
+#endif
+
 static inline int handle_ipv4(struct __sk_buff *skb, __u32 src_identity)
 {
 	struct ipv4_ct_tuple tuple = {};
@@ -370,6 +535,22 @@ static inline int handle_ipv4(struct __sk_buff *skb, __u32 src_identity)
 		return DROP_INVALID;
 #endif
 
+#if defined ENABLE_NODEPORT && !defined FROM_HOST
+	if (1) {
+		int ret;
+
+		ret = nodeport_lb4(skb, l4_off);
+		if (IS_ERR(ret))
+			return ret;
+
+		/* The frontend carried to remote backends grows the IP header */
+		if (!revalidate_data(skb, &data, &data_end, &ip4))
+			return DROP_INVALID;
+
+		l4_off = ETH_HLEN + ipv4_hdrlen(ip4);
+	}
+#endif
+
 	/* Lookup IPv4 address in list of local endpoints and host IPs */
 	if ((ep = lookup_ip4_endpoint(ip4)) != NULL) {
 		/* Let through packets to the node-ip so they are
@@ -23,6 +23,9 @@ MODE=$5
 NATIVE_DEV=$6
 XDP_DEV=$7
 XDP_MODE=$8
+# Comma separated list of the devices with node addresses, only set if
+# NodePort frontends are enabled
+NODE_PORT_DEVS=$9
 
 ID_HOST=1
 ID_WORLD=2
@@ -142,13 +145,13 @@ function setup_proxy_rules()
 	if [ -n "$(ip -4 rule list)" ]; then
 		ip -4 route flush table $PROXY_RT_TABLE
 		# Any packet from a proxy uses a separate routing table
-		ip -4 rule add fwmark 0xFEA/0xFFF pref 10 lookup $PROXY_RT_TABLE
+		ip -4 rule add fwmark 0xA00/0xF00 pref 10 lookup $PROXY_RT_TABLE
 	fi
 
 	if [ -n "$(ip -6 rule list)" ]; then
 		ip -6 route flush table $PROXY_RT_TABLE
 		# Any packet from a proxy uses a separate routing table
-		ip -6 rule add fwmark 0xFEA/0xFFF pref 10 lookup $PROXY_RT_TABLE
+		ip -6 rule add fwmark 0xA00/0xF00 pref 10 lookup $PROXY_RT_TABLE
 	fi
 
 	if [ -n "$IP4_HOST" ]; then
@@ -365,6 +368,35 @@ else
 	fi
 fi
 
+# The frontends of services are translated for traffic entering the node on
+# all devices with node addresses. The native device already runs bpf_netdev.o
+# in direct mode, which translates them as well, or bpf_lb.o in lb mode.
+FILE=$RUNDIR/nodeport.state
+NODE_PORT_DEVS=${NODE_PORT_DEVS//,/ }
+if [ -f $FILE ]; then
+	for NP_DEV in $(cat $FILE); do
+		if [[ " $NODE_PORT_DEVS " != *" $NP_DEV "* && "$NP_DEV" != "$NATIVE_DEV" ]]; then
+			echo "Removed BPF program from device $NP_DEV"
+			tc qdisc del dev $NP_DEV clsact 2> /dev/null || true
+		fi
+	done
+	rm $FILE
+fi
+for NP_DEV in $NODE_PORT_DEVS; do
+	if [ "$NP_DEV" = "$NATIVE_DEV" ]; then
+		continue
+	fi
+
+	echo 1 > /proc/sys/net/ipv6/conf/all/forwarding
+
+	CALLS_MAP=cilium_calls_netdev_${ID_WORLD}
+	POLICY_MAP="cilium_policy_reserved_${ID_WORLD}"
+	OPTS="-DSECLABEL=${ID_WORLD} -DPOLICY_MAP=${POLICY_MAP}"
+	bpf_load $NP_DEV "$OPTS" "ingress" bpf_netdev.c bpf_netdev.o from-netdev $CALLS_MAP
+
+	echo "$NP_DEV" >> $FILE
+done
+
 # bpf_host.o requires to see an updated node_config.h which includes ENCAP_IFINDEX
 CALLS_MAP="cilium_calls_netdev_ns_${ID_HOST}"
 POLICY_MAP="cilium_policy_reserved_${ID_HOST}"
@@ -160,17 +160,18 @@ struct endpoint_key {
 /* Value of endpoint map */
 struct endpoint_info {
 	__u32		ifindex;
-	__u16		sec_label;
+	__u16		unused; /* used to be a 16 bit sec_label */
 	__u16           lxc_id;
 	__u32		flags;
 	mac_t		mac;
 	mac_t		node_mac;
-	__u32		pad[4];
+	__u32		sec_label;
+	__u32		pad[3];
 };
 
 struct remote_endpoint_info {
-	__u16		sec_label;
-	__u16		pad[3];
+	__u32		sec_label;
+	__u32		pad;
 };
 
 struct policy_key {
@@ -275,17 +276,19 @@ enum {
 
 /* Magic skb->mark markers which identify packets originating from the host
  *
- * The upper 16 bits contain the magic marker values which indicate whether
- * the packet is coming from an ingress or egress proxy, or a local process.
+ * Bits 8-11 contain the magic marker values which indicate whether the packet
+ * is coming from an ingress or egress proxy, or a local process.
  *
- * The lower 16 bits may contain the security identity of the original source
- * endpoint.
+ * The upper 16 bits plus the lower 8 bits (mask 0xFFFF00FF) may contain the
+ * security identity of the original source endpoint:
+ *  - upper 16 bits: bits 0-15 of the identity
+ *  - lower 8 bits: bits 16-23 of the identity (cluster ID)
  */
-#define MARK_MAGIC_HOST_MASK		0xFFF
-#define MARK_MAGIC_PROXY_INGRESS	0xFEA
-#define MARK_MAGIC_PROXY_EGRESS		0xFEB
-#define MARK_MAGIC_HOST			0xFEC
-#define MARK_IDENTITY_MASK		(0xFFFF << 16)
+#define MARK_MAGIC_HOST_MASK		0x0F00
+#define MARK_MAGIC_PROXY_INGRESS	0x0A00
+#define MARK_MAGIC_PROXY_EGRESS		0x0B00
+#define MARK_MAGIC_HOST			0x0C00
+#define MARK_IDENTITY_MASK		((0xFFFF << 16) | 0xFF)
 
 #define SOURCE_INGRESS_PROXY 1
 #define SOURCE_EGRESS_PROXY 2
@@ -295,7 +298,7 @@ enum {
  */
 static inline int __inline__ get_identity_via_proxy(struct __sk_buff *skb)
 {
-	return skb->mark >> 16;
+	return ((skb->mark & 0xFF) << 16) | skb->mark >> 16;
 }
 
 /*
@@ -312,7 +315,7 @@ enum {
 	CB_IFINDEX,
 	CB_POLICY,
 	CB_NAT46_STATE,
-	CB_CT_STATE,
+	CB_CT_STATE,	/* Reverse NAT index of connections entering the node */
 };
 
 /* State values for NAT46 */
@@ -435,6 +438,128 @@ struct lb_sequence {
 	__u16 idx[LB_RR_MAX_SEQ];
 };
 
+// LB_MAGLEV_LUT_SIZE generated by daemon in node_config.h
+struct lb_maglev {
+	__u16 slave[LB_MAGLEV_LUT_SIZE];	/* Backend iterator per hash, 0 if unused */
+};
+
+struct lb_affinity_match {
+	__u32 timeout;		/* Session affinity timeout in seconds */
+};
+
+struct lb6_affinity_key {
+	struct lb6_key svc;	/* Master key of the service */
+	union v6addr client;
+} __attribute__((packed));
+
+struct lb6_affinity_val {
+	union v6addr target;
+	__be16 port;
+	__u16 slave;
+	__u32 last_used;	/* Seconds since boot */
+} __attribute__((packed));
+
+struct lb4_affinity_key {
+	struct lb4_key svc;	/* Master key of the service */
+	__be32 client;
+} __attribute__((packed));
+
+struct lb4_affinity_val {
+	__be32 target;
+	__be16 port;
+	__u16 slave;
+	__u32 last_used;	/* Seconds since boot */
+} __attribute__((packed));
+
+/* Connections to services are tracked to keep serving established
+ * connections from their backend while it is draining. The value is a
+ * struct lb6_affinity_val or struct lb4_affinity_val.
+ */
+struct lb6_conn_key {
+	struct lb6_key svc;	/* Master key of the service */
+	union v6addr client;
+	__be16 sport;
+	__u8 nexthdr;
+	__u8 pad;
+} __attribute__((packed));
+
+struct lb4_conn_key {
+	struct lb4_key svc;	/* Master key of the service */
+	__be32 client;
+	__be16 sport;
+	__u8 nexthdr;
+	__u8 pad;
+} __attribute__((packed));
+
+/* Services in direct server return mode carry their frontend to the backend
+ * node which reverse translates the replies itself, so the replies are sent
+ * directly to the client instead of passing the balancing node again.
+ */
+struct lb_dsr_match {
+	__u32 flags;		/* Reserved, must be 0 */
+};
+
+/* Frontend of a DSR service carried in an IPv4 option */
+struct lb4_dsr_opt {
+	__u8 type;
+	__u8 len;
+	__be16 port;
+	__be32 address;
+} __attribute__((packed));
+
+/* Frontend of a DSR service carried in an IPv6 destination options header */
+struct lb6_dsr_opt {
+	__u8 nexthdr;
+	__u8 hdrlen;		/* In 8 octet units, not including the first 8 */
+	__u8 type;
+	__u8 len;
+	union v6addr address;
+	__be16 port;
+	__u8 pad[2];		/* PadN option */
+} __attribute__((packed));
+
+/* Connection to a local backend of a DSR service, the value is a
+ * struct lb6_reverse_nat or struct lb4_reverse_nat of the frontend.
+ */
+struct lb6_dsr_key {
+	union v6addr client;
+	union v6addr backend;
+	__be16 client_port;
+	__be16 backend_port;
+	__u8 nexthdr;
+	__u8 pad[3];
+} __attribute__((packed));
+
+struct lb4_dsr_key {
+	__be32 client;
+	__be32 backend;
+	__be16 client_port;
+	__be16 backend_port;
+	__u8 nexthdr;
+	__u8 pad[3];
+} __attribute__((packed));
+
+/* The backend is zero for the totals of the frontend */
+struct lb6_stats_key {
+	union v6addr address;
+	union v6addr backend;
+	__be16 dport;
+	__be16 backend_port;
+} __attribute__((packed));
+
+struct lb4_stats_key {
+	__be32 address;
+	__be32 backend;
+	__be16 dport;
+	__be16 backend_port;
+} __attribute__((packed));
+
+struct lb_stats {
+	__u64 packets;
+	__u64 bytes;
+	__u64 conns;
+};
+
 struct ct_state {
 	__u16 rev_nat_index;
 	__u16 loopback:1,
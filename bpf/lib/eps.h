@@ -24,27 +24,39 @@
 #include "maps.h"
 
 static __always_inline struct endpoint_info *
-lookup_ip6_endpoint(struct ipv6hdr *ip6)
+__lookup_ip6_endpoint(union v6addr *ip6)
 {
 	struct endpoint_key key = {};
 
-	key.ip6 = *((union v6addr *) &ip6->daddr);
+	key.ip6 = *ip6;
 	key.family = ENDPOINT_KEY_IPV6;
 
 	return map_lookup_elem(&cilium_lxc, &key);
 }
 
 static __always_inline struct endpoint_info *
-lookup_ip4_endpoint(struct iphdr *ip4)
+lookup_ip6_endpoint(struct ipv6hdr *ip6)
+{
+	return __lookup_ip6_endpoint((union v6addr *) &ip6->daddr);
+}
+
+static __always_inline struct endpoint_info *
+__lookup_ip4_endpoint(__u32 ip)
 {
 	struct endpoint_key key = {};
 
-	key.ip4 = ip4->daddr;
+	key.ip4 = ip;
 	key.family = ENDPOINT_KEY_IPV4;
 
 	return map_lookup_elem(&cilium_lxc, &key);
 }
 
+static __always_inline struct endpoint_info *
+lookup_ip4_endpoint(struct iphdr *ip4)
+{
+	return __lookup_ip4_endpoint(ip4->daddr);
+}
+
 #if defined POLICY_EGRESS && defined LXC_ID
 /* IPCACHE_STATIC_PREFIX gets sizeof non-IP, non-prefix part of ipcache_key */
 #define IPCACHE_STATIC_PREFIX							\
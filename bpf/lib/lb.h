@@ -34,6 +34,25 @@
 
 #define CILIUM_LB_MAP_MAX_FE		256
 
+/* The client to backend mappings of session affinity are only expired by
+ * LRU maps.
+ */
+#if defined ENABLE_SESSION_AFFINITY && !defined HAVE_LRU_MAP_TYPE
+#undef ENABLE_SESSION_AFFINITY
+#endif
+
+/* Connections to services are only expired by LRU maps. */
+#if defined ENABLE_LB_DRAINING && !defined HAVE_LRU_MAP_TYPE
+#undef ENABLE_LB_DRAINING
+#endif
+
+/* The frontends learned for connections to DSR services are only expired
+ * by LRU maps.
+ */
+#if defined ENABLE_DSR && !defined HAVE_LRU_MAP_TYPE
+#undef ENABLE_DSR
+#endif
+
 struct bpf_elf_map __section_maps cilium_lb6_reverse_nat = {
 	.type		= BPF_MAP_TYPE_HASH,
 	.size_key	= sizeof(__u16),
@@ -81,6 +100,201 @@ struct bpf_elf_map __section_maps cilium_lb4_rr_seq = {
 	.pinning        = PIN_GLOBAL_NS,
 	.max_elem       = CILIUM_LB_MAP_MAX_FE,
 };
+
+#ifdef LB_SELECTION_MAGLEV
+struct bpf_elf_map __section_maps cilium_lb6_maglev = {
+	.type		= BPF_MAP_TYPE_HASH,
+	.size_key	= sizeof(struct lb6_key),
+	.size_value	= sizeof(struct lb_maglev),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_MAGLEV_MAP_MAX_ENTRIES,
+	.flags		= BPF_F_NO_PREALLOC,
+};
+
+struct bpf_elf_map __section_maps cilium_lb4_maglev = {
+	.type		= BPF_MAP_TYPE_HASH,
+	.size_key	= sizeof(struct lb4_key),
+	.size_value	= sizeof(struct lb_maglev),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_MAGLEV_MAP_MAX_ENTRIES,
+	.flags		= BPF_F_NO_PREALLOC,
+};
+#endif /* LB_SELECTION_MAGLEV */
+
+#ifdef ENABLE_SESSION_AFFINITY
+struct bpf_elf_map __section_maps cilium_lb6_affinity_match = {
+	.type		= BPF_MAP_TYPE_HASH,
+	.size_key	= sizeof(struct lb6_key),
+	.size_value	= sizeof(struct lb_affinity_match),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_MAP_MAX_ENTRIES,
+	.flags		= BPF_F_NO_PREALLOC,
+};
+
+struct bpf_elf_map __section_maps cilium_lb6_affinity = {
+	.type		= BPF_MAP_TYPE_LRU_HASH,
+	.size_key	= sizeof(struct lb6_affinity_key),
+	.size_value	= sizeof(struct lb6_affinity_val),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_AFFINITY_MAP_MAX_ENTRIES,
+};
+
+struct bpf_elf_map __section_maps cilium_lb4_affinity_match = {
+	.type		= BPF_MAP_TYPE_HASH,
+	.size_key	= sizeof(struct lb4_key),
+	.size_value	= sizeof(struct lb_affinity_match),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_MAP_MAX_ENTRIES,
+	.flags		= BPF_F_NO_PREALLOC,
+};
+
+struct bpf_elf_map __section_maps cilium_lb4_affinity = {
+	.type		= BPF_MAP_TYPE_LRU_HASH,
+	.size_key	= sizeof(struct lb4_affinity_key),
+	.size_value	= sizeof(struct lb4_affinity_val),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_AFFINITY_MAP_MAX_ENTRIES,
+};
+#endif /* ENABLE_SESSION_AFFINITY */
+
+#ifdef ENABLE_LB_DRAINING
+struct bpf_elf_map __section_maps cilium_lb6_conn = {
+	.type		= BPF_MAP_TYPE_LRU_HASH,
+	.size_key	= sizeof(struct lb6_conn_key),
+	.size_value	= sizeof(struct lb6_affinity_val),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_CONN_MAP_MAX_ENTRIES,
+};
+
+struct bpf_elf_map __section_maps cilium_lb4_conn = {
+	.type		= BPF_MAP_TYPE_LRU_HASH,
+	.size_key	= sizeof(struct lb4_conn_key),
+	.size_value	= sizeof(struct lb4_affinity_val),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_CONN_MAP_MAX_ENTRIES,
+};
+
+#define LB_TCP_FLAG_FIN	0x01
+#define LB_TCP_FLAG_RST	0x04
+
+/* Interval in seconds in which the last use of an established connection is
+ * recorded. Must be lower than ConnIdleTimeout in pkg/maps/lbmap/drain.go.
+ */
+#define LB_CONN_REFRESH_INTERVAL	30
+
+/* Loads the source port of the connection into sport and returns true if
+ * the packet closes the connection.
+ */
+static inline int __inline__ lb_conn_load(struct __sk_buff *skb, __u8 nexthdr,
+					  int l4_off, __be16 *sport)
+{
+	__u8 flags;
+
+	if (nexthdr != IPPROTO_TCP && nexthdr != IPPROTO_UDP)
+		return 0;
+
+	/* Port offsets for UDP and TCP are the same */
+	if (skb_load_bytes(skb, l4_off, sport, sizeof(*sport)) < 0)
+		return 0;
+
+	if (nexthdr != IPPROTO_TCP ||
+	    skb_load_bytes(skb, l4_off + 13, &flags, sizeof(flags)) < 0)
+		return 0;
+
+	return flags & (LB_TCP_FLAG_FIN | LB_TCP_FLAG_RST);
+}
+#endif /* ENABLE_LB_DRAINING */
+
+#ifdef ENABLE_DSR
+struct bpf_elf_map __section_maps cilium_lb6_dsr_match = {
+	.type		= BPF_MAP_TYPE_HASH,
+	.size_key	= sizeof(struct lb6_key),
+	.size_value	= sizeof(struct lb_dsr_match),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_MAP_MAX_ENTRIES,
+	.flags		= BPF_F_NO_PREALLOC,
+};
+
+struct bpf_elf_map __section_maps cilium_lb6_dsr = {
+	.type		= BPF_MAP_TYPE_LRU_HASH,
+	.size_key	= sizeof(struct lb6_dsr_key),
+	.size_value	= sizeof(struct lb6_reverse_nat),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_DSR_MAP_MAX_ENTRIES,
+};
+
+struct bpf_elf_map __section_maps cilium_lb4_dsr_match = {
+	.type		= BPF_MAP_TYPE_HASH,
+	.size_key	= sizeof(struct lb4_key),
+	.size_value	= sizeof(struct lb_dsr_match),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_MAP_MAX_ENTRIES,
+	.flags		= BPF_F_NO_PREALLOC,
+};
+
+struct bpf_elf_map __section_maps cilium_lb4_dsr = {
+	.type		= BPF_MAP_TYPE_LRU_HASH,
+	.size_key	= sizeof(struct lb4_dsr_key),
+	.size_value	= sizeof(struct lb4_reverse_nat),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_DSR_MAP_MAX_ENTRIES,
+};
+
+/* RFC 4727 experimental option types. The IPv4 option is copied into all
+ * fragments, the IPv6 option is skipped by nodes which do not know it.
+ */
+#define DSR_IPV4_OPT_TYPE	0x9e
+#define DSR_IPV6_OPT_TYPE	0x1e
+#define DSR_IPV6_OPT_PADN	1
+#endif /* ENABLE_DSR */
+
+#ifdef ENABLE_LB_STATS
+struct bpf_elf_map __section_maps cilium_lb6_stats = {
+	.type		= BPF_MAP_TYPE_HASH,
+	.size_key	= sizeof(struct lb6_stats_key),
+	.size_value	= sizeof(struct lb_stats),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_STATS_MAP_MAX_ENTRIES,
+	.flags		= BPF_F_NO_PREALLOC,
+};
+
+struct bpf_elf_map __section_maps cilium_lb4_stats = {
+	.type		= BPF_MAP_TYPE_HASH,
+	.size_key	= sizeof(struct lb4_stats_key),
+	.size_value	= sizeof(struct lb_stats),
+	.pinning	= PIN_GLOBAL_NS,
+	.max_elem	= CILIUM_LB_STATS_MAP_MAX_ENTRIES,
+	.flags		= BPF_F_NO_PREALLOC,
+};
+
+static inline void __inline__ lb_stats_add(void *map, void *key, __u64 packets,
+					   __u64 bytes, __u64 conns)
+{
+	struct lb_stats *stats, new_stats = {
+		.packets = packets,
+		.bytes = bytes,
+		.conns = conns,
+	};
+
+	if ((stats = map_lookup_elem(map, key))) {
+		__sync_fetch_and_add(&stats->packets, packets);
+		__sync_fetch_and_add(&stats->bytes, bytes);
+		__sync_fetch_and_add(&stats->conns, conns);
+	} else {
+		map_update_elem(map, key, &new_stats, 0);
+	}
+}
+
+/* Returns the port of the backend as translated by lb6_xlate and lb4_xlate */
+static inline __be16 __inline__ lb_backend_port(__be16 dport, __be16 port)
+{
+#ifdef LB_L4
+	if (port)
+		return port;
+#endif
+	return dport;
+}
+#endif /* ENABLE_LB_STATS */
 #define REV_NAT_F_TUPLE_SADDR 1
 #ifdef LB_DEBUG
 #define cilium_dbg_lb cilium_dbg
@@ -145,6 +359,24 @@ static inline int lb6_select_slave(struct __sk_buff *skb,
 	}
 #endif
 
+#if defined(LB_SELECTION_MAGLEV) && defined(HAVE_MAP_VAL_ADJ)
+	/* Maglev lookup table generated by the daemon. The table may still
+	 * refer to backends beyond count while the service is being updated,
+	 * fall back to the hash based selection in that case.
+	 */
+	if (slave == 0) {
+		struct lb_maglev *lut;
+		__u32 index = hash % LB_MAGLEV_LUT_SIZE;
+
+		lut = map_lookup_elem(&cilium_lb6_maglev, key);
+		if (lut && index < LB_MAGLEV_LUT_SIZE) {
+			slave = lut->slave[index];
+			if (slave > count)
+				slave = 0;
+		}
+	}
+#endif
+
 	if (slave == 0) {
 		/* Slave 0 is reserved for the master slot */
 		slave = (hash % count) + 1;
@@ -179,6 +411,24 @@ static inline int lb4_select_slave(struct __sk_buff *skb,
 	}
 #endif
 
+#if defined(LB_SELECTION_MAGLEV) && defined(HAVE_MAP_VAL_ADJ)
+	/* Maglev lookup table generated by the daemon. The table may still
+	 * refer to backends beyond count while the service is being updated,
+	 * fall back to the hash based selection in that case.
+	 */
+	if (slave == 0) {
+		struct lb_maglev *lut;
+		__u32 index = hash % LB_MAGLEV_LUT_SIZE;
+
+		lut = map_lookup_elem(&cilium_lb4_maglev, key);
+		if (lut && index < LB_MAGLEV_LUT_SIZE) {
+			slave = lut->slave[index];
+			if (slave > count)
+				slave = 0;
+		}
+	}
+#endif
+
 	if (slave == 0) {
 		/* Slave 0 is reserved for the master slot */
 		slave = (hash % count) + 1;
@@ -422,6 +672,142 @@ static inline int __inline__ lb6_xlate(struct __sk_buff *skb, union v6addr *new_
 	return TC_ACT_OK;
 }
 
+#ifdef ENABLE_SESSION_AFFINITY
+/* Returns the slave previously selected for the client of a service with
+ * session affinity or 0 if there is none or the affinity has expired. The
+ * slave is kept up to date by the daemon when the backends change.
+ */
+static inline __u16 __inline__ lb6_affinity_slave(struct lb6_affinity_key *affinity,
+						  __u16 count)
+{
+	struct lb_affinity_match *match;
+	struct lb6_affinity_val *val;
+
+	match = map_lookup_elem(&cilium_lb6_affinity_match, &affinity->svc);
+	if (!match)
+		return 0;
+
+	val = map_lookup_elem(&cilium_lb6_affinity, affinity);
+	if (!val || val->slave > count ||
+	    bpf_ktime_get_sec() - val->last_used > match->timeout)
+		return 0;
+
+	return val->slave;
+}
+
+static inline void __inline__ lb6_affinity_update(struct lb6_affinity_key *affinity,
+						  struct lb6_service *svc, __u16 slave)
+{
+	struct lb6_affinity_val val = {
+		.port = svc->port,
+		.slave = slave,
+	};
+
+	if (!map_lookup_elem(&cilium_lb6_affinity_match, &affinity->svc))
+		return;
+
+	ipv6_addr_copy(&val.target, &svc->target);
+	val.last_used = bpf_ktime_get_sec();
+	map_update_elem(&cilium_lb6_affinity, affinity, &val, 0);
+}
+#endif /* ENABLE_SESSION_AFFINITY */
+
+#ifdef ENABLE_LB_DRAINING
+/* Returns the slave which served the established connection conn or 0 for a
+ * new connection. The slave may be beyond the count of the service while its
+ * backend is draining. The slave is kept up to date by the daemon when the
+ * backends change, it is only used if it still holds the same backend. The
+ * last use of the connection is stored in last_used.
+ */
+static inline __u16 __inline__ lb6_conn_slave(struct __sk_buff *skb,
+					      struct lb6_conn_key *conn,
+					      struct lb6_key *key,
+					      __u32 *last_used)
+{
+	struct lb6_affinity_val *val;
+	struct lb6_service *backend;
+	__u16 slave;
+
+	val = map_lookup_elem(&cilium_lb6_conn, conn);
+	if (!val || val->slave == 0)
+		return 0;
+
+	slave = val->slave;
+	*last_used = val->last_used;
+	backend = lb6_lookup_slave(skb, key, slave);
+	key->slave = 0;
+	if (!backend || backend->port != val->port ||
+	    ipv6_addrcmp(&backend->target, &val->target))
+		return 0;
+
+	return slave;
+}
+
+/* Records the connection conn if it is new or has not been recorded within
+ * LB_CONN_REFRESH_INTERVAL, and removes it if the packet closes it.
+ * established is true if conn is still served by slave.
+ */
+static inline void __inline__ lb6_conn_update(struct lb6_conn_key *conn,
+					      struct lb6_service *svc, __u16 slave,
+					      int closing, int established,
+					      __u32 last_used)
+{
+	struct lb6_affinity_val val = {
+		.port = svc->port,
+		.slave = slave,
+	};
+	__u32 now;
+
+	if (closing) {
+		map_delete_elem(&cilium_lb6_conn, conn);
+		return;
+	}
+
+	now = bpf_ktime_get_sec();
+	if (established && now - last_used < LB_CONN_REFRESH_INTERVAL)
+		return;
+
+	ipv6_addr_copy(&val.target, &svc->target);
+	val.last_used = now;
+	map_update_elem(&cilium_lb6_conn, conn, &val, 0);
+}
+#endif /* ENABLE_LB_DRAINING */
+
+#ifdef ENABLE_LB_STATS
+/* Accounts the traffic forwarded by the frontend key to the backend, both to
+ * the backend and to the totals of the frontend.
+ */
+static inline void __inline__ lb6_stats_add(struct lb6_key *key, union v6addr *backend,
+					    __be16 backend_port, __u64 packets,
+					    __u64 bytes, __u64 conns)
+{
+	struct lb6_stats_key stats = {
+		.dport = key->dport,
+	};
+
+	ipv6_addr_copy(&stats.address, &key->address);
+	lb_stats_add(&cilium_lb6_stats, &stats, packets, bytes, conns);
+
+	ipv6_addr_copy(&stats.backend, backend);
+	stats.backend_port = backend_port;
+	lb_stats_add(&cilium_lb6_stats, &stats, packets, bytes, conns);
+}
+
+/* Accounts a new connection of the frontend key to the backend. The port of
+ * the backend is loaded from the already translated packet.
+ */
+static inline void __inline__ lb6_stats_conn(struct __sk_buff *skb, int l4_off,
+					     struct lb6_key *key, union v6addr *backend)
+{
+	__be16 port;
+
+	if (l4_load_port(skb, l4_off + TCP_DPORT_OFF, &port) < 0)
+		return;
+
+	lb6_stats_add(key, backend, port, 0, 0, 1);
+}
+#endif /* ENABLE_LB_STATS */
+
 static inline int __inline__ lb6_local(struct __sk_buff *skb, int l3_off, int l4_off,
 				       struct csum_offset *csum_off, struct lb6_key *key,
 				       struct ipv6_ct_tuple *tuple, struct lb6_service *svc,
@@ -429,11 +815,47 @@ static inline int __inline__ lb6_local(struct __sk_buff *skb, int l3_off, int l4
 {
 	__u16 slave;
 	union v6addr *addr;
+#ifdef ENABLE_SESSION_AFFINITY
+	struct lb6_affinity_key affinity = {
+		.svc = *key,
+	};
 
+	ipv6_addr_copy(&affinity.client, &tuple->saddr);
+#endif
+#ifdef ENABLE_LB_DRAINING
+	struct lb6_conn_key conn = {
+		.svc = *key,
+		.nexthdr = tuple->nexthdr,
+	};
+	__u32 last_used = 0;
+	__u16 conn_slave;
+	int closing;
+
+	ipv6_addr_copy(&conn.client, &tuple->saddr);
+	closing = lb_conn_load(skb, tuple->nexthdr, l4_off, &conn.sport);
+	slave = conn_slave = lb6_conn_slave(skb, &conn, key, &last_used);
+	if (slave == 0)
+#endif
+#ifdef ENABLE_SESSION_AFFINITY
+	slave = lb6_affinity_slave(&affinity, svc->count);
+	if (slave == 0)
+#endif
 	slave = lb6_select_slave(skb, key, svc->count, svc->weight);
 	if (!(svc = lb6_lookup_slave(skb, key, slave)))
 		return DROP_NO_SERVICE;
 
+#ifdef ENABLE_SESSION_AFFINITY
+	lb6_affinity_update(&affinity, svc, slave);
+#endif
+#ifdef ENABLE_LB_DRAINING
+	lb6_conn_update(&conn, svc, slave, closing, conn_slave != 0,
+			last_used);
+#endif
+#ifdef ENABLE_LB_STATS
+	lb6_stats_add(key, &svc->target, lb_backend_port(key->dport, svc->port),
+		      1, skb->len, 0);
+#endif
+
 	ipv6_addr_copy(&tuple->daddr, &svc->target);
 	addr = &tuple->daddr;
 
@@ -659,6 +1081,142 @@ lb4_xlate(struct __sk_buff *skb, __be32 *new_daddr, __be32 *new_saddr,
 }
 
 #ifdef ENABLE_IPV4
+#ifdef ENABLE_SESSION_AFFINITY
+/* Returns the slave previously selected for the client of a service with
+ * session affinity or 0 if there is none or the affinity has expired. The
+ * slave is kept up to date by the daemon when the backends change.
+ */
+static inline __u16 __inline__ lb4_affinity_slave(struct lb4_affinity_key *affinity,
+						  __u16 count)
+{
+	struct lb_affinity_match *match;
+	struct lb4_affinity_val *val;
+
+	match = map_lookup_elem(&cilium_lb4_affinity_match, &affinity->svc);
+	if (!match)
+		return 0;
+
+	val = map_lookup_elem(&cilium_lb4_affinity, affinity);
+	if (!val || val->slave > count ||
+	    bpf_ktime_get_sec() - val->last_used > match->timeout)
+		return 0;
+
+	return val->slave;
+}
+
+static inline void __inline__ lb4_affinity_update(struct lb4_affinity_key *affinity,
+						  struct lb4_service *svc, __u16 slave)
+{
+	struct lb4_affinity_val val = {
+		.target = svc->target,
+		.port = svc->port,
+		.slave = slave,
+	};
+
+	if (!map_lookup_elem(&cilium_lb4_affinity_match, &affinity->svc))
+		return;
+
+	val.last_used = bpf_ktime_get_sec();
+	map_update_elem(&cilium_lb4_affinity, affinity, &val, 0);
+}
+#endif /* ENABLE_SESSION_AFFINITY */
+
+#ifdef ENABLE_LB_DRAINING
+/* Returns the slave which served the established connection conn or 0 for a
+ * new connection. The slave may be beyond the count of the service while its
+ * backend is draining. The slave is kept up to date by the daemon when the
+ * backends change, it is only used if it still holds the same backend. The
+ * last use of the connection is stored in last_used.
+ */
+static inline __u16 __inline__ lb4_conn_slave(struct __sk_buff *skb,
+					      struct lb4_conn_key *conn,
+					      struct lb4_key *key,
+					      __u32 *last_used)
+{
+	struct lb4_affinity_val *val;
+	struct lb4_service *backend;
+	__u16 slave;
+
+	val = map_lookup_elem(&cilium_lb4_conn, conn);
+	if (!val || val->slave == 0)
+		return 0;
+
+	slave = val->slave;
+	*last_used = val->last_used;
+	backend = lb4_lookup_slave(skb, key, slave);
+	key->slave = 0;
+	if (!backend || backend->target != val->target ||
+	    backend->port != val->port)
+		return 0;
+
+	return slave;
+}
+
+/* Records the connection conn if it is new or has not been recorded within
+ * LB_CONN_REFRESH_INTERVAL, and removes it if the packet closes it.
+ * established is true if conn is still served by slave.
+ */
+static inline void __inline__ lb4_conn_update(struct lb4_conn_key *conn,
+					      struct lb4_service *svc, __u16 slave,
+					      int closing, int established,
+					      __u32 last_used)
+{
+	struct lb4_affinity_val val = {
+		.target = svc->target,
+		.port = svc->port,
+		.slave = slave,
+	};
+	__u32 now;
+
+	if (closing) {
+		map_delete_elem(&cilium_lb4_conn, conn);
+		return;
+	}
+
+	now = bpf_ktime_get_sec();
+	if (established && now - last_used < LB_CONN_REFRESH_INTERVAL)
+		return;
+
+	val.last_used = now;
+	map_update_elem(&cilium_lb4_conn, conn, &val, 0);
+}
+#endif /* ENABLE_LB_DRAINING */
+
+#ifdef ENABLE_LB_STATS
+/* Accounts the traffic forwarded by the frontend key to the backend, both to
+ * the backend and to the totals of the frontend.
+ */
+static inline void __inline__ lb4_stats_add(struct lb4_key *key, __be32 backend,
+					    __be16 backend_port, __u64 packets,
+					    __u64 bytes, __u64 conns)
+{
+	struct lb4_stats_key stats = {
+		.address = key->address,
+		.dport = key->dport,
+	};
+
+	lb_stats_add(&cilium_lb4_stats, &stats, packets, bytes, conns);
+
+	stats.backend = backend;
+	stats.backend_port = backend_port;
+	lb_stats_add(&cilium_lb4_stats, &stats, packets, bytes, conns);
+}
+
+/* Accounts a new connection of the frontend key to the backend. The port of
+ * the backend is loaded from the already translated packet.
+ */
+static inline void __inline__ lb4_stats_conn(struct __sk_buff *skb, int l4_off,
+					     struct lb4_key *key, __be32 backend)
+{
+	__be16 port;
+
+	if (l4_load_port(skb, l4_off + TCP_DPORT_OFF, &port) < 0)
+		return;
+
+	lb4_stats_add(key, backend, port, 0, 0, 1);
+}
+#endif /* ENABLE_LB_STATS */
+
 static inline int __inline__ lb4_local(struct __sk_buff *skb, int l3_off, int l4_off,
 				       struct csum_offset *csum_off, struct lb4_key *key,
 				       struct ipv4_ct_tuple *tuple, struct lb4_service *svc,
@@ -666,11 +1224,46 @@ static inline int __inline__ lb4_local(struct __sk_buff *skb, int l3_off, int l4
 {
 	__be32 new_saddr = 0, new_daddr;
 	__u16 slave;
-
+#ifdef ENABLE_SESSION_AFFINITY
+	struct lb4_affinity_key affinity = {
+		.svc = *key,
+		.client = saddr,
+	};
+#endif
+#ifdef ENABLE_LB_DRAINING
+	struct lb4_conn_key conn = {
+		.svc = *key,
+		.client = saddr,
+		.nexthdr = tuple->nexthdr,
+	};
+	__u32 last_used = 0;
+	__u16 conn_slave;
+	int closing;
+
+	closing = lb_conn_load(skb, tuple->nexthdr, l4_off, &conn.sport);
+	slave = conn_slave = lb4_conn_slave(skb, &conn, key, &last_used);
+	if (slave == 0)
+#endif
+#ifdef ENABLE_SESSION_AFFINITY
+	slave = lb4_affinity_slave(&affinity, svc->count);
+	if (slave == 0)
+#endif
 	slave = lb4_select_slave(skb, key, svc->count, svc->weight);
 	if (!(svc = lb4_lookup_slave(skb, key, slave)))
 		return DROP_NO_SERVICE;
 
+#ifdef ENABLE_SESSION_AFFINITY
+	lb4_affinity_update(&affinity, svc, slave);
+#endif
+#ifdef ENABLE_LB_DRAINING
+	lb4_conn_update(&conn, svc, slave, closing, conn_slave != 0,
+			last_used);
+#endif
+#ifdef ENABLE_LB_STATS
+	lb4_stats_add(key, svc->target, lb_backend_port(key->dport, svc->port),
+		      1, skb->len, 0);
+#endif
+
 	state->rev_nat_index = svc->rev_nat_index;
 	state->addr = new_daddr = svc->target;
 
@@ -699,4 +1292,310 @@ static inline int __inline__ lb4_local(struct __sk_buff *skb, int l3_off, int l4
 }
 #endif
 
+#ifdef ENABLE_DSR
+/** Returns true if the service of the master key is in DSR mode */
+static inline bool __inline__ lb6_dsr_lookup(struct lb6_key *key)
+{
+	return map_lookup_elem(&cilium_lb6_dsr_match, key) != NULL;
+}
+
+static inline bool __inline__ lb4_dsr_lookup(struct lb4_key *key)
+{
+	return map_lookup_elem(&cilium_lb4_dsr_match, key) != NULL;
+}
+
+#ifdef ENABLE_NODEPORT
+/** Returns true if port is in the range of node ports
+ *
+ * The NodePort frontends of the other nodes are not known to the backend
+ * node, the frontends carried in their range are accepted for any address.
+ */
+static inline bool __inline__ lb_dsr_nodeport(__be16 port)
+{
+	return bpf_ntohs(port) >= NODEPORT_PORT_MIN &&
+	       bpf_ntohs(port) <= NODEPORT_PORT_MAX;
+}
+#else
+static inline bool __inline__ lb_dsr_nodeport(__be16 port)
+{
+	return false;
+}
+#endif
+
+#ifdef HAVE_SKB_CHANGE_HEAD
+/** Carry the frontend of a DSR service to the backend node
+ * @arg skb	packet with the L3 header at ETH_HLEN
+ * @arg key	service key of the frontend
+ *
+ * Inserts a destination options header with the frontend after the IPv6
+ * header. All packet pointers are invalidated.
+ */
+static inline int __inline__ lb6_dsr_encap(struct __sk_buff *skb, struct lb6_key *key)
+{
+	struct lb6_dsr_opt opt = {
+		.hdrlen	= (sizeof(opt) >> 3) - 1,
+		.type	= DSR_IPV6_OPT_TYPE,
+		.len	= sizeof(opt.address) + sizeof(opt.port),
+		.port	= key->dport,
+		.pad	= { DSR_IPV6_OPT_PADN, 0 },
+	};
+	struct ipv6hdr ip6;
+	struct ethhdr eth;
+
+	if (skb_load_bytes(skb, 0, &eth, sizeof(eth)) < 0 ||
+	    skb_load_bytes(skb, ETH_HLEN, &ip6, sizeof(ip6)) < 0)
+		return DROP_INVALID;
+
+	ipv6_addr_copy(&opt.address, &key->address);
+	opt.nexthdr = ip6.nexthdr;
+	ip6.nexthdr = NEXTHDR_DEST;
+	ip6.payload_len = bpf_htons(bpf_ntohs(ip6.payload_len) + sizeof(opt));
+
+	/* The upper layer checksum does not cover extension headers */
+	if (skb_change_head(skb, sizeof(opt), 0) < 0)
+		return DROP_WRITE_ERROR;
+
+	if (skb_store_bytes(skb, 0, &eth, sizeof(eth), 0) < 0 ||
+	    skb_store_bytes(skb, ETH_HLEN, &ip6, sizeof(ip6), 0) < 0 ||
+	    skb_store_bytes(skb, ETH_HLEN + sizeof(ip6), &opt, sizeof(opt), 0) < 0)
+		return DROP_WRITE_ERROR;
+
+	return 0;
+}
+
+/** Carry the frontend of a DSR service to the backend node
+ * @arg skb	packet with the L3 header at ETH_HLEN
+ * @arg key	service key of the frontend
+ *
+ * Inserts an IP option with the frontend. Packets which already carry IP
+ * options are dropped. All packet pointers are invalidated.
+ */
+static inline int __inline__ lb4_dsr_encap(struct __sk_buff *skb, struct lb4_key *key)
+{
+	struct lb4_dsr_opt opt = {
+		.type		= DSR_IPV4_OPT_TYPE,
+		.len		= sizeof(opt),
+		.port		= key->dport,
+		.address	= key->address,
+	};
+	struct iphdr ip4;
+	struct ethhdr eth;
+	__be32 sum;
+
+	if (skb_load_bytes(skb, 0, &eth, sizeof(eth)) < 0 ||
+	    skb_load_bytes(skb, ETH_HLEN, &ip4, sizeof(ip4)) < 0)
+		return DROP_INVALID;
+
+	if (ip4.ihl != 5)
+		return DROP_INVALID;
+
+	ip4.ihl += sizeof(opt) >> 2;
+	ip4.tot_len = bpf_htons(bpf_ntohs(ip4.tot_len) + sizeof(opt));
+	ip4.check = 0;
+	sum = csum_diff(NULL, 0, &ip4, sizeof(ip4), 0);
+	sum = csum_diff(NULL, 0, &opt, sizeof(opt), sum);
+
+	if (skb_change_head(skb, sizeof(opt), 0) < 0)
+		return DROP_WRITE_ERROR;
+
+	if (skb_store_bytes(skb, 0, &eth, sizeof(eth), 0) < 0 ||
+	    skb_store_bytes(skb, ETH_HLEN, &ip4, sizeof(ip4), 0) < 0 ||
+	    skb_store_bytes(skb, ETH_HLEN + sizeof(ip4), &opt, sizeof(opt), 0) < 0)
+		return DROP_WRITE_ERROR;
+
+	if (l3_csum_replace(skb, ETH_HLEN + offsetof(struct iphdr, check), 0, sum, 0) < 0)
+		return DROP_CSUM_L3;
+
+	return 0;
+}
+#else
+/* Without support to grow packets, DSR services are translated like all
+ * other services and the replies are reverse translated by the balancer.
+ */
+static inline int __inline__ lb6_dsr_encap(struct __sk_buff *skb, struct lb6_key *key)
+{
+	return 0;
+}
+
+static inline int __inline__ lb4_dsr_encap(struct __sk_buff *skb, struct lb4_key *key)
+{
+	return 0;
+}
+#endif /* HAVE_SKB_CHANGE_HEAD */
+
+/** Record the frontend of a new connection to a local backend
+ * @arg skb	packet with the L3 header at ETH_HLEN
+ * @arg l4_off	offset to L4
+ * @arg nexthdr	L4 protocol
+ *
+ * If the packet carries the frontend of a DSR service, the replies of the
+ * connection are reverse translated to the frontend by lb6_dsr_rev_nat().
+ * Frontends which are not known as DSR services or node ports are ignored.
+ */
+static inline int __inline__ lb6_dsr_learn(struct __sk_buff *skb, int l4_off, __u8 nexthdr)
+{
+	struct lb6_dsr_key key = {
+		.nexthdr = nexthdr,
+	};
+	struct lb6_reverse_nat nat = {};
+	struct lb6_key svc = {};
+	struct lb6_dsr_opt opt;
+	__be16 ports[2];
+	__u8 first;
+
+	if (nexthdr != IPPROTO_TCP && nexthdr != IPPROTO_UDP)
+		return 0;
+
+	/* The frontend is always carried in the first extension header */
+	if (skb_load_bytes(skb, ETH_HLEN + offsetof(struct ipv6hdr, nexthdr), &first, sizeof(first)) < 0)
+		return DROP_INVALID;
+	if (first != NEXTHDR_DEST)
+		return 0;
+
+	if (skb_load_bytes(skb, ETH_HLEN + sizeof(struct ipv6hdr), &opt, sizeof(opt)) < 0)
+		return DROP_INVALID;
+	if (opt.type != DSR_IPV6_OPT_TYPE ||
+	    opt.len != sizeof(opt.address) + sizeof(opt.port))
+		return 0;
+
+	/* Only frontends of known DSR services and NodePort frontends are
+	 * learned, the replies of the backend could be sent from any address
+	 * otherwise.
+	 */
+	ipv6_addr_copy(&svc.address, &opt.address);
+	svc.dport = opt.port;
+	if (!lb6_dsr_lookup(&svc) && !lb_dsr_nodeport(svc.dport))
+		return 0;
+
+	/* Port offsets for UDP and TCP are the same */
+	if (ipv6_load_saddr(skb, ETH_HLEN, &key.client) < 0 ||
+	    ipv6_load_daddr(skb, ETH_HLEN, &key.backend) < 0 ||
+	    skb_load_bytes(skb, l4_off, ports, sizeof(ports)) < 0)
+		return DROP_INVALID;
+	key.client_port = ports[0];
+	key.backend_port = ports[1];
+
+	ipv6_addr_copy(&nat.address, &opt.address);
+	nat.port = opt.port;
+
+	if (map_update_elem(&cilium_lb6_dsr, &key, &nat, 0) < 0)
+		return DROP_WRITE_ERROR;
+
+	return 0;
+}
+
+static inline int __inline__ lb4_dsr_learn(struct __sk_buff *skb, int l4_off, __u8 nexthdr)
+{
+	struct lb4_dsr_key key = {
+		.nexthdr = nexthdr,
+	};
+	struct lb4_reverse_nat nat = {};
+	struct lb4_key svc = {};
+	struct lb4_dsr_opt opt;
+	__be16 ports[2];
+
+	if (nexthdr != IPPROTO_TCP && nexthdr != IPPROTO_UDP)
+		return 0;
+
+	/* The frontend is always carried in the first IP option */
+	if (l4_off - ETH_HLEN < sizeof(struct iphdr) + sizeof(opt))
+		return 0;
+
+	if (skb_load_bytes(skb, ETH_HLEN + sizeof(struct iphdr), &opt, sizeof(opt)) < 0)
+		return DROP_INVALID;
+	if (opt.type != DSR_IPV4_OPT_TYPE || opt.len != sizeof(opt))
+		return 0;
+
+	/* Only frontends of known DSR services and NodePort frontends are
+	 * learned, the replies of the backend could be sent from any address
+	 * otherwise.
+	 */
+	svc.address = opt.address;
+	svc.dport = opt.port;
+	if (!lb4_dsr_lookup(&svc) && !lb_dsr_nodeport(svc.dport))
+		return 0;
+
+	/* Port offsets for UDP and TCP are the same */
+	if (skb_load_bytes(skb, ETH_HLEN + offsetof(struct iphdr, saddr), &key.client, 4) < 0 ||
+	    skb_load_bytes(skb, ETH_HLEN + offsetof(struct iphdr, daddr), &key.backend, 4) < 0 ||
+	    skb_load_bytes(skb, l4_off, ports, sizeof(ports)) < 0)
+		return DROP_INVALID;
+	key.client_port = ports[0];
+	key.backend_port = ports[1];
+
+	nat.address = opt.address;
+	nat.port = opt.port;
+
+	if (map_update_elem(&cilium_lb4_dsr, &key, &nat, 0) < 0)
+		return DROP_WRITE_ERROR;
+
+	return 0;
+}
+
+/** Reverse translate a reply of a local backend of a DSR service
+ * @arg skb	packet with the L3 header at ETH_HLEN
+ * @arg l4_off	offset to L4
+ * @arg csum_off	offset to L4 checksum field
+ * @arg tuple	tuple
+ *
+ * The source of the reply is translated to the frontend recorded by
+ * lb6_dsr_learn(), the reply is then sent directly to the client.
+ */
+static inline int __inline__ lb6_dsr_rev_nat(struct __sk_buff *skb, int l4_off,
+					     struct csum_offset *csum_off,
+					     struct ipv6_ct_tuple *tuple)
+{
+	struct lb6_dsr_key key = {
+		.nexthdr = tuple->nexthdr,
+	};
+	struct lb6_reverse_nat *nat;
+	__be16 ports[2];
+
+	if (tuple->nexthdr != IPPROTO_TCP && tuple->nexthdr != IPPROTO_UDP)
+		return 0;
+
+	if (ipv6_load_saddr(skb, ETH_HLEN, &key.backend) < 0 ||
+	    ipv6_load_daddr(skb, ETH_HLEN, &key.client) < 0 ||
+	    skb_load_bytes(skb, l4_off, ports, sizeof(ports)) < 0)
+		return DROP_INVALID;
+	key.backend_port = ports[0];
+	key.client_port = ports[1];
+
+	nat = map_lookup_elem(&cilium_lb6_dsr, &key);
+	if (nat == NULL)
+		return 0;
+
+	return __lb6_rev_nat(skb, l4_off, csum_off, tuple, 0, nat);
+}
+
+static inline int __inline__ lb4_dsr_rev_nat(struct __sk_buff *skb, int l3_off, int l4_off,
+					     struct csum_offset *csum_off,
+					     struct ct_state *ct_state,
+					     struct ipv4_ct_tuple *tuple)
+{
+	struct lb4_dsr_key key = {
+		.nexthdr = tuple->nexthdr,
+	};
+	struct lb4_reverse_nat *nat;
+	__be16 ports[2];
+
+	if (tuple->nexthdr != IPPROTO_TCP && tuple->nexthdr != IPPROTO_UDP)
+		return 0;
+
+	if (skb_load_bytes(skb, l3_off + offsetof(struct iphdr, saddr), &key.backend, 4) < 0 ||
+	    skb_load_bytes(skb, l3_off + offsetof(struct iphdr, daddr), &key.client, 4) < 0 ||
+	    skb_load_bytes(skb, l4_off, ports, sizeof(ports)) < 0)
+		return DROP_INVALID;
+	key.backend_port = ports[0];
+	key.client_port = ports[1];
+
+	nat = map_lookup_elem(&cilium_lb4_dsr, &key);
+	if (nat == NULL)
+		return 0;
+
+	return __lb4_rev_nat(skb, l3_off, l4_off, csum_off, tuple, 0, nat,
+			     ct_state);
+}
+#endif /* ENABLE_DSR */
+
 #endif /* __LB_H_ */
@@ -186,7 +186,7 @@ policy_can_access_ingress(struct __sk_buff *skb, __u32 src_label,
 #if defined POLICY_EGRESS && defined LXC_ID
 
 static inline int __inline__
-policy_can_egress(struct __sk_buff *skb, __u16 identity, __u16 dport, __u8 proto)
+policy_can_egress(struct __sk_buff *skb, __u32 identity, __u16 dport, __u8 proto)
 {
 #ifdef DROP_ALL
 	return DROP_POLICY;
@@ -206,14 +206,14 @@ policy_can_egress(struct __sk_buff *skb, __u16 identity, __u16 dport, __u8 proto
 
 static inline int policy_can_egress6(struct __sk_buff *skb,
 				     struct ipv6_ct_tuple *tuple,
-				     __u16 default_identity,
+				     __u32 default_identity,
 				     union v6addr *daddr)
 {
 #ifdef DROP_ALL
 	return DROP_POLICY;
 #else
 	struct remote_endpoint_info *info;
-	__u16 identity = default_identity;
+	__u32 identity = default_identity;
 
 	info = lookup_ip6_remote_endpoint(daddr);
 	if (info)
@@ -227,13 +227,13 @@ static inline int policy_can_egress6(struct __sk_buff *skb,
 
 static inline int policy_can_egress4(struct __sk_buff *skb,
 				     struct ipv4_ct_tuple *tuple,
-				     __u16 default_identity, __be32 daddr)
+				     __u32 default_identity, __be32 daddr)
 {
 #ifdef DROP_ALL
 	return DROP_POLICY;
 #else
 	struct remote_endpoint_info *info;
-	__u16 identity = default_identity;
+	__u32 identity = default_identity;
 
 	info = lookup_ip4_remote_endpoint(daddr);
 	if (info)
@@ -249,7 +249,7 @@ static inline int policy_can_egress4(struct __sk_buff *skb,
 
 static inline int
 policy_can_egress6(struct __sk_buff *skb, struct ipv6_ct_tuple *tuple,
-		   __u16 default_identity, union v6addr *daddr)
+		   __u32 default_identity, union v6addr *daddr)
 {
 #ifdef DROP_ALL
 	return DROP_POLICY;
@@ -260,7 +260,7 @@ policy_can_egress6(struct __sk_buff *skb, struct ipv6_ct_tuple *tuple,
 
 static inline int
 policy_can_egress4(struct __sk_buff *skb, struct ipv4_ct_tuple *tuple,
-		   __u16 default_identity, __be32 daddr)
+		   __u32 default_identity, __be32 daddr)
 {
 #ifdef DROP_ALL
 	return DROP_POLICY;
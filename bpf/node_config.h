@@ -49,6 +49,20 @@
 #define NODE_MAC { .addr = { 0xde, 0xad, 0xbe, 0xef, 0xc0, 0xde } }
 #define ENABLE_IPV4
 #define LB_RR_MAX_SEQ 31
+#define LB_MAGLEV_LUT_SIZE 16381
+#define CILIUM_LB_MAGLEV_MAP_MAX_ENTRIES 4096
+#define LB_SELECTION_MAGLEV
+#define CILIUM_LB_AFFINITY_MAP_MAX_ENTRIES 65536
+#define ENABLE_SESSION_AFFINITY
+#define CILIUM_LB_CONN_MAP_MAX_ENTRIES 65536
+#define ENABLE_LB_DRAINING
+#define CILIUM_LB_DSR_MAP_MAX_ENTRIES 65536
+#define ENABLE_DSR
+#define CILIUM_LB_STATS_MAP_MAX_ENTRIES 65536
+#define ENABLE_LB_STATS
+#define NODEPORT_PORT_MIN 30000
+#define NODEPORT_PORT_MAX 32767
+#define ENABLE_NODEPORT
 #define TUNNEL_ENDPOINT_MAP_SIZE 65536
 #define ENDPOINTS_MAP_SIZE 65536
 #define METRICS_MAP_SIZE 65536
@@ -0,0 +1,18 @@
+/* Tests for availability of kernel commits (4.10+):
+ *
+ * 3a0af8fd61f9 ("bpf: BPF for lightweight tunnel infrastructure")
+ */
+	{
+		.emits	= "HAVE_SKB_CHANGE_HEAD",
+		.type	= BPF_PROG_TYPE_SCHED_CLS,
+		.insns	= {
+			BPF_MOV64_IMM(BPF_REG_2, 0),
+			BPF_MOV64_IMM(BPF_REG_3, 0),
+			BPF_EMIT_CALL(BPF_FUNC_skb_change_head),
+			BPF_EXIT_INSN(),
+		},
+		.warn = "Kernel does not support bpf_skb_change_head() helper. "
+			"Therefore, cilium does not support direct server "
+			"return for services. Recommendation is to run 4.10+ "
+			"kernels.",
+	},
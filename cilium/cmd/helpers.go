@@ -210,6 +210,20 @@ func parseTrafficString(td string) (policymap.TrafficDirection, error) {
 
 }
 
+// parseServiceLabels converts the provided list of "key=value" strings to a
+// map of service labels.
+func parseServiceLabels(labels []string) (map[string]string, error) {
+	result := make(map[string]string, len(labels))
+	for _, label := range labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid label %q, expected <key>=<value>", label)
+		}
+		result[kv[0]] = kv[1]
+	}
+	return result, nil
+}
+
 // updatePolicyKey updates an entry to the PolicyMap for the endpoint ID, identity,
 // traffic direction, and optional list of ports in the list of arguments for the
 // given command. Adds the entry to the PolicyMap if add is true, deletes if fails.
@@ -66,3 +66,15 @@ func (s *CMDHelpersSuite) TestParseTrafficString(c *C) {
 	c.Assert(err, Not(IsNil))
 
 }
+
+func (s *CMDHelpersSuite) TestParseServiceLabels(c *C) {
+	labels, err := parseServiceLabels([]string{"app=foo", "tier=", "url=a=b"})
+	c.Assert(err, IsNil)
+	c.Assert(labels, DeepEquals, map[string]string{"app": "foo", "tier": "", "url": "a=b"})
+
+	_, err = parseServiceLabels([]string{"app"})
+	c.Assert(err, Not(IsNil))
+
+	_, err = parseServiceLabels([]string{"=foo"})
+	c.Assert(err, Not(IsNil))
+}
@@ -22,9 +22,10 @@ import (
 )
 
 var (
-	recursive   bool
-	kvStore     string
-	kvStoreOpts = make(map[string]string)
+	recursive             bool
+	kvStore               string
+	kvStoreOpts           = make(map[string]string)
+	kvStoreEncryptionKeys string
 )
 
 // kvstoreCmd represents the bpf command
@@ -56,6 +57,14 @@ func setupKvstore() {
 		}
 	}
 
+	if kvStoreEncryptionKeys != "" {
+		keys, err := kvstore.LoadEncryptionKeys(kvStoreEncryptionKeys)
+		if err != nil {
+			Fatalf("Unable to load kvstore encryption keys: %s", err)
+		}
+		kvstore.SetEncryptionKeys(keys)
+	}
+
 	if err := kvstore.Setup(kvStore, kvStoreOpts); err != nil {
 		Fatalf("Unable to setup kvstore: %s", err)
 	}
@@ -66,4 +75,5 @@ func init() {
 	flags := kvstoreCmd.PersistentFlags()
 	flags.StringVar(&kvStore, "kvstore", "", "kvstore type")
 	flags.Var(option.NewNamedMapOptions("kvstore-opts", &kvStoreOpts, nil), "kvstore-opt", "kvstore options")
+	flags.StringVar(&kvStoreEncryptionKeys, "encryption-key-file", "", "Path to the file with the keys used to encrypt kvstore values")
 }
@@ -0,0 +1,79 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/cilium/cilium/pkg/kvstore"
+	"github.com/cilium/cilium/pkg/kvstore/snapshot"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotFile     string
+	snapshotViaAgent bool
+)
+
+var kvstoreExportCmd = &cobra.Command{
+	Use:     "export [options]",
+	Short:   "Export the state stored in the kvstore",
+	Example: "cilium kvstore export --file cilium-state.json",
+	Run: func(cmd *cobra.Command, args []string) {
+		var (
+			s   *snapshot.Snapshot
+			err error
+		)
+
+		if snapshotViaAgent {
+			m, err := client.KvstoreSnapshotGet()
+			if err != nil {
+				Fatalf("Unable to export kvstore state: %s", err)
+			}
+			s, err = snapshot.NewFromModel(m)
+			if err != nil {
+				Fatalf("Unable to decode kvstore state: %s", err)
+			}
+		} else {
+			setupKvstore()
+			s, err = snapshot.Export(kvstore.Client())
+			if err != nil {
+				Fatalf("Unable to export kvstore state: %s", err)
+			}
+		}
+
+		var w io.Writer = os.Stdout
+		if snapshotFile != "" {
+			f, err := os.OpenFile(snapshotFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+			if err != nil {
+				Fatalf("Unable to create file %s: %s", snapshotFile, err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if err := s.Write(w); err != nil {
+			Fatalf("Unable to write kvstore state: %s", err)
+		}
+	},
+}
+
+func init() {
+	kvstoreCmd.AddCommand(kvstoreExportCmd)
+	kvstoreExportCmd.Flags().StringVarP(&snapshotFile, "file", "f", "", "Write snapshot to file instead of standard output")
+	kvstoreExportCmd.Flags().BoolVar(&snapshotViaAgent, "agent", false, "Retrieve the snapshot via the agent API instead of accessing the kvstore directly")
+}
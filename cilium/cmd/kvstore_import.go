@@ -0,0 +1,101 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cilium/cilium/pkg/kvstore"
+	"github.com/cilium/cilium/pkg/kvstore/snapshot"
+
+	"github.com/spf13/cobra"
+)
+
+var snapshotDryRun bool
+
+var kvstoreImportCmd = &cobra.Command{
+	Use:   "import [options]",
+	Short: "Import state previously exported from the kvstore",
+	Long: `Import a snapshot created with "cilium kvstore export" into the kvstore.
+
+The snapshot is checked for consistency with itself and with the current
+content of the kvstore before any key is written. Identities allocated in the
+snapshot may not conflict with identities allocated in the kvstore. Existing
+keys are never overwritten.
+
+Keys which are protected by a lease when written by an agent are attached to
+a dedicated import lease which is not renewed, except for the identity
+references of the importing agent when importing via the agent, which are
+attached to the lease of the agent. Keys are attached to the lease of their
+owner again when the owner refreshes them and are removed when the import
+lease expires otherwise, e.g. if the owning node no longer exists.`,
+	Example: "cilium kvstore import --file cilium-state.json",
+	Run: func(cmd *cobra.Command, args []string) {
+		var r io.Reader = os.Stdin
+		if snapshotFile != "" {
+			f, err := os.Open(snapshotFile)
+			if err != nil {
+				Fatalf("Unable to open file %s: %s", snapshotFile, err)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		s, err := snapshot.Read(r)
+		if err != nil {
+			Fatalf("%s", err)
+		}
+
+		var result *snapshot.ImportResult
+		if snapshotViaAgent {
+			m, err := s.GetModel()
+			if err != nil {
+				Fatalf("Unable to encode snapshot: %s", err)
+			}
+			res, err := client.KvstoreSnapshotPut(m, snapshotDryRun)
+			if err != nil {
+				Fatalf("Unable to import kvstore state: %s", err)
+			}
+			result = &snapshot.ImportResult{
+				Created:   int(res.Created),
+				Unchanged: int(res.Unchanged),
+				Skipped:   int(res.Skipped),
+			}
+		} else {
+			setupKvstore()
+			result, err = snapshot.Import(kvstore.Client(), s, snapshot.ImportOptions{DryRun: snapshotDryRun})
+			if err != nil {
+				Fatalf("Unable to import kvstore state: %s", err)
+			}
+		}
+
+		if snapshotDryRun {
+			fmt.Printf("Dry run, no keys written: %d keys would be created, %d unchanged, %d skipped\n",
+				result.Created, result.Unchanged, result.Skipped)
+		} else {
+			fmt.Printf("%d keys created, %d unchanged, %d skipped\n",
+				result.Created, result.Unchanged, result.Skipped)
+		}
+	},
+}
+
+func init() {
+	kvstoreCmd.AddCommand(kvstoreImportCmd)
+	kvstoreImportCmd.Flags().StringVarP(&snapshotFile, "file", "f", "", "Read snapshot from file instead of standard input")
+	kvstoreImportCmd.Flags().BoolVar(&snapshotViaAgent, "agent", false, "Import the snapshot via the agent API instead of accessing the kvstore directly")
+	kvstoreImportCmd.Flags().BoolVar(&snapshotDryRun, "dry-run", false, "Check the snapshot for conflicts without writing any keys")
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/cilium/cilium/api/v1/models"
+	pkg "github.com/cilium/cilium/pkg/client"
+	"github.com/cilium/cilium/pkg/command"
+
+	"github.com/spf13/cobra"
+)
+
+// nodeListCmd represents the node_list command
+var nodeListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List nodes",
+	Run: func(cmd *cobra.Command, args []string) {
+		listNodes()
+	},
+}
+
+func init() {
+	nodeCmd.AddCommand(nodeListCmd)
+	command.AddJSONOutput(nodeListCmd)
+}
+
+func listNodes() {
+	resp, err := client.Daemon.GetHealthz(nil)
+	if err != nil {
+		Fatalf("Cannot get node list: %s", pkg.Hint(err))
+	}
+
+	nodes := []*models.NodeElement{}
+	if resp.Payload.Cluster != nil {
+		nodes = resp.Payload.Cluster.Nodes
+	}
+
+	if command.OutputJSON() {
+		if err := command.PrintOutput(nodes); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 0, 3, ' ', 0)
+	printNodeList(w, nodes)
+	w.Flush()
+}
+
+// nodeAddress returns the primary address of a node for an address family
+// including the allocation range of the node
+func nodeAddress(e *models.NodeAddressingElement) string {
+	if e == nil || e.IP == "" || e.IP == "<nil>" {
+		return ""
+	}
+	if e.AllocRange != "" {
+		return fmt.Sprintf("%s (%s)", e.IP, e.AllocRange)
+	}
+	return e.IP
+}
+
+func printNodeList(w *tabwriter.Writer, nodes []*models.NodeElement) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+
+	fmt.Fprintln(w, "Name\tIPv4 Address\tIPv6 Address\tSource")
+	for _, node := range nodes {
+		var ipv4, ipv6 string
+		if node.PrimaryAddress != nil {
+			ipv4 = nodeAddress(node.PrimaryAddress.IPV4)
+			ipv6 = nodeAddress(node.PrimaryAddress.IPV6)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", node.Name, ipv4, ipv6, node.Source)
+	}
+}
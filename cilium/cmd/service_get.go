@@ -19,6 +19,7 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/cilium/cilium/api/v1/models"
 	"github.com/cilium/cilium/common/types"
 	"github.com/cilium/cilium/pkg/command"
 
@@ -50,7 +51,17 @@ var serviceGetCmd = &cobra.Command{
 			if bea, err := types.NewL3n4AddrFromBackendModel(be); err != nil {
 				slice = append(slice, fmt.Sprintf("invalid backend: %+v", be))
 			} else {
-				slice = append(slice, bea.String())
+				str := bea.String()
+				if be.Health != "" {
+					str = fmt.Sprintf("%s [%s]", str, be.Health)
+				}
+				if be.Draining {
+					str = fmt.Sprintf("%s [draining]", str)
+				}
+				if be.Statistics != nil {
+					str = fmt.Sprintf("%s [%s]", str, formatServiceStats(be.Statistics))
+				}
+				slice = append(slice, str)
 			}
 		}
 
@@ -63,6 +74,8 @@ var serviceGetCmd = &cobra.Command{
 
 		if fea, err := types.NewL3n4AddrFromModel(svc.Status.Realized.FrontendAddress); err != nil {
 			fmt.Fprintf(os.Stderr, "invalid frontend model: %s", err)
+		} else if svc.Status.Statistics != nil {
+			fmt.Printf("%s => [%s]\n", fea.String(), formatServiceStats(svc.Status.Statistics))
 		} else {
 			fmt.Printf("%s =>\n", fea.String())
 		}
@@ -73,6 +86,13 @@ var serviceGetCmd = &cobra.Command{
 	},
 }
 
+// formatServiceStats returns the traffic statistics of a frontend or backend
+// in human readable form
+func formatServiceStats(stats *models.ServiceStatistics) string {
+	return fmt.Sprintf("packets: %d, bytes: %d, connections: %d",
+		stats.Packets, stats.Bytes, stats.Connections)
+}
+
 func init() {
 	serviceCmd.AddCommand(serviceGetCmd)
 	command.AddJSONOutput(serviceGetCmd)
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/cilium/cilium/api/v1/models"
@@ -27,6 +28,13 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	listName      string
+	listNamespace string
+	listSource    string
+	listLabels    []string
+)
+
 // serviceListCmd represents the service_list command
 var serviceListCmd = &cobra.Command{
 	Use:     "list",
@@ -39,15 +47,76 @@ var serviceListCmd = &cobra.Command{
 
 func init() {
 	serviceCmd.AddCommand(serviceListCmd)
+	serviceListCmd.Flags().StringVarP(&listName, "name", "", "", "Only list services with the given name")
+	serviceListCmd.Flags().StringVarP(&listNamespace, "namespace", "", "", "Only list services in the given namespace")
+	serviceListCmd.Flags().StringVarP(&listSource, "source", "", "", "Only list services of the given source (k8s, api)")
+	serviceListCmd.Flags().StringSliceVarP(&listLabels, "labels", "", []string{}, "Only list services with all of the given labels (<key>=<value>)")
 	command.AddJSONOutput(serviceListCmd)
 }
 
+// getServiceMeta returns the metadata of the service spec.
+func getServiceMeta(spec *models.ServiceSpec) types.ServiceMeta {
+	return types.ServiceMeta{
+		Name:      spec.Name,
+		Namespace: spec.Namespace,
+		PortName:  types.FEPortName(spec.PortName),
+		Source:    types.ServiceSource(spec.Source),
+		Labels:    spec.Labels,
+	}
+}
+
+// filterServices returns the services in list whose metadata matches
+// filter.
+func filterServices(list []*models.Service, filter types.ServiceMeta) []*models.Service {
+	if filter.IsEmpty() {
+		return list
+	}
+
+	filtered := []*models.Service{}
+	for _, svc := range list {
+		if svc.Status == nil || svc.Status.Realized == nil {
+			continue
+		}
+		meta := getServiceMeta(svc.Status.Realized)
+		if meta.Matches(filter) {
+			filtered = append(filtered, svc)
+		}
+	}
+	return filtered
+}
+
+// formatServiceName returns the name of the service in the
+// "namespace/name:port-name" format.
+func formatServiceName(spec *models.ServiceSpec) string {
+	name := spec.Name
+	if spec.Namespace != "" {
+		name = spec.Namespace + "/" + name
+	}
+	if spec.PortName != "" {
+		name = name + ":" + spec.PortName
+	}
+	return name
+}
+
 func listServices(cmd *cobra.Command, args []string) {
 	list, err := client.GetServices()
 	if err != nil {
 		Fatalf("Cannot get services list: %s", err)
 	}
 
+	filter := types.ServiceMeta{
+		Name:      listName,
+		Namespace: listNamespace,
+		Source:    types.ServiceSource(listSource),
+	}
+	if len(listLabels) != 0 {
+		filter.Labels, err = parseServiceLabels(listLabels)
+		if err != nil {
+			Fatalf("Invalid labels: %s", err)
+		}
+	}
+	list = filterServices(list, filter)
+
 	if command.OutputJSON() {
 		if err := command.PrintOutput(list); err != nil {
 			os.Exit(1)
@@ -60,11 +129,13 @@ func listServices(cmd *cobra.Command, args []string) {
 }
 
 func printServiceList(w *tabwriter.Writer, list []*models.Service) {
-	fmt.Fprintln(w, "ID\tFrontend\tBackend\t")
+	fmt.Fprintln(w, "ID\tFrontend\tType\tName\tBackend\t")
 
 	type ServiceOutput struct {
 		ID               int64
 		FrontendAddress  string
+		FrontendType     string
+		Name             string
 		BackendAddresses []string
 	}
 	svcs := []ServiceOutput{}
@@ -82,6 +153,7 @@ func printServiceList(w *tabwriter.Writer, list []*models.Service) {
 		}
 
 		var backendAddresses []string
+		clusters := map[string]int{}
 		for i, be := range svc.Status.Realized.BackendAddresses {
 			beA, err := types.NewL3n4AddrFromBackendModel(be)
 			if err != nil {
@@ -94,12 +166,35 @@ func printServiceList(w *tabwriter.Writer, list []*models.Service) {
 			} else {
 				str = fmt.Sprintf("%d => %s", i+1, beA.String())
 			}
+			if be.Health != "" {
+				str = fmt.Sprintf("%s [%s]", str, be.Health)
+			}
+			if be.Draining {
+				str = fmt.Sprintf("%s [draining]", str)
+			}
+			if be.Cluster != "" {
+				str = fmt.Sprintf("%s (%s)", str, be.Cluster)
+				clusters[be.Cluster]++
+			}
 			backendAddresses = append(backendAddresses, str)
 		}
+		if len(clusters) > 0 {
+			backendAddresses = append(backendAddresses, formatClusterBackends(clusters))
+		}
+
+		feType := svc.Status.Realized.FrontendType
+		if feType == "" {
+			feType = string(types.FrontendTypeClusterIP)
+		}
+		if svc.Status.Realized.ForwardingMode == models.ServiceSpecForwardingModeDSR {
+			feType = fmt.Sprintf("%s [DSR]", feType)
+		}
 
 		SvcOutput := ServiceOutput{
 			ID:               svc.Status.Realized.ID,
 			FrontendAddress:  feA.String(),
+			FrontendType:     feType,
+			Name:             formatServiceName(svc.Status.Realized),
 			BackendAddresses: backendAddresses,
 		}
 		svcs = append(svcs, SvcOutput)
@@ -113,22 +208,40 @@ func printServiceList(w *tabwriter.Writer, list []*models.Service) {
 		var str string
 
 		if len(service.BackendAddresses) == 0 {
-			str = fmt.Sprintf("%d\t%s\t\t",
-				service.ID, service.FrontendAddress)
+			str = fmt.Sprintf("%d\t%s\t%s\t%s\t\t",
+				service.ID, service.FrontendAddress, service.FrontendType,
+				service.Name)
 			fmt.Fprintln(w, str)
 			continue
 		}
 
-		str = fmt.Sprintf("%d\t%s\t%s\t",
-			service.ID, service.FrontendAddress,
-			service.BackendAddresses[0])
+		str = fmt.Sprintf("%d\t%s\t%s\t%s\t%s\t",
+			service.ID, service.FrontendAddress, service.FrontendType,
+			service.Name, service.BackendAddresses[0])
 		fmt.Fprintln(w, str)
 
 		for _, bkaddr := range service.BackendAddresses[1:] {
-			str := fmt.Sprintf("\t\t%s\t", bkaddr)
+			str := fmt.Sprintf("\t\t\t\t%s\t", bkaddr)
 			fmt.Fprintln(w, str)
 		}
 	}
 
 	w.Flush()
 }
+
+// formatClusterBackends returns the number of backends per cluster of a
+// global service, sorted by cluster name
+func formatClusterBackends(clusters map[string]int) string {
+	names := make([]string, 0, len(clusters))
+	for name := range clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	counts := make([]string, 0, len(names))
+	for _, name := range names {
+		counts = append(counts, fmt.Sprintf("%s=%d", name, clusters[name]))
+	}
+
+	return "backends per cluster: " + strings.Join(counts, ", ")
+}
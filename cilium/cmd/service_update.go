@@ -21,6 +21,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cilium/cilium/api/v1/models"
 	"github.com/cilium/cilium/common/types"
@@ -29,10 +30,21 @@ import (
 )
 
 var (
-	addRev   bool
-	idU      uint64
-	frontend string
-	backends []string
+	addRev          bool
+	idU             uint64
+	frontend        string
+	backends        []string
+	affinity        string
+	affinityTimeout uint32
+	forwardingMode  string
+	healthCheck     string
+	healthCheckPath string
+	healthInterval  uint32
+	healthTimeout   uint32
+	svcName         string
+	svcNamespace    string
+	svcPortName     string
+	svcLabels       []string
 )
 
 // serviceUpdateCmd represents the service_update command
@@ -48,8 +60,19 @@ func init() {
 	serviceCmd.AddCommand(serviceUpdateCmd)
 	serviceUpdateCmd.Flags().BoolVarP(&addRev, "rev", "", true, "Add reverse translation")
 	serviceUpdateCmd.Flags().Uint64VarP(&idU, "id", "", 0, "Identifier")
-	serviceUpdateCmd.Flags().StringVarP(&frontend, "frontend", "", "", "Frontend address")
+	serviceUpdateCmd.Flags().StringVarP(&frontend, "frontend", "", "", "Frontend address, a virtual IP is allocated for an unspecified IP (e.g. :80, 0.0.0.0:80, [::]:80)")
 	serviceUpdateCmd.Flags().StringSliceVarP(&backends, "backends", "", []string{}, "Backend address or addresses followed by optional weight (<IP:Port>[/weight])")
+	serviceUpdateCmd.Flags().StringVarP(&affinity, "session-affinity", "", models.ServiceSpecSessionAffinityNone, "Session affinity (None, ClientIP), ClientIP requires --enable-session-affinity on the agent")
+	serviceUpdateCmd.Flags().Uint32VarP(&affinityTimeout, "session-affinity-timeout", "", 0, "Idle time in seconds after which a client may be assigned a different backend (default 10800)")
+	serviceUpdateCmd.Flags().StringVarP(&forwardingMode, "forwarding-mode", "", models.ServiceSpecForwardingModeNAT, "Forwarding mode (NAT, DSR), DSR requires --enable-dsr on the agents")
+	serviceUpdateCmd.Flags().StringVarP(&healthCheck, "health-check", "", "", "Health check backends with the given probe (tcp, http)")
+	serviceUpdateCmd.Flags().StringVarP(&healthCheckPath, "health-check-path", "", "/", "Path requested by HTTP health checks")
+	serviceUpdateCmd.Flags().Uint32VarP(&healthInterval, "health-check-interval", "", 0, "Interval in seconds between health checks of a backend (default 10)")
+	serviceUpdateCmd.Flags().Uint32VarP(&healthTimeout, "health-check-timeout", "", 0, "Timeout in seconds of a health check (default 2)")
+	serviceUpdateCmd.Flags().StringVarP(&svcName, "name", "", "", "Name of the service")
+	serviceUpdateCmd.Flags().StringVarP(&svcNamespace, "namespace", "", "", "Namespace of the service")
+	serviceUpdateCmd.Flags().StringVarP(&svcPortName, "port-name", "", "", "Name of the service port of the frontend")
+	serviceUpdateCmd.Flags().StringSliceVarP(&svcLabels, "labels", "", []string{}, "Labels of the service (<key>=<value>)")
 }
 
 func parseFrontendAddress(address string) (*models.FrontendAddress, net.IP) {
@@ -59,11 +82,15 @@ func parseFrontendAddress(address string) (*models.FrontendAddress, net.IP) {
 	}
 
 	// FIXME support more than TCP
-	return &models.FrontendAddress{
-		IP:       frontend.IP.String(),
+	fa := &models.FrontendAddress{
 		Port:     uint16(frontend.Port),
 		Protocol: models.FrontendAddressProtocolTCP,
-	}, frontend.IP
+	}
+	// Without an IP, a virtual IP is allocated by the agent
+	if frontend.IP != nil {
+		fa.IP = frontend.IP.String()
+	}
+	return fa, frontend.IP
 }
 
 func updateService(cmd *cobra.Command, args []string) {
@@ -94,6 +121,49 @@ func updateService(cmd *cobra.Command, args []string) {
 	spec.FrontendAddress = fa
 	spec.Flags.DirectServerReturn = addRev
 
+	affinityConfig, err := types.NewSessionAffinityConfig(affinity, affinityTimeout)
+	if err != nil {
+		Fatalf("Invalid session affinity: %s", err)
+	}
+	spec.SessionAffinity = string(affinityConfig.Mode)
+	spec.SessionAffinityTimeout = int64(affinityConfig.TimeoutSec)
+
+	mode, err := types.NewForwardingMode(forwardingMode)
+	if err != nil {
+		Fatalf("Invalid forwarding mode: %s", err)
+	}
+	spec.ForwardingMode = string(mode)
+
+	if svcName != "" {
+		spec.Name = svcName
+	}
+	if svcNamespace != "" {
+		spec.Namespace = svcNamespace
+	}
+	if svcPortName != "" {
+		spec.PortName = svcPortName
+	}
+	if len(svcLabels) != 0 {
+		spec.Labels, err = parseServiceLabels(svcLabels)
+		if err != nil {
+			Fatalf("Invalid labels: %s", err)
+		}
+	}
+
+	healthConfig, err := types.NewHealthCheckConfig(healthCheck, healthCheckPath, int64(healthInterval), int64(healthTimeout))
+	if err != nil {
+		Fatalf("Invalid health check: %s", err)
+	}
+	spec.HealthCheck = nil
+	if healthConfig.IsEnabled() {
+		spec.HealthCheck = &models.ServiceHealthCheck{
+			Type:     string(healthConfig.Type),
+			Path:     healthConfig.Path,
+			Interval: int64(healthConfig.Interval / time.Second),
+			Timeout:  int64(healthConfig.Timeout / time.Second),
+		}
+	}
+
 	if len(backends) == 0 {
 		fmt.Printf("Reading backend list from stdin...\n")
 
@@ -147,4 +217,19 @@ func updateService(cmd *cobra.Command, args []string) {
 	} else {
 		fmt.Printf("Updated service with %d backends\n", len(spec.BackendAddresses))
 	}
+
+	if faIP == nil || faIP.IsUnspecified() {
+		svc, err := client.GetServiceID(id)
+		if err != nil {
+			Fatalf("Cannot get allocated frontend address: %s", err)
+		}
+		if svc.Status == nil || svc.Status.Realized == nil {
+			Fatalf("Cannot get allocated frontend address of service %d: empty state", id)
+		}
+		feA, err := types.NewL3n4AddrFromModel(svc.Status.Realized.FrontendAddress)
+		if err != nil {
+			Fatalf("Cannot parse allocated frontend address: %s", err)
+		}
+		fmt.Printf("Allocated frontend address %s\n", feA.String())
+	}
 }
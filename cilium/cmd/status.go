@@ -42,6 +42,7 @@ var (
 	allNodes       bool
 	allRedirects   bool
 	brief          bool
+	kvstoreOps     bool
 	healthLines    = 10
 )
 
@@ -53,7 +54,8 @@ func init() {
 	statusCmd.Flags().BoolVar(&allNodes, "all-nodes", false, "Show all nodes, not just localhost")
 	statusCmd.Flags().BoolVar(&allRedirects, "all-redirects", false, "Show all redirects")
 	statusCmd.Flags().BoolVar(&brief, "brief", false, "Only print a one-line status message")
-	statusCmd.Flags().BoolVar(&verbose, "verbose", false, "Equivalent to --all-addresses --all-controllers --all-nodes --all-health")
+	statusCmd.Flags().BoolVar(&kvstoreOps, "kvstore-operations", false, "Show statistics of kvstore operations")
+	statusCmd.Flags().BoolVar(&verbose, "verbose", false, "Equivalent to --all-addresses --all-controllers --all-nodes --all-health --kvstore-operations")
 	command.AddJSONOutput(statusCmd)
 }
 
@@ -64,6 +66,7 @@ func statusDaemon() {
 		allHealth = true
 		allNodes = true
 		allRedirects = true
+		kvstoreOps = true
 	}
 	if allHealth {
 		healthLines = 0
@@ -85,6 +88,9 @@ func statusDaemon() {
 		sr := resp.Payload
 		w := tabwriter.NewWriter(os.Stdout, 2, 0, 3, ' ', 0)
 		pkg.FormatStatusResponse(w, sr, allAddresses, allControllers, allNodes, allRedirects)
+		if kvstoreOps {
+			pkg.FormatKvstoreOperations(w, sr)
+		}
 		w.Flush()
 
 		state := sr.Cilium.State
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/cilium/cilium/api/v1/models"
 	"github.com/cilium/cilium/pkg/lock"
@@ -51,17 +52,357 @@ type ServiceID uint16
 type LBBackEnd struct {
 	L3n4Addr
 	Weight uint16
+	// Health is the result of the health checks of the backend, it is
+	// empty if the service is not health checked.
+	Health BackendHealth
+	// DrainDeadline is the time until which a removed backend keeps
+	// serving its established connections, it is zero if the backend
+	// is not draining.
+	DrainDeadline time.Time
+	// Cluster is the name of the cluster the backend belongs to, it is
+	// only set for the backends of global services.
+	Cluster string
+}
+
+// IsDraining returns true if the backend has been removed from the service
+// and only serves its established connections.
+func (lbbe *LBBackEnd) IsDraining() bool {
+	return !lbbe.DrainDeadline.IsZero()
 }
 
 func (lbbe *LBBackEnd) String() string {
 	return fmt.Sprintf("%s, weight: %d", lbbe.L3n4Addr.String(), lbbe.Weight)
 }
 
+// FrontendType is the type of a service frontend.
+type FrontendType string
+
+const (
+	// FrontendTypeClusterIP is the cluster IP of a service.
+	FrontendTypeClusterIP = FrontendType("ClusterIP")
+	// FrontendTypeNodePort is a node address with the node port of a
+	// service.
+	FrontendTypeNodePort = FrontendType("NodePort")
+	// FrontendTypeExternalIP is an external IP of a service.
+	FrontendTypeExternalIP = FrontendType("ExternalIP")
+	// FrontendTypeLoadBalancer is an ingress IP of a service assigned by
+	// an external load balancer.
+	FrontendTypeLoadBalancer = FrontendType("LoadBalancer")
+)
+
+// SessionAffinity is the session affinity mode of a service.
+type SessionAffinity string
+
+const (
+	// SessionAffinityNone selects a backend for each connection
+	// independently.
+	SessionAffinityNone = SessionAffinity("None")
+	// SessionAffinityClientIP selects the same backend for all
+	// connections of a client IP until the client has been idle for the
+	// session affinity timeout.
+	SessionAffinityClientIP = SessionAffinity("ClientIP")
+
+	// DefaultSessionAffinityTimeoutSec is the session affinity timeout
+	// used if none is specified, it matches the Kubernetes default.
+	DefaultSessionAffinityTimeoutSec = 10800
+)
+
+// SessionAffinityConfig is the session affinity configuration of a service.
+type SessionAffinityConfig struct {
+	Mode SessionAffinity
+	// TimeoutSec is the idle time in seconds after which a client may be
+	// assigned a different backend.
+	TimeoutSec uint32
+}
+
+// NewSessionAffinityConfig returns the session affinity configuration for
+// the given mode and timeout. The default timeout is used if timeoutSec is
+// 0.
+func NewSessionAffinityConfig(mode string, timeoutSec uint32) (SessionAffinityConfig, error) {
+	switch SessionAffinity(mode) {
+	case "", SessionAffinityNone:
+		return SessionAffinityConfig{Mode: SessionAffinityNone}, nil
+	case SessionAffinityClientIP:
+		if timeoutSec == 0 {
+			timeoutSec = DefaultSessionAffinityTimeoutSec
+		}
+		return SessionAffinityConfig{Mode: SessionAffinityClientIP, TimeoutSec: timeoutSec}, nil
+	default:
+		return SessionAffinityConfig{}, fmt.Errorf("unknown session affinity %q", mode)
+	}
+}
+
+// GetTimeoutSec returns the session affinity timeout in seconds, or 0 if
+// session affinity is disabled.
+func (c SessionAffinityConfig) GetTimeoutSec() uint32 {
+	if c.Mode != SessionAffinityClientIP {
+		return 0
+	}
+	return c.TimeoutSec
+}
+
+// BackendHealth is the health of a service backend.
+type BackendHealth string
+
+const (
+	// BackendHealthUnknown is the health of a backend which has not been
+	// probed yet, it keeps receiving traffic.
+	BackendHealthUnknown = BackendHealth("unknown")
+	// BackendHealthHealthy is the health of a backend which passes its
+	// health checks.
+	BackendHealthHealthy = BackendHealth("healthy")
+	// BackendHealthUnhealthy is the health of a backend which fails its
+	// health checks, it is removed from the BPF load balancer.
+	BackendHealthUnhealthy = BackendHealth("unhealthy")
+)
+
+// HealthCheckType is the type of probe used to health check the backends of
+// a service.
+type HealthCheckType string
+
+const (
+	// HealthCheckNone disables health checking.
+	HealthCheckNone = HealthCheckType("")
+	// HealthCheckTCP probes a backend by opening a TCP connection to it.
+	HealthCheckTCP = HealthCheckType("tcp")
+	// HealthCheckHTTP probes a backend with an HTTP GET request, any
+	// 2xx or 3xx response is considered healthy.
+	HealthCheckHTTP = HealthCheckType("http")
+
+	// DefaultHealthCheckInterval is the interval between probes used if
+	// none is specified.
+	DefaultHealthCheckInterval = 10 * time.Second
+	// DefaultHealthCheckTimeout is the probe timeout used if none is
+	// specified.
+	DefaultHealthCheckTimeout = 2 * time.Second
+)
+
+// HealthCheckConfig is the health check configuration of a service.
+type HealthCheckConfig struct {
+	Type HealthCheckType
+	// Path is the path requested by HTTP probes.
+	Path string
+	// Interval is the time between two probes of a backend.
+	Interval time.Duration
+	// Timeout is the time after which a probe is considered failed.
+	Timeout time.Duration
+}
+
+// NewHealthCheckConfig returns the health check configuration for the given
+// probe type, HTTP path, interval and timeout in seconds. The defaults are
+// used for an empty path and for an interval or timeout of 0.
+func NewHealthCheckConfig(checkType, path string, intervalSec, timeoutSec int64) (HealthCheckConfig, error) {
+	if intervalSec < 0 || timeoutSec < 0 {
+		return HealthCheckConfig{}, fmt.Errorf("invalid health check interval %d or timeout %d", intervalSec, timeoutSec)
+	}
+
+	cfg := HealthCheckConfig{
+		Type:     HealthCheckType(strings.ToLower(checkType)),
+		Interval: time.Duration(intervalSec) * time.Second,
+		Timeout:  time.Duration(timeoutSec) * time.Second,
+	}
+
+	switch cfg.Type {
+	case HealthCheckNone:
+		return HealthCheckConfig{}, nil
+	case HealthCheckTCP:
+	case HealthCheckHTTP:
+		cfg.Path = path
+		if !strings.HasPrefix(cfg.Path, "/") {
+			cfg.Path = "/" + cfg.Path
+		}
+	default:
+		return HealthCheckConfig{}, fmt.Errorf("unknown health check type %q", checkType)
+	}
+
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultHealthCheckInterval
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultHealthCheckTimeout
+	}
+	if cfg.Timeout > cfg.Interval {
+		return HealthCheckConfig{}, fmt.Errorf("health check timeout %s exceeds interval %s", cfg.Timeout, cfg.Interval)
+	}
+
+	return cfg, nil
+}
+
+// IsEnabled returns true if the backends are health checked.
+func (c HealthCheckConfig) IsEnabled() bool {
+	return c.Type != HealthCheckNone
+}
+
+// ForwardingMode is the mode in which the traffic of a service is forwarded
+// to its backends.
+type ForwardingMode string
+
+const (
+	// ForwardingModeNAT translates the frontend to the backend and the
+	// replies back to the frontend on the balancing node.
+	ForwardingModeNAT = ForwardingMode("NAT")
+	// ForwardingModeDSR carries the frontend to the backend node which
+	// translates the replies itself, they are sent directly to the client
+	// without passing the balancing node again.
+	ForwardingModeDSR = ForwardingMode("DSR")
+)
+
+// NewForwardingMode returns the forwarding mode for the given mode, NAT is
+// used if mode is empty.
+func NewForwardingMode(mode string) (ForwardingMode, error) {
+	switch ForwardingMode(mode) {
+	case "", ForwardingModeNAT:
+		return ForwardingModeNAT, nil
+	case ForwardingModeDSR:
+		return ForwardingModeDSR, nil
+	default:
+		return "", fmt.Errorf("unknown forwarding mode %q", mode)
+	}
+}
+
+// IsDSR returns true if the replies are sent directly to the client.
+func (m ForwardingMode) IsDSR() bool {
+	return m == ForwardingModeDSR
+}
+
+// ServiceSource is the origin of a service.
+type ServiceSource string
+
+const (
+	// ServiceSourceAPI is the source of services created via the REST API.
+	ServiceSourceAPI = ServiceSource("api")
+	// ServiceSourceK8s is the source of services derived from k8s
+	// services.
+	ServiceSourceK8s = ServiceSource("k8s")
+)
+
+// NewServiceSource returns the service source for the given source, the API
+// is used if source is empty.
+func NewServiceSource(source string) (ServiceSource, error) {
+	switch ServiceSource(strings.ToLower(source)) {
+	case "", ServiceSourceAPI:
+		return ServiceSourceAPI, nil
+	case ServiceSourceK8s:
+		return ServiceSourceK8s, nil
+	default:
+		return "", fmt.Errorf("unknown service source %q", source)
+	}
+}
+
+// ServiceMeta is the metadata of a service which is not part of the
+// datapath, it maps a frontend back to the service it was created for.
+type ServiceMeta struct {
+	Name      string            `json:"name,omitempty"`
+	Namespace string            `json:"namespace,omitempty"`
+	PortName  FEPortName        `json:"portName,omitempty"`
+	Source    ServiceSource     `json:"source,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// IsEmpty returns true if no metadata is set.
+func (m *ServiceMeta) IsEmpty() bool {
+	return m.Name == "" && m.Namespace == "" && m.PortName == "" &&
+		m.Source == "" && len(m.Labels) == 0
+}
+
+// Matches returns true if the metadata matches all non-empty fields of
+// filter and contains all labels of filter.
+func (m *ServiceMeta) Matches(filter ServiceMeta) bool {
+	if (filter.Name != "" && filter.Name != m.Name) ||
+		(filter.Namespace != "" && filter.Namespace != m.Namespace) ||
+		(filter.PortName != "" && filter.PortName != m.PortName) ||
+		(filter.Source != "" && filter.Source != m.Source) {
+		return false
+	}
+	for k, v := range filter.Labels {
+		if value, ok := m.Labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
 // LBSVC is essentially used for the REST API.
 type LBSVC struct {
-	Sha256 string
-	FE     L3n4AddrID
-	BES    []LBBackEnd
+	Sha256      string
+	FE          L3n4AddrID
+	BES         []LBBackEnd
+	Type        FrontendType
+	Affinity    SessionAffinityConfig
+	Mode        ForwardingMode
+	HealthCheck HealthCheckConfig
+	Meta        ServiceMeta
+}
+
+// GetHealthyBackends returns the backends of the service which receive
+// new connections, which excludes the draining backends and the unhealthy
+// backends unless all backends are unhealthy. In that case all backends
+// which are not draining are returned as dropping all traffic would not be
+// any better.
+func (s *LBSVC) GetHealthyBackends() []LBBackEnd {
+	active := []LBBackEnd{}
+	healthy := []LBBackEnd{}
+	for _, be := range s.BES {
+		if be.IsDraining() {
+			continue
+		}
+		active = append(active, be)
+		if be.Health != BackendHealthUnhealthy {
+			healthy = append(healthy, be)
+		}
+	}
+	if len(healthy) == 0 {
+		return active
+	}
+	return healthy
+}
+
+// GetDrainingBackends returns the backends of the service which no longer
+// receive new connections but keep serving their established connections.
+func (s *LBSVC) GetDrainingBackends() []LBBackEnd {
+	draining := []LBBackEnd{}
+	for _, be := range s.BES {
+		if be.IsDraining() {
+			draining = append(draining, be)
+		}
+	}
+	return draining
+}
+
+// SelectLocalBackends returns the backends in bes for which isLocal returns
+// true, or all backends if none of them is local.
+func SelectLocalBackends(bes []LBBackEnd, isLocal func(ip net.IP) bool) []LBBackEnd {
+	local := []LBBackEnd{}
+	for _, be := range bes {
+		if isLocal(be.IP) {
+			local = append(local, be)
+		}
+	}
+	if len(local) == 0 {
+		return bes
+	}
+	return local
+}
+
+// AddDrainingBackends appends the backends in old which are no longer
+// backends of the service as draining backends. Backends which were already
+// draining keep their deadline, the other ones drain until deadline.
+func (s *LBSVC) AddDrainingBackends(old []LBBackEnd, deadline time.Time) {
+	cur := map[string]bool{}
+	for _, be := range s.BES {
+		cur[be.L3n4Addr.String()] = true
+	}
+
+	for _, be := range old {
+		if cur[be.L3n4Addr.String()] {
+			continue
+		}
+		if !be.IsDraining() {
+			be.DrainDeadline = deadline
+		}
+		be.Health = ""
+		s.BES = append(s.BES, be)
+	}
 }
 
 func (s *LBSVC) GetModel() *models.Service {
@@ -76,6 +417,39 @@ func (s *LBSVC) GetModel() *models.Service {
 		BackendAddresses: make([]*models.BackendAddress, len(s.BES)),
 	}
 
+	if s.Type != "" {
+		spec.FrontendType = string(s.Type)
+	}
+
+	if timeout := s.Affinity.GetTimeoutSec(); timeout != 0 {
+		spec.SessionAffinity = string(s.Affinity.Mode)
+		spec.SessionAffinityTimeout = int64(timeout)
+	}
+
+	if s.Mode.IsDSR() {
+		spec.ForwardingMode = string(s.Mode)
+	}
+
+	spec.Name = s.Meta.Name
+	spec.Namespace = s.Meta.Namespace
+	spec.PortName = string(s.Meta.PortName)
+	spec.Source = string(s.Meta.Source)
+	if len(s.Meta.Labels) != 0 {
+		spec.Labels = make(map[string]string, len(s.Meta.Labels))
+		for k, v := range s.Meta.Labels {
+			spec.Labels[k] = v
+		}
+	}
+
+	if s.HealthCheck.IsEnabled() {
+		spec.HealthCheck = &models.ServiceHealthCheck{
+			Type:     string(s.HealthCheck.Type),
+			Path:     s.HealthCheck.Path,
+			Interval: int64(s.HealthCheck.Interval / time.Second),
+			Timeout:  int64(s.HealthCheck.Timeout / time.Second),
+		}
+	}
+
 	for i, be := range s.BES {
 		spec.BackendAddresses[i] = be.GetBackendModel()
 	}
@@ -110,6 +484,10 @@ type LoadBalancer struct {
 	K8sServices  map[K8sServiceNamespace]*K8sServiceInfo
 	K8sEndpoints map[K8sServiceNamespace]*K8sServiceEndpoint
 	K8sIngress   map[K8sServiceNamespace]*K8sServiceInfo
+
+	// RemoteK8sEndpoints are the endpoints of global services in remote
+	// clusters indexed by the name of the cluster.
+	RemoteK8sEndpoints map[K8sServiceNamespace]map[string]*K8sServiceEndpoint
 }
 
 // AddService adds a service to list of loadbalancers and returns true if created.
@@ -161,6 +539,8 @@ func NewLoadBalancer() *LoadBalancer {
 		K8sServices:  map[K8sServiceNamespace]*K8sServiceInfo{},
 		K8sEndpoints: map[K8sServiceNamespace]*K8sServiceEndpoint{},
 		K8sIngress:   map[K8sServiceNamespace]*K8sServiceInfo{},
+
+		RemoteK8sEndpoints: map[K8sServiceNamespace]map[string]*K8sServiceEndpoint{},
 	}
 }
 
@@ -178,6 +558,29 @@ type K8sServiceInfo struct {
 	Ports      map[FEPortName]*FEPort
 	Labels     map[string]string
 	Selector   map[string]string
+	Affinity   SessionAffinityConfig
+
+	// Frontends are the frontends of the service in addition to the
+	// cluster IP with each of the Ports.
+	Frontends []*K8sFrontend
+
+	// ExternalTrafficLocal restricts the backends of the frontends
+	// reachable from outside of the cluster to the local backends.
+	ExternalTrafficLocal bool
+
+	// PreferLocal restricts the backends of all frontends to the local
+	// backends.
+	PreferLocal bool
+
+	// Global shares the backends of the service with the services of the
+	// same name and namespace in all other clusters.
+	Global bool
+}
+
+// PrefersLocalBackends returns true if the frontends of type feType must only
+// use the backends on the local node, as long as there are any.
+func (si *K8sServiceInfo) PrefersLocalBackends(feType FrontendType) bool {
+	return si.PreferLocal || (si.ExternalTrafficLocal && feType != FrontendTypeClusterIP)
 }
 
 // IsExternal returns true if the service is expected to serve out-of-cluster endpoints:
@@ -196,6 +599,36 @@ func NewK8sServiceInfo(ip net.IP, headless bool, labels map[string]string, selec
 	}
 }
 
+// K8sFrontend is a frontend of a k8s service in addition to its cluster IP,
+// such as a node port or an external IP. The backends of the frontend are
+// the endpoints of the service port PortName.
+type K8sFrontend struct {
+	*FEPort
+	Type     FrontendType
+	IP       net.IP
+	PortName FEPortName
+}
+
+// NewK8sFrontend creates a new K8sFrontend with the ID set to 0.
+func NewK8sFrontend(feType FrontendType, ip net.IP, protocol L4Type, portNumber uint16, portName FEPortName) (*K8sFrontend, error) {
+	fePort, err := NewFEPort(protocol, portNumber)
+	if err != nil {
+		return nil, err
+	}
+	return &K8sFrontend{
+		FEPort:   fePort,
+		Type:     feType,
+		IP:       ip,
+		PortName: portName,
+	}, nil
+}
+
+// String returns the address of the frontend in the "IP:Port" format.
+func (f *K8sFrontend) String() string {
+	addr := L3n4Addr{IP: f.IP, L4Addr: *f.L4Addr}
+	return addr.String()
+}
+
 // K8sServiceEndpoint is an abstraction for the k8s endpoint object. Each service is
 // composed by a set of backend IPs (BEIPs) and a map of Ports (Ports). Each k8s endpoint
 // present in BEIPs share the same list of Ports open.
@@ -371,9 +804,12 @@ func (b *LBBackEnd) GetBackendModel() *models.BackendAddress {
 
 	ip := b.IP.String()
 	return &models.BackendAddress{
-		IP:     &ip,
-		Port:   b.Port,
-		Weight: b.Weight,
+		IP:       &ip,
+		Port:     b.Port,
+		Weight:   b.Weight,
+		Health:   string(b.Health),
+		Draining: b.IsDraining(),
+		Cluster:  b.Cluster,
 	}
 }
 
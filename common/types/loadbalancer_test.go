@@ -15,7 +15,9 @@
 package types
 
 import (
+	"net"
 	"testing"
+	"time"
 
 	"gopkg.in/check.v1"
 )
@@ -37,3 +39,245 @@ func (s *TypesSuite) TestIsK8ServiceExternal(c *check.C) {
 	si.Selector = map[string]string{"l": "v"}
 	c.Assert(si.IsExternal(), check.Equals, false)
 }
+
+func (s *TypesSuite) TestNewSessionAffinityConfig(c *check.C) {
+	cfg, err := NewSessionAffinityConfig("", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.Mode, check.Equals, SessionAffinityNone)
+	c.Assert(cfg.GetTimeoutSec(), check.Equals, uint32(0))
+
+	cfg, err = NewSessionAffinityConfig("None", 60)
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.GetTimeoutSec(), check.Equals, uint32(0))
+
+	cfg, err = NewSessionAffinityConfig("ClientIP", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.Mode, check.Equals, SessionAffinityClientIP)
+	c.Assert(cfg.GetTimeoutSec(), check.Equals, uint32(DefaultSessionAffinityTimeoutSec))
+
+	cfg, err = NewSessionAffinityConfig("ClientIP", 60)
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.GetTimeoutSec(), check.Equals, uint32(60))
+
+	_, err = NewSessionAffinityConfig("Cookie", 60)
+	c.Assert(err, check.Not(check.IsNil))
+}
+
+func (s *TypesSuite) TestLBSVCGetModelSessionAffinity(c *check.C) {
+	svc := &LBSVC{}
+	c.Assert(svc.GetModel().Spec.SessionAffinity, check.Equals, "")
+
+	svc.Affinity = SessionAffinityConfig{Mode: SessionAffinityClientIP, TimeoutSec: 60}
+	spec := svc.GetModel().Spec
+	c.Assert(spec.SessionAffinity, check.Equals, "ClientIP")
+	c.Assert(spec.SessionAffinityTimeout, check.Equals, int64(60))
+}
+
+func (s *TypesSuite) TestNewForwardingMode(c *check.C) {
+	mode, err := NewForwardingMode("")
+	c.Assert(err, check.IsNil)
+	c.Assert(mode, check.Equals, ForwardingModeNAT)
+	c.Assert(mode.IsDSR(), check.Equals, false)
+
+	mode, err = NewForwardingMode("DSR")
+	c.Assert(err, check.IsNil)
+	c.Assert(mode.IsDSR(), check.Equals, true)
+
+	_, err = NewForwardingMode("Tunnel")
+	c.Assert(err, check.Not(check.IsNil))
+}
+
+func (s *TypesSuite) TestLBSVCGetModelForwardingMode(c *check.C) {
+	svc := &LBSVC{Mode: ForwardingModeNAT}
+	c.Assert(svc.GetModel().Spec.ForwardingMode, check.Equals, "")
+
+	svc.Mode = ForwardingModeDSR
+	c.Assert(svc.GetModel().Spec.ForwardingMode, check.Equals, "DSR")
+}
+
+func (s *TypesSuite) TestNewServiceSource(c *check.C) {
+	source, err := NewServiceSource("")
+	c.Assert(err, check.IsNil)
+	c.Assert(source, check.Equals, ServiceSourceAPI)
+
+	source, err = NewServiceSource("K8s")
+	c.Assert(err, check.IsNil)
+	c.Assert(source, check.Equals, ServiceSourceK8s)
+
+	_, err = NewServiceSource("etcd")
+	c.Assert(err, check.Not(check.IsNil))
+}
+
+func (s *TypesSuite) TestServiceMetaMatches(c *check.C) {
+	meta := ServiceMeta{
+		Name:      "foo",
+		Namespace: "default",
+		PortName:  "http",
+		Source:    ServiceSourceK8s,
+		Labels:    map[string]string{"app": "foo", "tier": "web"},
+	}
+	c.Assert(meta.IsEmpty(), check.Equals, false)
+	c.Assert((&ServiceMeta{}).IsEmpty(), check.Equals, true)
+
+	c.Assert(meta.Matches(ServiceMeta{}), check.Equals, true)
+	c.Assert(meta.Matches(ServiceMeta{Name: "foo", Namespace: "default"}), check.Equals, true)
+	c.Assert(meta.Matches(ServiceMeta{Namespace: "kube-system"}), check.Equals, false)
+	c.Assert(meta.Matches(ServiceMeta{Source: ServiceSourceAPI}), check.Equals, false)
+	c.Assert(meta.Matches(ServiceMeta{Labels: map[string]string{"app": "foo"}}), check.Equals, true)
+	c.Assert(meta.Matches(ServiceMeta{Labels: map[string]string{"app": "bar"}}), check.Equals, false)
+	c.Assert(meta.Matches(ServiceMeta{Labels: map[string]string{"env": ""}}), check.Equals, false)
+}
+
+func (s *TypesSuite) TestLBSVCGetModelMeta(c *check.C) {
+	svc := &LBSVC{}
+	spec := svc.GetModel().Spec
+	c.Assert(spec.Name, check.Equals, "")
+	c.Assert(spec.Labels, check.IsNil)
+
+	svc.Meta = ServiceMeta{
+		Name:      "foo",
+		Namespace: "default",
+		PortName:  "http",
+		Source:    ServiceSourceK8s,
+		Labels:    map[string]string{"app": "foo"},
+	}
+	spec = svc.GetModel().Spec
+	c.Assert(spec.Name, check.Equals, "foo")
+	c.Assert(spec.Namespace, check.Equals, "default")
+	c.Assert(spec.PortName, check.Equals, "http")
+	c.Assert(spec.Source, check.Equals, "k8s")
+	c.Assert(spec.Labels, check.DeepEquals, map[string]string{"app": "foo"})
+}
+
+func (s *TypesSuite) TestPrefersLocalBackends(c *check.C) {
+	si := NewK8sServiceInfo(net.ParseIP("10.96.0.1"), false, nil, nil)
+	c.Assert(si.PrefersLocalBackends(FrontendTypeClusterIP), check.Equals, false)
+	c.Assert(si.PrefersLocalBackends(FrontendTypeNodePort), check.Equals, false)
+
+	si.ExternalTrafficLocal = true
+	c.Assert(si.PrefersLocalBackends(FrontendTypeClusterIP), check.Equals, false)
+	c.Assert(si.PrefersLocalBackends(FrontendTypeNodePort), check.Equals, true)
+	c.Assert(si.PrefersLocalBackends(FrontendTypeLoadBalancer), check.Equals, true)
+
+	si.PreferLocal = true
+	c.Assert(si.PrefersLocalBackends(FrontendTypeClusterIP), check.Equals, true)
+}
+
+func (s *TypesSuite) TestSelectLocalBackends(c *check.C) {
+	bes := []LBBackEnd{
+		{L3n4Addr: L3n4Addr{IP: net.ParseIP("10.0.0.1")}},
+		{L3n4Addr: L3n4Addr{IP: net.ParseIP("10.0.1.1")}},
+		{L3n4Addr: L3n4Addr{IP: net.ParseIP("10.0.0.2")}},
+	}
+	isLocal := func(ip net.IP) bool {
+		_, local, _ := net.ParseCIDR("10.0.0.0/24")
+		return local.Contains(ip)
+	}
+
+	local := SelectLocalBackends(bes, isLocal)
+	c.Assert(len(local), check.Equals, 2)
+	c.Assert(local[0].IP.String(), check.Equals, "10.0.0.1")
+	c.Assert(local[1].IP.String(), check.Equals, "10.0.0.2")
+
+	// All backends are used if none is local
+	c.Assert(len(SelectLocalBackends(bes[1:2], isLocal)), check.Equals, 1)
+}
+
+func (s *TypesSuite) TestNewK8sFrontend(c *check.C) {
+	fe, err := NewK8sFrontend(FrontendTypeNodePort, net.ParseIP("192.168.0.1"), TCP, 30080, "http")
+	c.Assert(err, check.IsNil)
+	c.Assert(fe.Type, check.Equals, FrontendTypeNodePort)
+	c.Assert(fe.PortName, check.Equals, FEPortName("http"))
+	c.Assert(fe.String(), check.Equals, "192.168.0.1:30080")
+
+	_, err = NewK8sFrontend(FrontendTypeNodePort, net.ParseIP("192.168.0.1"), "SCTP", 30080, "http")
+	c.Assert(err, check.Not(check.IsNil))
+}
+
+func (s *TypesSuite) TestLBSVCGetModelFrontendType(c *check.C) {
+	svc := &LBSVC{}
+	c.Assert(svc.GetModel().Spec.FrontendType, check.Equals, "")
+
+	svc.Type = FrontendTypeExternalIP
+	c.Assert(svc.GetModel().Spec.FrontendType, check.Equals, "ExternalIP")
+}
+
+func (s *TypesSuite) TestNewHealthCheckConfig(c *check.C) {
+	cfg, err := NewHealthCheckConfig("", "/healthz", 5, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.IsEnabled(), check.Equals, false)
+
+	cfg, err = NewHealthCheckConfig("tcp", "", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg, check.Equals, HealthCheckConfig{
+		Type:     HealthCheckTCP,
+		Interval: DefaultHealthCheckInterval,
+		Timeout:  DefaultHealthCheckTimeout,
+	})
+
+	cfg, err = NewHealthCheckConfig("HTTP", "healthz", 5, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg, check.Equals, HealthCheckConfig{
+		Type:     HealthCheckHTTP,
+		Path:     "/healthz",
+		Interval: 5 * time.Second,
+		Timeout:  time.Second,
+	})
+
+	_, err = NewHealthCheckConfig("udp", "", 0, 0)
+	c.Assert(err, check.Not(check.IsNil))
+
+	_, err = NewHealthCheckConfig("tcp", "", 1, 5)
+	c.Assert(err, check.Not(check.IsNil))
+}
+
+func (s *TypesSuite) TestLBSVCGetHealthyBackends(c *check.C) {
+	svc := &LBSVC{
+		BES: []LBBackEnd{
+			{L3n4Addr: L3n4Addr{IP: net.ParseIP("10.0.0.1")}, Health: BackendHealthHealthy},
+			{L3n4Addr: L3n4Addr{IP: net.ParseIP("10.0.0.2")}, Health: BackendHealthUnhealthy},
+			{L3n4Addr: L3n4Addr{IP: net.ParseIP("10.0.0.3")}},
+		},
+	}
+	healthy := svc.GetHealthyBackends()
+	c.Assert(len(healthy), check.Equals, 2)
+	c.Assert(healthy[0].IP.String(), check.Equals, "10.0.0.1")
+	c.Assert(healthy[1].IP.String(), check.Equals, "10.0.0.3")
+
+	// All backends are used if none is healthy
+	svc.BES = svc.BES[1:2]
+	c.Assert(len(svc.GetHealthyBackends()), check.Equals, 1)
+}
+
+func (s *TypesSuite) TestLBSVCAddDrainingBackends(c *check.C) {
+	deadline := time.Now().Add(time.Minute)
+	earlier := deadline.Add(-30 * time.Second)
+	old := []LBBackEnd{
+		{L3n4Addr: L3n4Addr{IP: net.ParseIP("10.0.0.1")}},
+		{L3n4Addr: L3n4Addr{IP: net.ParseIP("10.0.0.2")}, Health: BackendHealthHealthy},
+		{L3n4Addr: L3n4Addr{IP: net.ParseIP("10.0.0.3")}, DrainDeadline: earlier},
+	}
+	svc := &LBSVC{
+		BES: []LBBackEnd{
+			{L3n4Addr: L3n4Addr{IP: net.ParseIP("10.0.0.1")}},
+		},
+	}
+
+	svc.AddDrainingBackends(old, deadline)
+	c.Assert(len(svc.BES), check.Equals, 3)
+	c.Assert(svc.BES[0].IsDraining(), check.Equals, false)
+	c.Assert(svc.BES[1].DrainDeadline, check.Equals, deadline)
+	c.Assert(svc.BES[1].Health, check.Equals, BackendHealth(""))
+	c.Assert(svc.BES[2].DrainDeadline, check.Equals, earlier)
+
+	c.Assert(len(svc.GetHealthyBackends()), check.Equals, 1)
+	draining := svc.GetDrainingBackends()
+	c.Assert(len(draining), check.Equals, 2)
+	c.Assert(draining[0].IP.String(), check.Equals, "10.0.0.2")
+
+	// Draining backends are not used even if all backends are unhealthy
+	svc.BES[0].Health = BackendHealthUnhealthy
+	healthy := svc.GetHealthyBackends()
+	c.Assert(len(healthy), check.Equals, 1)
+	c.Assert(healthy[0].IP.String(), check.Equals, "10.0.0.1")
+}
@@ -0,0 +1,213 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/cilium/cilium/pkg/controller"
+	"github.com/cilium/cilium/pkg/ipam/clusterpool"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/node"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// clusterPoolControllers manages the controllers renewing the leases of
+	// the allocation ranges of the node
+	clusterPoolControllers = controller.NewManager()
+
+	// leasedAllocRangesMutex protects leasedAllocRanges
+	leasedAllocRangesMutex lock.Mutex
+
+	// leasedAllocRanges holds the allocators of all allocation ranges leased
+	// by the node and renewed by a controller, indexed by allocation range
+	leasedAllocRanges = map[string]*clusterpool.Allocator{}
+
+	// lostAllocRangesMutex protects lostAllocRanges
+	lostAllocRangesMutex lock.Mutex
+
+	// lostAllocRanges holds the errors of all allocation ranges of the node
+	// which have been leased by other nodes, indexed by allocation range
+	lostAllocRanges = map[string]error{}
+)
+
+// leaseAllocRange leases an allocation range of maskSize out of the cluster
+// pool cidr, preferring the ranges containing hints, and starts a controller
+// renewing the lease.
+func leaseAllocRange(cidr string, maskSize int, hints ...net.IP) (*net.IPNet, error) {
+	_, cluster, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cluster pool CIDR: %s", err)
+	}
+
+	allocator, err := clusterpool.NewAllocator(node.GetName(), cluster, maskSize, clusterPoolLease)
+	if err != nil {
+		return nil, err
+	}
+
+	allocRange, err := allocator.Acquire(hints...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to lease allocation range from cluster pool %s: %s", cluster, err)
+	}
+
+	log.WithFields(logrus.Fields{
+		"clusterPool":      cluster,
+		"allocRange":       allocRange,
+		"leaseDuration":    clusterPoolLease,
+		logfields.NodeName: node.GetName(),
+	}).Info("Leased allocation range from cluster pool")
+
+	leasedAllocRangesMutex.Lock()
+	leasedAllocRanges[allocRange.String()] = allocator
+	leasedAllocRangesMutex.Unlock()
+
+	clusterPoolControllers.UpdateController(clusterPoolControllerName(allocRange),
+		controller.ControllerParams{
+			DoFunc: func() error {
+				err := allocator.Renew(allocRange)
+				if _, ok := err.(*clusterpool.LeaseTakenError); ok {
+					log.WithError(err).WithField("allocRange", allocRange).
+						Error("Lost lease of allocation range to another node, the agent must be restarted to lease a new allocation range")
+
+					lostAllocRangesMutex.Lock()
+					lostAllocRanges[allocRange.String()] = err
+					lostAllocRangesMutex.Unlock()
+
+					// Renewing will never succeed again
+					stopAllocRangeLease(allocRange)
+					return controller.NewExitReason(err.Error())
+				}
+				return err
+			},
+			RunInterval: clusterPoolLease / 3,
+		})
+
+	return allocRange, nil
+}
+
+// clusterPoolControllerName returns the name of the controller renewing the
+// lease of allocRange
+func clusterPoolControllerName(allocRange *net.IPNet) string {
+	return "cluster-pool-lease-" + allocRange.String()
+}
+
+// stopAllocRangeLease removes the controller renewing the lease of
+// allocRange and returns the allocator which leased it, nil if allocRange is
+// not leased by the node.
+func stopAllocRangeLease(allocRange *net.IPNet) *clusterpool.Allocator {
+	leasedAllocRangesMutex.Lock()
+	defer leasedAllocRangesMutex.Unlock()
+
+	allocator, ok := leasedAllocRanges[allocRange.String()]
+	if !ok {
+		return nil
+	}
+	delete(leasedAllocRanges, allocRange.String())
+
+	if err := clusterPoolControllers.RemoveController(clusterPoolControllerName(allocRange)); err != nil {
+		log.WithError(err).WithField("allocRange", allocRange).
+			Warning("Unable to remove controller renewing the lease of allocation range")
+	}
+
+	return allocator
+}
+
+// releaseAllocRange stops renewing the lease of allocRange and releases it
+// back to the cluster pool.
+func releaseAllocRange(allocRange *net.IPNet) error {
+	allocator := stopAllocRangeLease(allocRange)
+	if allocator == nil {
+		return fmt.Errorf("allocation range %s is not leased by the node", allocRange)
+	}
+
+	if err := allocator.Release(allocRange); err != nil {
+		return fmt.Errorf("unable to release allocation range %s: %s", allocRange, err)
+	}
+
+	log.WithField("allocRange", allocRange).Info("Released allocation range to cluster pool")
+
+	return nil
+}
+
+// getClusterPoolStatus returns a failure status if the lease of any
+// allocation range of the node has been taken over by another node, nil
+// otherwise.
+func getClusterPoolStatus() *models.Status {
+	lostAllocRangesMutex.Lock()
+	defer lostAllocRangesMutex.Unlock()
+
+	if len(lostAllocRanges) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, 0, len(lostAllocRanges))
+	for _, err := range lostAllocRanges {
+		msgs = append(msgs, err.Error())
+	}
+	sort.Strings(msgs)
+
+	return &models.Status{
+		State: models.StatusStateFailure,
+		Msg:   "Cluster pool lease lost: " + strings.Join(msgs, ", "),
+	}
+}
+
+// leaseNodeAllocRanges leases the IPv4 and IPv6 allocation ranges of the node
+// from the cluster pools if configured. The allocation ranges used before or
+// retrieved from Kubernetes are leased if available. Allocation ranges
+// specified with --ipv4-range and --ipv6-range take precedence.
+func leaseNodeAllocRanges() error {
+	ipv4GW, ipv6Router := node.GetRestoredCiliumHostIPs()
+
+	if clusterPoolIPv4CIDR != "" && v4Prefix == AutoCIDR {
+		hints := []net.IP{ipv4GW}
+		if r := node.GetIPv4AllocRange(); r != nil {
+			hints = append(hints, r.IP)
+		}
+
+		allocRange, err := leaseAllocRange(clusterPoolIPv4CIDR, clusterPoolIPv4Mask, hints...)
+		if err != nil {
+			return err
+		}
+		node.SetIPv4AllocRange(allocRange)
+	}
+
+	if clusterPoolIPv6CIDR != "" && v6Prefix == AutoCIDR {
+		hints := []net.IP{ipv6Router}
+		if r := node.GetIPv6NodeRange(); r != nil {
+			hints = append(hints, r.IP)
+		}
+
+		allocRange, err := leaseAllocRange(clusterPoolIPv6CIDR, node.IPv6NodePrefixLen, hints...)
+		if err != nil {
+			return err
+		}
+		if err := node.SetIPv6NodeRange(allocRange); err != nil {
+			if err2 := releaseAllocRange(allocRange); err2 != nil {
+				log.WithError(err2).Warning("Unable to release unusable allocation range")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/cilium/cilium/pkg/ipam/clusterpool"
+
+	. "gopkg.in/check.v1"
+)
+
+func (ds *DaemonSuite) TestClusterPoolStatus(c *C) {
+	c.Assert(getClusterPoolStatus(), IsNil)
+
+	_, cidr, err := net.ParseCIDR("10.0.0.0/24")
+	c.Assert(err, IsNil)
+
+	lostAllocRangesMutex.Lock()
+	lostAllocRanges[cidr.String()] = &clusterpool.LeaseTakenError{CIDR: cidr, Node: "node2"}
+	lostAllocRangesMutex.Unlock()
+
+	st := getClusterPoolStatus()
+	c.Assert(st, Not(IsNil))
+	c.Assert(st.State, Equals, models.StatusStateFailure)
+	c.Assert(st.Msg, Matches, ".*10.0.0.0/24.*node2.*")
+
+	lostAllocRangesMutex.Lock()
+	delete(lostAllocRanges, cidr.String())
+	lostAllocRangesMutex.Unlock()
+}
+
+// hasClusterPoolController returns true if the controller renewing the lease
+// of allocRange exists
+func hasClusterPoolController(allocRange *net.IPNet) bool {
+	for _, status := range clusterPoolControllers.GetStatusModel() {
+		if status.Name == clusterPoolControllerName(allocRange) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ds *DaemonSuite) TestReleaseAllocRange(c *C) {
+	oldLease := clusterPoolLease
+	clusterPoolLease = time.Minute
+	defer func() { clusterPoolLease = oldLease }()
+
+	allocRange, err := leaseAllocRange("10.250.0.0/16", 24)
+	c.Assert(err, IsNil)
+	c.Assert(hasClusterPoolController(allocRange), Equals, true)
+
+	c.Assert(releaseAllocRange(allocRange), IsNil)
+	c.Assert(hasClusterPoolController(allocRange), Equals, false)
+
+	// The range is no longer leased by the node
+	c.Assert(releaseAllocRange(allocRange), Not(IsNil))
+
+	// The released range can be leased by other nodes
+	_, cluster, err := net.ParseCIDR("10.250.0.0/16")
+	c.Assert(err, IsNil)
+	allocator, err := clusterpool.NewAllocator("node2", cluster, 24, time.Minute)
+	c.Assert(err, IsNil)
+	other, err := allocator.Acquire(allocRange.IP)
+	c.Assert(err, IsNil)
+	c.Assert(other.String(), Equals, allocRange.String())
+	c.Assert(allocator.Release(other), IsNil)
+}
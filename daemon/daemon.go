@@ -39,6 +39,7 @@ import (
 	"github.com/cilium/cilium/pkg/apierror"
 	"github.com/cilium/cilium/pkg/bpf"
 	"github.com/cilium/cilium/pkg/byteorder"
+	"github.com/cilium/cilium/pkg/clustermesh"
 	"github.com/cilium/cilium/pkg/controller"
 	"github.com/cilium/cilium/pkg/endpoint"
 	"github.com/cilium/cilium/pkg/endpointmanager"
@@ -47,7 +48,9 @@ import (
 	"github.com/cilium/cilium/pkg/ipam"
 	"github.com/cilium/cilium/pkg/ipcache"
 	"github.com/cilium/cilium/pkg/k8s"
+	"github.com/cilium/cilium/pkg/kvstore"
 	"github.com/cilium/cilium/pkg/labels"
+	"github.com/cilium/cilium/pkg/lbhealth"
 	"github.com/cilium/cilium/pkg/lock"
 	"github.com/cilium/cilium/pkg/logging"
 	"github.com/cilium/cilium/pkg/logging/logfields"
@@ -62,10 +65,13 @@ import (
 	"github.com/cilium/cilium/pkg/maps/tunnel"
 	"github.com/cilium/cilium/pkg/monitor"
 	"github.com/cilium/cilium/pkg/node"
+	nodeStore "github.com/cilium/cilium/pkg/node/store"
 	"github.com/cilium/cilium/pkg/option"
 	"github.com/cilium/cilium/pkg/policy"
 	"github.com/cilium/cilium/pkg/proxy"
 	"github.com/cilium/cilium/pkg/proxy/logger"
+	serviceStore "github.com/cilium/cilium/pkg/service/store"
+	"github.com/cilium/cilium/pkg/service/vip"
 	"github.com/cilium/cilium/pkg/u8proto"
 	"github.com/cilium/cilium/pkg/workloads"
 
@@ -81,6 +87,10 @@ const (
 
 	// AutoCIDR indicates that a CIDR should be allocated
 	AutoCIDR = "auto"
+
+	// agentElectionName is the name of the leader election among all
+	// agents of the cluster
+	agentElectionName = "agent"
 )
 
 const (
@@ -92,6 +102,7 @@ const (
 	initArgDevice
 	initArgDevicePreFilter
 	initArgModePreFilter
+	initArgNodePortDevices
 	initArgMax
 )
 
@@ -101,6 +112,7 @@ type Daemon struct {
 	buildEndpointChan chan *endpoint.Request
 	l7Proxy           *proxy.Proxy
 	loadBalancer      *types.LoadBalancer
+	lbHealth          *lbhealth.Checker
 	policy            *policy.Repository
 	preFilter         *policy.PreFilter
 
@@ -125,6 +137,30 @@ type Daemon struct {
 
 	// ipcacheListeners lists all parties interested in IP -> ID mappings.
 	ipcacheListeners []ipcache.IPIdentityMappingListener
+
+	// nodeRegistrar publishes the local node into the kvstore
+	nodeRegistrar nodeStore.NodeRegistrar
+
+	// serviceRegistrar publishes the backends of global services of the
+	// local cluster into the kvstore
+	serviceRegistrar serviceStore.ServiceRegistrar
+
+	// vipAllocator allocates the frontend IPs of services created via the
+	// API from the service ranges
+	vipAllocator *vip.Allocator
+
+	// clustermesh is the connectivity to remote clusters, it is nil if
+	// no clustermesh configuration was provided
+	clustermesh *clustermesh.ClusterMesh
+
+	// serviceMetaChanged is true if the metadata of the services changed
+	// since it was last persisted. It is protected by
+	// loadBalancer.BPFMapMU.
+	serviceMetaChanged bool
+
+	// leaderElection elects the agent running cluster-wide chores which
+	// must only be performed by a single agent
+	leaderElection *kvstore.Election
 }
 
 // UpdateProxyRedirect updates the redirect rules in the proxy for a particular
@@ -714,6 +750,16 @@ func (d *Daemon) compileBase() error {
 		args[initArgMode] = option.Config.Tunnel
 	}
 
+	if option.Config.EnableNodePort {
+		// The frontends of services are translated for traffic
+		// entering the node on all devices with node addresses
+		devices, err := node.GetLocalAddressDevices()
+		if err != nil {
+			return fmt.Errorf("unable to list the network devices of the node: %s", err)
+		}
+		args[initArgNodePortDevices] = strings.Join(devices, ",")
+	}
+
 	prog := filepath.Join(option.Config.BpfDir, "init.sh")
 	ctx, cancel := context.WithTimeout(context.Background(), ExecTimeout)
 	defer cancel()
@@ -813,6 +859,35 @@ func (d *Daemon) init() error {
 				RunInterval: 5 * time.Second,
 			})
 
+		// Start the controller for periodic sync of the traffic
+		// statistics of services with the prometheus server.
+		if lbmap.IsStatsEnabled() {
+			statsSync := newServiceStatsSync()
+			controller.NewManager().UpdateController("lb-stats-prom-sync",
+				controller.ControllerParams{
+					DoFunc:      func() error { return d.syncServiceStatsMetrics(statsSync) },
+					RunInterval: 5 * time.Second,
+				})
+		}
+
+		// Start the controller persisting the metadata of services
+		// once it changed.
+		controller.NewManager().UpdateController("lb-service-meta-sync",
+			controller.ControllerParams{
+				DoFunc:      d.syncServiceMeta,
+				RunInterval: 5 * time.Second,
+			})
+
+		// Start the controller removing the draining backends of
+		// services once their connections are closed.
+		if lbmap.IsDrainingEnabled() {
+			controller.NewManager().UpdateController("lb-backend-drain",
+				controller.ControllerParams{
+					DoFunc:      d.retireDrainedBackends,
+					RunInterval: 5 * time.Second,
+				})
+		}
+
 		if _, err := lbmap.Service6Map.OpenOrCreate(); err != nil {
 			return err
 		}
@@ -822,6 +897,34 @@ func (d *Daemon) init() error {
 		if _, err := lbmap.RRSeq6Map.OpenOrCreate(); err != nil {
 			return err
 		}
+		if lbmap.GetAlgorithm() == lbmap.AlgorithmMaglev {
+			if _, err := lbmap.Maglev6Map.OpenOrCreate(); err != nil {
+				return err
+			}
+		}
+		if lbmap.IsSessionAffinityEnabled() {
+			if _, err := lbmap.AffinityMatch6Map.OpenOrCreate(); err != nil {
+				return err
+			}
+			if _, err := lbmap.Affinity6Map.OpenOrCreate(); err != nil {
+				return err
+			}
+		}
+		if lbmap.IsDSREnabled() {
+			if _, err := lbmap.DSRMatch6Map.OpenOrCreate(); err != nil {
+				return err
+			}
+		}
+		if lbmap.IsStatsEnabled() {
+			if _, err := lbmap.Stats6Map.OpenOrCreate(); err != nil {
+				return err
+			}
+		}
+		if lbmap.IsDrainingEnabled() {
+			if _, err := lbmap.Conn6Map.OpenOrCreate(); err != nil {
+				return err
+			}
+		}
 		if !option.Config.IPv4Disabled {
 			if _, err := lbmap.Service4Map.OpenOrCreate(); err != nil {
 				return err
@@ -832,6 +935,34 @@ func (d *Daemon) init() error {
 			if _, err := lbmap.RRSeq4Map.OpenOrCreate(); err != nil {
 				return err
 			}
+			if lbmap.GetAlgorithm() == lbmap.AlgorithmMaglev {
+				if _, err := lbmap.Maglev4Map.OpenOrCreate(); err != nil {
+					return err
+				}
+			}
+			if lbmap.IsSessionAffinityEnabled() {
+				if _, err := lbmap.AffinityMatch4Map.OpenOrCreate(); err != nil {
+					return err
+				}
+				if _, err := lbmap.Affinity4Map.OpenOrCreate(); err != nil {
+					return err
+				}
+			}
+			if lbmap.IsDSREnabled() {
+				if _, err := lbmap.DSRMatch4Map.OpenOrCreate(); err != nil {
+					return err
+				}
+			}
+			if lbmap.IsStatsEnabled() {
+				if _, err := lbmap.Stats4Map.OpenOrCreate(); err != nil {
+					return err
+				}
+			}
+			if lbmap.IsDrainingEnabled() {
+				if _, err := lbmap.Conn4Map.OpenOrCreate(); err != nil {
+					return err
+				}
+			}
 		}
 		// Clean all lb entries
 		if !option.Config.RestoreState {
@@ -849,6 +980,34 @@ func (d *Daemon) init() error {
 			if err := lbmap.RRSeq6Map.DeleteAll(); err != nil {
 				return err
 			}
+			if lbmap.GetAlgorithm() == lbmap.AlgorithmMaglev {
+				if err := lbmap.Maglev6Map.DeleteAll(); err != nil {
+					return err
+				}
+			}
+			if lbmap.IsSessionAffinityEnabled() {
+				if err := lbmap.AffinityMatch6Map.DeleteAll(); err != nil {
+					return err
+				}
+				if err := lbmap.Affinity6Map.DeleteAll(); err != nil {
+					return err
+				}
+			}
+			if lbmap.IsDSREnabled() {
+				if err := lbmap.DSRMatch6Map.DeleteAll(); err != nil {
+					return err
+				}
+			}
+			if lbmap.IsStatsEnabled() {
+				if err := lbmap.Stats6Map.DeleteAll(); err != nil {
+					return err
+				}
+			}
+			if lbmap.IsDrainingEnabled() {
+				if err := lbmap.Conn6Map.DeleteAll(); err != nil {
+					return err
+				}
+			}
 
 			if !option.Config.IPv4Disabled {
 				if err := lbmap.Service4Map.DeleteAll(); err != nil {
@@ -857,6 +1016,34 @@ func (d *Daemon) init() error {
 				if err := lbmap.RRSeq4Map.DeleteAll(); err != nil {
 					return err
 				}
+				if lbmap.GetAlgorithm() == lbmap.AlgorithmMaglev {
+					if err := lbmap.Maglev4Map.DeleteAll(); err != nil {
+						return err
+					}
+				}
+				if lbmap.IsSessionAffinityEnabled() {
+					if err := lbmap.AffinityMatch4Map.DeleteAll(); err != nil {
+						return err
+					}
+					if err := lbmap.Affinity4Map.DeleteAll(); err != nil {
+						return err
+					}
+				}
+				if lbmap.IsDSREnabled() {
+					if err := lbmap.DSRMatch4Map.DeleteAll(); err != nil {
+						return err
+					}
+				}
+				if lbmap.IsStatsEnabled() {
+					if err := lbmap.Stats4Map.DeleteAll(); err != nil {
+						return err
+					}
+				}
+				if lbmap.IsDrainingEnabled() {
+					if err := lbmap.Conn4Map.DeleteAll(); err != nil {
+						return err
+					}
+				}
 			}
 		}
 	}
@@ -923,6 +1110,32 @@ func createNodeConfigHeaderfile() error {
 	fmt.Fprintf(fw, "#define CLUSTER_ID %d\n", identity.GetReservedID(labels.IDNameCluster))
 	fmt.Fprintf(fw, "#define LB_RR_MAX_SEQ %d\n", lbmap.MaxSeq)
 	fmt.Fprintf(fw, "#define CILIUM_LB_MAP_MAX_ENTRIES %d\n", lbmap.MaxEntries)
+	fmt.Fprintf(fw, "#define LB_MAGLEV_LUT_SIZE %d\n", lbmap.MaglevTableSize)
+	fmt.Fprintf(fw, "#define CILIUM_LB_MAGLEV_MAP_MAX_ENTRIES %d\n", lbmap.MaxMaglevEntries)
+	if lbmap.GetAlgorithm() == lbmap.AlgorithmMaglev {
+		fw.WriteString("#define LB_SELECTION_MAGLEV\n")
+	}
+	if lbmap.IsSessionAffinityEnabled() {
+		fmt.Fprintf(fw, "#define CILIUM_LB_AFFINITY_MAP_MAX_ENTRIES %d\n", lbmap.MaxAffinityEntries)
+		fw.WriteString("#define ENABLE_SESSION_AFFINITY\n")
+	}
+	if lbmap.IsDSREnabled() {
+		fmt.Fprintf(fw, "#define CILIUM_LB_DSR_MAP_MAX_ENTRIES %d\n", lbmap.MaxDSREntries)
+		fw.WriteString("#define ENABLE_DSR\n")
+	}
+	if lbmap.IsStatsEnabled() {
+		fmt.Fprintf(fw, "#define CILIUM_LB_STATS_MAP_MAX_ENTRIES %d\n", lbmap.MaxStatsEntries)
+		fw.WriteString("#define ENABLE_LB_STATS\n")
+	}
+	if option.Config.EnableNodePort {
+		fmt.Fprintf(fw, "#define NODEPORT_PORT_MIN %d\n", option.Config.NodePortMin)
+		fmt.Fprintf(fw, "#define NODEPORT_PORT_MAX %d\n", option.Config.NodePortMax)
+		fw.WriteString("#define ENABLE_NODEPORT\n")
+	}
+	if lbmap.IsDrainingEnabled() {
+		fmt.Fprintf(fw, "#define CILIUM_LB_CONN_MAP_MAX_ENTRIES %d\n", lbmap.MaxConnEntries)
+		fw.WriteString("#define ENABLE_LB_DRAINING\n")
+	}
 	fmt.Fprintf(fw, "#define TUNNEL_ENDPOINT_MAP_SIZE %d\n", tunnel.MaxEntries)
 	fmt.Fprintf(fw, "#define PROXY_MAP_SIZE %d\n", proxymap.MaxEntries)
 	fmt.Fprintf(fw, "#define ENDPOINTS_MAP_SIZE %d\n", lxcmap.MaxEntries)
@@ -1035,6 +1248,12 @@ func NewDaemon() (*Daemon, error) {
 		compilationMutex:  new(lock.RWMutex),
 	}
 
+	d.lbHealth = lbhealth.NewChecker(lbhealth.Probe, d.updateBackendHealth)
+
+	// Services preferring local backends must be resynchronized whenever
+	// local endpoints come and go
+	endpointmanager.Subscribe(newLocalBackendObserver(&d))
+
 	workloads.Init(&d)
 
 	// Clear previous leftovers before listening for new requests
@@ -1082,11 +1301,23 @@ func NewDaemon() (*Daemon, error) {
 	// or IPv4 alloc prefix, respectively, retrieved by k8s node annotations.
 	log.Info("Initializing node addressing")
 
+	if err := leaseNodeAllocRanges(); err != nil {
+		log.WithError(err).Fatal("Unable to lease node allocation ranges from cluster pool")
+	}
+
 	if err := node.AutoComplete(); err != nil {
 		log.WithError(err).Fatal("Cannot autocomplete node addresses")
 	}
 
 	node.SetIPv4ClusterCidrMaskSize(v4ClusterCidrMaskSize)
+	if clusterPoolIPv4CIDR != "" && v4Prefix == AutoCIDR {
+		// The cluster pool is the cluster prefix
+		_, cluster, _ := net.ParseCIDR(clusterPoolIPv4CIDR)
+		ones, _ := cluster.Mask.Size()
+		node.SetIPv4ClusterCidrMaskSize(ones)
+	}
+
+	serviceRanges := []*net.IPNet{}
 
 	if v4Prefix != AutoCIDR {
 		_, net, err := net.ParseCIDR(v4Prefix)
@@ -1103,6 +1334,7 @@ func NewDaemon() (*Daemon, error) {
 		}
 
 		node.AddAuxPrefix(ipnet)
+		serviceRanges = append(serviceRanges, ipnet)
 	}
 
 	if v6Prefix != AutoCIDR {
@@ -1123,8 +1355,11 @@ func NewDaemon() (*Daemon, error) {
 		}
 
 		node.AddAuxPrefix(ipnet)
+		serviceRanges = append(serviceRanges, ipnet)
 	}
 
+	d.vipAllocator = vip.NewAllocator(node.GetName(), serviceRanges...)
+
 	if k8s.IsEnabled() {
 		log.Info("Annotating k8s node with CIDR ranges")
 		err := k8s.AnnotateNode(k8s.Client(), node.GetName(),
@@ -1160,6 +1395,16 @@ func NewDaemon() (*Daemon, error) {
 		log.WithError(err).Fatal("IPAM init failed")
 	}
 
+	if ipamPoolsFile != "" {
+		pools, err := ipam.LoadPoolConfigs(ipamPoolsFile)
+		if err != nil {
+			log.WithError(err).WithField(logfields.Path, ipamPoolsFile).Fatal("Unable to load IPAM pools")
+		}
+		if err := ipam.InitPools(pools); err != nil {
+			log.WithError(err).Fatal("Unable to initialize IPAM pools")
+		}
+	}
+
 	log.Info("Validating configured node address ranges")
 	if err := node.ValidatePostInit(); err != nil {
 		log.WithError(err).Fatal("postinit failed")
@@ -1191,6 +1436,28 @@ func NewDaemon() (*Daemon, error) {
 	ni, n := node.GetLocalNode()
 	node.UpdateNode(ni, n, node.TunnelRoute, nil)
 
+	// Global services of remote clusters are learned via the clustermesh,
+	// the local service store is only written to. The store must be joined
+	// before the leader election starts as the leader publishes the global
+	// services of the local cluster.
+	if err := d.serviceRegistrar.Join(nil); err != nil {
+		log.WithError(err).Error("Unable to join service store in kvstore")
+	}
+
+	// Elect a single agent to run controllers marked as leader only, e.g.
+	// the garbage collectors of the identity allocator, and to publish the
+	// global services of the local cluster
+	d.leaderElection, err = kvstore.NewElection(kvstore.Client(), kvstore.ElectionConfig{
+		Name:             agentElectionName,
+		Identity:         node.GetName(),
+		OnStartedLeading: d.startPublishingGlobalServices,
+		OnStoppedLeading: d.stopPublishingGlobalServices,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to start leader election: %s", err)
+	}
+	controller.SetLeaderFunc(d.leaderElection.IsLeader)
+
 	// This needs to be done after the node addressing has been configured
 	// as the node address is required as sufix
 	identity.InitIdentityAllocator(&d)
@@ -1205,6 +1472,28 @@ func NewDaemon() (*Daemon, error) {
 	// we populate the IPCache with the host's IP(s).
 	ipcache.InitIPIdentityWatcher(d.ipcacheListeners)
 
+	// Publish the local node into the kvstore so it can be discovered by
+	// agents of remote clusters. Without Kubernetes, the nodes of the local
+	// cluster are discovered via the kvstore as well.
+	var nodeObserver nodeStore.NodeObserver
+	if !k8s.IsEnabled() {
+		nodeObserver = &nodeDiscoveryObserver{}
+	}
+	if err := d.registerLocalNode(nodeObserver); err != nil {
+		log.WithError(err).Error("Unable to register local node in kvstore")
+	}
+
+	if path := option.Config.ClusterMeshConfig; path != "" {
+		d.clustermesh, err = clustermesh.NewClusterMesh(clustermesh.Configuration{
+			Name:            option.Config.ClusterName,
+			ConfigDirectory: path,
+			ServiceObserver: &globalServiceObserver{d: &d},
+		})
+		if err != nil {
+			log.WithError(err).WithField("path", path).Fatal("Unable to initialize ClusterMesh")
+		}
+	}
+
 	// FIXME: Make the port range configurable.
 	d.l7Proxy = proxy.StartProxySupport(10000, 20000, option.Config.RunDir,
 		option.Config.AccessLog, &d, option.Config.AgentLabels)
@@ -1227,6 +1516,10 @@ func NewDaemon() (*Daemon, error) {
 		workloads.IgnoreRunningWorkloads()
 	}
 
+	// Restore the IPAM allocations of workloads which did not become
+	// endpoints before allocating any new address
+	restoreIPAMCheckpoint()
+
 	d.collectStaleMapGarbage()
 
 	// Allocate health endpoint IPs after restoring state
@@ -1240,6 +1533,11 @@ func NewDaemon() (*Daemon, error) {
 	log.Debugf("IPv4 health endpoint address: %s", node.GetIPv4HealthIP())
 	log.Debugf("IPv6 health endpoint address: %s", node.GetIPv6HealthIP())
 
+	// Publish the health endpoint addresses of the local node
+	if err := d.syncLocalNode(); err != nil {
+		log.WithError(err).Warning("Unable to update local node in kvstore")
+	}
+
 	d.startStatusCollector()
 
 	return &d, nil
@@ -1538,11 +1836,15 @@ func numWorkerThreads() int {
 func (d *Daemon) GetServiceList() []*models.Service {
 	list := []*models.Service{}
 
+	stats := dumpServiceStats()
+
 	d.loadBalancer.BPFMapMU.RLock()
 	defer d.loadBalancer.BPFMapMU.RUnlock()
 
 	for _, v := range d.loadBalancer.SVCMap {
-		list = append(list, v.GetModel())
+		model := v.GetModel()
+		addServiceStatsToModel(stats, model)
+		list = append(list, model)
 	}
 	return list
 }
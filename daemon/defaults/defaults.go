@@ -54,4 +54,7 @@ const (
 
 	// EventsPipe is the name of the named pipe for agent <=> monitor events
 	EventsPipe = "events.sock"
+
+	// ClusterName is the default cluster name
+	ClusterName = "default"
 )
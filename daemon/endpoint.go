@@ -774,7 +774,7 @@ func (d *Daemon) OnIPIdentityCacheChange(modType ipcache.CacheModification, oldI
 
 	switch modType {
 	case ipcache.Upsert:
-		value := ipCacheBPF.RemoteEndpointInfo{SecurityIdentity: uint16(newIPIDPair.ID)}
+		value := ipCacheBPF.RemoteEndpointInfo{SecurityIdentity: uint32(newIPIDPair.ID)}
 		err := ipCacheBPF.IPCache.Update(&key, &value)
 		if err != nil {
 			log.WithError(err).WithFields(logrus.Fields{"key": key.String(),
@@ -0,0 +1,227 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/controller"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/option"
+	serviceStore "github.com/cilium/cilium/pkg/service/store"
+
+	"github.com/sirupsen/logrus"
+)
+
+// isGlobalServiceWriter returns true if the local agent publishes the global
+// services of the local cluster. Only the leader publishes them so that every
+// service is written by a single agent.
+func (d *Daemon) isGlobalServiceWriter() bool {
+	return d.leaderElection != nil && d.leaderElection.IsLeader()
+}
+
+// registerGlobalService publishes the backends se of the global service svc
+// of the local cluster into the kvstore if the local agent is the leader.
+//
+// Must be called with d.loadBalancer.K8sMU locked.
+func (d *Daemon) registerGlobalService(svc types.K8sServiceNamespace, se *types.K8sServiceEndpoint) {
+	if !d.isGlobalServiceWriter() {
+		return
+	}
+
+	cs := serviceStore.NewClusterService(option.Config.ClusterName, svc, se)
+	if err := d.serviceRegistrar.RegisterService(cs); err != nil {
+		log.WithError(err).WithFields(logrus.Fields{
+			logfields.K8sSvcName:   svc.ServiceName,
+			logfields.K8sNamespace: svc.Namespace,
+		}).Warn("Unable to publish global service in kvstore")
+	}
+}
+
+// deregisterGlobalService removes the backends of the global service svc of
+// the local cluster from the kvstore if the local agent is the leader.
+//
+// Must be called with d.loadBalancer.K8sMU locked.
+func (d *Daemon) deregisterGlobalService(svc types.K8sServiceNamespace) {
+	if !d.isGlobalServiceWriter() {
+		return
+	}
+
+	d.serviceRegistrar.DeregisterService(serviceStore.ClusterService{
+		Cluster:   option.Config.ClusterName,
+		Namespace: svc.Namespace,
+		Name:      svc.ServiceName,
+	})
+}
+
+// getGlobalServices returns the global services of the local cluster.
+//
+// Must be called with d.loadBalancer.K8sMU locked.
+func (d *Daemon) getGlobalServices() []serviceStore.ClusterService {
+	services := []serviceStore.ClusterService{}
+	for svc, svcInfo := range d.loadBalancer.K8sServices {
+		if !svcInfo.Global {
+			continue
+		}
+		if se, ok := d.loadBalancer.K8sEndpoints[svc]; ok {
+			services = append(services, serviceStore.NewClusterService(option.Config.ClusterName, svc, se))
+		}
+	}
+	return services
+}
+
+// startPublishingGlobalServices publishes all global services of the local
+// cluster, taking over the keys of the previous leader. It is called when
+// the local agent became the leader.
+func (d *Daemon) startPublishingGlobalServices() {
+	d.loadBalancer.K8sMU.Lock()
+	defer d.loadBalancer.K8sMU.Unlock()
+
+	for _, cs := range d.getGlobalServices() {
+		if err := d.serviceRegistrar.RegisterService(cs); err != nil {
+			log.WithError(err).WithField(logfields.ServiceName, cs.String()).
+				Warn("Unable to publish global service in kvstore")
+		}
+	}
+}
+
+// startGlobalServicesGC starts the controller removing the global services
+// of the local cluster from the kvstore which are no longer global services,
+// e.g. because they were deleted while there was no leader. It must only be
+// started once all services and endpoints have been received from
+// Kubernetes.
+func (d *Daemon) startGlobalServicesGC() {
+	controller.NewManager().UpdateController("global-services-gc",
+		controller.ControllerParams{
+			DoFunc: func() error {
+				d.loadBalancer.K8sMU.Lock()
+				defer d.loadBalancer.K8sMU.Unlock()
+				return d.serviceRegistrar.SyncServices(option.Config.ClusterName, d.getGlobalServices())
+			},
+			RunInterval: 5 * time.Minute,
+			LeaderOnly:  true,
+		})
+}
+
+// stopPublishingGlobalServices stops publishing the global services of the
+// local cluster. The services are left in the kvstore for the next leader
+// to take over. It is called when the local agent lost the leadership.
+func (d *Daemon) stopPublishingGlobalServices() {
+	d.loadBalancer.K8sMU.Lock()
+	defer d.loadBalancer.K8sMU.Unlock()
+
+	d.serviceRegistrar.ReleaseServices()
+}
+
+// getRemoteBackends returns the backends of the service port fePortName of
+// the global service svc in all remote clusters which are of the same address
+// family as feIP.
+//
+// Must be called with d.loadBalancer.K8sMU locked.
+func (d *Daemon) getRemoteBackends(svc types.K8sServiceNamespace, feIP net.IP, fePortName types.FEPortName) []types.LBBackEnd {
+	isFEIPv4 := feIP.To4() != nil
+	bes := []types.LBBackEnd{}
+
+	for cluster, se := range d.loadBalancer.RemoteK8sEndpoints[svc] {
+		port := se.Ports[fePortName]
+		if port == nil {
+			continue
+		}
+
+		for beIP := range se.BEIPs {
+			ip := net.ParseIP(beIP)
+			if ip == nil || (ip.To4() != nil) != isFEIPv4 {
+				continue
+			}
+			bes = append(bes, types.LBBackEnd{
+				L3n4Addr: types.L3n4Addr{IP: ip, L4Addr: *port},
+				Cluster:  cluster,
+			})
+		}
+	}
+
+	return bes
+}
+
+// globalServiceObserver merges the backends of global services learned from
+// remote clusters into the backends of the corresponding local services.
+type globalServiceObserver struct {
+	d *Daemon
+}
+
+// ServiceUpdated is called when the backends of a global service of a remote
+// cluster have been created or updated
+func (o *globalServiceObserver) ServiceUpdated(svc serviceStore.ClusterService) {
+	if svc.Cluster == option.Config.ClusterName {
+		return
+	}
+
+	svcns := svc.K8sServiceNamespace()
+
+	o.d.loadBalancer.K8sMU.Lock()
+	defer o.d.loadBalancer.K8sMU.Unlock()
+
+	clusters, ok := o.d.loadBalancer.RemoteK8sEndpoints[svcns]
+	if !ok {
+		clusters = map[string]*types.K8sServiceEndpoint{}
+		o.d.loadBalancer.RemoteK8sEndpoints[svcns] = clusters
+	}
+	clusters[svc.Cluster] = svc.K8sServiceEndpoint()
+
+	log.WithFields(logrus.Fields{
+		logfields.ServiceName: svc.String(),
+		"backends":            len(svc.Backends),
+	}).Debug("Updated global service backends of remote cluster")
+
+	o.resync(svcns)
+}
+
+// ServiceDeleted is called when the backends of a global service of a remote
+// cluster have been removed
+func (o *globalServiceObserver) ServiceDeleted(svc serviceStore.ClusterService) {
+	if svc.Cluster == option.Config.ClusterName {
+		return
+	}
+
+	svcns := svc.K8sServiceNamespace()
+
+	o.d.loadBalancer.K8sMU.Lock()
+	defer o.d.loadBalancer.K8sMU.Unlock()
+
+	clusters, ok := o.d.loadBalancer.RemoteK8sEndpoints[svcns]
+	if !ok {
+		return
+	}
+	delete(clusters, svc.Cluster)
+	if len(clusters) == 0 {
+		delete(o.d.loadBalancer.RemoteK8sEndpoints, svcns)
+	}
+
+	log.WithField(logfields.ServiceName, svc.String()).
+		Debug("Removed global service backends of remote cluster")
+
+	o.resync(svcns)
+}
+
+// resync reinstalls the local service svc if it is a global service.
+//
+// Must be called with d.loadBalancer.K8sMU locked.
+func (o *globalServiceObserver) resync(svc types.K8sServiceNamespace) {
+	if svcInfo, ok := o.d.loadBalancer.K8sServices[svc]; ok && svcInfo.Global {
+		o.d.syncLB(&svc, nil, nil)
+	}
+}
@@ -15,15 +15,21 @@
 package main
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/cilium/cilium/api/v1/models"
 	ipamapi "github.com/cilium/cilium/api/v1/server/restapi/ipam"
 	"github.com/cilium/cilium/pkg/apierror"
 	"github.com/cilium/cilium/pkg/ipam"
+	"github.com/cilium/cilium/pkg/k8s"
+	"github.com/cilium/cilium/pkg/logging/logfields"
 
 	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
 	"github.com/go-openapi/swag"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type postIPAM struct {
@@ -42,11 +48,24 @@ func (h *postIPAM) Handle(params ipamapi.PostIPAMParams) middleware.Responder {
 		Address:        &models.AddressPair{},
 	}
 
-	ipv4, ipv6, err := ipam.AllocateNext(strings.ToLower(swag.StringValue(params.Family)))
+	owner, err := getIPAMOwner(params.Owner)
 	if err != nil {
 		return apierror.Error(ipamapi.PostIPAMFailureCode, err)
 	}
 
+	ipv4, ipv6, pool, err := ipam.AllocateNextForOwner(strings.ToLower(swag.StringValue(params.Family)), owner)
+	if err != nil {
+		return apierror.Error(ipamapi.PostIPAMFailureCode, err)
+	}
+
+	if pool != "" {
+		log.WithFields(logrus.Fields{
+			logfields.K8sNamespace: owner.Namespace,
+			logfields.K8sPodName:   owner.PodName,
+			"pool":                 pool,
+		}).Debug("Allocated addresses from IPAM pool")
+	}
+
 	if ipv4 != nil {
 		resp.Address.IPV4 = ipv4.String()
 	}
@@ -58,6 +77,32 @@ func (h *postIPAM) Handle(params ipamapi.PostIPAMParams) middleware.Responder {
 	return ipamapi.NewPostIPAMCreated().WithPayload(resp)
 }
 
+// getIPAMOwner returns the owner of the addresses requested with the owner
+// m. If no annotations are specified, the annotations of the pod are
+// retrieved from Kubernetes if any IPAM pool is configured.
+func getIPAMOwner(m *models.IPAMOwner) (*ipam.Owner, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	owner := &ipam.Owner{
+		ContainerID: m.ContainerID,
+		Namespace:   m.Namespace,
+		PodName:     m.PodName,
+		Annotations: m.Annotations,
+	}
+
+	if owner.Annotations == nil && owner.PodName != "" && k8s.IsEnabled() && ipam.HasPools() {
+		pod, err := k8s.Client().CoreV1().Pods(owner.Namespace).Get(owner.PodName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get pod %s/%s: %s", owner.Namespace, owner.PodName, err)
+		}
+		owner.Annotations = pod.GetAnnotations()
+	}
+
+	return owner, nil
+}
+
 type postIPAMIP struct{}
 
 // NewPostIPAMIPHandler creates a new postIPAM from the daemon.
@@ -89,12 +134,38 @@ func (h *deleteIPAMIP) Handle(params ipamapi.DeleteIPAMIPParams) middleware.Resp
 	return ipamapi.NewDeleteIPAMIPOK()
 }
 
-// DumpIPAM dumps in the form of a map, and only if debug is enabled, the list of
-// reserved IPv4 and IPv6 addresses.
+// DumpIPAM dumps the status of the IPAM including, only if debug is enabled,
+// the list of reserved IPv4 and IPv6 addresses.
 func (d *Daemon) DumpIPAM() *models.IPAMStatus {
-	allocv4, allocv6 := ipam.Dump()
-	return &models.IPAMStatus{
-		IPV4: allocv4,
-		IPV6: allocv6,
+	status := &models.IPAMStatus{}
+
+	if d.DebugEnabled() {
+		status.IPV4, status.IPV6 = ipam.Dump()
 	}
+
+	for _, pool := range ipam.DumpPools() {
+		status.Pools = append(status.Pools, &models.IPAMPoolStatus{
+			Name:     pool.Name,
+			Cidrs:    pool.CIDRs,
+			Used:     int64(pool.Used),
+			Capacity: int64(pool.Capacity),
+		})
+	}
+
+	if cp := ipam.DumpCheckpoint(); cp != nil {
+		status.Checkpoint = &models.IPAMCheckpointStatus{
+			Allocations:   int64(cp.Allocations),
+			LastGc:        strfmt.DateTime(cp.LastGC),
+			ReleasedTotal: int64(cp.ReleasedTotal),
+		}
+		for _, a := range cp.Released {
+			status.Checkpoint.Released = append(status.Checkpoint.Released, &models.IPAMAllocation{
+				IP:        a.IP,
+				Owner:     a.Owner,
+				Timestamp: strfmt.DateTime(a.Timestamp),
+			})
+		}
+	}
+
+	return status
 }
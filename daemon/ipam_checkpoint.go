@@ -0,0 +1,103 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/cilium/cilium/pkg/controller"
+	"github.com/cilium/cilium/pkg/endpointmanager"
+	"github.com/cilium/cilium/pkg/ipam"
+	"github.com/cilium/cilium/pkg/option"
+	"github.com/cilium/cilium/pkg/workloads"
+)
+
+const (
+	// ipamCheckpointGCInterval is the interval in which orphaned IPAM
+	// allocations are garbage collected
+	ipamCheckpointGCInterval = 5 * time.Minute
+
+	// workloadsListTimeout is the timeout for listing the running
+	// workloads
+	workloadsListTimeout = 10 * time.Second
+)
+
+// ipamOrphanCheck returns a function considering an IPAM allocation orphaned
+// if its owner is not running and no endpoint uses its address. It returns
+// nil if the running workloads cannot be listed, in which case no allocation
+// must be considered orphaned.
+func ipamOrphanCheck() ipam.IsOrphanFunc {
+	if workloads.Client() == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), workloadsListTimeout)
+	defer cancel()
+
+	ids, err := workloads.WorkloadIDsList(ctx)
+	if err != nil {
+		log.WithError(err).Warning("Unable to list running workloads, skipping IPAM garbage collection")
+		return nil
+	}
+
+	running := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		running[id] = struct{}{}
+	}
+
+	return func(a ipam.Allocation) bool {
+		if _, ok := running[a.Owner]; ok {
+			return false
+		}
+
+		ip := net.ParseIP(a.IP)
+		if ip == nil {
+			return true
+		}
+
+		if ip.To4() != nil {
+			return endpointmanager.LookupIPv4(a.IP) == nil
+		}
+		return endpointmanager.LookupIPv6(a.IP) == nil
+	}
+}
+
+// restoreIPAMCheckpoint restores the IPAM allocations checkpointed to the
+// state directory which have not been restored with the endpoints and starts
+// a controller garbage collecting orphaned allocations.
+func restoreIPAMCheckpoint() {
+	path := filepath.Join(option.Config.StateDir, ipam.CheckpointFile)
+	if err := ipam.RestoreCheckpoint(path, ipamOrphanCheck()); err != nil {
+		log.WithError(err).Warning("Unable to restore checkpointed IPAM allocations")
+	}
+
+	if workloads.Client() == nil {
+		return
+	}
+
+	controller.NewManager().UpdateController("ipam-checkpoint-gc",
+		controller.ControllerParams{
+			DoFunc: func() error {
+				if isOrphan := ipamOrphanCheck(); isOrphan != nil {
+					ipam.CollectOrphans(isOrphan)
+				}
+				return nil
+			},
+			RunInterval: ipamCheckpointGCInterval,
+		})
+}
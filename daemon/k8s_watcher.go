@@ -21,10 +21,12 @@ import (
 	"net"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/annotation"
 	"github.com/cilium/cilium/pkg/controller"
 	"github.com/cilium/cilium/pkg/endpoint"
 	"github.com/cilium/cilium/pkg/endpointmanager"
@@ -35,6 +37,7 @@ import (
 	informer "github.com/cilium/cilium/pkg/k8s/client/informers/externalversions"
 	"github.com/cilium/cilium/pkg/lock"
 	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/maps/lbmap"
 	"github.com/cilium/cilium/pkg/metrics"
 	"github.com/cilium/cilium/pkg/node"
 	"github.com/cilium/cilium/pkg/option"
@@ -358,6 +361,21 @@ func (d *Daemon) EnableK8sWatcher(reSyncPeriod time.Duration) error {
 	go endpointController.Run(wait.NeverStop)
 	d.k8sAPIGroups.addAPI(k8sAPIGroupEndpointV1Core)
 
+	// Stale global services are only removed from the kvstore once all
+	// services and endpoints existing at startup have been handled
+	go func() {
+		if !cache.WaitForCacheSync(wait.NeverStop, svcController.HasSynced, endpointController.HasSynced) {
+			return
+		}
+		serSvcs.Enqueue(func() error {
+			serEps.Enqueue(func() error {
+				d.startGlobalServicesGC()
+				return nil
+			}, serializer.NoRetry)
+			return nil
+		}, serializer.NoRetry)
+	}()
+
 	if option.Config.IsLBEnabled() {
 		_, ingressController := cache.NewInformer(
 			cache.NewListWatchFromClient(k8s.Client().ExtensionsV1beta1().RESTClient(),
@@ -648,8 +666,35 @@ func (d *Daemon) addK8sServiceV1(svc *v1.Service) {
 	}
 	newSI := types.NewK8sServiceInfo(clusterIP, headless, svc.Labels, svc.Spec.Selector)
 
-	// FIXME: Add support for
-	//  - NodePort
+	if svc.Spec.SessionAffinity == v1.ServiceAffinityClientIP {
+		timeout := uint32(v1.DefaultClientIPServiceAffinitySeconds)
+		if cfg := svc.Spec.SessionAffinityConfig; cfg != nil && cfg.ClientIP != nil && cfg.ClientIP.TimeoutSeconds != nil {
+			timeout = uint32(*cfg.ClientIP.TimeoutSeconds)
+		}
+		newSI.Affinity = types.SessionAffinityConfig{
+			Mode:       types.SessionAffinityClientIP,
+			TimeoutSec: timeout,
+		}
+	}
+
+	newSI.ExternalTrafficLocal = svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal
+	if value, ok := svc.Annotations[annotation.ServicePreferLocalBackends]; ok {
+		preferLocal, err := strconv.ParseBool(value)
+		if err != nil {
+			scopedLog.WithError(err).WithField("annotation", annotation.ServicePreferLocalBackends).
+				Warn("Ignoring invalid service annotation")
+		}
+		newSI.PreferLocal = preferLocal
+	}
+
+	if value, ok := svc.Annotations[annotation.GlobalService]; ok {
+		global, err := strconv.ParseBool(value)
+		if err != nil {
+			scopedLog.WithError(err).WithField("annotation", annotation.GlobalService).
+				Warn("Ignoring invalid service annotation")
+		}
+		newSI.Global = global
+	}
 
 	for _, port := range svc.Spec.Ports {
 		p, err := types.NewFEPort(types.L4Type(port.Protocol), uint16(port.Port))
@@ -662,14 +707,141 @@ func (d *Daemon) addK8sServiceV1(svc *v1.Service) {
 		}
 	}
 
+	if !headless {
+		newSI.Frontends = getK8sServiceFrontends(scopedLog, svc, clusterIP)
+	}
+
 	d.loadBalancer.K8sMU.Lock()
 	defer d.loadBalancer.K8sMU.Unlock()
 
+	if oldSI, ok := d.loadBalancer.K8sServices[svcns]; ok {
+		d.delK8sStaleFrontends(svcns, oldSI, newSI)
+
+		if oldSI.Global && !newSI.Global {
+			d.deregisterGlobalService(svcns)
+		}
+	}
+
 	d.loadBalancer.K8sServices[svcns] = newSI
 
 	d.syncLB(&svcns, nil, nil)
 }
 
+// getNodePortAddresses returns the node addresses of the given address
+// family on which node ports are exposed, i.e. the node addresses and all
+// addresses with global scope of the network devices of the node.
+func getNodePortAddresses(ipv4 bool) []net.IP {
+	var candidates []net.IP
+	if ipv4 {
+		candidates = []net.IP{node.GetExternalIPv4(), option.Config.HostV4Addr}
+	} else {
+		candidates = []net.IP{node.GetIPv6(), option.Config.HostV6Addr}
+	}
+
+	local, err := node.GetLocalAddresses(ipv4)
+	if err != nil {
+		log.WithError(err).Warning("Unable to list the local addresses, node ports are only exposed on the node addresses")
+	}
+	candidates = append(candidates, local...)
+
+	addrs := []net.IP{}
+	for _, candidate := range candidates {
+		if candidate == nil || candidate.IsUnspecified() {
+			continue
+		}
+		found := false
+		for _, addr := range addrs {
+			if addr.Equal(candidate) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			addrs = append(addrs, candidate)
+		}
+	}
+	return addrs
+}
+
+// getK8sServiceFrontends returns the NodePort, external IP and LoadBalancer
+// ingress frontends of svc. Only addresses of the same family as the
+// clusterIP are used as all frontends share the backends of the service.
+func getK8sServiceFrontends(scopedLog *logrus.Entry, svc *v1.Service, clusterIP net.IP) []*types.K8sFrontend {
+	isSvcIPv4 := clusterIP.To4() != nil
+	frontends := []*types.K8sFrontend{}
+
+	add := func(feType types.FrontendType, ip net.IP, port v1.ServicePort, number int32) {
+		if ip == nil || (ip.To4() != nil) != isSvcIPv4 {
+			scopedLog.WithFields(logrus.Fields{
+				logfields.IPAddr: ip,
+				"type":           feType,
+			}).Debug("Ignoring service frontend of a different address family than the cluster IP")
+			return
+		}
+
+		fe, err := types.NewK8sFrontend(feType, ip, types.L4Type(port.Protocol),
+			uint16(number), types.FEPortName(port.Name))
+		if err != nil {
+			scopedLog.WithError(err).WithField("port", port).Error("Unable to add service frontend")
+			return
+		}
+		frontends = append(frontends, fe)
+	}
+
+	for _, port := range svc.Spec.Ports {
+		if port.NodePort != 0 && option.Config.EnableNodePort {
+			if option.Config.IsNodePortInRange(int(port.NodePort)) {
+				for _, addr := range getNodePortAddresses(isSvcIPv4) {
+					add(types.FrontendTypeNodePort, addr, port, port.NodePort)
+				}
+			} else {
+				scopedLog.WithField(logfields.Port, port.NodePort).Warn("Ignoring node port outside of the node port range")
+			}
+		}
+
+		for _, externalIP := range svc.Spec.ExternalIPs {
+			add(types.FrontendTypeExternalIP, net.ParseIP(externalIP), port, port.Port)
+		}
+
+		if svc.Spec.Type == v1.ServiceTypeLoadBalancer {
+			for _, ingress := range svc.Status.LoadBalancer.Ingress {
+				if ingress.IP != "" {
+					add(types.FrontendTypeLoadBalancer, net.ParseIP(ingress.IP), port, port.Port)
+				}
+			}
+		}
+	}
+
+	return frontends
+}
+
+// delK8sStaleFrontends deletes the frontends of oldSI which are no longer
+// frontends of newSI.
+//
+// Must be called with d.loadBalancer.K8sMU locked.
+func (d *Daemon) delK8sStaleFrontends(svc types.K8sServiceNamespace, oldSI, newSI *types.K8sServiceInfo) {
+	if _, ok := d.loadBalancer.K8sEndpoints[svc]; !ok || oldSI.IsHeadless {
+		// The frontends of the service have never been added
+		return
+	}
+
+	current := map[string]bool{}
+	for _, fe := range newSI.Frontends {
+		current[fe.String()] = true
+	}
+
+	scopedLog := log.WithFields(logrus.Fields{
+		logfields.K8sSvcName:   svc.ServiceName,
+		logfields.K8sNamespace: svc.Namespace,
+	})
+
+	for _, fe := range oldSI.Frontends {
+		if !current[fe.String()] {
+			d.delK8sFrontend(scopedLog, fe.IP, fe.FEPort)
+		}
+	}
+}
+
 func (d *Daemon) updateK8sServiceV1(oldSvc, newSvc *v1.Service) {
 	log.WithFields(logrus.Fields{
 		logfields.K8sAPIVersion:         oldSvc.TypeMeta.APIVersion,
@@ -871,33 +1043,42 @@ func (d *Daemon) delK8sSVCs(svc types.K8sServiceNamespace, svcInfo *types.K8sSer
 		}
 		repPorts[svcPort.Port] = false
 
-		if svcPort.ID != 0 {
-			if err := DeleteL3n4AddrIDByUUID(uint32(svcPort.ID)); err != nil {
-				scopedLog.WithError(err).Warn("Error while cleaning service ID")
-			}
-		}
+		d.delK8sFrontend(scopedLog, svcInfo.FEIP, svcPort)
+	}
 
-		fe, err := types.NewL3n4Addr(svcPort.Protocol, svcInfo.FEIP, svcPort.Port)
-		if err != nil {
-			scopedLog.WithError(err).Error("Error while creating a New L3n4AddrID. Ignoring service")
-			continue
+	for _, fe := range svcInfo.Frontends {
+		d.delK8sFrontend(scopedLog, fe.IP, fe.FEPort)
+	}
+	return nil
+}
+
+// delK8sFrontend deletes the service with the frontend feIP and fePort.
+func (d *Daemon) delK8sFrontend(scopedLog *logrus.Entry, feIP net.IP, fePort *types.FEPort) {
+	if fePort.ID != 0 {
+		if err := DeleteL3n4AddrIDByUUID(uint32(fePort.ID)); err != nil {
+			scopedLog.WithError(err).Warn("Error while cleaning service ID")
 		}
+	}
+
+	fe, err := types.NewL3n4Addr(fePort.Protocol, feIP, fePort.Port)
+	if err != nil {
+		scopedLog.WithError(err).Error("Error while creating a New L3n4AddrID. Ignoring service")
+		return
+	}
 
-		if err := d.svcDeleteByFrontend(fe); err != nil {
-			scopedLog.WithError(err).WithField(logfields.Object, logfields.Repr(fe)).
-				Warn("Error deleting service by frontend")
+	if err := d.svcDeleteByFrontend(fe); err != nil {
+		scopedLog.WithError(err).WithField(logfields.Object, logfields.Repr(fe)).
+			Warn("Error deleting service by frontend")
 
-		} else {
-			scopedLog.Debugf("# cilium lb delete-service %s %d 0", svcInfo.FEIP, svcPort.Port)
-		}
+	} else {
+		scopedLog.Debugf("# cilium lb delete-service %s %d 0", feIP, fePort.Port)
+	}
 
-		if err := d.RevNATDelete(svcPort.ID); err != nil {
-			scopedLog.WithError(err).WithField(logfields.ServiceID, svcPort.ID).Warn("Error deleting reverse NAT")
-		} else {
-			scopedLog.Debugf("# cilium lb delete-rev-nat %d", svcPort.ID)
-		}
+	if err := d.RevNATDelete(fePort.ID); err != nil {
+		scopedLog.WithError(err).WithField(logfields.ServiceID, fePort.ID).Warn("Error deleting reverse NAT")
+	} else {
+		scopedLog.Debugf("# cilium lb delete-rev-nat %d", fePort.ID)
 	}
-	return nil
 }
 
 func (d *Daemon) addK8sSVCs(svc types.K8sServiceNamespace, svcInfo *types.K8sServiceInfo, se *types.K8sServiceEndpoint) error {
@@ -929,63 +1110,118 @@ func (d *Daemon) addK8sSVCs(svc types.K8sServiceNamespace, svcInfo *types.K8sSer
 			continue
 		}
 
-		k8sBEPort := se.Ports[fePortName]
 		uniqPorts[fePort.Port] = false
 
-		if fePort.ID == 0 {
-			feAddr, err := types.NewL3n4Addr(fePort.Protocol, svcInfo.FEIP, fePort.Port)
-			if err != nil {
-				scopedLog.WithError(err).WithFields(logrus.Fields{
-					logfields.ServiceID: fePortName,
-					logfields.IPAddr:    svcInfo.FEIP,
-					logfields.Port:      fePort.Port,
-					logfields.Protocol:  fePort.Protocol,
-				}).Error("Error while creating a new L3n4Addr. Ignoring service...")
-				continue
-			}
-			feAddrID, err := PutL3n4Addr(*feAddr, 0)
-			if err != nil {
-				scopedLog.WithError(err).WithFields(logrus.Fields{
-					logfields.ServiceID: fePortName,
-					logfields.IPAddr:    svcInfo.FEIP,
-					logfields.Port:      fePort.Port,
-					logfields.Protocol:  fePort.Protocol,
-				}).Error("Error while getting a new service ID. Ignoring service...")
-				continue
-			}
-			scopedLog.WithFields(logrus.Fields{
-				logfields.ServiceName: fePortName,
-				logfields.ServiceID:   feAddrID.ID,
-				logfields.Object:      logfields.Repr(svc),
-			}).Debug("Got feAddr ID for service")
-			fePort.ID = feAddrID.ID
-		}
+		d.addK8sFrontend(scopedLog, svc, svcInfo, se, types.FrontendTypeClusterIP, svcInfo.FEIP, fePortName, fePort)
+	}
 
-		besValues := []types.LBBackEnd{}
+	for _, fe := range svcInfo.Frontends {
+		d.addK8sFrontend(scopedLog, svc, svcInfo, se, fe.Type, fe.IP, fe.PortName, fe.FEPort)
+	}
+	return nil
+}
 
-		if k8sBEPort != nil {
-			for epIP := range se.BEIPs {
-				bePort := types.LBBackEnd{
-					L3n4Addr: types.L3n4Addr{IP: net.ParseIP(epIP), L4Addr: *k8sBEPort},
-					Weight:   0,
-				}
-				besValues = append(besValues, bePort)
-			}
-		}
+// addK8sFrontend adds the service with the frontend feIP and fePort of type
+// feType. The backends of the service are the endpoints of the service port
+// fePortName.
+func (d *Daemon) addK8sFrontend(scopedLog *logrus.Entry, svc types.K8sServiceNamespace, svcInfo *types.K8sServiceInfo,
+	se *types.K8sServiceEndpoint, feType types.FrontendType, feIP net.IP, fePortName types.FEPortName, fePort *types.FEPort) {
 
-		fe, err := types.NewL3n4AddrID(fePort.Protocol, svcInfo.FEIP, fePort.Port, fePort.ID)
+	k8sBEPort := se.Ports[fePortName]
+
+	if fePort.ID == 0 {
+		feAddr, err := types.NewL3n4Addr(fePort.Protocol, feIP, fePort.Port)
 		if err != nil {
 			scopedLog.WithError(err).WithFields(logrus.Fields{
-				logfields.IPAddr: svcInfo.FEIP,
-				logfields.Port:   svcInfo.Ports,
-			}).Error("Error while creating a New L3n4AddrID. Ignoring service...")
-			continue
+				logfields.ServiceID: fePortName,
+				logfields.IPAddr:    feIP,
+				logfields.Port:      fePort.Port,
+				logfields.Protocol:  fePort.Protocol,
+			}).Error("Error while creating a new L3n4Addr. Ignoring service...")
+			return
 		}
-		if _, err := d.svcAdd(*fe, besValues, true); err != nil {
-			scopedLog.WithError(err).Error("Error while inserting service in LB map")
+		feAddrID, err := PutL3n4Addr(*feAddr, 0)
+		if err != nil {
+			scopedLog.WithError(err).WithFields(logrus.Fields{
+				logfields.ServiceID: fePortName,
+				logfields.IPAddr:    feIP,
+				logfields.Port:      fePort.Port,
+				logfields.Protocol:  fePort.Protocol,
+			}).Error("Error while getting a new service ID. Ignoring service...")
+			return
 		}
+		scopedLog.WithFields(logrus.Fields{
+			logfields.ServiceName: fePortName,
+			logfields.ServiceID:   feAddrID.ID,
+			logfields.Object:      logfields.Repr(svc),
+		}).Debug("Got feAddr ID for service")
+		fePort.ID = feAddrID.ID
 	}
-	return nil
+
+	besValues := []types.LBBackEnd{}
+
+	if k8sBEPort != nil {
+		for epIP := range se.BEIPs {
+			bePort := types.LBBackEnd{
+				L3n4Addr: types.L3n4Addr{IP: net.ParseIP(epIP), L4Addr: *k8sBEPort},
+				Weight:   0,
+			}
+			besValues = append(besValues, bePort)
+		}
+	}
+
+	if svcInfo.Global {
+		for i := range besValues {
+			besValues[i].Cluster = option.Config.ClusterName
+		}
+		besValues = append(besValues, d.getRemoteBackends(svc, feIP, fePortName)...)
+	}
+
+	if svcInfo.PrefersLocalBackends(feType) {
+		besValues = types.SelectLocalBackends(besValues, isLocalEndpointIP)
+	}
+
+	fe, err := types.NewL3n4AddrID(fePort.Protocol, feIP, fePort.Port, fePort.ID)
+	if err != nil {
+		scopedLog.WithError(err).WithFields(logrus.Fields{
+			logfields.IPAddr: feIP,
+			logfields.Port:   fePort.Port,
+		}).Error("Error while creating a New L3n4AddrID. Ignoring service...")
+		return
+	}
+	// Kubernetes services are forwarded in DSR mode if enabled on the
+	// agent, the reverse translation is always installed
+	mode := types.ForwardingModeNAT
+	if lbmap.IsDSREnabled() {
+		mode = types.ForwardingModeDSR
+	}
+
+	lbSVC := types.LBSVC{
+		FE:       *fe,
+		BES:      besValues,
+		Type:     feType,
+		Affinity: svcInfo.Affinity,
+		Mode:     mode,
+		Meta: types.ServiceMeta{
+			Name:      svc.ServiceName,
+			Namespace: svc.Namespace,
+			PortName:  fePortName,
+			Source:    types.ServiceSourceK8s,
+			Labels:    svcInfo.Labels,
+		},
+	}
+	if _, err := d.svcAdd(lbSVC, true); err != nil {
+		scopedLog.WithError(err).Error("Error while inserting service in LB map")
+	}
+}
+
+// isLocalEndpointIP returns true if ip is the address of an endpoint managed
+// by this node.
+func isLocalEndpointIP(ip net.IP) bool {
+	if ip.To4() != nil {
+		return endpointmanager.LookupIPv4(ip.String()) != nil
+	}
+	return endpointmanager.LookupIPv6(ip.String()) != nil
 }
 
 func (d *Daemon) syncLB(newSN, modSN, delSN *types.K8sServiceNamespace) {
@@ -1010,6 +1246,10 @@ func (d *Daemon) syncLB(newSN, modSN, delSN *types.K8sServiceNamespace) {
 			return
 		}
 
+		if svc.Global {
+			d.deregisterGlobalService(delSN)
+		}
+
 		delete(d.loadBalancer.K8sServices, delSN)
 		delete(d.loadBalancer.K8sEndpoints, delSN)
 	}
@@ -1031,6 +1271,10 @@ func (d *Daemon) syncLB(newSN, modSN, delSN *types.K8sServiceNamespace) {
 				logfields.K8sNamespace: addSN.Namespace,
 			}).Error("Unable to add k8s service")
 		}
+
+		if svcInfo.Global {
+			d.registerGlobalService(addSN, endpoint)
+		}
 	}
 
 	if delSN != nil {
@@ -1462,22 +1706,11 @@ func (d *Daemon) updateCiliumNetworkPolicyV2(ciliumV2Store cache.Store,
 }
 
 func (d *Daemon) addK8sNodeV1(k8sNode *v1.Node) {
-	ni := node.Identity{Name: k8sNode.ObjectMeta.Name}
 	n := k8s.ParseNode(k8sNode)
+	ni := n.Identity()
 
-	routeTypes := node.TunnelRoute
-
-	// Add IPv6 routing only in non encap. With encap we do it with bpf tunnel
 	// FIXME create a function to know on which mode is the daemon running on
-	var ownAddr net.IP
-	if autoIPv6NodeRoutes && option.Config.Device != "undefined" {
-		// ignore own node
-		if n.Name != node.GetName() {
-			ownAddr = node.GetIPv6()
-			routeTypes |= node.DirectRoute
-		}
-	}
-
+	routeTypes, ownAddr := nodeRouteTypes(n)
 	node.UpdateNode(ni, n, routeTypes, ownAddr)
 
 	log.WithFields(logrus.Fields{
@@ -1489,7 +1722,7 @@ func (d *Daemon) addK8sNodeV1(k8sNode *v1.Node) {
 
 func (d *Daemon) updateK8sNodeV1(_, k8sNode *v1.Node) {
 	newNode := k8s.ParseNode(k8sNode)
-	ni := node.Identity{Name: k8sNode.ObjectMeta.Name}
+	ni := newNode.Identity()
 
 	oldNode := node.GetNode(ni)
 
@@ -1499,17 +1732,8 @@ func (d *Daemon) updateK8sNodeV1(_, k8sNode *v1.Node) {
 		return
 	}
 
-	routeTypes := node.TunnelRoute
 	// Always re-add the routing tables as they might be accidentally removed
-	var ownAddr net.IP
-	if autoIPv6NodeRoutes && option.Config.Device != "undefined" {
-		// ignore own node
-		if newNode.Name != node.GetName() {
-			ownAddr = node.GetIPv6()
-			routeTypes |= node.DirectRoute
-		}
-	}
-
+	routeTypes, ownAddr := nodeRouteTypes(newNode)
 	node.UpdateNode(ni, newNode, routeTypes, ownAddr)
 
 	log.WithFields(logrus.Fields{
@@ -1520,7 +1744,10 @@ func (d *Daemon) updateK8sNodeV1(_, k8sNode *v1.Node) {
 }
 
 func (d *Daemon) deleteK8sNodeV1(k8sNode *v1.Node) {
-	ni := node.Identity{Name: k8sNode.ObjectMeta.Name}
+	ni := node.Identity{
+		Name:    k8sNode.ObjectMeta.Name,
+		Cluster: option.Config.ClusterName,
+	}
 
 	node.DeleteNode(ni, node.TunnelRoute|node.DirectRoute)
 
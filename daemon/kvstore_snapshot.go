@@ -0,0 +1,105 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/cilium/cilium/api/v1/models"
+	. "github.com/cilium/cilium/api/v1/server/restapi/daemon"
+	"github.com/cilium/cilium/pkg/apierror"
+	"github.com/cilium/cilium/pkg/kvstore"
+	"github.com/cilium/cilium/pkg/kvstore/snapshot"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+type getKvstoreSnapshot struct {
+	daemon *Daemon
+}
+
+// NewGetKvstoreSnapshotHandler returns the handler exporting the state
+// stored in the kvstore
+func NewGetKvstoreSnapshotHandler(d *Daemon) GetKvstoreSnapshotHandler {
+	return &getKvstoreSnapshot{daemon: d}
+}
+
+func (h *getKvstoreSnapshot) Handle(params GetKvstoreSnapshotParams) middleware.Responder {
+	s, err := snapshot.Export(kvstore.Client())
+	if err != nil {
+		return apierror.Error(GetKvstoreSnapshotFailureCode, err)
+	}
+
+	m, err := s.GetModel()
+	if err != nil {
+		return apierror.Error(GetKvstoreSnapshotFailureCode, err)
+	}
+
+	return NewGetKvstoreSnapshotOK().WithPayload(m)
+}
+
+type putKvstoreSnapshot struct {
+	daemon *Daemon
+}
+
+// NewPutKvstoreSnapshotHandler returns the handler importing a snapshot of
+// the state stored in the kvstore
+func NewPutKvstoreSnapshotHandler(d *Daemon) PutKvstoreSnapshotHandler {
+	return &putKvstoreSnapshot{daemon: d}
+}
+
+func (h *putKvstoreSnapshot) Handle(params PutKvstoreSnapshotParams) middleware.Responder {
+	s, err := snapshot.NewFromModel(params.Snapshot)
+	if err != nil {
+		return apierror.Error(PutKvstoreSnapshotInvalidCode, err)
+	}
+
+	opts := snapshot.ImportOptions{Owner: h.daemon.GetNodeSuffix()}
+	if params.DryRun != nil {
+		opts.DryRun = *params.DryRun
+	}
+
+	scopedLog := log.WithFields(logrus.Fields{
+		"prefix":  s.Prefix,
+		"dryRun":  opts.DryRun,
+		"entries": len(s.Entries),
+	})
+	scopedLog.Info("Importing kvstore snapshot")
+
+	result, err := snapshot.Import(kvstore.Client(), s, opts)
+	switch err.(type) {
+	case nil:
+	case *snapshot.ConflictError:
+		scopedLog.WithError(err).Warning("Refusing to import conflicting kvstore snapshot")
+		return apierror.Error(PutKvstoreSnapshotConflictCode, err)
+	case *snapshot.InvalidError:
+		return apierror.Error(PutKvstoreSnapshotInvalidCode, err)
+	default:
+		scopedLog.WithError(err).Warning("Unable to import kvstore snapshot")
+		return apierror.Error(PutKvstoreSnapshotFailureCode, err)
+	}
+
+	scopedLog.WithFields(logrus.Fields{
+		"created":   result.Created,
+		"unchanged": result.Unchanged,
+		"skipped":   result.Skipped,
+	}).Info("Imported kvstore snapshot")
+
+	return NewPutKvstoreSnapshotOK().WithPayload(&models.KvstoreSnapshotImportResult{
+		Created:   int64(result.Created),
+		Unchanged: int64(result.Unchanged),
+		Skipped:   int64(result.Skipped),
+		DryRun:    opts.DryRun,
+	})
+}
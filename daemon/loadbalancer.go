@@ -16,7 +16,11 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"reflect"
+	"time"
 
+	"github.com/cilium/cilium/api/v1/models"
 	. "github.com/cilium/cilium/api/v1/server/restapi/service"
 	"github.com/cilium/cilium/common/types"
 	"github.com/cilium/cilium/pkg/apierror"
@@ -31,8 +35,10 @@ import (
 
 // addSVC2BPFMap adds the given bpf service to the bpf maps. If addRevNAT is set, adds the
 // RevNAT value (feCilium.L3n4Addr) to the lb's RevNAT map for the given feCilium.ID.
+// The backends in drainingBPF only keep serving their established connections.
 func (d *Daemon) addSVC2BPFMap(feCilium types.L3n4AddrID, feBPF lbmap.ServiceKey,
-	besBPF []lbmap.ServiceValue, addRevNAT bool) error {
+	besBPF, drainingBPF []lbmap.ServiceValue, affinity types.SessionAffinityConfig,
+	mode types.ForwardingMode, addRevNAT bool) error {
 	log.WithField(logfields.ServiceName, feCilium.String()).Debug("adding service to BPF maps")
 
 	// Try to delete service before adding it and ignore errors as it might not exist.
@@ -41,7 +47,7 @@ func (d *Daemon) addSVC2BPFMap(feCilium types.L3n4AddrID, feBPF lbmap.ServiceKey
 		log.WithError(err).WithField(logfields.ServiceName, feCilium.L3n4Addr.String()).Debug("error deleting service before adding it")
 	}
 
-	err = lbmap.AddSVC2BPFMap(feBPF, besBPF, addRevNAT, int(feCilium.ID))
+	err = lbmap.AddSVC2BPFMap(feBPF, besBPF, drainingBPF, addRevNAT, int(feCilium.ID))
 	if err != nil {
 		if addRevNAT {
 			delete(d.loadBalancer.RevNATMap, feCilium.ID)
@@ -49,6 +55,16 @@ func (d *Daemon) addSVC2BPFMap(feCilium types.L3n4AddrID, feBPF lbmap.ServiceKey
 		return err
 	}
 
+	err = lbmap.UpdateSessionAffinity(feBPF, besBPF, affinity.GetTimeoutSec())
+	if err != nil {
+		return fmt.Errorf("unable to update session affinity of %s: %s", feCilium.String(), err)
+	}
+
+	err = lbmap.UpdateDSR(feBPF, mode.IsDSR())
+	if err != nil {
+		return fmt.Errorf("unable to update forwarding mode of %s: %s", feCilium.String(), err)
+	}
+
 	if addRevNAT {
 		log.WithField(logfields.ServiceName, feCilium.String()).Debug("adding service to RevNATMap")
 		d.loadBalancer.RevNATMap[feCilium.ID] = *feCilium.L3n4Addr.DeepCopy()
@@ -61,7 +77,8 @@ func (d *Daemon) addSVC2BPFMap(feCilium types.L3n4AddrID, feBPF lbmap.ServiceKey
 // returned to the caller.
 //
 // Returns true if service was created.
-func (d *Daemon) SVCAdd(feL3n4Addr types.L3n4AddrID, be []types.LBBackEnd, addRevNAT bool) (bool, error) {
+func (d *Daemon) SVCAdd(svc types.LBSVC, addRevNAT bool) (bool, error) {
+	feL3n4Addr := svc.FE
 	log.WithField(logfields.ServiceID, feL3n4Addr.String()).Debug("adding service")
 	if feL3n4Addr.ID == 0 {
 		return false, fmt.Errorf("invalid service ID 0")
@@ -89,50 +106,221 @@ func (d *Daemon) SVCAdd(feL3n4Addr types.L3n4AddrID, be []types.LBBackEnd, addRe
 		return false, fmt.Errorf("service ID %d is already registered to L3n4Addr %s, please choose a different ID", feL3n4Addr.ID, feAddr.String())
 	}
 
-	return d.svcAdd(feL3n4Addr, be, addRevNAT)
+	return d.svcAdd(svc, addRevNAT)
 }
 
-// svcAdd adds a service from the given feL3n4Addr (frontend) and LBBackEnd (backends).
-// If addRevNAT is set, the RevNAT entry is also created for this particular service.
-// If any of the backend addresses set in bes have a different L3 address type than the
-// one set in fe, it returns an error without modifying the bpf LB map. If any backend
+// svcAdd adds the service svc with the frontend svc.FE and the backends
+// svc.BES. If addRevNAT is set, the RevNAT entry is also created for this
+// particular service. The frontend is of type svc.Type and the session
+// affinity of the service is configured according to svc.Affinity. The
+// traffic is forwarded to the backends according to svc.Mode. If
+// svc.HealthCheck is enabled, the backends are health checked and only the
+// healthy backends are added to the LB map. If draining is enabled, the
+// backends removed from the service keep serving their established
+// connections. The metadata svc.Meta of the service is persisted to be
+// restored by SyncLBMap. svc.Sha256 is set by svcAdd.
+// If any of the backend addresses have a different L3 address type than the
+// frontend, it returns an error without modifying the bpf LB map. If any backend
 // entry fails while updating the LB map, the frontend won't be inserted in the LB map
 // therefore there won't be any traffic going to the given backends.
 // All of the backends added will be DeepCopied to the internal load balancer map.
-func (d *Daemon) svcAdd(feL3n4Addr types.L3n4AddrID, bes []types.LBBackEnd, addRevNAT bool) (bool, error) {
+func (d *Daemon) svcAdd(svc types.LBSVC, addRevNAT bool) (bool, error) {
 	log.WithFields(logrus.Fields{
-		logfields.ServiceID: feL3n4Addr.String(),
-		logfields.Object:    logfields.Repr(bes),
+		logfields.ServiceID: svc.FE.String(),
+		logfields.Object:    logfields.Repr(svc.BES),
 	}).Debug("adding service")
 
 	// Move the slice to the loadbalancer map which has a mutex. If we don't
 	// copy the slice we might risk changing memory that should be locked.
 	beCpy := []types.LBBackEnd{}
-	for _, v := range bes {
+	for _, v := range svc.BES {
 		beCpy = append(beCpy, v)
 	}
+	svc.BES = beCpy
+	svc.Sha256 = svc.FE.L3n4Addr.SHA256Sum()
 
-	svc := types.LBSVC{
-		FE:     feL3n4Addr,
-		BES:    beCpy,
-		Sha256: feL3n4Addr.L3n4Addr.SHA256Sum(),
+	// Validate the service before starting to health check it
+	if _, _, err := lbmap.LBSVC2ServiceKeynValue(svc); err != nil {
+		return false, err
 	}
 
-	fe, besValues, err := lbmap.LBSVC2ServiceKeynValue(svc)
+	d.loadBalancer.BPFMapMU.Lock()
+	defer d.loadBalancer.BPFMapMU.Unlock()
 
-	if err != nil {
+	old, exists := d.loadBalancer.SVCMap[svc.Sha256]
+	if exists && option.Config.IsLBDrainingEnabled() {
+		svc.AddDrainingBackends(old.BES, time.Now().Add(option.Config.LBDrainTimeout))
+	}
+
+	beAddrs := make([]types.L3n4Addr, 0, len(beCpy))
+	for _, be := range beCpy {
+		beAddrs = append(beAddrs, be.L3n4Addr)
+	}
+	d.lbHealth.Update(svc.Sha256, svc.HealthCheck, beAddrs)
+
+	// The metadata is only persisted again if it changed, replacing a
+	// service with the same metadata leaves the persisted state intact
+	if !exists || !reflect.DeepEqual(old.Meta, svc.Meta) {
+		d.serviceMetaChanged = true
+	}
+
+	if err := d.addHealthySVC2BPFMap(&svc, addRevNAT); err != nil {
+		d.lbHealth.Remove(svc.Sha256)
 		return false, err
 	}
 
+	created := d.loadBalancer.AddService(svc)
+
+	return created, nil
+}
+
+// addHealthySVC2BPFMap updates the health of the backends of svc and adds
+// svc with its healthy backends to the BPF maps. The draining backends of
+// svc are added after them to keep serving their established connections.
+//
+// Must be called with d.loadBalancer.BPFMapMU locked.
+func (d *Daemon) addHealthySVC2BPFMap(svc *types.LBSVC, addRevNAT bool) error {
+	for i := range svc.BES {
+		if !svc.BES[i].IsDraining() {
+			svc.BES[i].Health = d.lbHealth.GetHealth(svc.Sha256, svc.BES[i].L3n4Addr)
+		}
+	}
+
+	healthySVC := *svc
+	healthySVC.BES = svc.GetHealthyBackends()
+
+	fe, besValues, err := lbmap.LBSVC2ServiceKeynValue(healthySVC)
+	if err != nil {
+		return err
+	}
+
+	drainingSVC := *svc
+	drainingSVC.BES = svc.GetDrainingBackends()
+
+	_, drainingValues, err := lbmap.LBSVC2ServiceKeynValue(drainingSVC)
+	if err != nil {
+		return err
+	}
+
+	return d.addSVC2BPFMap(svc.FE, fe, besValues, drainingValues, svc.Affinity, svc.Mode, addRevNAT)
+}
+
+// retireDrainedBackends removes the draining backends from all services
+// which have no active connections left or whose drain timeout has expired.
+func (d *Daemon) retireDrainedBackends() error {
 	d.loadBalancer.BPFMapMU.Lock()
 	defer d.loadBalancer.BPFMapMU.Unlock()
 
-	err = d.addSVC2BPFMap(feL3n4Addr, fe, besValues, addRevNAT)
+	shas := []string{}
+	for sha, svc := range d.loadBalancer.SVCMap {
+		if len(svc.GetDrainingBackends()) != 0 {
+			shas = append(shas, sha)
+		}
+	}
+
+	now := time.Now()
+	for _, sha := range shas {
+		svc := d.loadBalancer.SVCMap[sha]
+		scopedLog := log.WithField(logfields.ServiceName, svc.FE.String())
+
+		drainingSVC := svc
+		drainingSVC.BES = svc.GetDrainingBackends()
+		fe, drainingValues, err := lbmap.LBSVC2ServiceKeynValue(drainingSVC)
+		if err != nil {
+			scopedLog.WithError(err).Warn("Unable to convert draining backends")
+			continue
+		}
+
+		// The backends are only retired on timeout if their
+		// connections cannot be counted
+		conns, err := lbmap.CountConnections(fe, drainingValues)
+		if err != nil {
+			scopedLog.WithError(err).Warn("Unable to count connections of draining backends")
+			conns = nil
+		}
+
+		bes := []types.LBBackEnd{}
+		retired := false
+		i := 0
+		for _, be := range svc.BES {
+			if be.IsDraining() {
+				idle := conns != nil && conns[i] == 0
+				i++
+				if idle || now.After(be.DrainDeadline) {
+					scopedLog.WithFields(logrus.Fields{
+						"backend": be.L3n4Addr.String(),
+						"idle":    idle,
+					}).Info("Removing drained service backend")
+					retired = true
+					continue
+				}
+			}
+			bes = append(bes, be)
+		}
+
+		if !retired {
+			continue
+		}
+
+		svc.BES = bes
+		// The reverse NAT entry of the service is left untouched
+		if err := d.addHealthySVC2BPFMap(&svc, false); err != nil {
+			scopedLog.WithError(err).Error("Unable to remove drained service backends")
+			continue
+		}
+		d.loadBalancer.AddService(svc)
+	}
+
+	return nil
+}
+
+// updateBackendHealth updates the BPF maps of the service with the given
+// SHA256 sum after the health of one of its backends has changed.
+func (d *Daemon) updateBackendHealth(sha256 string) {
+	d.loadBalancer.BPFMapMU.Lock()
+	defer d.loadBalancer.BPFMapMU.Unlock()
+
+	svc, ok := d.loadBalancer.SVCMap[sha256]
+	if !ok {
+		return
+	}
+
+	// The service is stored by value, the backends must be copied before
+	// updating their health.
+	svc.BES = append([]types.LBBackEnd(nil), svc.BES...)
+
+	// The reverse NAT entry of the service is left untouched
+	if err := d.addHealthySVC2BPFMap(&svc, false); err != nil {
+		log.WithError(err).WithField(logfields.ServiceName, svc.FE.String()).
+			Error("Unable to update service backends after health change")
+		d.lbHealth.Remove(sha256)
+		return
+	}
+
+	d.loadBalancer.AddService(svc)
+}
+
+// getServiceForwarding returns the forwarding mode of the service spec and
+// whether the reverse translation of the service is installed. The
+// direct-server-return flag only controls the reverse translation which DSR
+// services require for the replies of connections which are not carried to
+// the backend node in DSR mode, e.g. if DSR is disabled on the agent.
+func getServiceForwarding(spec *models.ServiceSpec) (types.ForwardingMode, bool, error) {
+	revNAT := false
+	if spec.Flags != nil {
+		revNAT = spec.Flags.DirectServerReturn
+	}
+
+	mode, err := types.NewForwardingMode(spec.ForwardingMode)
 	if err != nil {
-		return false, err
+		return mode, revNAT, err
+	}
+
+	if mode.IsDSR() && !revNAT {
+		return mode, revNAT, fmt.Errorf("forwarding mode %s requires the reverse translation of the service", mode)
 	}
 
-	return d.loadBalancer.AddService(svc), nil
+	return mode, revNAT, nil
 }
 
 type putServiceID struct {
@@ -146,7 +334,25 @@ func NewPutServiceIDHandler(d *Daemon) PutServiceIDHandler {
 func (h *putServiceID) Handle(params PutServiceIDParams) middleware.Responder {
 	log.WithField(logfields.Params, logfields.Repr(params)).Debug("PUT /service/{id} request")
 
-	f, err := types.NewL3n4AddrFromModel(params.Config.FrontendAddress)
+	mode, revnat, err := getServiceForwarding(params.Config)
+	if err != nil {
+		return apierror.Error(PutServiceIDInvalidFrontendCode, err)
+	}
+
+	feModel := params.Config.FrontendAddress
+	if feModel != nil {
+		ip, err := h.d.reserveFrontendIP(feModel.IP, types.ServiceID(params.Config.ID))
+		if err != nil {
+			return apierror.Error(PutServiceIDInvalidFrontendCode, err)
+		}
+		feModel = &models.FrontendAddress{
+			IP:       ip.String(),
+			Port:     feModel.Port,
+			Protocol: feModel.Protocol,
+		}
+	}
+
+	f, err := types.NewL3n4AddrFromModel(feModel)
 	if err != nil {
 		return apierror.Error(PutServiceIDInvalidFrontendCode, err)
 	}
@@ -165,16 +371,58 @@ func (h *putServiceID) Handle(params PutServiceIDParams) middleware.Responder {
 		backends = append(backends, *b)
 	}
 
-	revnat := false
-	if params.Config.Flags != nil {
-		revnat = params.Config.Flags.DirectServerReturn
+	feType := types.FrontendTypeClusterIP
+	if params.Config.FrontendType != "" {
+		feType = types.FrontendType(params.Config.FrontendType)
+	}
+
+	if params.Config.SessionAffinityTimeout < 0 || params.Config.SessionAffinityTimeout > math.MaxUint32 {
+		return apierror.Error(PutServiceIDFailureCode,
+			fmt.Errorf("invalid session affinity timeout %d", params.Config.SessionAffinityTimeout))
+	}
+	affinity, err := types.NewSessionAffinityConfig(params.Config.SessionAffinity,
+		uint32(params.Config.SessionAffinityTimeout))
+	if err != nil {
+		return apierror.Error(PutServiceIDFailureCode, err)
+	}
+
+	healthCheck := types.HealthCheckConfig{}
+	if hc := params.Config.HealthCheck; hc != nil {
+		healthCheck, err = types.NewHealthCheckConfig(hc.Type, hc.Path, hc.Interval, hc.Timeout)
+		if err != nil {
+			return apierror.Error(PutServiceIDFailureCode, err)
+		}
+	}
+
+	source, err := types.NewServiceSource(params.Config.Source)
+	if err != nil {
+		return apierror.Error(PutServiceIDFailureCode, err)
+	}
+	meta := types.ServiceMeta{
+		Name:      params.Config.Name,
+		Namespace: params.Config.Namespace,
+		PortName:  types.FEPortName(params.Config.PortName),
+		Source:    source,
+		Labels:    params.Config.Labels,
 	}
 
 	// FIXME
 	// Add flag to indicate whether service should be registered in
 	// global key value store
 
-	if created, err := h.d.SVCAdd(frontend, backends, revnat); err != nil {
+	svc := types.LBSVC{
+		FE:          frontend,
+		BES:         backends,
+		Type:        feType,
+		Affinity:    affinity,
+		Mode:        mode,
+		HealthCheck: healthCheck,
+		Meta:        meta,
+	}
+	if created, err := h.d.SVCAdd(svc, revnat); err != nil {
+		if !h.d.hasFrontendIP(frontend.ID, frontend.IP) {
+			h.d.releaseFrontendIP(frontend.IP, frontend.ID)
+		}
 		return apierror.Error(PutServiceIDFailureCode, err)
 	} else if created {
 		return NewPutServiceIDCreated()
@@ -211,10 +459,18 @@ func (h *deleteServiceID) Handle(params DeleteServiceIDParams) middleware.Respon
 		log.WithError(err).Warn("error, DeleteL3n4AddrIDByUUID failed")
 	}
 
+	h.d.lbHealth.Remove(svc.Sha256)
+	deleteServiceStats(&svc.FE.L3n4Addr)
+
 	if err := h.d.svcDelete(svc); err != nil {
 		log.WithError(err).WithField(logfields.Object, logfields.Repr(svc)).Warn("DELETE /service/{id}: error deleting service")
 		return apierror.Error(DeleteServiceIDFailureCode, err)
 	}
+	if !svc.Meta.IsEmpty() {
+		h.d.serviceMetaChanged = true
+	}
+
+	h.d.releaseFrontendIP(svc.FE.IP, svc.FE.ID)
 
 	return NewDeleteServiceIDOK()
 }
@@ -232,7 +488,18 @@ func (d *Daemon) svcDeleteByFrontend(frontend *types.L3n4Addr) error {
 	d.loadBalancer.BPFMapMU.Lock()
 	defer d.loadBalancer.BPFMapMU.Unlock()
 
-	return d.svcDeleteByFrontendLocked(frontend)
+	d.lbHealth.Remove(frontend.SHA256Sum())
+	deleteServiceStats(frontend)
+
+	svc, ok := d.loadBalancer.SVCMap[frontend.SHA256Sum()]
+	if err := d.svcDeleteByFrontendLocked(frontend); err != nil {
+		return err
+	}
+	if ok && !svc.Meta.IsEmpty() {
+		d.serviceMetaChanged = true
+	}
+
+	return nil
 }
 
 func (d *Daemon) svcDelete(svc *types.LBSVC) error {
@@ -263,6 +530,23 @@ func (d *Daemon) svcDeleteBPF(svc *types.LBSVC) error {
 	vval := val.(lbmap.ServiceValue)
 	numBackends := uint16(vval.GetCount())
 
+	// The slots of draining backends follow the slots of the backends
+	// and are not part of the count of the master.
+	for i := numBackends + 1; ; i++ {
+		var slaveKey lbmap.ServiceKey
+		if !svc.FE.IsIPv6() {
+			slaveKey = lbmap.NewService4Key(svc.FE.IP, svc.FE.Port, i)
+		} else {
+			slaveKey = lbmap.NewService6Key(svc.FE.IP, svc.FE.Port, i)
+		}
+		if _, err := lbmap.LookupService(slaveKey); err != nil {
+			break
+		}
+		if err := lbmap.DeleteService(slaveKey); err != nil {
+			return fmt.Errorf("deleting draining service failed for %s: %s", slaveKey, err)
+		}
+	}
+
 	// ServiceKeys are unique by their slave number, which corresponds to the number of backends. Delete each of these.
 	for i := numBackends; i > 0; i-- {
 		var slaveKey lbmap.ServiceKey
@@ -306,7 +590,9 @@ func (h *getServiceID) Handle(params GetServiceIDParams) middleware.Responder {
 	defer d.loadBalancer.BPFMapMU.RUnlock()
 
 	if svc, ok := d.loadBalancer.SVCMapID[types.ServiceID(params.ID)]; ok {
-		return NewGetServiceIDOK().WithPayload(svc.GetModel())
+		model := svc.GetModel()
+		addServiceStatsToModel(dumpServiceStats(), model)
+		return NewGetServiceIDOK().WithPayload(model)
 	}
 	return NewGetServiceIDNotFound()
 }
@@ -328,8 +614,13 @@ func (d *Daemon) svcGetBySHA256Sum(feL3n4SHA256Sum string) *types.LBSVC {
 		beCpy = append(beCpy, v)
 	}
 	return &types.LBSVC{
-		FE:  *v.FE.DeepCopy(),
-		BES: beCpy,
+		FE:          *v.FE.DeepCopy(),
+		BES:         beCpy,
+		Type:        v.Type,
+		Affinity:    v.Affinity,
+		Mode:        v.Mode,
+		HealthCheck: v.HealthCheck,
+		Meta:        v.Meta,
 	}
 }
 
@@ -452,6 +743,7 @@ func (d *Daemon) SyncLBMap() error {
 	newRevNATMap := types.RevNATMap{}
 	failedSyncSVC := []types.LBSVC{}
 	failedSyncRevNAT := map[types.ServiceID]types.L3n4Addr{}
+	svcMeta := loadServiceMeta()
 
 	addSVC2BPFMap := func(oldID types.ServiceID, svc types.LBSVC) error {
 		scopedLog := log.WithFields(logrus.Fields{
@@ -488,13 +780,22 @@ func (d *Daemon) SyncLBMap() error {
 			newRevNATMap[svc.FE.ID] = revNAT
 		}
 
-		fe, besValues, err := lbmap.LBSVC2ServiceKeynValue(svc)
+		activeSVC, drainingSVC := svc, svc
+		activeSVC.BES = svc.GetHealthyBackends()
+		drainingSVC.BES = svc.GetDrainingBackends()
+
+		fe, besValues, err := lbmap.LBSVC2ServiceKeynValue(activeSVC)
 		if err != nil {
 			return fmt.Errorf("Unable to create a BPF key and values for service FE: %s and backends: %+v. Error: %s."+
 				" This entry will be removed from the bpf's LB map.", svc.FE.String(), svc.BES, err)
 		}
+		_, drainingValues, err := lbmap.LBSVC2ServiceKeynValue(drainingSVC)
+		if err != nil {
+			return fmt.Errorf("Unable to create a BPF key and values for service FE: %s and draining backends: %+v. Error: %s."+
+				" This entry will be removed from the bpf's LB map.", svc.FE.String(), drainingSVC.BES, err)
+		}
 
-		err = d.addSVC2BPFMap(svc.FE, fe, besValues, false)
+		err = d.addSVC2BPFMap(svc.FE, fe, besValues, drainingValues, svc.Affinity, svc.Mode, false)
 		if err != nil {
 			return fmt.Errorf("Unable to add service FE: %s: %s."+
 				" This entry will be removed from the bpf's LB map.", svc.FE.String(), err)
@@ -502,6 +803,25 @@ func (d *Daemon) SyncLBMap() error {
 		return nil
 	}
 
+	// The number of backends of each frontend, the slots following them
+	// belong to draining backends.
+	numBackends := map[string]int{}
+	parseMasterEntries := func(key bpf.MapKey, value bpf.MapValue) {
+		svcKey := key.(lbmap.ServiceKey)
+		if svcKey.GetBackend() != 0 {
+			return
+		}
+		svcValue := value.(lbmap.ServiceValue)
+		fe, _, err := lbmap.ServiceKeynValue2FEnBE(svcKey, svcValue)
+		if err != nil {
+			log.WithError(err).WithField(logfields.BPFMapKey, svcKey).Error("SyncLBMap.parseMasterEntries")
+			return
+		}
+		numBackends[fe.SHA256Sum()] = svcValue.GetCount()
+	}
+
+	drainDeadline := time.Now().Add(option.Config.LBDrainTimeout)
+
 	parseSVCEntries := func(key bpf.MapKey, value bpf.MapValue) {
 		svcKey := key.(lbmap.ServiceKey)
 		//It's the frontend service so we don't add this one
@@ -522,7 +842,30 @@ func (d *Daemon) SyncLBMap() error {
 			return
 		}
 
+		if svcKey.GetBackend() > numBackends[fe.SHA256Sum()] {
+			if !option.Config.IsLBDrainingEnabled() {
+				scopedLog.Debug("ignoring draining backend")
+				return
+			}
+			be.DrainDeadline = drainDeadline
+		}
+
 		svc := newSVCMap.AddFEnBE(fe, be, svcKey.GetBackend())
+		if timeout := lbmap.LookupSessionAffinity(svcKey); timeout != 0 {
+			svc.Affinity = types.SessionAffinityConfig{
+				Mode:       types.SessionAffinityClientIP,
+				TimeoutSec: timeout,
+			}
+			newSVCMap[svc.Sha256] = *svc
+		}
+		if lbmap.LookupDSR(svcKey) {
+			svc.Mode = types.ForwardingModeDSR
+			newSVCMap[svc.Sha256] = *svc
+		}
+		if meta, ok := svcMeta[svc.Sha256]; ok {
+			svc.Meta = meta
+			newSVCMap[svc.Sha256] = *svc
+		}
 		newSVCList = append(newSVCList, svc)
 	}
 
@@ -546,6 +889,9 @@ func (d *Daemon) SyncLBMap() error {
 	if !option.Config.IPv4Disabled {
 		// lbmap.RRSeq4Map is updated as part of Service4Map and does
 		// not need separate dump.
+		if err := lbmap.Service4Map.DumpWithCallback(parseMasterEntries); err != nil {
+			log.WithError(err).Warn("error dumping Service4Map")
+		}
 		if err := lbmap.Service4Map.DumpWithCallback(parseSVCEntries); err != nil {
 			log.WithError(err).Warn("error dumping Service4Map")
 		}
@@ -556,6 +902,9 @@ func (d *Daemon) SyncLBMap() error {
 
 	// lbmap.RRSeq6Map is updated as part of Service6Map and does not need
 	// separate dump.
+	if err := lbmap.Service6Map.DumpWithCallback(parseMasterEntries); err != nil {
+		log.WithError(err).Warn("error dumping Service6Map")
+	}
 	if err := lbmap.Service6Map.DumpWithCallback(parseSVCEntries); err != nil {
 		log.WithError(err).Warn("error dumping Service6Map")
 	}
@@ -650,5 +999,8 @@ func (d *Daemon) SyncLBMap() error {
 	d.loadBalancer.SVCMapID = newSVCMapID
 	d.loadBalancer.RevNATMap = newRevNATMap
 
+	// Drop the metadata of the services which were not restored
+	d.serviceMetaChanged = true
+
 	return nil
 }
@@ -0,0 +1,135 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/controller"
+	"github.com/cilium/cilium/pkg/endpoint"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+
+	"github.com/sirupsen/logrus"
+)
+
+const localBackendsControllerName = "sync-local-backends"
+
+// localBackendObserver resynchronizes services preferring node-local backends
+// when local endpoints are created or deleted. The backends selected for
+// these services depend on which endpoints are managed by this node, so they
+// have to be re-selected whenever one of their backends comes or goes.
+type localBackendObserver struct {
+	d           *Daemon
+	controllers *controller.Manager
+
+	// mutex protects changed
+	mutex lock.Mutex
+
+	// changed is the set of endpoint IPs created or deleted since the
+	// last resync
+	changed map[string]struct{}
+}
+
+func newLocalBackendObserver(d *Daemon) *localBackendObserver {
+	return &localBackendObserver{
+		d:           d,
+		controllers: controller.NewManager(),
+		changed:     map[string]struct{}{},
+	}
+}
+
+// EndpointCreated is called when ep has been added to the endpointmanager
+func (o *localBackendObserver) EndpointCreated(ep *endpoint.Endpoint) {
+	o.endpointChanged(ep)
+}
+
+// EndpointDeleted is called when ep has been removed from the endpointmanager
+func (o *localBackendObserver) EndpointDeleted(ep *endpoint.Endpoint) {
+	o.endpointChanged(ep)
+}
+
+// endpointChanged records the IPs of ep and schedules a resync. The resync is
+// performed by a controller as the endpointmanager is locked while
+// subscribers are notified.
+func (o *localBackendObserver) endpointChanged(ep *endpoint.Endpoint) {
+	o.mutex.Lock()
+	for _, ip := range []string{ep.IPv4.String(), ep.IPv6.String()} {
+		if ip != "" {
+			o.changed[ip] = struct{}{}
+		}
+	}
+	o.mutex.Unlock()
+
+	o.controllers.UpdateController(localBackendsControllerName,
+		controller.ControllerParams{
+			DoFunc: o.resync,
+		},
+	)
+}
+
+// resync re-adds all services preferring local backends which have a backend
+// on one of the changed endpoint IPs.
+func (o *localBackendObserver) resync() error {
+	o.mutex.Lock()
+	changed := o.changed
+	o.changed = map[string]struct{}{}
+	o.mutex.Unlock()
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	o.d.loadBalancer.K8sMU.Lock()
+	defer o.d.loadBalancer.K8sMU.Unlock()
+
+	for _, svcns := range o.d.localBackendServices(changed) {
+		svcns := svcns
+		log.WithFields(logrus.Fields{
+			logfields.K8sSvcName:   svcns.ServiceName,
+			logfields.K8sNamespace: svcns.Namespace,
+		}).Debug("Resynchronizing local backends of service")
+		o.d.syncLB(&svcns, nil, nil)
+	}
+
+	return nil
+}
+
+// localBackendServices returns all services preferring local backends which
+// have a backend on one of the given IPs.
+//
+// Must be called with d.loadBalancer.K8sMU locked.
+func (d *Daemon) localBackendServices(ips map[string]struct{}) []types.K8sServiceNamespace {
+	var svcs []types.K8sServiceNamespace
+
+	for svcns, svcInfo := range d.loadBalancer.K8sServices {
+		if !svcInfo.PreferLocal && !svcInfo.ExternalTrafficLocal {
+			continue
+		}
+
+		se, ok := d.loadBalancer.K8sEndpoints[svcns]
+		if !ok {
+			continue
+		}
+
+		for ip := range se.BEIPs {
+			if _, ok := ips[ip]; ok {
+				svcs = append(svcs, svcns)
+				break
+			}
+		}
+	}
+
+	return svcs
+}
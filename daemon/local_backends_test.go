@@ -0,0 +1,58 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+
+	"github.com/cilium/cilium/common/types"
+
+	. "gopkg.in/check.v1"
+)
+
+func (ds *DaemonSuite) TestLocalBackendServices(c *C) {
+	local := types.K8sServiceNamespace{ServiceName: "local", Namespace: "default"}
+	external := types.K8sServiceNamespace{ServiceName: "external", Namespace: "default"}
+	cluster := types.K8sServiceNamespace{ServiceName: "cluster", Namespace: "default"}
+
+	ds.d.loadBalancer.K8sMU.Lock()
+	for _, svcns := range []types.K8sServiceNamespace{local, external, cluster} {
+		ds.d.loadBalancer.K8sServices[svcns] = types.NewK8sServiceInfo(net.ParseIP("10.96.0.1"), false, nil, nil)
+		se := types.NewK8sServiceEndpoint()
+		se.BEIPs["10.0.0.1"] = true
+		se.BEIPs["f00d::1"] = true
+		ds.d.loadBalancer.K8sEndpoints[svcns] = se
+	}
+	ds.d.loadBalancer.K8sServices[local].PreferLocal = true
+	ds.d.loadBalancer.K8sServices[external].ExternalTrafficLocal = true
+
+	svcs := ds.d.localBackendServices(map[string]struct{}{"10.0.0.1": {}})
+	c.Assert(len(svcs), Equals, 2)
+	for _, svcns := range svcs {
+		c.Assert(svcns, Not(Equals), cluster)
+	}
+
+	svcs = ds.d.localBackendServices(map[string]struct{}{"f00d::1": {}})
+	c.Assert(len(svcs), Equals, 2)
+
+	svcs = ds.d.localBackendServices(map[string]struct{}{"10.0.0.2": {}})
+	c.Assert(len(svcs), Equals, 0)
+
+	for _, svcns := range []types.K8sServiceNamespace{local, external, cluster} {
+		delete(ds.d.loadBalancer.K8sServices, svcns)
+		delete(ds.d.loadBalancer.K8sEndpoints, svcns)
+	}
+	ds.d.loadBalancer.K8sMU.Unlock()
+}
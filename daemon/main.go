@@ -40,6 +40,7 @@ import (
 	"github.com/cilium/cilium/pkg/labels"
 	"github.com/cilium/cilium/pkg/logging"
 	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/maps/lbmap"
 	"github.com/cilium/cilium/pkg/metrics"
 	"github.com/cilium/cilium/pkg/monitor"
 	"github.com/cilium/cilium/pkg/node"
@@ -84,21 +85,28 @@ var (
 	// autoIPv6NodeRoutes automatically adds L3 direct routing when using direct mode (-d)
 	autoIPv6NodeRoutes    bool
 	bpfRoot               string
+	clusterPoolIPv4CIDR   string
+	clusterPoolIPv4Mask   int
+	clusterPoolIPv6CIDR   string
+	clusterPoolLease      time.Duration
 	cmdRefDir             string
 	debugVerboseFlags     []string
 	disableConntrack      bool
 	dockerEndpoint        string
 	enableLogstash        bool
 	enableTracing         bool
+	ipamPoolsFile         string
 	k8sAPIServer          string
 	k8sKubeConfigPath     string
 	kvStore               string
 	labelPrefixFile       string
+	lbAlgorithm           string
 	loggers               []string
 	logstashAddr          string
 	logstashProbeTimer    uint32
 	masquerade            bool
 	nat46prefix           string
+	nodePortRange         string
 	prometheusServeAddr   string
 	singleClusterRoute    bool
 	socketPath            string
@@ -116,6 +124,8 @@ var (
 var (
 	logOpts               = make(map[string]string)
 	kvStoreOpts           = make(map[string]string)
+	kvStoreRateLimits     = make(map[string]string)
+	kvStoreEncryptionKeys string
 	containerRuntimesOpts = make(map[string]string)
 	cfgFile               string
 
@@ -313,6 +323,20 @@ func init() {
 		"auto-ipv6-node-routes", false, "Automatically adds IPv6 L3 routes to reach other nodes for non-overlay mode (--device) (BETA)")
 	flags.StringVar(&bpfRoot,
 		"bpf-root", "", "Path to BPF filesystem")
+	flags.IntVar(&option.Config.ClusterID,
+		"cluster-id", 0, "Unique identifier of the cluster (0-15)")
+	flags.StringVar(&option.Config.ClusterName,
+		"cluster-name", defaults.ClusterName, "Name of the cluster")
+	flags.StringVar(&clusterPoolIPv4CIDR,
+		"cluster-pool-ipv4-cidr", "", "Cluster wide IPv4 CIDR to lease the IPv4 allocation range of the node from via the kvstore")
+	flags.IntVar(&clusterPoolIPv4Mask,
+		"cluster-pool-ipv4-mask-size", 24, "Mask size of the IPv4 allocation ranges leased from the cluster pool")
+	flags.StringVar(&clusterPoolIPv6CIDR,
+		"cluster-pool-ipv6-cidr", "", "Cluster wide IPv6 CIDR to lease the IPv6 allocation range of the node from via the kvstore")
+	flags.DurationVar(&clusterPoolLease,
+		"cluster-pool-lease-ttl", time.Hour, "Duration after which allocation ranges leased from the cluster pool expire unless renewed")
+	flags.StringVar(&option.Config.ClusterMeshConfig,
+		"clustermesh-config", "", "Path to the ClusterMesh configuration directory")
 	flags.StringVar(&cfgFile,
 		"config", "", `Configuration file (default "$HOME/ciliumd.yaml")`)
 	flags.StringSliceVar(&option.Config.Workloads,
@@ -332,7 +356,15 @@ func init() {
 		false, "Disable east-west K8s load balancing by cilium")
 	flags.StringVarP(&dockerEndpoint,
 		"docker", "e", workloads.GetRuntimeDefaultOpt(workloads.Docker, "endpoint"), "Path to docker runtime socket (DEPRECATED: use container-runtime-endpoint instead)")
+	flags.BoolVar(&option.Config.EnableDSR,
+		"enable-dsr", false, "Enable direct server return of services and forward Kubernetes services in DSR mode, required on the balancing and the backend nodes (requires kernel support for LRU maps)")
+	flags.BoolVar(&option.Config.EnableLBStats,
+		"enable-lb-stats", false, "Enable the collection of per-service and per-backend traffic statistics by the datapath")
+	flags.BoolVar(&option.Config.EnableNodePort,
+		"enable-node-port", false, "Enable NodePort frontends of Kubernetes services on all node addresses and load balance services for traffic entering the node (implies --enable-dsr)")
 	flags.String("enable-policy", option.DefaultEnforcement, "Enable policy enforcement")
+	flags.BoolVar(&option.Config.EnableSessionAffinity,
+		"enable-session-affinity", false, "Enable ClientIP session affinity of services (requires kernel support for LRU maps)")
 	flags.BoolVar(&enableTracing,
 		"enable-tracing", false, "Enable tracing while determining policy (debugging)")
 	flags.String("envoy-log", "", "Path to a separate Envoy log file, if any")
@@ -342,6 +374,8 @@ func init() {
 	flags.MarkHidden("disable-envoy-version-check")
 	// Disable version check if Envoy build is disabled
 	viper.BindEnv("disable-envoy-version-check", "CILIUM_DISABLE_ENVOY_BUILD")
+	flags.StringVar(&ipamPoolsFile,
+		"ipam-pools", "", "Path to a JSON file defining named IPAM pools and the pods they are selected for")
 	flags.IntVar(&v4ClusterCidrMaskSize,
 		"ipv4-cluster-cidr-mask-size", 8, "Mask size for the cluster wide CIDR")
 	flags.StringVar(&v4Prefix,
@@ -349,9 +383,9 @@ func init() {
 	flags.StringVar(&v6Prefix,
 		"ipv6-range", AutoCIDR, "Per-node IPv6 endpoint prefix, must be /96, e.g. fd02:1:1::/96")
 	flags.StringVar(&v4ServicePrefix,
-		"ipv4-service-range", AutoCIDR, "Kubernetes IPv4 services CIDR if not inside cluster prefix")
+		"ipv4-service-range", AutoCIDR, "Kubernetes IPv4 services CIDR if not inside cluster prefix, virtual IPs of API services are allocated from it")
 	flags.StringVar(&v6ServicePrefix,
-		"ipv6-service-range", AutoCIDR, "Kubernetes IPv6 services CIDR if not inside cluster prefix")
+		"ipv6-service-range", AutoCIDR, "Kubernetes IPv6 services CIDR if not inside cluster prefix, virtual IPs of API services are allocated from it")
 	flags.StringVar(&k8sAPIServer,
 		"k8s-api-server", "", "Kubernetes api address server (for https use --k8s-kubeconfig-path instead)")
 	flags.StringVar(&k8sKubeConfigPath,
@@ -364,12 +398,20 @@ func init() {
 		"kvstore", "", "Key-value store type")
 	flags.Var(option.NewNamedMapOptions("kvstore-opts", &kvStoreOpts, nil),
 		"kvstore-opt", "Key-value store options")
+	flags.Var(option.NewNamedMapOptions("kvstore-rate-limits", &kvStoreRateLimits, kvstore.ValidateRateLimit),
+		"kvstore-rate-limit", "Client-side rate limit of a class of key-value store operations (read, write, lock, lease) as <class>=<qps>[:<burst>]")
+	flags.StringVar(&kvStoreEncryptionKeys,
+		"kvstore-encryption-key-file", "", "Path to the file with the keys used to encrypt values stored in the key-value store")
 	flags.StringVar(&labelPrefixFile,
 		"label-prefix-file", "", "Valid label prefixes file path")
 	flags.StringSliceVar(&validLabels,
 		"labels", []string{}, "List of label prefixes used to determine identity of an endpoint")
 	flags.StringVar(&option.Config.LBInterface,
 		"lb", "", "Enables load balancer mode where load balancer bpf program is attached to the given interface")
+	flags.StringVar(&lbAlgorithm,
+		"lb-algorithm", string(lbmap.AlgorithmRandom), "Backend selection algorithm of services (random, maglev)")
+	flags.DurationVar(&option.Config.LBDrainTimeout,
+		"lb-drain-timeout", 0, "Maximum duration a removed service backend keeps serving established connections, 0 disables draining (requires kernel support for LRU maps)")
 	flags.StringVar(&option.Config.LibDir,
 		"lib-dir", defaults.LibraryPath, "Directory path to store runtime build environment")
 	flags.StringSliceVar(&loggers,
@@ -386,6 +428,8 @@ func init() {
 		"nat46-range", node.DefaultNAT46Prefix, "IPv6 prefix to map IPv4 addresses to")
 	flags.BoolVar(&masquerade,
 		"masquerade", true, "Masquerade packets from endpoints leaving the host")
+	flags.StringVar(&nodePortRange,
+		"node-port-range", fmt.Sprintf("%d-%d", option.NodePortMinDefault, option.NodePortMaxDefault), "Port range of NodePort frontends of Kubernetes services")
 	flags.StringVar(&v6Address,
 		"ipv6-node", "auto", "IPv6 address of node")
 	flags.StringVar(&v4Address,
@@ -578,6 +622,14 @@ func initEnv(cmd *cobra.Command) {
 			option.AllowLocalhostAuto, option.AllowLocalhostAlways, option.AllowLocalhostPolicy)
 	}
 
+	if err := option.Config.ValidateClusterID(); err != nil {
+		log.WithError(err).Fatal("Invalid setting for --cluster-id")
+	}
+
+	if err := option.Config.ParseNodePortRange(nodePortRange); err != nil {
+		log.WithError(err).Fatal("Invalid setting for --node-port-range")
+	}
+
 	option.Config.ModePreFilter = strings.ToLower(option.Config.ModePreFilter)
 	switch option.Config.ModePreFilter {
 	case option.ModePreFilterNative:
@@ -624,6 +676,19 @@ func initEnv(cmd *cobra.Command) {
 
 	policy.SetPolicyEnabled(strings.ToLower(viper.GetString("enable-policy")))
 
+	if err := kvstore.SetRateLimits(kvStoreRateLimits); err != nil {
+		log.WithError(err).Fatal("Invalid kvstore rate limits")
+	}
+
+	if kvStoreEncryptionKeys != "" {
+		keys, err := kvstore.LoadEncryptionKeys(kvStoreEncryptionKeys)
+		if err != nil {
+			log.WithError(err).Fatal("Unable to load kvstore encryption keys")
+		}
+		kvstore.SetEncryptionKeys(keys)
+		log.WithField("key", keys.PrimaryKeyID()).Info("Encrypting values stored in kvstore")
+	}
+
 	if err := kvstore.Setup(kvStore, kvStoreOpts); err != nil {
 		addrkey := fmt.Sprintf("%s.address", kvStore)
 		addr := kvStoreOpts[addrkey]
@@ -637,6 +702,41 @@ func initEnv(cmd *cobra.Command) {
 		log.WithError(err).Fatal("Unable to parse Label prefix configuration")
 	}
 
+	algorithm, err := lbmap.ParseAlgorithm(lbAlgorithm)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid load balancing algorithm")
+	}
+	lbmap.SetAlgorithm(algorithm)
+
+	if option.Config.LBDrainTimeout < 0 {
+		log.WithField("timeout", option.Config.LBDrainTimeout).Fatal("Invalid load balancer drain timeout")
+	}
+	if option.Config.IsLBDrainingEnabled() && !bpf.HaveLRUMapType() {
+		log.Warning("Disabling draining of removed service backends, the kernel does not support LRU maps")
+		option.Config.LBDrainTimeout = 0
+	}
+	lbmap.SetDraining(option.Config.IsLBDrainingEnabled())
+
+	if option.Config.EnableSessionAffinity && !bpf.HaveLRUMapType() {
+		log.Warning("Disabling session affinity of services, the kernel does not support LRU maps")
+		option.Config.EnableSessionAffinity = false
+	}
+	lbmap.SetSessionAffinity(option.Config.EnableSessionAffinity)
+
+	if option.Config.EnableNodePort && !option.Config.EnableDSR {
+		// Traffic entering the node is forwarded to the backends on
+		// other nodes in DSR mode, their replies could not be reverse
+		// translated otherwise
+		log.Info("Enabling direct server return of services, required by NodePort frontends")
+		option.Config.EnableDSR = true
+	}
+	if option.Config.EnableDSR && !bpf.HaveLRUMapType() {
+		log.Warning("Disabling direct server return of services, the kernel does not support LRU maps")
+		option.Config.EnableDSR = false
+	}
+	lbmap.SetDSR(option.Config.EnableDSR)
+	lbmap.SetStats(option.Config.EnableLBStats)
+
 	_, r, err := net.ParseCIDR(nat46prefix)
 	if err != nil {
 		log.WithError(err).WithField(logfields.V6Prefix, nat46prefix).Fatal("Invalid NAT46 prefix")
@@ -816,6 +916,10 @@ func runDaemon() {
 	// /debuginfo
 	api.DaemonGetDebuginfoHandler = NewGetDebugInfoHandler(d)
 
+	// /kvstore/snapshot
+	api.DaemonGetKvstoreSnapshotHandler = NewGetKvstoreSnapshotHandler(d)
+	api.DaemonPutKvstoreSnapshotHandler = NewPutKvstoreSnapshotHandler(d)
+
 	server := server.NewServer(api)
 	server.EnabledListeners = []string{"unix"}
 	server.SocketPath = flags.Filename(socketPath)
@@ -0,0 +1,111 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/cilium/cilium/pkg/controller"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/node"
+	nodeStore "github.com/cilium/cilium/pkg/node/store"
+	"github.com/cilium/cilium/pkg/option"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// localNodeSyncInterval is the interval in which the local node is
+	// compared with the node published into the kvstore
+	localNodeSyncInterval = 10 * time.Second
+)
+
+// nodeRouteTypes returns the route types to install for node n and the
+// local address to use for direct routes
+func nodeRouteTypes(n *node.Node) (node.RouteType, net.IP) {
+	routeTypes := node.TunnelRoute
+
+	// Add IPv6 routing only in non encap. With encap we do it with bpf tunnel
+	var ownAddr net.IP
+	if autoIPv6NodeRoutes && option.Config.Device != "undefined" {
+		// ignore own node
+		if n.Name != node.GetName() {
+			ownAddr = node.GetIPv6()
+			routeTypes |= node.DirectRoute
+		}
+	}
+
+	return routeTypes, ownAddr
+}
+
+// nodeDiscoveryObserver inserts all nodes of the local cluster learned from
+// the node store in the kvstore into the local node manager. It is used to
+// discover nodes if Kubernetes is not available.
+type nodeDiscoveryObserver struct{}
+
+// NodeUpdated is called when a node has been created or updated in the
+// kvstore
+func (o *nodeDiscoveryObserver) NodeUpdated(n node.Node) {
+	n.Source = node.FromKVStore
+	routeTypes, ownAddr := nodeRouteTypes(&n)
+	node.UpdateNode(n.Identity(), &n, routeTypes, ownAddr)
+
+	log.WithFields(logrus.Fields{
+		logfields.NodeName: n.Fullname(),
+		logfields.Node:     logfields.Repr(n),
+	}).Debug("Updated node from kvstore")
+}
+
+// NodeDeleted is called when a node has been removed from the kvstore
+func (o *nodeDiscoveryObserver) NodeDeleted(n node.Node) {
+	node.DeleteNode(n.Identity(), node.TunnelRoute|node.DirectRoute)
+
+	log.WithField(logfields.NodeName, n.Fullname()).Debug("Removed node from kvstore")
+}
+
+// registerLocalNode publishes the local node into the kvstore and starts a
+// controller publishing the local node again whenever its addresses or
+// allocation ranges change. The observer, if not nil, is notified about all
+// other nodes in the kvstore.
+func (d *Daemon) registerLocalNode(observer nodeStore.NodeObserver) error {
+	_, localNode := node.GetLocalNode()
+	if err := d.nodeRegistrar.RegisterNode(*localNode, observer); err != nil {
+		return err
+	}
+
+	controller.NewManager().UpdateController("node-registration",
+		controller.ControllerParams{
+			DoFunc:      d.syncLocalNode,
+			RunInterval: localNodeSyncInterval,
+		})
+
+	return nil
+}
+
+// syncLocalNode publishes the local node into the kvstore if it has changed
+// since it has been published last
+func (d *Daemon) syncLocalNode() error {
+	_, localNode := node.GetLocalNode()
+	updated, err := d.nodeRegistrar.UpdateLocalNode(*localNode)
+	if updated {
+		log.WithFields(logrus.Fields{
+			logfields.NodeName: localNode.Fullname(),
+			logfields.Node:     logfields.Repr(*localNode),
+		}).Info("Updated local node in kvstore")
+	}
+
+	return err
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/atomicfile"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/option"
+)
+
+const (
+	// serviceMetaFile is the file in the state directory holding the
+	// metadata of the services, which is not part of the BPF maps.
+	serviceMetaFile = "services.json"
+)
+
+func serviceMetaPath() string {
+	return filepath.Join(option.Config.StateDir, serviceMetaFile)
+}
+
+// loadServiceMeta returns the persisted metadata of the services indexed by
+// the SHA256 sum of their frontend.
+func loadServiceMeta() map[string]types.ServiceMeta {
+	meta := map[string]types.ServiceMeta{}
+
+	path := serviceMetaPath()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithError(err).WithField(logfields.Path, path).Warning("Unable to read service metadata")
+		}
+		return meta
+	}
+
+	if err := json.Unmarshal(data, &meta); err != nil {
+		log.WithError(err).WithField(logfields.Path, path).Warning("Unable to parse service metadata")
+		return map[string]types.ServiceMeta{}
+	}
+
+	return meta
+}
+
+// writeServiceMeta atomically replaces the file at path with the metadata
+// meta.
+func writeServiceMeta(path string, meta map[string]types.ServiceMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(path, data, 0600)
+}
+
+// syncServiceMeta persists the metadata of all services so that it can be
+// restored by SyncLBMap. The metadata is only written if it changed since it
+// was last persisted, changes are batched by the controller calling it.
+func (d *Daemon) syncServiceMeta() error {
+	d.loadBalancer.BPFMapMU.Lock()
+	if !d.serviceMetaChanged {
+		d.loadBalancer.BPFMapMU.Unlock()
+		return nil
+	}
+
+	meta := map[string]types.ServiceMeta{}
+	for sha, svc := range d.loadBalancer.SVCMap {
+		if !svc.Meta.IsEmpty() {
+			meta[sha] = svc.Meta
+		}
+	}
+	d.serviceMetaChanged = false
+	d.loadBalancer.BPFMapMU.Unlock()
+
+	path := serviceMetaPath()
+	if err := writeServiceMeta(path, meta); err != nil {
+		// Retry with the next run of the controller
+		d.loadBalancer.BPFMapMU.Lock()
+		d.serviceMetaChanged = true
+		d.loadBalancer.BPFMapMU.Unlock()
+		return fmt.Errorf("unable to persist service metadata to %s: %s", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,284 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/bpf"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/maps/lbmap"
+	"github.com/cilium/cilium/pkg/metrics"
+	"github.com/cilium/cilium/pkg/option"
+)
+
+// serviceStats are the traffic statistics of a frontend and of each of its
+// backends
+type serviceStats struct {
+	frontend lbmap.StatsValue
+	backends map[string]lbmap.StatsValue
+}
+
+// statsAddr returns the address of a frontend or backend in the format used
+// to index the traffic statistics
+func statsAddr(ip net.IP, port uint16) string {
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))
+}
+
+// dumpStatsEntries calls cb with all entries of the statistics maps. It does
+// nothing if the statistics of services are not collected.
+func dumpStatsEntries(cb func(key lbmap.StatsKey, value *lbmap.StatsValue)) {
+	if !lbmap.IsStatsEnabled() {
+		return
+	}
+
+	maps := []*bpf.Map{lbmap.Stats6Map}
+	if !option.Config.IPv4Disabled {
+		maps = append(maps, lbmap.Stats4Map)
+	}
+
+	for _, m := range maps {
+		if err := lbmap.DumpStats(m, cb); err != nil {
+			log.WithError(err).Warning("Unable to dump service statistics")
+		}
+	}
+}
+
+// dumpServiceStats returns the traffic statistics of all frontends indexed
+// by the address of the frontend.
+func dumpServiceStats() map[string]*serviceStats {
+	stats := map[string]*serviceStats{}
+
+	dumpStatsEntries(func(key lbmap.StatsKey, value *lbmap.StatsValue) {
+		feIP, fePort := key.GetFrontend()
+		fe := statsAddr(feIP, fePort)
+		s, ok := stats[fe]
+		if !ok {
+			s = &serviceStats{backends: map[string]lbmap.StatsValue{}}
+			stats[fe] = s
+		}
+
+		if beIP, bePort := key.GetBackend(); beIP == nil {
+			s.frontend = *value
+		} else {
+			s.backends[statsAddr(beIP, bePort)] = *value
+		}
+	})
+
+	return stats
+}
+
+func statsToModel(value lbmap.StatsValue) *models.ServiceStatistics {
+	return &models.ServiceStatistics{
+		Packets:     int64(value.Packets),
+		Bytes:       int64(value.Bytes),
+		Connections: int64(value.Connections),
+	}
+}
+
+// addServiceStatsToModel sets the traffic statistics of the frontend and of
+// the backends of the service svc.
+func addServiceStatsToModel(stats map[string]*serviceStats, svc *models.Service) {
+	if svc.Status == nil || svc.Status.Realized == nil || svc.Status.Realized.FrontendAddress == nil {
+		return
+	}
+
+	spec := svc.Status.Realized
+	s, ok := stats[statsAddr(net.ParseIP(spec.FrontendAddress.IP), spec.FrontendAddress.Port)]
+	if !ok {
+		return
+	}
+
+	svc.Status.Statistics = statsToModel(s.frontend)
+	for _, be := range spec.BackendAddresses {
+		if be.IP == nil {
+			continue
+		}
+		if value, ok := s.backends[statsAddr(net.ParseIP(*be.IP), be.Port)]; ok {
+			be.Statistics = statsToModel(value)
+		}
+	}
+}
+
+// deleteServiceStats removes the traffic statistics of the service with the
+// frontend fe.
+func deleteServiceStats(fe *types.L3n4Addr) {
+	if !lbmap.IsStatsEnabled() {
+		return
+	}
+
+	var svcKey lbmap.ServiceKey
+	if !fe.IsIPv6() {
+		svcKey = lbmap.NewService4Key(fe.IP, fe.Port, 0)
+	} else {
+		svcKey = lbmap.NewService6Key(fe.IP, fe.Port, 0)
+	}
+
+	if err := lbmap.DeleteStats(svcKey); err != nil {
+		log.WithError(err).WithField("frontend", fe.String()).Warning("Unable to delete service statistics")
+	}
+}
+
+// getK8sServiceNames returns the k8s service of each service ID.
+func (d *Daemon) getK8sServiceNames() map[types.ServiceID]types.K8sServiceNamespace {
+	names := map[types.ServiceID]types.K8sServiceNamespace{}
+
+	d.loadBalancer.K8sMU.Lock()
+	defer d.loadBalancer.K8sMU.Unlock()
+
+	for svcns, svcInfo := range d.loadBalancer.K8sServices {
+		for _, port := range svcInfo.Ports {
+			names[port.ID] = svcns
+		}
+		for _, fe := range svcInfo.Frontends {
+			names[fe.ID] = svcns
+		}
+	}
+
+	return names
+}
+
+// statsEntry is an entry of the statistics maps
+type statsEntry struct {
+	key   lbmap.StatsKey
+	value lbmap.StatsValue
+}
+
+// statsDelta returns the traffic counted since the value last was reported.
+// The counters start over if the entry was removed and created again by the
+// datapath in the meantime.
+func statsDelta(value, last lbmap.StatsValue) lbmap.StatsValue {
+	if value.Packets < last.Packets || value.Bytes < last.Bytes || value.Connections < last.Connections {
+		return value
+	}
+	return lbmap.StatsValue{
+		Packets:     value.Packets - last.Packets,
+		Bytes:       value.Bytes - last.Bytes,
+		Connections: value.Connections - last.Connections,
+	}
+}
+
+// serviceStatsSync is the state of the synchronization of the traffic
+// statistics of services with the prometheus metrics
+type serviceStatsSync struct {
+	// last is the value of each backend entry of the statistics maps at
+	// the previous synchronization, indexed by the string of the key
+	last map[string]lbmap.StatsValue
+
+	// series is the set of services with prometheus series
+	series map[types.K8sServiceNamespace]struct{}
+}
+
+func newServiceStatsSync() *serviceStatsSync {
+	return &serviceStatsSync{
+		last:   map[string]lbmap.StatsValue{},
+		series: map[types.K8sServiceNamespace]struct{}{},
+	}
+}
+
+// syncServiceStatsMetrics updates the prometheus metrics of the traffic of
+// the services with the statistics collected by the datapath. The metrics
+// are labelled by service and namespace only, the number of backends is not
+// bounded. The entries of the statistics maps of removed services and
+// backends are deleted, as are the series of removed services.
+func (d *Daemon) syncServiceStatsMetrics(sync *serviceStatsSync) error {
+	names := d.getK8sServiceNames()
+
+	entries := []statsEntry{}
+	dumpStatsEntries(func(key lbmap.StatsKey, value *lbmap.StatsValue) {
+		entries = append(entries, statsEntry{key: key, value: *value})
+	})
+
+	d.loadBalancer.BPFMapMU.RLock()
+	defer d.loadBalancer.BPFMapMU.RUnlock()
+
+	// Index the frontends and the backends of the live services, the
+	// backends include the draining backends which still forward traffic
+	services := map[string]types.K8sServiceNamespace{}
+	backends := map[string]struct{}{}
+	for _, svc := range d.loadBalancer.SVCMap {
+		fe := statsAddr(svc.FE.IP, svc.FE.Port)
+		services[fe] = names[svc.FE.ID]
+		for _, be := range svc.BES {
+			backends[fe+"/"+statsAddr(be.IP, be.Port)] = struct{}{}
+		}
+	}
+
+	live := map[string]struct{}{}
+	series := map[types.K8sServiceNamespace]struct{}{}
+	for _, entry := range entries {
+		feIP, fePort := entry.key.GetFrontend()
+		fe := statsAddr(feIP, fePort)
+		svcns, svcOK := services[fe]
+
+		beIP, bePort := entry.key.GetBackend()
+		if beIP == nil {
+			if !svcOK {
+				deleteStatsEntry(entry.key)
+			}
+			continue
+		}
+
+		if _, ok := backends[fe+"/"+statsAddr(beIP, bePort)]; !svcOK || !ok {
+			deleteStatsEntry(entry.key)
+			continue
+		}
+
+		id := entry.key.String()
+		delta := statsDelta(entry.value, sync.last[id])
+		sync.last[id] = entry.value
+		live[id] = struct{}{}
+
+		labels := []string{svcns.ServiceName, svcns.Namespace}
+		metrics.ServicesPackets.WithLabelValues(labels...).Add(float64(delta.Packets))
+		metrics.ServicesBytes.WithLabelValues(labels...).Add(float64(delta.Bytes))
+		metrics.ServicesConnections.WithLabelValues(labels...).Add(float64(delta.Connections))
+		series[svcns] = struct{}{}
+	}
+
+	for id := range sync.last {
+		if _, ok := live[id]; !ok {
+			delete(sync.last, id)
+		}
+	}
+
+	// A service keeps its series as long as it has a frontend
+	for _, svcns := range services {
+		if _, ok := sync.series[svcns]; ok {
+			series[svcns] = struct{}{}
+		}
+	}
+	for svcns := range sync.series {
+		if _, ok := series[svcns]; !ok {
+			metrics.ServicesPackets.DeleteLabelValues(svcns.ServiceName, svcns.Namespace)
+			metrics.ServicesBytes.DeleteLabelValues(svcns.ServiceName, svcns.Namespace)
+			metrics.ServicesConnections.DeleteLabelValues(svcns.ServiceName, svcns.Namespace)
+		}
+	}
+	sync.series = series
+
+	return nil
+}
+
+// deleteStatsEntry removes the statistics map entry with the key key of a
+// removed service or backend.
+func deleteStatsEntry(key lbmap.StatsKey) {
+	if err := lbmap.DeleteStatsEntry(key); err != nil {
+		log.WithError(err).WithField(logfields.Object, key.String()).Warning("Unable to delete service statistics")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/option"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reserveFrontendIP returns the frontend IP of the service id for the IP
+// feIP requested via the API. An IP of the service ranges is reserved for the
+// service in the kvstore. If feIP is empty or unspecified, the frontend IP
+// already registered for id is reused or else a virtual IP is allocated from
+// the service range of the family of feIP, IPv4 unless disabled if feIP is
+// empty.
+func (d *Daemon) reserveFrontendIP(feIP string, id types.ServiceID) (net.IP, error) {
+	ip := net.ParseIP(feIP)
+	if feIP != "" && ip == nil {
+		return nil, fmt.Errorf("Invalid IP address \"%s\"", feIP)
+	}
+
+	if d.vipAllocator == nil {
+		if ip == nil || ip.IsUnspecified() {
+			return nil, fmt.Errorf("virtual IP allocation is not available")
+		}
+		return ip, nil
+	}
+
+	if ip != nil && !ip.IsUnspecified() {
+		return ip, d.vipAllocator.Reserve(ip, id)
+	}
+
+	ipv6 := option.Config.IPv4Disabled
+	if ip != nil {
+		ipv6 = ip.To4() == nil
+	}
+
+	feAddr, err := GetL3n4AddrID(uint32(id))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get service %d: %s", id, err)
+	}
+	if feAddr != nil && feAddr.IsIPv6() == ipv6 {
+		return feAddr.IP, d.vipAllocator.Reserve(feAddr.IP, id)
+	}
+
+	ip, err = d.vipAllocator.Allocate(ipv6, id, d.isFrontendIPInUse)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(logrus.Fields{
+		logfields.ServiceID: id,
+		logfields.IPAddr:    ip,
+	}).Info("Allocated virtual IP for service")
+
+	return ip, nil
+}
+
+// isFrontendIPInUse returns true if ip is the frontend IP of any service.
+func (d *Daemon) isFrontendIPInUse(ip net.IP) bool {
+	d.loadBalancer.BPFMapMU.RLock()
+	defer d.loadBalancer.BPFMapMU.RUnlock()
+
+	for _, svc := range d.loadBalancer.SVCMap {
+		if svc.FE.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFrontendIP returns true if the service id with the frontend IP ip
+// exists.
+func (d *Daemon) hasFrontendIP(id types.ServiceID, ip net.IP) bool {
+	d.loadBalancer.BPFMapMU.RLock()
+	defer d.loadBalancer.BPFMapMU.RUnlock()
+
+	svc, ok := d.loadBalancer.SVCMapID[id]
+	return ok && svc.FE.IP.Equal(ip)
+}
+
+// releaseFrontendIP releases the reservation of the frontend IP ip for the
+// service id.
+func (d *Daemon) releaseFrontendIP(ip net.IP, id types.ServiceID) {
+	if d.vipAllocator == nil {
+		return
+	}
+
+	if err := d.vipAllocator.Release(ip, id); err != nil {
+		log.WithError(err).WithFields(logrus.Fields{
+			logfields.ServiceID: id,
+			logfields.IPAddr:    ip,
+		}).Warning("Unable to release frontend IP of service")
+	}
+}
@@ -16,12 +16,16 @@ package main
 
 import (
 	"net"
+	"net/http/httptest"
 
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/cilium/cilium/api/v1/server/restapi/service"
 	"github.com/cilium/cilium/common"
 	"github.com/cilium/cilium/common/types"
 	"github.com/cilium/cilium/pkg/comparator"
 	"github.com/cilium/cilium/pkg/kvstore"
 
+	"github.com/go-openapi/runtime"
 	. "gopkg.in/check.v1"
 )
 
@@ -146,3 +150,50 @@ func (ds *DaemonSuite) TestGetMaxServiceID(c *C) {
 	c.Assert(err, Equals, nil)
 	c.Assert(id, Equals, (common.MaxSetOfServiceID - 1))
 }
+
+func (ds *DaemonSuite) TestGetServiceForwarding(c *C) {
+	mode, revNAT, err := getServiceForwarding(&models.ServiceSpec{})
+	c.Assert(err, IsNil)
+	c.Assert(mode, Equals, types.ForwardingModeNAT)
+	c.Assert(revNAT, Equals, false)
+
+	mode, revNAT, err = getServiceForwarding(&models.ServiceSpec{
+		ForwardingMode: models.ServiceSpecForwardingModeDSR,
+		Flags:          &models.ServiceSpecFlags{DirectServerReturn: true},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(mode, Equals, types.ForwardingModeDSR)
+	c.Assert(revNAT, Equals, true)
+
+	// DSR services require the reverse translation
+	_, _, err = getServiceForwarding(&models.ServiceSpec{
+		ForwardingMode: models.ServiceSpecForwardingModeDSR,
+	})
+	c.Assert(err, Not(IsNil))
+
+	_, _, err = getServiceForwarding(&models.ServiceSpec{ForwardingMode: "foo"})
+	c.Assert(err, Not(IsNil))
+}
+
+func (ds *DaemonSuite) TestPutServiceIDConflictingForwarding(c *C) {
+	params := service.PutServiceIDParams{
+		ID: 1,
+		Config: &models.ServiceSpec{
+			ID:             1,
+			ForwardingMode: models.ServiceSpecForwardingModeDSR,
+			Flags:          &models.ServiceSpecFlags{DirectServerReturn: false},
+			FrontendAddress: &models.FrontendAddress{
+				IP:       "10.0.0.1",
+				Port:     80,
+				Protocol: models.FrontendAddressProtocolTCP,
+			},
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	NewPutServiceIDHandler(ds.d).Handle(params).WriteResponse(rec, runtime.JSONProducer())
+	c.Assert(rec.Code, Equals, service.PutServiceIDInvalidFrontendCode)
+
+	// The frontend IP is not reserved by the rejected service
+	c.Assert(ds.d.hasFrontendIP(types.ServiceID(1), net.ParseIP("10.0.0.1")), Equals, false)
+}
@@ -129,6 +129,29 @@ func (h *getHealthz) Handle(params GetHealthzParams) middleware.Responder {
 	return NewGetHealthzOK().WithPayload(&sr)
 }
 
+// getKvstoreOperationStatuses returns the statistics of all kvstore
+// operations performed by the agent
+func getKvstoreOperationStatuses() models.KvstoreOperationStatuses {
+	stats := kvstore.GetOperationStatistics()
+	statuses := make(models.KvstoreOperationStatuses, 0, len(stats))
+	for _, s := range stats {
+		status := &models.KvstoreOperationStatus{
+			Class:       string(s.Class),
+			Count:       int64(s.Count),
+			Errors:      int64(s.Errors),
+			Throttled:   int64(s.Throttled),
+			Bytes:       int64(s.Bytes),
+			MeanLatency: s.MeanLatency().Seconds(),
+		}
+		if s.RateLimit != nil {
+			status.QPS = s.RateLimit.QPS
+			status.Burst = int64(s.RateLimit.Burst)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
 func (d *Daemon) getStatus() models.StatusResponse {
 	sr := models.StatusResponse{
 		Controllers: controller.GetGlobalStatus(),
@@ -142,6 +165,16 @@ func (d *Daemon) getStatus() models.StatusResponse {
 		sr.Kvstore = &models.Status{State: models.StatusStateOk, Msg: info}
 	}
 
+	sr.KvstoreOperations = getKvstoreOperationStatuses()
+
+	if d.leaderElection != nil {
+		sr.LeaderElection = &models.LeaderElectionStatus{
+			Name:     d.leaderElection.Name(),
+			Leader:   d.leaderElection.Leader(),
+			IsLeader: d.leaderElection.IsLeader(),
+		}
+	}
+
 	sr.ContainerRuntime = workloads.Status()
 
 	sr.Kubernetes = d.getK8sStatus()
@@ -161,13 +194,13 @@ func (d *Daemon) getStatus() models.StatusResponse {
 			State: sr.Kubernetes.State,
 			Msg:   "Kubernetes service is not ready",
 		}
+	} else if st := getClusterPoolStatus(); st != nil {
+		sr.Cilium = st
 	} else {
 		sr.Cilium = &models.Status{State: models.StatusStateOk, Msg: "OK"}
 	}
 
-	if d.DebugEnabled() {
-		sr.IPAM = d.DumpIPAM()
-	}
+	sr.IPAM = d.DumpIPAM()
 
 	sr.NodeMonitor = d.nodeMonitor.State()
 
@@ -181,5 +214,9 @@ func (d *Daemon) getStatus() models.StatusResponse {
 		sr.Proxy = d.l7Proxy.GetStatusModel()
 	}
 
+	if d.clustermesh != nil {
+		sr.ClusterMesh = d.clustermesh.Status()
+	}
+
 	return sr
 }
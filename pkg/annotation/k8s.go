@@ -33,4 +33,14 @@ const (
 	// V6HealthName is the annotation name used to store the IPv6
 	// address of the cilium-health endpoint in the node's annotations.
 	V6HealthName = "io.cilium.network.ipv6-health-ip"
+
+	// ServicePreferLocalBackends is the annotation name used to restrict
+	// the backends of all frontends of a service to the backends running
+	// on the local node, as long as there are any, if set to "true".
+	ServicePreferLocalBackends = "io.cilium.service.prefer-local-backends"
+
+	// GlobalService is the annotation name used to share the backends of
+	// a service with the services of the same name and namespace in all
+	// other clusters, if set to "true".
+	GlobalService = "io.cilium.service.global"
 )
@@ -0,0 +1,57 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type AtomicFileSuite struct{}
+
+var _ = Suite(&AtomicFileSuite{})
+
+func (s *AtomicFileSuite) TestWriteFile(c *C) {
+	dir, err := ioutil.TempDir("", "atomicfile")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	c.Assert(WriteFile(path, []byte("foo"), 0600), IsNil)
+	c.Assert(WriteFile(path, []byte("bar"), 0600), IsNil)
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "bar")
+
+	info, err := os.Stat(path)
+	c.Assert(err, IsNil)
+	c.Assert(info.Mode().Perm(), Equals, os.FileMode(0600))
+
+	// The temporary file is renamed
+	_, err = os.Stat(path + ".tmp")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	// Files in missing directories cannot be written
+	c.Assert(WriteFile(filepath.Join(dir, "missing", "state.json"), []byte("foo"), 0600), Not(IsNil))
+}
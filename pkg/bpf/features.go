@@ -0,0 +1,42 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"sync"
+)
+
+var (
+	lruProbeOnce sync.Once
+	haveLRU      bool
+)
+
+// HaveLRUMapType returns true if the kernel supports LRU hash maps. This
+// mirrors the HAVE_LRU_MAP_TYPE probe of the datapath, maps which must be
+// of type LRU may only be created if it returns true. The kernel is only
+// probed once.
+func HaveLRUMapType() bool {
+	lruProbeOnce.Do(func() {
+		fd, err := CreateMap(BPF_MAP_TYPE_LRU_HASH, 4, 4, 1, 0)
+		if err != nil {
+			log.WithError(err).Info("Kernel does not support LRU maps")
+			return
+		}
+		ObjClose(fd)
+		haveLRU = true
+	})
+
+	return haveLRU
+}
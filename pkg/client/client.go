@@ -182,6 +182,16 @@ func FormatStatusResponse(w io.Writer, sr *models.StatusResponse, allAddresses,
 	if sr.Kvstore != nil {
 		fmt.Fprintf(w, "KVStore:\t%s\t%s\n", sr.Kvstore.State, sr.Kvstore.Msg)
 	}
+	if le := sr.LeaderElection; le != nil {
+		switch {
+		case le.Leader == "":
+			fmt.Fprintf(w, "Leader:\tNone\n")
+		case le.IsLeader:
+			fmt.Fprintf(w, "Leader:\t%s (this node)\n", le.Leader)
+		default:
+			fmt.Fprintf(w, "Leader:\t%s\n", le.Leader)
+		}
+	}
 	if sr.ContainerRuntime != nil {
 		fmt.Fprintf(w, "ContainerRuntime:\t%s\t%s\n",
 			sr.ContainerRuntime.State, sr.ContainerRuntime.Msg)
@@ -231,16 +241,33 @@ func FormatStatusResponse(w io.Writer, sr *models.StatusResponse, allAddresses,
 				v6CIDR = fmt.Sprintf("/%d", nIPs)
 			}
 		}
-		fmt.Fprintf(w, "IPv4 address pool:\t%d%s allocated\n", len(sr.IPAM.IPV4), v4CIDR)
-		if allAddresses {
-			for _, ipv4 := range sr.IPAM.IPV4 {
-				fmt.Fprintf(w, "  %s\n", ipv4)
+		// The allocated addresses are only reported in debug mode
+		if sr.IPAM.IPV4 != nil || sr.IPAM.IPV6 != nil {
+			fmt.Fprintf(w, "IPv4 address pool:\t%d%s allocated\n", len(sr.IPAM.IPV4), v4CIDR)
+			if allAddresses {
+				for _, ipv4 := range sr.IPAM.IPV4 {
+					fmt.Fprintf(w, "  %s\n", ipv4)
+				}
+			}
+			fmt.Fprintf(w, "IPv6 address pool:\t%d%s allocated\n", len(sr.IPAM.IPV6), v6CIDR)
+			if allAddresses {
+				for _, ipv6 := range sr.IPAM.IPV6 {
+					fmt.Fprintf(w, "  %s\n", ipv6)
+				}
 			}
 		}
-		fmt.Fprintf(w, "IPv6 address pool:\t%d%s allocated\n", len(sr.IPAM.IPV6), v6CIDR)
-		if allAddresses {
-			for _, ipv6 := range sr.IPAM.IPV6 {
-				fmt.Fprintf(w, "  %s\n", ipv6)
+		for _, pool := range sr.IPAM.Pools {
+			fmt.Fprintf(w, "IPAM pool %s:\t%d/%d allocated from %s\n",
+				pool.Name, pool.Used, pool.Capacity, strings.Join(pool.Cidrs, ", "))
+		}
+		if cp := sr.IPAM.Checkpoint; cp != nil {
+			fmt.Fprintf(w, "IPAM checkpoint:\t%d allocations, %d orphans released (%d by last GC %s)\n",
+				cp.Allocations, cp.ReleasedTotal, len(cp.Released), timeSince(time.Time(cp.LastGc)))
+			if allAddresses {
+				for _, a := range cp.Released {
+					fmt.Fprintf(w, "  %s released from %s allocated %s\n",
+						a.IP, a.Owner, timeSince(time.Time(a.Timestamp)))
+				}
 			}
 		}
 	}
@@ -290,4 +317,51 @@ func FormatStatusResponse(w io.Writer, sr *models.StatusResponse, allAddresses,
 	} else {
 		fmt.Fprintf(w, "Proxy Status:\tNo managed proxy redirect\n")
 	}
+
+	if sr.ClusterMesh != nil {
+		nReady := 0
+		for _, cluster := range sr.ClusterMesh.Clusters {
+			if cluster.Ready {
+				nReady++
+			}
+		}
+
+		fmt.Fprintf(w, "ClusterMesh:\t%d/%d clusters ready\n", nReady, len(sr.ClusterMesh.Clusters))
+		for _, cluster := range sr.ClusterMesh.Clusters {
+			ready := "ready"
+			if !cluster.Ready {
+				ready = "not-ready"
+			}
+			fmt.Fprintf(w, "  %s:\t%s, %d nodes, %d identities\n",
+				cluster.Name, ready, cluster.NumNodes, cluster.NumIdentities)
+			fmt.Fprintf(w, "  \t%s\n", cluster.Status)
+		}
+	}
+}
+
+// FormatKvstoreOperations writes the statistics of the kvstore operations of
+// the status response to w
+func FormatKvstoreOperations(w io.Writer, sr *models.StatusResponse) {
+	if len(sr.KvstoreOperations) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "KVStore Operations:\n")
+	tab := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(tab, "  Class\tCount\tErrors\tThrottled\tBytes\tMean latency\tRate limit\n")
+	for _, op := range sr.KvstoreOperations {
+		if op == nil {
+			continue
+		}
+
+		limit := "none"
+		if op.QPS > 0 {
+			limit = fmt.Sprintf("%g/s, burst %d", op.QPS, op.Burst)
+		}
+
+		latency := time.Duration(op.MeanLatency * float64(time.Second))
+		fmt.Fprintf(tab, "  %s\t%d\t%d\t%d\t%d\t%s\t%s\n", op.Class, op.Count,
+			op.Errors, op.Throttled, op.Bytes, latency.Round(time.Microsecond), limit)
+	}
+	tab.Flush()
 }
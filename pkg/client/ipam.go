@@ -25,8 +25,10 @@ const (
 )
 
 // IPAMAllocate allocates an IP address out of address family specific pool.
-func (c *Client) IPAMAllocate(family string) (*models.IPAMResponse, error) {
-	params := ipam.NewPostIPAMParams()
+// If owner is not nil, the address is allocated from the IPAM pool selected
+// for the owner.
+func (c *Client) IPAMAllocate(family string, owner *models.IPAMOwner) (*models.IPAMResponse, error) {
+	params := ipam.NewPostIPAMParams().WithOwner(owner)
 
 	if family != "" {
 		params.SetFamily(&family)
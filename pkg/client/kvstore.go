@@ -0,0 +1,39 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"github.com/cilium/cilium/api/v1/client/daemon"
+	"github.com/cilium/cilium/api/v1/models"
+)
+
+// KvstoreSnapshotGet returns a snapshot of the state stored in the kvstore
+func (c *Client) KvstoreSnapshotGet() (*models.KvstoreSnapshot, error) {
+	resp, err := c.Daemon.GetKvstoreSnapshot(nil)
+	if err != nil {
+		return nil, Hint(err)
+	}
+	return resp.Payload, nil
+}
+
+// KvstoreSnapshotPut imports a snapshot of the state stored in the kvstore
+func (c *Client) KvstoreSnapshotPut(snapshot *models.KvstoreSnapshot, dryRun bool) (*models.KvstoreSnapshotImportResult, error) {
+	params := daemon.NewPutKvstoreSnapshotParams().WithSnapshot(snapshot).WithDryRun(&dryRun)
+	resp, err := c.Daemon.PutKvstoreSnapshot(params)
+	if err != nil {
+		return nil, Hint(err)
+	}
+	return resp.Payload, nil
+}
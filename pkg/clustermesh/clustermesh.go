@@ -0,0 +1,196 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clustermesh
+
+import (
+	"fmt"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/cilium/cilium/pkg/controller"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/node"
+	nodeStore "github.com/cilium/cilium/pkg/node/store"
+	serviceStore "github.com/cilium/cilium/pkg/service/store"
+)
+
+// Configuration is the configuration that must be provided to
+// NewClusterMesh()
+type Configuration struct {
+	// Name is the name of the local cluster. This is used for logging and
+	// to ignore configuration of the local cluster.
+	Name string
+
+	// ConfigDirectory is the path to the directory that will be watched
+	// for etcd configuration files to appear
+	ConfigDirectory string
+
+	// NodeObserver is notified about all nodes of all remote clusters. If
+	// nil, remote nodes are inserted into the local node manager.
+	NodeObserver nodeStore.NodeObserver
+
+	// ServiceObserver is notified about all global services of all
+	// remote clusters. If nil, global services are not imported.
+	ServiceObserver serviceStore.ServiceObserver
+}
+
+// ClusterMesh is a cache of multiple remote clusters
+type ClusterMesh struct {
+	// conf is the configuration, it is immutable after NewClusterMesh()
+	conf Configuration
+
+	mutex         lock.RWMutex
+	clusters      map[string]*remoteCluster
+	configWatcher *configDirectoryWatcher
+}
+
+// NewClusterMesh creates a new remote cluster cache based on the provided
+// configuration
+func NewClusterMesh(c Configuration) (*ClusterMesh, error) {
+	if c.NodeObserver == nil {
+		c.NodeObserver = &nodeManagerObserver{}
+	}
+
+	cm := &ClusterMesh{
+		conf:     c,
+		clusters: map[string]*remoteCluster{},
+	}
+
+	w, err := createConfigDirectoryWatcher(c.ConfigDirectory, cm)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create config directory watcher: %s", err)
+	}
+
+	cm.configWatcher = w
+
+	if err := cm.configWatcher.watch(); err != nil {
+		return nil, err
+	}
+
+	return cm, nil
+}
+
+// Close stops watching for remote cluster configuration files to appear and
+// will close all connections to remote clusters
+func (cm *ClusterMesh) Close() {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	if cm.configWatcher != nil {
+		cm.configWatcher.close()
+	}
+
+	for name, cluster := range cm.clusters {
+		cluster.onRemove()
+		delete(cm.clusters, name)
+	}
+}
+
+func (cm *ClusterMesh) newRemoteCluster(name, path string) *remoteCluster {
+	return &remoteCluster{
+		name:        name,
+		configPath:  path,
+		mesh:        cm,
+		changed:     make(chan bool, 1),
+		controllers: controller.NewManager(),
+	}
+}
+
+func (cm *ClusterMesh) add(name, path string) {
+	if name == cm.conf.Name {
+		log.WithField(fieldClusterName, name).Debug("Ignoring configuration for own cluster")
+		return
+	}
+
+	inserted := false
+	cm.mutex.Lock()
+	cluster, ok := cm.clusters[name]
+	if !ok {
+		cluster = cm.newRemoteCluster(name, path)
+		cm.clusters[name] = cluster
+		inserted = true
+	} else {
+		// Signal a change in configuration while holding the mutex as
+		// remove() closes the channel. A pending signal already causes
+		// the connection to be re-created, so the signal is dropped if
+		// one is pending.
+		select {
+		case cluster.changed <- true:
+		default:
+		}
+	}
+	cm.mutex.Unlock()
+
+	log.WithField(fieldClusterName, name).Debug("Remote cluster configuration added")
+
+	if inserted {
+		cluster.onInsert()
+	}
+}
+
+func (cm *ClusterMesh) remove(name string) {
+	cm.mutex.Lock()
+	if cluster, ok := cm.clusters[name]; ok {
+		cluster.onRemove()
+		delete(cm.clusters, name)
+	}
+	cm.mutex.Unlock()
+
+	log.WithField(fieldClusterName, name).Debug("Remote cluster configuration removed")
+}
+
+// NumReadyClusters returns the number remote clusters to which a connection
+// has been established
+func (cm *ClusterMesh) NumReadyClusters() int {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	nready := 0
+	for _, cm := range cm.clusters {
+		if cm.isReady() {
+			nready++
+		}
+	}
+
+	return nready
+}
+
+// Status returns the status of the ClusterMesh subsystem
+func (cm *ClusterMesh) Status() (status *models.ClusterMeshStatus) {
+	status = &models.ClusterMeshStatus{}
+
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	for _, cm := range cm.clusters {
+		status.Clusters = append(status.Clusters, cm.status())
+	}
+
+	return
+}
+
+// nodeManagerObserver inserts all nodes of remote clusters into the local
+// node manager
+type nodeManagerObserver struct{}
+
+// NodeUpdated is called when a node of a remote cluster is created or updated
+func (o *nodeManagerObserver) NodeUpdated(n node.Node) {
+	n.Source = node.FromClusterMesh
+	node.UpdateNode(n.Identity(), &n, node.TunnelRoute, nil)
+}
+
+// NodeDeleted is called when a node of a remote cluster has been removed
+func (o *nodeManagerObserver) NodeDeleted(n node.Node) {
+	node.DeleteNode(n.Identity(), node.TunnelRoute)
+}
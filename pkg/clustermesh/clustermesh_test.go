@@ -0,0 +1,46 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clustermesh
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *ClusterMeshTestSuite) TestChangeAndRemove(c *C) {
+	cm := &ClusterMesh{clusters: map[string]*remoteCluster{}}
+	cluster := cm.newRemoteCluster("cluster1", "/nonexistent")
+	cm.clusters["cluster1"] = cluster
+
+	// Repeated changes must not block while a change is pending
+	done := make(chan struct{})
+	go func() {
+		cm.add("cluster1", "/nonexistent")
+		cm.add("cluster1", "/nonexistent")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatal("signaling a configuration change blocked")
+	}
+	c.Assert(<-cluster.changed, Equals, true)
+
+	cm.remove("cluster1")
+	_, ok := <-cluster.changed
+	c.Assert(ok, Equals, false)
+	c.Assert(cm.clusters, HasLen, 0)
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clustermesh
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// clusterLifecycle is the interface to implement in order to receive cluster
+// configuration lifecycle events. This is implemented by the ClusterMesh.
+type clusterLifecycle interface {
+	add(clusterName, clusterConfigPath string)
+	remove(clusterName string)
+}
+
+type configDirectoryWatcher struct {
+	watcher   *fsnotify.Watcher
+	lifecycle clusterLifecycle
+	path      string
+	stop      chan struct{}
+}
+
+func createConfigDirectoryWatcher(path string, lifecycle clusterLifecycle) (*configDirectoryWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := watcher.Add(path); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	return &configDirectoryWatcher{
+		watcher:   watcher,
+		path:      path,
+		lifecycle: lifecycle,
+		stop:      make(chan struct{}),
+	}, nil
+}
+
+// isEtcdConfigFile returns true if the file represents a remote cluster
+// configuration. Hidden files are ignored to support configuration
+// directories populated by Kubernetes secrets and configmaps which contain
+// "..data" style symbolic links.
+func isEtcdConfigFile(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	return !strings.HasPrefix(filepath.Base(path), ".")
+}
+
+func (cdw *configDirectoryWatcher) handleAddedFile(name, absolutePath string) {
+	if !isEtcdConfigFile(absolutePath) {
+		return
+	}
+
+	cdw.lifecycle.add(name, absolutePath)
+}
+
+func (cdw *configDirectoryWatcher) watch() error {
+	log.WithField(fieldConfig, cdw.path).Debug("Starting config directory watcher")
+
+	files, err := ioutil.ReadDir(cdw.path)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		absolutePath := path.Join(cdw.path, f.Name())
+		cdw.handleAddedFile(f.Name(), absolutePath)
+	}
+
+	go func() {
+		for {
+			select {
+			case event := <-cdw.watcher.Events:
+				name := filepath.Base(event.Name)
+				log.WithFields(logrus.Fields{
+					fieldClusterName: name,
+					fieldConfig:      event.Name,
+					"operation":      event.Op,
+				}).Debug("Received fsnotify event")
+
+				switch event.Op {
+				case fsnotify.Create, fsnotify.Write, fsnotify.Chmod:
+					cdw.handleAddedFile(name, event.Name)
+				case fsnotify.Remove, fsnotify.Rename:
+					cdw.lifecycle.remove(name)
+				}
+
+			case err := <-cdw.watcher.Errors:
+				log.WithError(err).WithField(fieldConfig, cdw.path).
+					Warning("Error encountered while watching directory with fsnotify")
+
+			case <-cdw.stop:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (cdw *configDirectoryWatcher) close() {
+	close(cdw.stop)
+	cdw.watcher.Close()
+}
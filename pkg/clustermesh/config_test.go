@@ -0,0 +1,106 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clustermesh
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/cilium/cilium/pkg/lock"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type ClusterMeshTestSuite struct{}
+
+var _ = Suite(&ClusterMeshTestSuite{})
+
+type fakeLifecycle struct {
+	mutex    lock.Mutex
+	clusters map[string]string
+}
+
+func (f *fakeLifecycle) add(name, path string) {
+	f.mutex.Lock()
+	f.clusters[name] = path
+	f.mutex.Unlock()
+}
+
+func (f *fakeLifecycle) remove(name string) {
+	f.mutex.Lock()
+	delete(f.clusters, name)
+	f.mutex.Unlock()
+}
+
+func (f *fakeLifecycle) has(name string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	_, ok := f.clusters[name]
+	return ok
+}
+
+func (f *fakeLifecycle) len() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.clusters)
+}
+
+func waitUntil(condition func() bool) bool {
+	for i := 0; i < 50; i++ {
+		if condition() {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+func (s *ClusterMeshTestSuite) TestWatchConfigDirectory(c *C) {
+	dir, err := ioutil.TempDir("", "multicluster")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	file1 := path.Join(dir, "cluster1")
+	c.Assert(ioutil.WriteFile(file1, []byte("endpoints:\n- http://127.0.0.1:2379\n"), 0644), IsNil)
+
+	// hidden files and directories are ignored
+	c.Assert(ioutil.WriteFile(path.Join(dir, "..data"), []byte{}, 0644), IsNil)
+	c.Assert(os.Mkdir(path.Join(dir, "subdir"), 0755), IsNil)
+
+	lifecycle := &fakeLifecycle{clusters: map[string]string{}}
+	watcher, err := createConfigDirectoryWatcher(dir, lifecycle)
+	c.Assert(err, IsNil)
+	c.Assert(watcher.watch(), IsNil)
+	defer watcher.close()
+
+	// existing files are reported synchronously
+	c.Assert(lifecycle.has("cluster1"), Equals, true)
+	c.Assert(lifecycle.len(), Equals, 1)
+
+	file2 := path.Join(dir, "cluster2")
+	c.Assert(ioutil.WriteFile(file2, []byte("endpoints:\n- http://127.0.0.1:2379\n"), 0644), IsNil)
+	c.Assert(waitUntil(func() bool { return lifecycle.has("cluster2") }), Equals, true)
+
+	c.Assert(os.Remove(file1), IsNil)
+	c.Assert(waitUntil(func() bool { return !lifecycle.has("cluster1") }), Equals, true)
+	c.Assert(lifecycle.len(), Equals, 1)
+}
@@ -0,0 +1,399 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clustermesh
+
+import (
+	"fmt"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/cilium/cilium/pkg/controller"
+	"github.com/cilium/cilium/pkg/identity"
+	"github.com/cilium/cilium/pkg/ipcache"
+	"github.com/cilium/cilium/pkg/kvstore"
+	"github.com/cilium/cilium/pkg/kvstore/allocator"
+	"github.com/cilium/cilium/pkg/kvstore/store"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/node"
+	nodeStore "github.com/cilium/cilium/pkg/node/store"
+	serviceStore "github.com/cilium/cilium/pkg/service/store"
+
+	"github.com/sirupsen/logrus"
+)
+
+// remoteCluster represents another cluster other than the cluster the agent
+// is running in
+type remoteCluster struct {
+	// name is the name of the cluster
+	name string
+
+	// configPath is the path to the etcd configuration to be used to
+	// connect to the etcd cluster of the remote cluster
+	configPath string
+
+	// changed receives an event when the remote cluster configuration has
+	// changed and is closed when the configuration file was removed
+	changed chan bool
+
+	// mesh is the cluster mesh this remote cluster belongs to
+	mesh *ClusterMesh
+
+	controllers *controller.Manager
+
+	// remoteConnectionControllerName is the name of the backing
+	// controller that maintains the remote connection
+	remoteConnectionControllerName string
+
+	// mutex protects the following variables
+	// - backend
+	// - remoteNodes
+	// - remoteServices
+	// - remoteIdentityCache
+	// - ipCacheWatcher
+	mutex lock.RWMutex
+
+	// backend is the kvstore backend being used
+	backend kvstore.BackendOperations
+
+	// remoteNodes is the shared store representing nodes in the remote
+	// cluster
+	remoteNodes *store.SharedStore
+
+	// nodeObserver tracks all nodes of the remote cluster
+	nodeObserver *remoteNodeObserver
+
+	// remoteServices is the shared store representing the global
+	// services of the remote cluster, it is nil if global services are
+	// not imported
+	remoteServices *store.SharedStore
+
+	// serviceObserver tracks all global services of the remote cluster
+	serviceObserver *remoteServiceObserver
+
+	// remoteIdentityCache is the cache of identities of the remote
+	// cluster
+	remoteIdentityCache *allocator.RemoteCache
+
+	// ipCacheWatcher is the watcher importing IP to identity mappings of
+	// the remote cluster into the local IP cache
+	ipCacheWatcher *ipcache.IPIdentityWatcher
+}
+
+func (rc *remoteCluster) getLogger() *logrus.Entry {
+	var (
+		status string
+		err    error
+	)
+
+	rc.mutex.RLock()
+	if rc.backend != nil {
+		status, err = rc.backend.Status()
+	}
+	rc.mutex.RUnlock()
+
+	return log.WithFields(logrus.Fields{
+		fieldClusterName:   rc.name,
+		fieldConfig:        rc.configPath,
+		fieldKVStoreStatus: status,
+		fieldKVStoreErr:    err,
+	})
+}
+
+// releaseOldConnection releases all resources associated with the current
+// connection to the remote cluster
+func (rc *remoteCluster) releaseOldConnection() {
+	rc.mutex.Lock()
+	if rc.ipCacheWatcher != nil {
+		rc.ipCacheWatcher.Close()
+		rc.ipCacheWatcher = nil
+	}
+	if rc.remoteIdentityCache != nil {
+		rc.remoteIdentityCache.Close()
+		rc.remoteIdentityCache = nil
+	}
+	if rc.remoteNodes != nil {
+		rc.remoteNodes.Close()
+		rc.remoteNodes = nil
+	}
+	if rc.nodeObserver != nil {
+		rc.nodeObserver.deleteAll()
+		rc.nodeObserver = nil
+	}
+	if rc.remoteServices != nil {
+		rc.remoteServices.Close()
+		rc.remoteServices = nil
+	}
+	if rc.serviceObserver != nil {
+		rc.serviceObserver.deleteAll()
+		rc.serviceObserver = nil
+	}
+	if rc.backend != nil {
+		kvstore.CloseClient(rc.backend)
+		rc.backend = nil
+	}
+	rc.mutex.Unlock()
+}
+
+func (rc *remoteCluster) restartRemoteConnection() {
+	rc.controllers.UpdateController(rc.remoteConnectionControllerName,
+		controller.ControllerParams{
+			DoFunc: func() error {
+				rc.releaseOldConnection()
+
+				backend, err := kvstore.NewClient(kvstore.EtcdBackendName,
+					map[string]string{kvstore.EtcdOptionConfig: rc.configPath})
+				if err != nil {
+					return fmt.Errorf("unable to connect to etcd of remote cluster: %s", err)
+				}
+
+				observer := &remoteNodeObserver{
+					cluster:  rc.name,
+					observer: rc.mesh.conf.NodeObserver,
+					nodes:    map[string]node.Node{},
+				}
+
+				remoteNodes, err := nodeStore.JoinNodeStore(backend, observer)
+				if err != nil {
+					kvstore.CloseClient(backend)
+					return fmt.Errorf("unable to join node store of remote cluster: %s", err)
+				}
+
+				var (
+					remoteServices  *store.SharedStore
+					serviceObserver *remoteServiceObserver
+				)
+				if rc.mesh.conf.ServiceObserver != nil {
+					serviceObserver = &remoteServiceObserver{
+						cluster:  rc.name,
+						observer: rc.mesh.conf.ServiceObserver,
+						services: map[string]serviceStore.ClusterService{},
+					}
+
+					remoteServices, err = serviceStore.JoinServiceStore(backend, serviceObserver)
+					if err != nil {
+						remoteNodes.Close()
+						observer.deleteAll()
+						kvstore.CloseClient(backend)
+						return fmt.Errorf("unable to join service store of remote cluster: %s", err)
+					}
+				}
+
+				remoteIdentityCache := identity.WatchRemoteIdentities(backend)
+
+				ipCacheWatcher := ipcache.NewIPIdentityWatcher(backend)
+				go ipCacheWatcher.Watch()
+
+				rc.mutex.Lock()
+				rc.backend = backend
+				rc.remoteNodes = remoteNodes
+				rc.nodeObserver = observer
+				rc.remoteServices = remoteServices
+				rc.serviceObserver = serviceObserver
+				rc.remoteIdentityCache = remoteIdentityCache
+				rc.ipCacheWatcher = ipCacheWatcher
+				rc.mutex.Unlock()
+
+				rc.getLogger().Info("Established connection to remote etcd")
+
+				return nil
+			},
+			StopFunc: func() error {
+				rc.releaseOldConnection()
+				rc.getLogger().Info("All resources of remote cluster cleaned up")
+				return nil
+			},
+		},
+	)
+}
+
+func (rc *remoteCluster) onInsert() {
+	rc.getLogger().Info("New remote cluster configuration")
+
+	rc.remoteConnectionControllerName = fmt.Sprintf("remote-etcd-%s", rc.name)
+	rc.restartRemoteConnection()
+
+	go func() {
+		for {
+			val := <-rc.changed
+			if val {
+				rc.getLogger().Info("etcd configuration has changed, re-creating connection")
+				rc.restartRemoteConnection()
+			} else {
+				rc.getLogger().Info("Closing connection to remote etcd")
+				return
+			}
+		}
+	}()
+}
+
+func (rc *remoteCluster) onRemove() {
+	rc.controllers.RemoveAll()
+	close(rc.changed)
+
+	rc.getLogger().Info("Remote cluster disconnected")
+}
+
+func (rc *remoteCluster) isReady() bool {
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+
+	return rc.isReadyLocked()
+}
+
+func (rc *remoteCluster) isReadyLocked() bool {
+	return rc.backend != nil && rc.remoteNodes != nil && rc.ipCacheWatcher != nil
+}
+
+func (rc *remoteCluster) status() *models.RemoteCluster {
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+
+	status := &models.RemoteCluster{
+		Name:      rc.name,
+		Ready:     rc.isReadyLocked(),
+		Connected: rc.backend != nil,
+	}
+
+	if rc.backend != nil {
+		var err error
+		status.Status, err = rc.backend.Status()
+		if err != nil {
+			status.Status = fmt.Sprintf("%s: %s", status.Status, err)
+		}
+	} else {
+		status.Status = "Waiting for initial connection to be established"
+	}
+
+	status.NumNodes = int64(rc.nodeObserver.numNodes())
+	status.NumIdentities = int64(rc.remoteIdentityCache.NumEntries())
+
+	return status
+}
+
+// remoteNodeObserver keeps track of all nodes of a remote cluster and
+// forwards all node events to the observer configured for the cluster mesh
+type remoteNodeObserver struct {
+	cluster  string
+	observer nodeStore.NodeObserver
+
+	mutex lock.RWMutex
+	nodes map[string]node.Node
+}
+
+// NodeUpdated is called when a node of the remote cluster has been created or
+// updated
+func (o *remoteNodeObserver) NodeUpdated(n node.Node) {
+	if n.Cluster != o.cluster {
+		log.WithFields(logrus.Fields{
+			fieldClusterName: o.cluster,
+			"node":           n.Fullname(),
+		}).Warning("Ignoring node of foreign cluster found in remote cluster")
+		return
+	}
+
+	o.mutex.Lock()
+	o.nodes[n.Name] = n
+	o.mutex.Unlock()
+
+	o.observer.NodeUpdated(n)
+}
+
+// NodeDeleted is called when a node of the remote cluster has been removed
+func (o *remoteNodeObserver) NodeDeleted(n node.Node) {
+	o.mutex.Lock()
+	_, ok := o.nodes[n.Name]
+	delete(o.nodes, n.Name)
+	o.mutex.Unlock()
+
+	if ok {
+		o.observer.NodeDeleted(n)
+	}
+}
+
+// deleteAll reports all remaining nodes of the remote cluster as deleted
+func (o *remoteNodeObserver) deleteAll() {
+	o.mutex.Lock()
+	nodes := o.nodes
+	o.nodes = map[string]node.Node{}
+	o.mutex.Unlock()
+
+	for _, n := range nodes {
+		o.observer.NodeDeleted(n)
+	}
+}
+
+func (o *remoteNodeObserver) numNodes() int {
+	if o == nil {
+		return 0
+	}
+
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	return len(o.nodes)
+}
+
+// remoteServiceObserver keeps track of all global services of a remote
+// cluster and forwards all service events to the observer configured for the
+// cluster mesh
+type remoteServiceObserver struct {
+	cluster  string
+	observer serviceStore.ServiceObserver
+
+	mutex    lock.RWMutex
+	services map[string]serviceStore.ClusterService
+}
+
+// ServiceUpdated is called when a global service of the remote cluster has
+// been created or updated
+func (o *remoteServiceObserver) ServiceUpdated(svc serviceStore.ClusterService) {
+	if svc.Cluster != o.cluster {
+		log.WithFields(logrus.Fields{
+			fieldClusterName: o.cluster,
+			"service":        svc.String(),
+		}).Warning("Ignoring service of foreign cluster found in remote cluster")
+		return
+	}
+
+	o.mutex.Lock()
+	o.services[svc.String()] = svc
+	o.mutex.Unlock()
+
+	o.observer.ServiceUpdated(svc)
+}
+
+// ServiceDeleted is called when a global service of the remote cluster has
+// been removed
+func (o *remoteServiceObserver) ServiceDeleted(svc serviceStore.ClusterService) {
+	o.mutex.Lock()
+	_, ok := o.services[svc.String()]
+	delete(o.services, svc.String())
+	o.mutex.Unlock()
+
+	if ok {
+		o.observer.ServiceDeleted(svc)
+	}
+}
+
+// deleteAll reports all remaining global services of the remote cluster as
+// deleted
+func (o *remoteServiceObserver) deleteAll() {
+	o.mutex.Lock()
+	services := o.services
+	o.services = map[string]serviceStore.ClusterService{}
+	o.mutex.Unlock()
+
+	for _, svc := range services {
+		o.observer.ServiceDeleted(svc)
+	}
+}
@@ -15,6 +15,7 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -39,6 +40,11 @@ type ExitReason struct {
 	error
 }
 
+// NewExitReason returns a new ExitReason
+func NewExitReason(reason string) ExitReason {
+	return ExitReason{errors.New(reason)}
+}
+
 // ControllerParams contains all parameters of a controller
 type ControllerParams struct {
 	// DoFunc is the function that will be run until it succeeds and/or
@@ -68,6 +74,42 @@ type ControllerParams struct {
 
 	// NoErrorRetry when set to true, disabled retries on errors
 	NoErrorRetry bool
+
+	// LeaderOnly when set to true, restricts DoFunc to only run while the
+	// node is the leader as determined by the function set with
+	// SetLeaderFunc(). Controllers of other nodes check again after
+	// RunInterval, or leaderCheckInterval if RunInterval is 0.
+	LeaderOnly bool
+}
+
+var (
+	leaderMutex lock.RWMutex
+
+	// leaderFunc returns true if the node is the leader. If unset, all
+	// nodes are considered to be the leader.
+	leaderFunc func() bool
+
+	// leaderCheckInterval is the interval in which a controller marked
+	// LeaderOnly without RunInterval checks whether the node has become
+	// the leader
+	leaderCheckInterval = 10 * time.Second
+)
+
+// SetLeaderFunc sets the function used by controllers marked LeaderOnly to
+// determine whether the node is the leader
+func SetLeaderFunc(f func() bool) {
+	leaderMutex.Lock()
+	leaderFunc = f
+	leaderMutex.Unlock()
+}
+
+// isLeader returns true if the node is the leader
+func isLeader() bool {
+	leaderMutex.RLock()
+	f := leaderFunc
+	leaderMutex.RUnlock()
+
+	return f == nil || f()
 }
 
 // undefinedDoFunc is used when no DoFunc is set. controller.DoFunc is set to this
@@ -182,6 +224,14 @@ func (c *Controller) runController() {
 			interval = c.params.RunInterval
 		)
 
+		if c.params.LeaderOnly && !isLeader() {
+			c.getLogger().Debug("Skipping controller run, not the leader")
+			if interval == time.Duration(0) {
+				interval = leaderCheckInterval
+			}
+			goto wait
+		}
+
 		err = c.params.DoFunc()
 		if err != nil {
 			switch err := err.(type) {
@@ -219,6 +269,7 @@ func (c *Controller) runController() {
 			}
 		}
 
+	wait:
 		select {
 		case <-c.stopForUpdate:
 			goto shutdownForUpdate
@@ -139,3 +139,45 @@ func (b *ControllerSuite) TestRunController(c *C) {
 	c.Assert(ctrl.GetLastError(), IsNil)
 	c.Assert(mngr.RemoveController("test"), IsNil)
 }
+
+func (b *ControllerSuite) TestLeaderOnly(c *C) {
+	oldInterval := leaderCheckInterval
+	leaderCheckInterval = 10 * time.Millisecond
+	defer func() { leaderCheckInterval = oldInterval }()
+
+	leader := make(chan struct{})
+	SetLeaderFunc(func() bool {
+		select {
+		case <-leader:
+			return true
+		default:
+			return false
+		}
+	})
+	defer SetLeaderFunc(nil)
+
+	ran := make(chan struct{})
+	mngr := Manager{}
+	mngr.UpdateController("test", ControllerParams{
+		LeaderOnly: true,
+		DoFunc: func() error {
+			close(ran)
+			return nil
+		},
+	})
+	defer mngr.RemoveAll()
+
+	select {
+	case <-ran:
+		c.Fatal("Controller ran without being the leader")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(leader)
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		c.Fatal("Controller did not run after becoming the leader")
+	}
+}
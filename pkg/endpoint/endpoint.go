@@ -20,7 +20,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand"
 	"net"
 	"os"
 	"path/filepath"
@@ -40,6 +39,7 @@ import (
 	"github.com/cilium/cilium/pkg/controller"
 	identityPkg "github.com/cilium/cilium/pkg/identity"
 	"github.com/cilium/cilium/pkg/k8s"
+	k8sConst "github.com/cilium/cilium/pkg/k8s/apis/cilium.io"
 	cilium_v2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
 	clientset "github.com/cilium/cilium/pkg/k8s/client/clientset/versioned"
 	cilium_client_v2 "github.com/cilium/cilium/pkg/k8s/client/clientset/versioned/typed/cilium.io/v2"
@@ -127,8 +127,7 @@ func getCiliumClient() (ciliumClient cilium_client_v2.CiliumV2Interface, err err
 // objects are created by the sync-to-k8s-ciliumendpoint controller on each
 // Endpoint.
 // The general steps are:
-//   - get list of nodes
-//   - only run with probability 1/nodes
+//   - only run on the leader
 //   - get list of CEPs
 //   - for each CEP
 //       delete CEP if the corresponding pod does not exist
@@ -137,9 +136,6 @@ func RunK8sCiliumEndpointSyncGC() {
 	var (
 		controllerName = fmt.Sprintf("sync-to-k8s-ciliumendpoint-gc (%v)", node.GetName())
 		scopedLog      = log.WithField("controller", controllerName)
-
-		// random source to throttle how often this controller runs cluster-wide
-		runThrottler = rand.New(rand.NewSource(time.Now().UnixNano()))
 	)
 
 	// this is a sanity check
@@ -171,16 +167,10 @@ func RunK8sCiliumEndpointSyncGC() {
 	controller.NewManager().UpdateController(controllerName,
 		controller.ControllerParams{
 			RunInterval: 1 * time.Minute,
+			// This controller runs on every node but only one is
+			// needed to run
+			LeaderOnly: true,
 			DoFunc: func() error {
-				// Don't run if there are no other known nodes
-				// Only run with a probability of 1/(number of nodes in cluster). This
-				// is because this controller runs on every node on the same interval
-				// but only one is neede to run.
-				nodes := node.GetNodes()
-				if len(nodes) <= 1 || runThrottler.Int63n(int64(len(nodes))) != 0 {
-					return nil
-				}
-
 				clusterPodSet := map[string]bool{}
 				clusterPods, err := k8sClient.CoreV1().Pods("").List(meta_v1.ListOptions{})
 				if err != nil {
@@ -1390,7 +1380,7 @@ func (e *Endpoint) GetBPFValue() (*lxcmap.EndpointInfo, error) {
 		// Store security identity in network byte order so it can be
 		// written into the packet without an additional byte order
 		// conversion.
-		SecLabelID: byteorder.HostToNetwork(uint16(e.GetIdentity())).(uint16),
+		SecLabelID: byteorder.HostToNetwork(uint32(e.GetIdentity())).(uint32),
 		LxcID:      e.ID,
 		MAC:        lxcmap.MAC(mac),
 		NodeMAC:    lxcmap.MAC(nodeMAC),
@@ -1639,6 +1629,31 @@ func (e *Endpoint) replaceInformationLabels(l pkgLabels.Labels) {
 	e.OpLabels.OrchestrationInfo.DeleteMarked()
 }
 
+// clusterLabel returns the label carrying the name of the cluster the
+// endpoint is part of.
+func clusterLabel() *pkgLabels.Label {
+	return pkgLabels.NewLabel(k8sConst.PolicyLabelCluster, option.Config.ClusterName, pkgLabels.LabelSourceK8s)
+}
+
+// withClusterLabel returns the identity labels l including the cluster label
+// so endpoints of all workload runtimes and endpoints created via the API can
+// be selected by cluster. Reserved labels are returned unchanged as they map
+// to the reserved identities.
+func withClusterLabel(l pkgLabels.Labels) pkgLabels.Labels {
+	if len(l) == 0 || l.FindReserved() != nil || l[k8sConst.PolicyLabelCluster] != nil {
+		return l
+	}
+
+	result := make(pkgLabels.Labels, len(l)+1)
+	for k, v := range l {
+		result[k] = v
+	}
+	lbl := clusterLabel()
+	result[lbl.Key] = lbl
+
+	return result
+}
+
 // replaceIdentityLabels replaces the identity labels of the endpoint. If a net
 // changed occurred, the identityRevision is bumped and returned, otherwise 0 is
 // returned.
@@ -1646,6 +1661,8 @@ func (e *Endpoint) replaceInformationLabels(l pkgLabels.Labels) {
 func (e *Endpoint) replaceIdentityLabels(l pkgLabels.Labels) int {
 	changed := false
 
+	l = withClusterLabel(l)
+
 	e.OpLabels.OrchestrationIdentity.MarkAllForDeletion()
 	e.OpLabels.Disabled.MarkAllForDeletion()
 
@@ -2145,6 +2162,14 @@ func (e *Endpoint) ModifyIdentityLabels(owner Owner, addLabels, delLabels pkgLab
 		}
 	}
 
+	// Labels added to an endpoint without orchestration labels, e.g. one
+	// created via the API, must carry the cluster label as well
+	lbl := clusterLabel()
+	if newLabels.IdentityLabels().FindReserved() == nil && newLabels.OrchestrationIdentity[lbl.Key] == nil &&
+		newLabels.Custom[lbl.Key] == nil && newLabels.Disabled[lbl.Key] == nil {
+		newLabels.OrchestrationIdentity[lbl.Key] = lbl
+	}
+
 	e.OpLabels = *newLabels
 
 	// Mark with StateWaitingForIdentity, it will be set to
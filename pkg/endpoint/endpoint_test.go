@@ -189,18 +189,28 @@ func (s *EndpointSuite) TestEndpointUpdateLabels(c *C) {
 	e.SetDefaultOpts(nil)
 	e.Mutex.Unlock()
 
-	// Test that inserting identity labels works
+	// Test that inserting identity labels works, the cluster label is
+	// added to all identity labels
 	rev := e.replaceIdentityLabels(pkgLabels.Map2Labels(map[string]string{"foo": "bar", "zip": "zop"}, "cilium"))
 	c.Assert(rev, Not(Equals), 0)
-	c.Assert(string(e.OpLabels.OrchestrationIdentity.SortedList()), Equals, "cilium:foo=bar;cilium:zip=zop;")
+	c.Assert(string(e.OpLabels.OrchestrationIdentity.SortedList()), Equals,
+		"cilium:foo=bar;k8s:io.cilium.k8s.policy.cluster=default;cilium:zip=zop;")
 	// Test that nothing changes
 	rev = e.replaceIdentityLabels(pkgLabels.Map2Labels(map[string]string{"foo": "bar", "zip": "zop"}, "cilium"))
 	c.Assert(rev, Equals, 0)
-	c.Assert(string(e.OpLabels.OrchestrationIdentity.SortedList()), Equals, "cilium:foo=bar;cilium:zip=zop;")
+	c.Assert(string(e.OpLabels.OrchestrationIdentity.SortedList()), Equals,
+		"cilium:foo=bar;k8s:io.cilium.k8s.policy.cluster=default;cilium:zip=zop;")
 	// Remove one label, change the source and value of the other.
 	rev = e.replaceIdentityLabels(pkgLabels.Map2Labels(map[string]string{"foo": "zop"}, "nginx"))
 	c.Assert(rev, Not(Equals), 0)
-	c.Assert(string(e.OpLabels.OrchestrationIdentity.SortedList()), Equals, "nginx:foo=zop;")
+	c.Assert(string(e.OpLabels.OrchestrationIdentity.SortedList()), Equals,
+		"nginx:foo=zop;k8s:io.cilium.k8s.policy.cluster=default;")
+	// Reserved labels map to reserved identities and are left unchanged
+	rev = e.replaceIdentityLabels(pkgLabels.Labels{
+		pkgLabels.IDNameInit: pkgLabels.NewLabel(pkgLabels.IDNameInit, "", pkgLabels.LabelSourceReserved),
+	})
+	c.Assert(rev, Not(Equals), 0)
+	c.Assert(string(e.OpLabels.OrchestrationIdentity.SortedList()), Equals, "reserved:init=;")
 
 	// Test that inserting information labels works
 	e.replaceInformationLabels(pkgLabels.Map2Labels(map[string]string{"foo": "bar", "zip": "zop"}, "cilium"))
@@ -35,6 +35,11 @@ const (
 	// IPv4Prefix is the prefix used in Cilium IDs when the identifier is
 	// the IPv4 address of the endpoint
 	IPv4Prefix = "ipv4"
+
+	// IPv6Prefix is the prefix used to index endpoints by their IPv6
+	// address. IDs with this prefix cannot be parsed as the address
+	// contains colons.
+	IPv6Prefix = "ipv6"
 )
 
 func NewCiliumID(id int64) string {
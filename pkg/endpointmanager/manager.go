@@ -39,8 +39,32 @@ var (
 	// be held to read and write.
 	endpoints    = map[uint16]*endpoint.Endpoint{}
 	endpointsAux = map[string]*endpoint.Endpoint{}
+
+	// subscribers is the list of subscribers notified about endpoints
+	// being inserted and removed. mutex must be held to read and write.
+	subscribers []Subscriber
 )
 
+// Subscriber is notified when endpoints are inserted into or removed from the
+// global maps. The methods are called with mutex held and with ep.Mutex.RLock
+// held, implementations must not block and must not call back into the
+// endpointmanager.
+type Subscriber interface {
+	// EndpointCreated is called after ep has been inserted
+	EndpointCreated(ep *endpoint.Endpoint)
+
+	// EndpointDeleted is called after ep has been removed
+	EndpointDeleted(ep *endpoint.Endpoint)
+}
+
+// Subscribe registers s to be notified about all subsequent endpoint
+// insertions and removals.
+func Subscribe(s Subscriber) {
+	mutex.Lock()
+	subscribers = append(subscribers, s)
+	mutex.Unlock()
+}
+
 func init() {
 	// EndpointCount is a function used to collect this metric. We cannot
 	// increment/decrement a gauge since we invoke Remove gratuitiously and that
@@ -65,6 +89,10 @@ func Insert(ep *endpoint.Endpoint) {
 	endpoints[ep.ID] = ep
 	updateReferences(ep)
 	ep.RunK8sCiliumEndpointSync() // start the k8s update controller
+
+	for _, s := range subscribers {
+		s.EndpointCreated(ep)
+	}
 }
 
 // Lookup looks up the endpoint by prefix id
@@ -132,6 +160,14 @@ func LookupIPv4(ipv4 string) *endpoint.Endpoint {
 	return ep
 }
 
+// LookupIPv6 looks up endpoint by IPv6 address
+func LookupIPv6(ipv6 string) *endpoint.Endpoint {
+	mutex.RLock()
+	ep := lookupIPv6(ipv6)
+	mutex.RUnlock()
+	return ep
+}
+
 // UpdateReferences makes an endpoint available by all possible reference
 // fields as available for this endpoint (containerID, IPv4 address, ...)
 // Must be called with ep.Mutex.RLock held.
@@ -146,6 +182,16 @@ func UpdateReferences(ep *endpoint.Endpoint) {
 func Remove(ep *endpoint.Endpoint) {
 	mutex.Lock()
 	defer mutex.Unlock()
+
+	// Remove is invoked gratuitously, only notify subscribers about
+	// endpoints which were actually present.
+	if _, ok := endpoints[ep.ID]; ok {
+		defer func() {
+			for _, s := range subscribers {
+				s.EndpointDeleted(ep)
+			}
+		}()
+	}
 	delete(endpoints, ep.ID)
 
 	if ep.DockerID != "" {
@@ -160,6 +206,10 @@ func Remove(ep *endpoint.Endpoint) {
 		delete(endpointsAux, endpoint.NewID(endpoint.IPv4Prefix, ep.IPv4.String()))
 	}
 
+	if ep.IPv6.String() != "" {
+		delete(endpointsAux, endpoint.NewID(endpoint.IPv6Prefix, ep.IPv6.String()))
+	}
+
 	if ep.ContainerName != "" {
 		delete(endpointsAux, endpoint.NewID(endpoint.ContainerNamePrefix, ep.ContainerName))
 	}
@@ -213,6 +263,13 @@ func lookupIPv4(ipv4 string) *endpoint.Endpoint {
 	return nil
 }
 
+func lookupIPv6(ipv6 string) *endpoint.Endpoint {
+	if ep, ok := endpointsAux[endpoint.NewID(endpoint.IPv6Prefix, ipv6)]; ok {
+		return ep
+	}
+	return nil
+}
+
 func lookupDockerID(id string) *endpoint.Endpoint {
 	if ep, ok := endpointsAux[endpoint.NewID(endpoint.ContainerIdPrefix, id)]; ok {
 		return ep
@@ -239,6 +296,10 @@ func updateReferences(ep *endpoint.Endpoint) {
 		endpointsAux[endpoint.NewID(endpoint.IPv4Prefix, ep.IPv4.String())] = ep
 	}
 
+	if ep.IPv6.String() != "" {
+		endpointsAux[endpoint.NewID(endpoint.IPv6Prefix, ep.IPv6.String())] = ep
+	}
+
 	if ep.ContainerName != "" {
 		endpointsAux[endpoint.NewID(endpoint.ContainerNamePrefix, ep.ContainerName)] = ep
 	}
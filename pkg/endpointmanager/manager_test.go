@@ -0,0 +1,79 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpointmanager
+
+import (
+	"testing"
+
+	"github.com/cilium/cilium/common/addressing"
+	"github.com/cilium/cilium/pkg/endpoint"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type EndpointManagerSuite struct{}
+
+var _ = Suite(&EndpointManagerSuite{})
+
+func (s *EndpointManagerSuite) TearDownTest(c *C) {
+	RemoveAll()
+
+	mutex.Lock()
+	subscribers = nil
+	mutex.Unlock()
+}
+
+type testSubscriber struct {
+	created []uint16
+	deleted []uint16
+}
+
+func (t *testSubscriber) EndpointCreated(ep *endpoint.Endpoint) {
+	t.created = append(t.created, ep.ID)
+}
+
+func (t *testSubscriber) EndpointDeleted(ep *endpoint.Endpoint) {
+	t.deleted = append(t.deleted, ep.ID)
+}
+
+func (s *EndpointManagerSuite) TestSubscribe(c *C) {
+	sub := &testSubscriber{}
+	Subscribe(sub)
+
+	ep := endpoint.NewEndpointWithState(1, endpoint.StateReady)
+	ep.IPv4, _ = addressing.NewCiliumIPv4("10.0.0.1")
+
+	ep.Mutex.RLock()
+	Insert(ep)
+	ep.Mutex.RUnlock()
+	c.Assert(sub.created, DeepEquals, []uint16{1})
+	c.Assert(sub.deleted, IsNil)
+	c.Assert(LookupIPv4("10.0.0.1"), Equals, ep)
+
+	ep.Mutex.RLock()
+	Remove(ep)
+	ep.Mutex.RUnlock()
+	c.Assert(sub.deleted, DeepEquals, []uint16{1})
+	c.Assert(LookupIPv4("10.0.0.1"), IsNil)
+
+	// Removing an endpoint which is not present must not notify
+	ep.Mutex.RLock()
+	Remove(ep)
+	ep.Mutex.RUnlock()
+	c.Assert(sub.deleted, DeepEquals, []uint16{1})
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/cilium/cilium/pkg/kvstore/allocator"
 	"github.com/cilium/cilium/pkg/labels"
 	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/option"
 
 	"github.com/sirupsen/logrus"
 )
@@ -75,11 +76,15 @@ type IdentityAllocatorOwner interface {
 // invocation of this function will have an effect.
 func InitIdentityAllocator(owner IdentityAllocatorOwner) {
 	setupOnce.Do(func() {
-		log.Info("Initializing identity allocator")
-		minID := allocator.ID(MinimalNumericIdentity)
-		maxID := allocator.ID(^uint16(0))
+		minID, maxID := GetAllocationRange(option.Config.ClusterID)
+		log.WithFields(logrus.Fields{
+			"min":        minID,
+			"max":        maxID,
+			"cluster-id": option.Config.ClusterID,
+		}).Info("Initializing identity allocator")
+
 		a, err := allocator.NewAllocator(IdentitiesPath, globalIdentity{},
-			allocator.WithMax(maxID), allocator.WithMin(minID),
+			allocator.WithMax(allocator.ID(maxID)), allocator.WithMin(allocator.ID(minID)),
 			allocator.WithSuffix(owner.GetNodeSuffix()))
 		if err != nil {
 			log.WithError(err).Fatal("Unable to initialize identity allocator")
@@ -163,3 +168,10 @@ func ReleaseSlice(identities []*Identity) error {
 	}
 	return err
 }
+
+// WatchRemoteIdentities starts watching for identities in another kvstore and
+// syncs all identities to the local identity cache. The identity allocator
+// must have been initialized with InitIdentityAllocator() before.
+func WatchRemoteIdentities(backend kvstore.BackendOperations) *allocator.RemoteCache {
+	return identityAllocator.WatchRemoteKVStore(backend)
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/cilium/cilium/pkg/kvstore"
 	"github.com/cilium/cilium/pkg/labels"
+	"github.com/cilium/cilium/pkg/option"
 
 	. "gopkg.in/check.v1"
 )
@@ -69,6 +70,45 @@ func (s *IdentityTestSuite) TestIsReservedIdentity(c *C) {
 	c.Assert(NumericIdentity(123456).IsReservedIdentity(), Equals, false)
 }
 
+func (s *IdentityTestSuite) TestClusterID(c *C) {
+	min, max := GetAllocationRange(0)
+	c.Assert(min, Equals, MinimalAllocationIdentity)
+	c.Assert(max, Equals, MaximumAllocationIdentity)
+	c.Assert(min.ClusterID(), Equals, uint32(0))
+	c.Assert(max.ClusterID(), Equals, uint32(0))
+
+	min, max = GetAllocationRange(5)
+	c.Assert(min, Equals, NumericIdentity(5<<ClusterIDShift|256))
+	c.Assert(max, Equals, NumericIdentity(5<<ClusterIDShift|0xFFFF))
+	c.Assert(min.ClusterID(), Equals, uint32(5))
+	c.Assert(max.ClusterID(), Equals, uint32(5))
+
+	// identity ranges of different clusters may never overlap
+	min2, max2 := GetAllocationRange(6)
+	c.Assert(min2 > max, Equals, true)
+	c.Assert(max2.ClusterID(), Equals, uint32(6))
+
+	min, max = GetAllocationRange(option.ClusterIDMax)
+	c.Assert(min.ClusterID(), Equals, uint32(option.ClusterIDMax))
+	c.Assert(max.ClusterID(), Equals, uint32(option.ClusterIDMax))
+	c.Assert(max, Equals, NumericIdentity(FlowLabelMask))
+}
+
+func (s *IdentityTestSuite) TestFlowLabel(c *C) {
+	// identities of the cluster with the maximum cluster ID must survive
+	// being carried in the IPv6 flow label
+	min, max := GetAllocationRange(option.ClusterIDMax)
+	for _, id := range []NumericIdentity{min, max, ReservedIdentityWorld} {
+		decoded := NumericIdentityFromFlowLabel(id.FlowLabel())
+		c.Assert(decoded, Equals, id)
+		c.Assert(decoded.ClusterID(), Equals, id.ClusterID())
+	}
+
+	// identities of different clusters may not collide on the wire
+	min0, _ := GetAllocationRange(0)
+	c.Assert(min.FlowLabel(), Not(Equals), min0.FlowLabel())
+}
+
 func (s *IdentityTestSuite) TestAllocateIdentityReserved(c *C) {
 	var (
 		lbls  labels.Labels
@@ -145,6 +185,16 @@ func (e *IdentityAllocatorConsulSuite) SetUpTest(c *C) {
 	kvstore.SetupDummy("consul")
 }
 
+type IdentityAllocatorMemorySuite struct {
+	IdentityAllocatorSuite
+}
+
+var _ = Suite(&IdentityAllocatorMemorySuite{})
+
+func (e *IdentityAllocatorMemorySuite) SetUpTest(c *C) {
+	kvstore.SetupDummy(kvstore.MemoryBackendName)
+}
+
 type dummyOwner struct{}
 
 func (d dummyOwner) TriggerPolicyUpdates(force bool) *sync.WaitGroup {
@@ -21,10 +21,32 @@ import (
 )
 
 const (
+	// ClusterIDShift specifies the number of bits the cluster ID will be
+	// shifted
+	ClusterIDShift = 16
+
+	// ClusterIDBits is the number of bits available for the cluster ID.
+	// Identities are carried in the 20 bit IPv6 flow label between nodes
+	// which leaves 4 bits on top of the allocation range.
+	ClusterIDBits = 4
+
+	// FlowLabelMask is the mask of the IPv6 flow label carrying the
+	// identity between nodes, it must match IPV6_FLOWLABEL_MASK in
+	// bpf/lib/ipv6.h
+	FlowLabelMask = 0x000FFFFF
+
 	// MinimalNumericIdentity represents the minimal numeric identity not
 	// used for reserved purposes.
 	MinimalNumericIdentity = NumericIdentity(256)
 
+	// MinimalAllocationIdentity is the minimum numeric identity handed out
+	// by the identity allocator. The cluster ID is encoded on top of it.
+	MinimalAllocationIdentity = MinimalNumericIdentity
+
+	// MaximumAllocationIdentity is the maximum numeric identity handed out
+	// by the identity allocator. The cluster ID is encoded on top of it.
+	MaximumAllocationIdentity = NumericIdentity(1<<ClusterIDShift - 1)
+
 	// InvalidIdentity is the identity assigned if the identity is invalid
 	// or not determined yet
 	InvalidIdentity = NumericIdentity(0)
@@ -98,6 +120,30 @@ func (id NumericIdentity) Uint32() uint32 {
 	return uint32(id)
 }
 
+// ClusterID returns the cluster ID encoded in the identity
+func (id NumericIdentity) ClusterID() uint32 {
+	return (uint32(id) >> ClusterIDShift) & (1<<ClusterIDBits - 1)
+}
+
+// FlowLabel returns the IPv6 flow label carrying the identity between nodes
+// as stored by ipv6_store_flowlabel() in the datapath
+func (id NumericIdentity) FlowLabel() uint32 {
+	return uint32(id) & FlowLabelMask
+}
+
+// NumericIdentityFromFlowLabel returns the identity carried in an IPv6 flow
+// label as derived by derive_sec_ctx() in the datapath
+func NumericIdentityFromFlowLabel(label uint32) NumericIdentity {
+	return NumericIdentity(label & FlowLabelMask)
+}
+
+// GetAllocationRange returns the range of numeric identities handed out by
+// the identity allocator of the cluster with the given ID
+func GetAllocationRange(clusterID int) (min, max NumericIdentity) {
+	clusterBits := NumericIdentity(clusterID) << ClusterIDShift
+	return MinimalAllocationIdentity | clusterBits, MaximumAllocationIdentity | clusterBits
+}
+
 func GetReservedID(name string) NumericIdentity {
 	if v, ok := ReservedIdentities[name]; ok {
 		return v
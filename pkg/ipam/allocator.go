@@ -34,10 +34,25 @@ var (
 	ErrIPv6Disabled = errors.New("IPv6 allocation disabled")
 )
 
-// AllocateIP allocates a IP address.
-func AllocateIP(ip net.IP) error {
-	ipamConf.allocatorMutex.Lock()
-	defer ipamConf.allocatorMutex.Unlock()
+// getPool returns the pool containing ip or nil.
+//
+// ipamConf.allocatorMutex must be held.
+func getPool(ip net.IP) *Pool {
+	for _, p := range ipamConf.Pools {
+		if p.Contains(ip) {
+			return p
+		}
+	}
+	return nil
+}
+
+// allocateIP allocates the IP address ip.
+//
+// ipamConf.allocatorMutex must be held.
+func allocateIP(ip net.IP) error {
+	if p := getPool(ip); p != nil {
+		return p.Allocate(ip)
+	}
 
 	if ip.To4() != nil {
 		if ipamConf.IPv4Allocator == nil {
@@ -60,6 +75,14 @@ func AllocateIP(ip net.IP) error {
 	return nil
 }
 
+// AllocateIP allocates a IP address.
+func AllocateIP(ip net.IP) error {
+	ipamConf.allocatorMutex.Lock()
+	defer ipamConf.allocatorMutex.Unlock()
+
+	return allocateIP(ip)
+}
+
 // AllocateIPString is identical to AllocateIP but takes a string
 func AllocateIPString(ipAddr string) error {
 	ip := net.ParseIP(ipAddr)
@@ -75,33 +98,113 @@ func AllocateIPString(ipAddr string) error {
 // allocation is limited to the specified address family. If the pool has been
 // drained of addresses, an error will be returned.
 func AllocateNext(family string) (net.IP, net.IP, error) {
+	ipv4, ipv6, _, err := AllocateNextForOwner(family, nil)
+	return ipv4, ipv6, err
+}
+
+// selectPool returns the pool to allocate the addresses of owner from or nil
+// if the addresses are allocated from the node allocation range.
+//
+// ipamConf.allocatorMutex must be held.
+func selectPool(owner *Owner) (*Pool, error) {
+	if owner == nil {
+		return nil, nil
+	}
+
+	for _, p := range ipamConf.Pools {
+		if p.Matches(owner) {
+			return p, nil
+		}
+	}
+
+	if name, ok := owner.Annotations[PoolAnnotation]; ok {
+		return nil, fmt.Errorf("unknown IPAM pool %s", name)
+	}
+
+	return nil, nil
+}
+
+// allocateNextFamily allocates the next available address of the IPv6 or
+// IPv4 family from the pool p if it has CIDRs of the family, or else from the
+// node allocation range.
+//
+// ipamConf.allocatorMutex must be held.
+func allocateNextFamily(p *Pool, ipv6 bool) (net.IP, error) {
+	if p != nil && p.HasFamily(ipv6) {
+		return p.AllocateNext(ipv6)
+	}
+	if ipv6 {
+		return ipamConf.IPv6Allocator.AllocateNext()
+	}
+	return ipamConf.IPv4Allocator.AllocateNext()
+}
+
+// AllocateNextForOwner is identical to AllocateNext but allocates the
+// addresses from the first pool matching owner. Addresses of a family for
+// which the pool has no CIDRs, and addresses of owners not matching any pool,
+// are allocated from the node allocation range. The name of the pool is
+// returned, or an empty string if no pool matched. If the owner has a
+// container ID, the allocation is checkpointed.
+func AllocateNextForOwner(family string, owner *Owner) (net.IP, net.IP, string, error) {
 	var ipv4, ipv6 net.IP
 
+	ipamConf.allocatorMutex.Lock()
+	defer ipamConf.allocatorMutex.Unlock()
+
+	p, err := selectPool(owner)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
 	if (family == "ipv6" || family == "") && ipamConf.IPv6Allocator != nil {
-		ipConf, err := ipamConf.IPv6Allocator.AllocateNext()
+		ipConf, err := allocateNextFamily(p, true)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, "", err
 		}
 
 		ipv6 = ipConf
 	}
 
 	if (family == "ipv4" || family == "") && ipamConf.IPv4Allocator != nil {
-		ipConf, err := ipamConf.IPv4Allocator.AllocateNext()
+		ipConf, err := allocateNextFamily(p, false)
 		if err != nil {
-			return nil, nil, err
+			if ipv6 != nil {
+				releaseIP(ipv6)
+			}
+			return nil, nil, "", err
 		}
 
 		ipv4 = ipConf
 	}
 
-	return ipv4, ipv6, nil
+	pool := ""
+	if p != nil {
+		pool = p.Name()
+	}
+
+	if owner != nil {
+		for _, ip := range []net.IP{ipv4, ipv6} {
+			if ip != nil {
+				recordAllocation(ip, owner.ContainerID)
+			}
+		}
+	}
+
+	return ipv4, ipv6, pool, nil
 }
 
-// ReleaseIP release a IP address.
-func ReleaseIP(ip net.IP) error {
-	ipamConf.allocatorMutex.Lock()
-	defer ipamConf.allocatorMutex.Unlock()
+// releaseIP releases the IP address ip and removes its checkpointed
+// allocation.
+//
+// ipamConf.allocatorMutex must be held.
+func releaseIP(ip net.IP) error {
+	if p := getPool(ip); p != nil {
+		if err := p.Release(ip); err != nil {
+			return err
+		}
+		removeAllocation(ip)
+		return nil
+	}
 
 	if ip.To4() != nil {
 		if ipamConf.IPv4Allocator == nil {
@@ -121,9 +224,18 @@ func ReleaseIP(ip net.IP) error {
 		}
 	}
 
+	removeAllocation(ip)
 	return nil
 }
 
+// ReleaseIP release a IP address.
+func ReleaseIP(ip net.IP) error {
+	ipamConf.allocatorMutex.Lock()
+	defer ipamConf.allocatorMutex.Unlock()
+
+	return releaseIP(ip)
+}
+
 // ReleaseIPString is identical to ReleaseIP but takes a string
 func ReleaseIPString(ipAddr string) error {
 	ip := net.ParseIP(ipAddr)
@@ -134,7 +246,8 @@ func ReleaseIPString(ipAddr string) error {
 	return ReleaseIP(ip)
 }
 
-// Dump dumps the list of allocated IP addresses
+// Dump dumps the list of allocated IP addresses, including the addresses
+// allocated from pools
 func Dump() ([]string, []string) {
 	ipamConf.allocatorMutex.RLock()
 	defer ipamConf.allocatorMutex.RUnlock()
@@ -161,5 +274,31 @@ func Dump() ([]string, []string) {
 		}
 	}
 
+	for _, p := range ipamConf.Pools {
+		poolv4, poolv6 := p.dump()
+		allocv4 = append(allocv4, poolv4...)
+		allocv6 = append(allocv6, poolv6...)
+	}
+
 	return allocv4, allocv6
 }
+
+// HasPools returns true if any pool is configured
+func HasPools() bool {
+	ipamConf.allocatorMutex.RLock()
+	defer ipamConf.allocatorMutex.RUnlock()
+
+	return len(ipamConf.Pools) > 0
+}
+
+// DumpPools returns the utilization of the pools
+func DumpPools() []PoolStatus {
+	ipamConf.allocatorMutex.RLock()
+	defer ipamConf.allocatorMutex.RUnlock()
+
+	status := make([]PoolStatus, 0, len(ipamConf.Pools))
+	for _, p := range ipamConf.Pools {
+		status = append(status, p.Status())
+	}
+	return status
+}
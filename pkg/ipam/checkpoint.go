@@ -0,0 +1,292 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/cilium/cilium/pkg/atomicfile"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// CheckpointFile is the file in the state directory holding the
+	// checkpointed allocations
+	CheckpointFile = "ipam.json"
+
+	// OrphanGracePeriod is the time during which an allocation is never
+	// considered orphaned. It covers the time between the allocation by
+	// the CNI plugin and the owner becoming visible to the container
+	// runtime.
+	OrphanGracePeriod = 5 * time.Minute
+)
+
+// Allocation is an address allocated to an owner.
+type Allocation struct {
+	// IP is the allocated address
+	IP string `json:"ip"`
+
+	// Owner is the ID of the container the address was allocated to
+	Owner string `json:"owner"`
+
+	// Timestamp is the time of the allocation
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// IsOrphanFunc returns true if the address of the allocation a is no longer
+// used by its owner.
+type IsOrphanFunc func(a Allocation) bool
+
+// CheckpointStatus is the status of the checkpointed allocations and of the
+// garbage collection of orphaned allocations.
+type CheckpointStatus struct {
+	// Allocations is the number of checkpointed allocations
+	Allocations int
+
+	// LastGC is the time of the last garbage collection
+	LastGC time.Time
+
+	// Released are the orphaned allocations released by the last garbage
+	// collection
+	Released []Allocation
+
+	// ReleasedTotal is the number of orphaned allocations released since
+	// the start of the agent
+	ReleasedTotal int
+}
+
+type checkpoint struct {
+	path          string
+	allocations   map[string]Allocation
+	lastGC        time.Time
+	released      []Allocation
+	releasedTotal int
+}
+
+// loadCheckpoint returns the allocations checkpointed to path.
+func loadCheckpoint(path string) ([]Allocation, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var allocations []Allocation
+	if err := json.Unmarshal(data, &allocations); err != nil {
+		return nil, fmt.Errorf("unable to parse IPAM checkpoint %s: %s", path, err)
+	}
+
+	return allocations, nil
+}
+
+// writeCheckpoint atomically replaces the file at path with allocations.
+func writeCheckpoint(path string, allocations []Allocation) error {
+	data, err := json.Marshal(allocations)
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(path, data, 0600)
+}
+
+// save persists the allocations of the checkpoint.
+func (c *checkpoint) save() {
+	allocations := make([]Allocation, 0, len(c.allocations))
+	for _, a := range c.allocations {
+		allocations = append(allocations, a)
+	}
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].IP < allocations[j].IP
+	})
+
+	if err := writeCheckpoint(c.path, allocations); err != nil {
+		log.WithError(err).WithField(logfields.Path, c.path).Warning("Unable to checkpoint IPAM allocations")
+	}
+}
+
+// recordAllocation checkpoints the allocation of ip to owner if checkpointing
+// is enabled and owner is known.
+//
+// ipamConf.allocatorMutex must be held.
+func recordAllocation(ip net.IP, owner string) {
+	c := ipamConf.checkpoint
+	if c == nil || owner == "" {
+		return
+	}
+
+	c.allocations[ip.String()] = Allocation{
+		IP:        ip.String(),
+		Owner:     owner,
+		Timestamp: time.Now(),
+	}
+	c.save()
+}
+
+// removeAllocation removes the checkpointed allocation of ip, if any.
+//
+// ipamConf.allocatorMutex must be held.
+func removeAllocation(ip net.IP) {
+	c := ipamConf.checkpoint
+	if c == nil {
+		return
+	}
+
+	if _, ok := c.allocations[ip.String()]; ok {
+		delete(c.allocations, ip.String())
+		c.save()
+	}
+}
+
+// isAllocated returns true if ip is allocated.
+//
+// ipamConf.allocatorMutex must be held.
+func isAllocated(ip net.IP) bool {
+	if p := getPool(ip); p != nil {
+		r := p.getRange(ip)
+		return r.Has(ip)
+	}
+
+	if ip.To4() != nil {
+		return ipamConf.IPv4Allocator != nil && ipamConf.IPv4Allocator.Has(ip)
+	}
+	return ipamConf.IPv6Allocator != nil && ipamConf.IPv6Allocator.Has(ip)
+}
+
+// isOrphan returns true if the allocation a is older than OrphanGracePeriod
+// and isOrphan considers it orphaned.
+func isOrphan(a Allocation, now time.Time, isOrphan IsOrphanFunc) bool {
+	return isOrphan != nil && now.Sub(a.Timestamp) >= OrphanGracePeriod && isOrphan(a)
+}
+
+// RestoreCheckpoint enables checkpointing the allocations of owners to path
+// and restores the allocations checkpointed by the previous agent. The
+// addresses of allocations which are not allocated already, e.g. by restored
+// endpoints, are allocated again unless isOrphan considers them orphaned. If
+// isOrphan is nil, all allocations are restored.
+func RestoreCheckpoint(path string, isOrphanFn IsOrphanFunc) error {
+	allocations, err := loadCheckpoint(path)
+
+	ipamConf.allocatorMutex.Lock()
+	defer ipamConf.allocatorMutex.Unlock()
+
+	c := &checkpoint{
+		path:        path,
+		allocations: map[string]Allocation{},
+		lastGC:      time.Now(),
+	}
+	ipamConf.checkpoint = c
+
+	for _, a := range allocations {
+		scopedLog := log.WithFields(logrus.Fields{
+			logfields.IPAddr:      a.IP,
+			logfields.ContainerID: a.Owner,
+		})
+
+		ip := net.ParseIP(a.IP)
+		if ip == nil {
+			scopedLog.Warning("Ignoring invalid checkpointed IPAM allocation")
+			continue
+		}
+
+		if !isAllocated(ip) {
+			if isOrphan(a, c.lastGC, isOrphanFn) {
+				scopedLog.Info("Released orphaned IPAM allocation")
+				c.released = append(c.released, a)
+				continue
+			}
+
+			if err := allocateIP(ip); err != nil {
+				scopedLog.WithError(err).Warning("Unable to restore checkpointed IPAM allocation")
+				continue
+			}
+		}
+
+		c.allocations[a.IP] = a
+	}
+
+	c.releasedTotal = len(c.released)
+	c.save()
+
+	log.WithFields(logrus.Fields{
+		"count.restored": len(c.allocations),
+		"count.released": len(c.released),
+	}).Info("Restored checkpointed IPAM allocations")
+
+	return err
+}
+
+// CollectOrphans releases the checkpointed allocations which isOrphan
+// considers orphaned.
+func CollectOrphans(isOrphanFn IsOrphanFunc) {
+	ipamConf.allocatorMutex.Lock()
+	defer ipamConf.allocatorMutex.Unlock()
+
+	c := ipamConf.checkpoint
+	if c == nil {
+		return
+	}
+
+	c.lastGC = time.Now()
+	c.released = nil
+
+	for _, a := range c.allocations {
+		if !isOrphan(a, c.lastGC, isOrphanFn) {
+			continue
+		}
+
+		scopedLog := log.WithFields(logrus.Fields{
+			logfields.IPAddr:      a.IP,
+			logfields.ContainerID: a.Owner,
+		})
+		if err := releaseIP(net.ParseIP(a.IP)); err != nil {
+			scopedLog.WithError(err).Warning("Unable to release orphaned IPAM allocation")
+			continue
+		}
+
+		scopedLog.Info("Released orphaned IPAM allocation")
+		c.released = append(c.released, a)
+	}
+
+	c.releasedTotal += len(c.released)
+}
+
+// DumpCheckpoint returns the status of the checkpointed allocations or nil if
+// checkpointing is disabled.
+func DumpCheckpoint() *CheckpointStatus {
+	ipamConf.allocatorMutex.RLock()
+	defer ipamConf.allocatorMutex.RUnlock()
+
+	c := ipamConf.checkpoint
+	if c == nil {
+		return nil
+	}
+
+	return &CheckpointStatus{
+		Allocations:   len(c.allocations),
+		LastGC:        c.lastGC,
+		Released:      append([]Allocation(nil), c.released...),
+		ReleasedTotal: c.releasedTotal,
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cilium/cilium/pkg/node"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *IPAMSuite) TestCheckpoint(c *C) {
+	node.InitDefaultPrefix("")
+	c.Assert(Init(), IsNil)
+	defer func() { ipamConf.checkpoint = nil }()
+
+	dir, err := ioutil.TempDir("", "cilium-ipam-checkpoint")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, CheckpointFile)
+
+	c.Assert(RestoreCheckpoint(path, nil), IsNil)
+	c.Assert(DumpCheckpoint().Allocations, Equals, 0)
+
+	ip1, _, _, err := AllocateNextForOwner("ipv4", &Owner{ContainerID: "c1"})
+	c.Assert(err, IsNil)
+	ip2, _, _, err := AllocateNextForOwner("ipv4", &Owner{ContainerID: "c2"})
+	c.Assert(err, IsNil)
+
+	// Allocations without a container ID are not checkpointed
+	ip3, _, err := AllocateNext("ipv4")
+	c.Assert(err, IsNil)
+
+	allocations, err := loadCheckpoint(path)
+	c.Assert(err, IsNil)
+	c.Assert(allocations, HasLen, 2)
+
+	c.Assert(ReleaseIP(ip2), IsNil)
+	allocations, err = loadCheckpoint(path)
+	c.Assert(err, IsNil)
+	c.Assert(allocations, HasLen, 1)
+	c.Assert(allocations[0].IP, Equals, ip1.String())
+	c.Assert(allocations[0].Owner, Equals, "c1")
+
+	// Simulate a restart of the agent, ip1 is used by a restored endpoint
+	c.Assert(Init(), IsNil)
+	c.Assert(AllocateIP(ip1), IsNil)
+
+	old := time.Now().Add(-2 * OrphanGracePeriod)
+	c.Assert(writeCheckpoint(path, []Allocation{
+		{IP: ip1.String(), Owner: "gone", Timestamp: old},
+		{IP: ip2.String(), Owner: "gone", Timestamp: old},
+		{IP: ip3.String(), Owner: "gone", Timestamp: time.Now()},
+		{IP: "invalid", Owner: "c4", Timestamp: old},
+	}), IsNil)
+
+	gone := func(a Allocation) bool { return a.Owner == "gone" }
+	c.Assert(RestoreCheckpoint(path, gone), IsNil)
+
+	status := DumpCheckpoint()
+	c.Assert(status.Allocations, Equals, 2)
+	c.Assert(status.Released, HasLen, 1)
+	c.Assert(status.Released[0].IP, Equals, ip2.String())
+	c.Assert(status.ReleasedTotal, Equals, 1)
+
+	// Allocations within the grace period are restored
+	c.Assert(AllocateIP(ip3), Not(IsNil))
+	c.Assert(AllocateIP(ip2), IsNil)
+	c.Assert(ReleaseIP(ip2), IsNil)
+
+	// Orphans are collected once the grace period has passed, ip1 is
+	// considered orphaned as well as gone does not check endpoints
+	ipamConf.checkpoint.allocations[ip3.String()] = Allocation{
+		IP:        ip3.String(),
+		Owner:     "gone",
+		Timestamp: old,
+	}
+	CollectOrphans(gone)
+
+	status = DumpCheckpoint()
+	c.Assert(status.Released, HasLen, 2)
+	c.Assert(status.ReleasedTotal, Equals, 3)
+	c.Assert(AllocateIP(ip3), IsNil)
+	c.Assert(ReleaseIP(ip3), IsNil)
+
+	allocations, err = loadCheckpoint(path)
+	c.Assert(err, IsNil)
+	c.Assert(allocations, HasLen, 0)
+}
+
+func (s *IPAMSuite) TestIsOrphan(c *C) {
+	now := time.Now()
+	a := Allocation{IP: "192.0.2.1", Owner: "c1", Timestamp: now}
+	always := func(Allocation) bool { return true }
+
+	c.Assert(isOrphan(a, now, nil), Equals, false)
+	c.Assert(isOrphan(a, now, always), Equals, false)
+	c.Assert(isOrphan(a, now.Add(OrphanGracePeriod), always), Equals, true)
+	c.Assert(isOrphan(a, now.Add(OrphanGracePeriod), func(Allocation) bool { return false }), Equals, false)
+}
@@ -0,0 +1,339 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterpool
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cilium/cilium/pkg/kvstore"
+	"github.com/cilium/cilium/pkg/lock"
+)
+
+var (
+	// LeasePrefix is the kvstore prefix of the allocation ranges leased by
+	// the nodes
+	//
+	// WARNING - STABLE API: Changing the structure or values of this will
+	// break backwards compatibility
+	LeasePrefix = path.Join(kvstore.BaseKeyPrefix, "state", "nodecidrs", "v1")
+
+	// leasesPrefix holds a key per leased allocation range
+	leasesPrefix = path.Join(LeasePrefix, "leases")
+)
+
+// LeaseTakenError is returned by Renew if the lease has expired and the
+// allocation range has been leased by another node in the meantime. The
+// allocation range must no longer be used by the node.
+type LeaseTakenError struct {
+	// CIDR is the allocation range
+	CIDR *net.IPNet
+
+	// Node is the name of the node now holding the lease
+	Node string
+}
+
+func (e *LeaseTakenError) Error() string {
+	return fmt.Sprintf("allocation range %s has been leased by node %s", e.CIDR, e.Node)
+}
+
+// Lease is an allocation range leased by a node. The key of the lease is
+// attached to a kvstore lease of the node and is removed by the kvstore
+// once the node fails to renew it, so the expiration does not depend on the
+// clocks of the nodes.
+//
+// WARNING - STABLE API: Changing the structure of the lease may break
+// backwards compatibility
+type Lease struct {
+	// Node is the name of the node holding the lease
+	Node string `json:"node"`
+}
+
+// Allocator leases allocation ranges of a fixed size out of a cluster CIDR
+// to a node.
+type Allocator struct {
+	node     string
+	cluster  *net.IPNet
+	maskSize int
+	duration time.Duration
+
+	// mutex protects lease
+	mutex lock.Mutex
+
+	// lease is the kvstore lease the keys of the leased allocation ranges
+	// are attached to, it is nil until the first allocation range has
+	// been leased
+	lease interface{}
+}
+
+// NewAllocator returns an allocator leasing allocation ranges with a prefix
+// length of maskSize out of cluster to node. Leases expire after duration
+// unless renewed.
+func NewAllocator(node string, cluster *net.IPNet, maskSize int, duration time.Duration) (*Allocator, error) {
+	ones, bits := cluster.Mask.Size()
+	if maskSize < ones || maskSize > bits {
+		return nil, fmt.Errorf("mask size /%d must be between /%d and /%d for cluster CIDR %s",
+			maskSize, ones, bits, cluster)
+	}
+
+	if duration <= 0 {
+		return nil, fmt.Errorf("lease duration must be positive")
+	}
+
+	return &Allocator{
+		node:     node,
+		cluster:  cluster,
+		maskSize: maskSize,
+		duration: duration,
+	}, nil
+}
+
+// Duration returns the duration of the leases.
+func (a *Allocator) Duration() time.Duration {
+	return a.duration
+}
+
+func leasePath(cidr *net.IPNet) string {
+	ones, _ := cidr.Mask.Size()
+	return path.Join(leasesPrefix, cidr.IP.String(), strconv.Itoa(ones))
+}
+
+// parseLeasePath returns the allocation range of the lease key.
+func parseLeasePath(key string) (*net.IPNet, error) {
+	_, cidr, err := net.ParseCIDR(strings.TrimPrefix(key, leasesPrefix+"/"))
+	return cidr, err
+}
+
+// subnet returns the allocation range containing ip.
+func (a *Allocator) subnet(ip net.IP) *net.IPNet {
+	_, bits := a.cluster.Mask.Size()
+	mask := net.CIDRMask(a.maskSize, bits)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+// getLeases returns the leases of the allocation ranges of the cluster CIDR
+// indexed by allocation range.
+func (a *Allocator) getLeases() (map[string]*Lease, error) {
+	pairs, err := kvstore.ListPrefix(leasesPrefix + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	leases := make(map[string]*Lease, len(pairs))
+	for key, value := range pairs {
+		cidr, err := parseLeasePath(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lease key %s: %s", key, err)
+		}
+		if ones, _ := cidr.Mask.Size(); ones != a.maskSize || !a.cluster.Contains(cidr.IP) {
+			continue
+		}
+
+		var lease Lease
+		if err := json.Unmarshal(value, &lease); err != nil {
+			return nil, fmt.Errorf("invalid lease %s: %s", key, err)
+		}
+		leases[cidr.String()] = &lease
+	}
+
+	return leases, nil
+}
+
+// getLease returns the kvstore lease of the node, creating it if needed.
+func (a *Allocator) getLease() (interface{}, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.lease == nil {
+		lease, err := kvstore.Client().CreateLease(a.duration)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create lease: %s", err)
+		}
+		a.lease = lease
+	}
+
+	return a.lease, nil
+}
+
+// setLease atomically leases cidr to the node if it is still leased as
+// described by current, nil meaning not leased. It returns false if the
+// lease has been changed by another node in the meantime.
+func (a *Allocator) setLease(cidr *net.IPNet, current *Lease) (bool, error) {
+	value, err := json.Marshal(Lease{Node: a.node})
+	if err != nil {
+		return false, err
+	}
+
+	lease, err := a.getLease()
+	if err != nil {
+		return false, err
+	}
+
+	key := leasePath(cidr)
+	txn := kvstore.NewTxn()
+	if current == nil {
+		txn.IfMissing(key)
+	} else {
+		currentValue, err := json.Marshal(current)
+		if err != nil {
+			return false, err
+		}
+		txn.IfValue(key, currentValue)
+	}
+
+	return kvstore.Commit(txn.PutWithLease(key, value, lease))
+}
+
+// isAvailable returns true if cidr is not leased or is leased by the node.
+func (a *Allocator) isAvailable(leases map[string]*Lease, cidr *net.IPNet) bool {
+	lease, ok := leases[cidr.String()]
+	return !ok || lease.Node == a.node
+}
+
+// tryAcquire leases cidr to the node if it is available and returns true on
+// success. Leases of the node attached to an old kvstore lease, e.g. before
+// a restart, are taken over.
+func (a *Allocator) tryAcquire(leases map[string]*Lease, cidr *net.IPNet) (bool, error) {
+	if !a.isAvailable(leases, cidr) {
+		return false, nil
+	}
+	return a.setLease(cidr, leases[cidr.String()])
+}
+
+// Acquire leases an allocation range to the node. The available allocation
+// ranges containing one of hints, e.g. the addresses used by the node before,
+// are preferred, followed by an allocation range already leased to the node.
+// Otherwise, the first available allocation range of the cluster CIDR is
+// leased.
+func (a *Allocator) Acquire(hints ...net.IP) (*net.IPNet, error) {
+	leases, err := a.getLeases()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list leases: %s", err)
+	}
+
+	candidates := []*net.IPNet{}
+	for _, hint := range hints {
+		if hint != nil && a.cluster.Contains(hint) {
+			candidates = append(candidates, a.subnet(hint))
+		}
+	}
+
+	var own []*net.IPNet
+	for key, lease := range leases {
+		if lease.Node == a.node {
+			_, cidr, _ := net.ParseCIDR(key)
+			own = append(own, cidr)
+		}
+	}
+	sort.Slice(own, func(i, j int) bool {
+		return own[i].String() < own[j].String()
+	})
+	candidates = append(candidates, own...)
+
+	for _, cidr := range candidates {
+		if ok, err := a.tryAcquire(leases, cidr); err != nil || ok {
+			return cidr, err
+		}
+	}
+
+	ones, bits := a.cluster.Mask.Size()
+	count := new(big.Int).Lsh(big.NewInt(1), uint(a.maskSize-ones))
+	step := new(big.Int).Lsh(big.NewInt(1), uint(bits-a.maskSize))
+	base := new(big.Int).SetBytes(a.cluster.IP)
+	size := len(a.cluster.IP)
+
+	for i := big.NewInt(0); i.Cmp(count) < 0; i.Add(i, big.NewInt(1)) {
+		addr := new(big.Int).Add(base, new(big.Int).Mul(i, step)).Bytes()
+		ip := make(net.IP, size)
+		copy(ip[size-len(addr):], addr)
+
+		cidr := a.subnet(ip)
+		if ok, err := a.tryAcquire(leases, cidr); err != nil || ok {
+			return cidr, err
+		}
+	}
+
+	return nil, fmt.Errorf("no allocation range left in cluster CIDR %s", a.cluster)
+}
+
+// Renew renews the kvstore lease of the node and verifies that cidr is still
+// leased to the node. If the kvstore lease has expired in the meantime, cidr
+// is leased again unless it has been leased by another node, in which case a
+// *LeaseTakenError is returned.
+func (a *Allocator) Renew(cidr *net.IPNet) error {
+	a.mutex.Lock()
+	lease := a.lease
+	a.mutex.Unlock()
+
+	if lease != nil {
+		if err := kvstore.Client().KeepAlive(lease); err != nil {
+			// The lease has expired, a new one is created below
+			a.mutex.Lock()
+			if a.lease == lease {
+				a.lease = nil
+			}
+			a.mutex.Unlock()
+		}
+	}
+
+	leases, err := a.getLeases()
+	if err != nil {
+		return fmt.Errorf("unable to list leases: %s", err)
+	}
+
+	current, ok := leases[cidr.String()]
+	if ok && current.Node != a.node {
+		return &LeaseTakenError{CIDR: cidr, Node: current.Node}
+	}
+	if ok && lease != nil && a.hasLease(lease) {
+		return nil
+	}
+
+	succeeded, err := a.setLease(cidr, current)
+	if err != nil {
+		return err
+	}
+	if !succeeded {
+		return fmt.Errorf("lease of allocation range %s changed concurrently", cidr)
+	}
+
+	return nil
+}
+
+// hasLease returns true if lease is still the kvstore lease of the node.
+func (a *Allocator) hasLease(lease interface{}) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.lease == lease
+}
+
+// Release removes the lease of cidr if it is held by the node.
+func (a *Allocator) Release(cidr *net.IPNet) error {
+	value, err := json.Marshal(Lease{Node: a.node})
+	if err != nil {
+		return err
+	}
+
+	key := leasePath(cidr)
+	_, err = kvstore.Commit(kvstore.NewTxn().IfValue(key, value).Delete(key))
+	return err
+}
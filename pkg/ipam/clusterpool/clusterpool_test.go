@@ -0,0 +1,170 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterpool
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cilium/cilium/pkg/kvstore"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type ClusterPoolSuite struct{}
+
+var _ = Suite(&ClusterPoolSuite{})
+
+func (s *ClusterPoolSuite) SetUpTest(c *C) {
+	kvstore.SetupDummy(kvstore.MemoryBackendName)
+}
+
+func (s *ClusterPoolSuite) TearDownTest(c *C) {
+	kvstore.Close()
+}
+
+func newTestAllocator(c *C, node, cluster string, maskSize int) *Allocator {
+	_, cidr, err := net.ParseCIDR(cluster)
+	c.Assert(err, IsNil)
+	a, err := NewAllocator(node, cidr, maskSize, time.Hour)
+	c.Assert(err, IsNil)
+	return a
+}
+
+func (s *ClusterPoolSuite) TestNewAllocator(c *C) {
+	_, cidr, _ := net.ParseCIDR("10.0.0.0/16")
+
+	_, err := NewAllocator("node1", cidr, 8, time.Hour)
+	c.Assert(err, Not(IsNil))
+	_, err = NewAllocator("node1", cidr, 33, time.Hour)
+	c.Assert(err, Not(IsNil))
+	_, err = NewAllocator("node1", cidr, 24, 0)
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *ClusterPoolSuite) TestAcquire(c *C) {
+	a1 := newTestAllocator(c, "node1", "10.0.0.0/22", 24)
+	a2 := newTestAllocator(c, "node2", "10.0.0.0/22", 24)
+
+	cidr, err := a1.Acquire()
+	c.Assert(err, IsNil)
+	c.Assert(cidr.String(), Equals, "10.0.0.0/24")
+
+	// The lease of the node is reused
+	cidr, err = a1.Acquire()
+	c.Assert(err, IsNil)
+	c.Assert(cidr.String(), Equals, "10.0.0.0/24")
+
+	// Leases of other nodes are skipped
+	cidr, err = a2.Acquire(net.ParseIP("10.0.0.1"))
+	c.Assert(err, IsNil)
+	c.Assert(cidr.String(), Equals, "10.0.1.0/24")
+
+	// Hints outside of the cluster CIDR are ignored
+	a3 := newTestAllocator(c, "node3", "10.0.0.0/22", 24)
+	cidr, err = a3.Acquire(net.ParseIP("10.0.3.1"), net.ParseIP("192.168.0.1"))
+	c.Assert(err, IsNil)
+	c.Assert(cidr.String(), Equals, "10.0.3.0/24")
+
+	a4 := newTestAllocator(c, "node4", "10.0.0.0/22", 24)
+	cidr, err = a4.Acquire()
+	c.Assert(err, IsNil)
+	c.Assert(cidr.String(), Equals, "10.0.2.0/24")
+
+	a5 := newTestAllocator(c, "node5", "10.0.0.0/22", 24)
+	_, err = a5.Acquire()
+	c.Assert(err, Not(IsNil))
+
+	// Expired leases can be taken over
+	c.Assert(kvstore.Client().DeleteLease(a1.lease), IsNil)
+	cidr, err = a5.Acquire()
+	c.Assert(err, IsNil)
+	c.Assert(cidr.String(), Equals, "10.0.0.0/24")
+
+	err = a1.Renew(cidr)
+	c.Assert(err, Not(IsNil))
+	taken, ok := err.(*LeaseTakenError)
+	c.Assert(ok, Equals, true)
+	c.Assert(taken.Node, Equals, "node5")
+	c.Assert(a5.Renew(cidr), IsNil)
+
+	// Releasing leases of other nodes has no effect
+	c.Assert(a1.Release(cidr), IsNil)
+	leases, err := a5.getLeases()
+	c.Assert(err, IsNil)
+	c.Assert(leases[cidr.String()].Node, Equals, "node5")
+
+	c.Assert(a5.Release(cidr), IsNil)
+	leases, err = a5.getLeases()
+	c.Assert(err, IsNil)
+	c.Assert(leases, HasLen, 3)
+}
+
+func (s *ClusterPoolSuite) TestAcquireIPv6(c *C) {
+	a1 := newTestAllocator(c, "node1", "fd00::/94", 96)
+	a2 := newTestAllocator(c, "node2", "fd00::/94", 96)
+
+	cidr, err := a1.Acquire(nil)
+	c.Assert(err, IsNil)
+	c.Assert(cidr.String(), Equals, "fd00::/96")
+
+	cidr, err = a2.Acquire()
+	c.Assert(err, IsNil)
+	c.Assert(cidr.String(), Equals, "fd00::1:0:0/96")
+}
+
+func (s *ClusterPoolSuite) TestLeaseExpiry(c *C) {
+	_, cluster, _ := net.ParseCIDR("10.0.0.0/22")
+	a1, err := NewAllocator("node1", cluster, 24, time.Second)
+	c.Assert(err, IsNil)
+
+	cidr, err := a1.Acquire()
+	c.Assert(err, IsNil)
+	c.Assert(cidr.String(), Equals, "10.0.0.0/24")
+
+	// The lease is removed by the kvstore once it is no longer renewed
+	for i := 0; i < 50; i++ {
+		if leases, _ := a1.getLeases(); len(leases) == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	leases, err := a1.getLeases()
+	c.Assert(err, IsNil)
+	c.Assert(leases, HasLen, 0)
+
+	// Renewing leases the allocation range again if it is still available
+	c.Assert(a1.Renew(cidr), IsNil)
+	leases, err = a1.getLeases()
+	c.Assert(err, IsNil)
+	c.Assert(leases[cidr.String()].Node, Equals, "node1")
+	c.Assert(a1.Renew(cidr), IsNil)
+
+	// A restarted node takes over its lease
+	a2, err := NewAllocator("node1", cluster, 24, time.Hour)
+	c.Assert(err, IsNil)
+	cidr2, err := a2.Acquire()
+	c.Assert(err, IsNil)
+	c.Assert(cidr2.String(), Equals, cidr.String())
+	c.Assert(kvstore.Client().DeleteLease(a1.lease), IsNil)
+	leases, err = a2.getLeases()
+	c.Assert(err, IsNil)
+	c.Assert(leases[cidr.String()].Node, Equals, "node1")
+}
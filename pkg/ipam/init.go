@@ -196,3 +196,67 @@ func Init() error {
 
 	return nil
 }
+
+// InitPools configures the named pools of addresses. The CIDRs of the pools
+// must neither overlap with each other nor with the node allocation ranges.
+// Init must have been called before. The CIDRs are routed to the Cilium
+// network of the node like the node allocation ranges, so InitPools must be
+// called before node.InstallHostRoutes().
+func InitPools(configs []PoolConfig) error {
+	nodeRanges := []*net.IPNet{node.GetIPv4AllocRange(), node.GetIPv6AllocRange()}
+	names := map[string]bool{}
+	pools := make([]*Pool, 0, len(configs))
+
+	for _, config := range configs {
+		if names[config.Name] {
+			return fmt.Errorf("duplicate IPAM pool %s", config.Name)
+		}
+		names[config.Name] = true
+
+		p, err := NewPool(config)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range p.ranges {
+			cidr := r.CIDR()
+			for _, nodeRange := range nodeRanges {
+				if nodeRange != nil && overlaps(&cidr, nodeRange) {
+					return fmt.Errorf("CIDR %s of IPAM pool %s overlaps with node allocation range %s",
+						cidr.String(), config.Name, nodeRange)
+				}
+			}
+			for _, other := range pools {
+				for _, otherRange := range other.ranges {
+					otherCIDR := otherRange.CIDR()
+					if overlaps(&cidr, &otherCIDR) {
+						return fmt.Errorf("CIDR %s of IPAM pool %s overlaps with IPAM pool %s",
+							cidr.String(), config.Name, other.Name())
+					}
+				}
+			}
+		}
+
+		pools = append(pools, p)
+	}
+
+	for _, p := range pools {
+		for _, r := range p.ranges {
+			cidr := r.CIDR()
+			node.AddAuxPrefix(&cidr)
+		}
+	}
+
+	ipamConf.allocatorMutex.Lock()
+	ipamConf.Pools = pools
+	ipamConf.allocatorMutex.Unlock()
+
+	for _, p := range pools {
+		log.WithFields(logrus.Fields{
+			"pool":  p.Name(),
+			"cidrs": p.config.CIDRs,
+		}).Info("Configured IPAM pool")
+	}
+
+	return nil
+}
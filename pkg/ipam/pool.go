@@ -0,0 +1,258 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+
+	"k8s.io/kubernetes/pkg/registry/core/service/ipallocator"
+)
+
+const (
+	// PoolAnnotation is the pod annotation selecting the pool to allocate
+	// the addresses of the pod from by name, taking precedence over the
+	// selection rules of the pools.
+	PoolAnnotation = "io.cilium/ipam-pool"
+)
+
+// PoolConfig is the configuration of a named pool of addresses.
+type PoolConfig struct {
+	// Name is the unique name of the pool
+	Name string `json:"name"`
+
+	// CIDRs are the IPv4 and IPv6 prefixes the addresses of the pool are
+	// allocated from
+	CIDRs []string `json:"cidrs"`
+
+	// Namespaces restricts the pool to owners in one of the namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Annotations restricts the pool to owners with all of the
+	// annotations
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Owner is the owner of the addresses requested from the IPAM, used to
+// select the pool to allocate the addresses from and to checkpoint the
+// allocation.
+type Owner struct {
+	ContainerID string
+	Namespace   string
+	PodName     string
+	Annotations map[string]string
+}
+
+// PoolStatus is the utilization of a pool.
+type PoolStatus struct {
+	Name     string
+	CIDRs    []string
+	Used     int
+	Capacity int
+}
+
+// Pool is a named pool of addresses allocated to the owners matching the
+// selection rules of the pool.
+type Pool struct {
+	config PoolConfig
+	ranges []*ipallocator.Range
+}
+
+// NewPool returns a pool for the configuration config.
+func NewPool(config PoolConfig) (*Pool, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("pool name must not be empty")
+	}
+	if len(config.CIDRs) == 0 {
+		return nil, fmt.Errorf("pool %s has no CIDRs", config.Name)
+	}
+
+	p := &Pool{config: config}
+	for _, cidr := range config.CIDRs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR of pool %s: %s", config.Name, err)
+		}
+		p.ranges = append(p.ranges, ipallocator.NewCIDRRange(ipnet))
+	}
+
+	return p, nil
+}
+
+// LoadPoolConfigs reads the configuration of the pools from the JSON file at
+// path.
+func LoadPoolConfigs(path string) ([]PoolConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []PoolConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("unable to parse IPAM pools %s: %s", path, err)
+	}
+
+	return configs, nil
+}
+
+// Name returns the name of the pool.
+func (p *Pool) Name() string {
+	return p.config.Name
+}
+
+// Matches returns true if the addresses of owner must be allocated from the
+// pool. A pool without selection rules is only used if requested by
+// PoolAnnotation.
+func (p *Pool) Matches(owner *Owner) bool {
+	if owner == nil {
+		return false
+	}
+
+	if name, ok := owner.Annotations[PoolAnnotation]; ok {
+		return name == p.config.Name
+	}
+
+	if len(p.config.Namespaces) == 0 && len(p.config.Annotations) == 0 {
+		return false
+	}
+
+	if len(p.config.Namespaces) > 0 {
+		found := false
+		for _, ns := range p.config.Namespaces {
+			if ns == owner.Namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for key, value := range p.config.Annotations {
+		if v, ok := owner.Annotations[key]; !ok || v != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// overlaps returns true if the prefixes a and b overlap.
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func isIPv6Range(r *ipallocator.Range) bool {
+	cidr := r.CIDR()
+	return cidr.IP.To4() == nil
+}
+
+// HasFamily returns true if the pool has a CIDR of the IPv6 or IPv4 family.
+func (p *Pool) HasFamily(ipv6 bool) bool {
+	for _, r := range p.ranges {
+		if isIPv6Range(r) == ipv6 {
+			return true
+		}
+	}
+	return false
+}
+
+// getRange returns the range of the pool containing ip or nil.
+func (p *Pool) getRange(ip net.IP) *ipallocator.Range {
+	for _, r := range p.ranges {
+		cidr := r.CIDR()
+		if cidr.Contains(ip) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Contains returns true if ip is part of one of the CIDRs of the pool.
+func (p *Pool) Contains(ip net.IP) bool {
+	return p.getRange(ip) != nil
+}
+
+// AllocateNext allocates the next available address of the IPv6 or IPv4
+// family from the CIDRs of the pool.
+func (p *Pool) AllocateNext(ipv6 bool) (net.IP, error) {
+	for _, r := range p.ranges {
+		if isIPv6Range(r) != ipv6 {
+			continue
+		}
+
+		ip, err := r.AllocateNext()
+		switch err {
+		case nil:
+			return ip, nil
+		case ipallocator.ErrFull:
+			continue
+		default:
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("no address left in pool %s", p.config.Name)
+}
+
+// Allocate allocates the address ip of the pool.
+func (p *Pool) Allocate(ip net.IP) error {
+	r := p.getRange(ip)
+	if r == nil {
+		return fmt.Errorf("%s is not part of pool %s", ip, p.config.Name)
+	}
+	return r.Allocate(ip)
+}
+
+// Release releases the address ip of the pool.
+func (p *Pool) Release(ip net.IP) error {
+	r := p.getRange(ip)
+	if r == nil {
+		return fmt.Errorf("%s is not part of pool %s", ip, p.config.Name)
+	}
+	return r.Release(ip)
+}
+
+// dump returns the allocated IPv4 and IPv6 addresses of the pool.
+func (p *Pool) dump() ([]string, []string) {
+	allocv4, allocv6 := []string{}, []string{}
+	for _, r := range p.ranges {
+		ipv6 := isIPv6Range(r)
+		r.ForEach(func(ip net.IP) {
+			if ipv6 {
+				allocv6 = append(allocv6, ip.String())
+			} else {
+				allocv4 = append(allocv4, ip.String())
+			}
+		})
+	}
+	return allocv4, allocv6
+}
+
+// Status returns the utilization of the pool.
+func (p *Pool) Status() PoolStatus {
+	status := PoolStatus{
+		Name:  p.config.Name,
+		CIDRs: p.config.CIDRs,
+	}
+	for _, r := range p.ranges {
+		status.Used += r.Used()
+		status.Capacity += r.Used() + r.Free()
+	}
+	return status
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	"net"
+
+	"github.com/cilium/cilium/pkg/node"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *IPAMSuite) TestPoolMatches(c *C) {
+	p, err := NewPool(PoolConfig{
+		Name:        "tenant",
+		CIDRs:       []string{"192.0.2.0/24"},
+		Namespaces:  []string{"a", "b"},
+		Annotations: map[string]string{"tenant": "foo"},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(p.Matches(nil), Equals, false)
+	c.Assert(p.Matches(&Owner{Namespace: "a"}), Equals, false)
+	c.Assert(p.Matches(&Owner{Namespace: "c", Annotations: map[string]string{"tenant": "foo"}}), Equals, false)
+	c.Assert(p.Matches(&Owner{Namespace: "b", Annotations: map[string]string{"tenant": "foo"}}), Equals, true)
+	c.Assert(p.Matches(&Owner{Annotations: map[string]string{PoolAnnotation: "tenant"}}), Equals, true)
+	c.Assert(p.Matches(&Owner{Namespace: "a", Annotations: map[string]string{
+		"tenant":       "foo",
+		PoolAnnotation: "other",
+	}}), Equals, false)
+
+	// Pools without selection rules must be requested explicitly
+	p, err = NewPool(PoolConfig{Name: "explicit", CIDRs: []string{"192.0.2.0/24"}})
+	c.Assert(err, IsNil)
+	c.Assert(p.Matches(&Owner{Namespace: "a"}), Equals, false)
+
+	_, err = NewPool(PoolConfig{Name: "invalid", CIDRs: []string{"192.0.2.0"}})
+	c.Assert(err, Not(IsNil))
+	_, err = NewPool(PoolConfig{Name: "empty"})
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *IPAMSuite) TestAllocateNextForOwner(c *C) {
+	node.InitDefaultPrefix("")
+	c.Assert(Init(), IsNil)
+	defer InitPools(nil)
+
+	err := InitPools([]PoolConfig{
+		{Name: "a", CIDRs: []string{"192.0.2.0/30"}, Namespaces: []string{"a"}},
+		{Name: "b", CIDRs: []string{"192.0.2.0/24"}, Namespaces: []string{"b"}},
+	})
+	c.Assert(err, Not(IsNil))
+
+	err = InitPools([]PoolConfig{
+		{Name: "a", CIDRs: []string{"192.0.2.0/30"}, Namespaces: []string{"a"}},
+		{Name: "a", CIDRs: []string{"198.51.100.0/24"}},
+	})
+	c.Assert(err, Not(IsNil))
+
+	err = InitPools([]PoolConfig{
+		{Name: "a", CIDRs: []string{"192.0.2.0/30"}, Namespaces: []string{"a"}},
+	})
+	c.Assert(err, IsNil)
+
+	_, poolCIDR, _ := net.ParseCIDR("192.0.2.0/30")
+	owner := &Owner{Namespace: "a", PodName: "pod"}
+	ipv4, ipv6, pool, err := AllocateNextForOwner("", owner)
+	c.Assert(err, IsNil)
+	c.Assert(pool, Equals, "a")
+	c.Assert(poolCIDR.Contains(ipv4), Equals, true)
+	first := ipv4
+	// The pool has no IPv6 CIDR, the node allocation range is used
+	c.Assert(node.GetIPv6AllocRange().Contains(ipv6), Equals, true)
+
+	ipv4, _, pool, err = AllocateNextForOwner("ipv4", &Owner{Namespace: "b"})
+	c.Assert(err, IsNil)
+	c.Assert(pool, Equals, "")
+	c.Assert(node.GetIPv4AllocRange().Contains(ipv4), Equals, true)
+	c.Assert(ReleaseIP(ipv4), IsNil)
+
+	_, _, _, err = AllocateNextForOwner("", &Owner{Annotations: map[string]string{PoolAnnotation: "unknown"}})
+	c.Assert(err, Not(IsNil))
+
+	status := DumpPools()
+	c.Assert(status, DeepEquals, []PoolStatus{{
+		Name:     "a",
+		CIDRs:    []string{"192.0.2.0/30"},
+		Used:     1,
+		Capacity: 2,
+	}})
+
+	allocv4, _ := Dump()
+	c.Assert(allocv4, Not(HasLen), 0)
+	c.Assert(allocv4[len(allocv4)-1], Equals, first.String())
+
+	ipv4, _, _, err = AllocateNextForOwner("ipv4", owner)
+	c.Assert(err, IsNil)
+	c.Assert(poolCIDR.Contains(ipv4), Equals, true)
+	c.Assert(ipv4.Equal(first), Equals, false)
+
+	// The IPv6 address is released if the pool is exhausted
+	_, allocv6 := Dump()
+	_, _, _, err = AllocateNextForOwner("", owner)
+	c.Assert(err, Not(IsNil))
+	_, allocv6After := Dump()
+	c.Assert(allocv6After, DeepEquals, allocv6)
+
+	c.Assert(ReleaseIP(first), IsNil)
+	c.Assert(ReleaseIP(ipv6), IsNil)
+	c.Assert(AllocateIP(first), IsNil)
+	c.Assert(DumpPools()[0].Used, Equals, 2)
+}
@@ -27,6 +27,14 @@ type Config struct {
 	IPv6Allocator *ipallocator.Range
 	IPv4Allocator *ipallocator.Range
 
+	// Pools are the named pools of addresses, the addresses of owners not
+	// matching any pool are allocated from the IPv6 and IPv4 allocators
+	Pools []*Pool
+
+	// checkpoint records the allocations of owners to persist them across
+	// restarts, nil unless enabled by RestoreCheckpoint
+	checkpoint *checkpoint
+
 	// mutex covers access to all members of this struct
 	allocatorMutex lock.RWMutex
 }
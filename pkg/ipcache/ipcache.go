@@ -463,153 +463,3 @@ func findShadowedCIDR(pair *identity.IPIdentityPair) (identity.NumericIdentity,
 	cidrStr := fmt.Sprintf("%s/%d", pair.PrefixString(), bits)
 	return IPIdentityCache.LookupByIP(cidrStr)
 }
-
-func ipIdentityWatcher(listeners []IPIdentityMappingListener) {
-	log.Info("Starting IP identity watcher")
-
-	for {
-
-		watcher := kvstore.ListAndWatch("endpointIPWatcher", IPIdentitiesPath, 512)
-
-		// Get events from channel as they come in.
-		for event := range watcher.Events {
-
-			scopedLog := log.WithFields(logrus.Fields{"kvstore-event": event.Typ.String(), "key": event.Key})
-			scopedLog.Debug("received event")
-
-			var (
-				cacheChanged      bool
-				cacheModification CacheModification
-				ipIDPair          identity.IPIdentityPair
-				cachedIdentity    identity.NumericIdentity
-				ipIsInCache       bool
-			)
-
-			// Synchronize local caching of endpoint IP to ipIDPair mapping with
-			// operation key-value store has informed us about.
-			//
-			// To resolve conflicts between hosts and full CIDR prefixes:
-			// - Insert hosts into the cache as ".../w.x.y.z"
-			// - Insert CIDRS into the cache as ".../w.x.y.z/N"
-			// - If a host entry created, notify the listeners.
-			// - If a CIDR is created and there's no overlapping host
-			//   entry, ie it is a less than fully masked CIDR, OR
-			//   it is a fully masked CIDR and there is no corresponding
-			//   host entry, then:
-			//   - Notify the listeners.
-			//   - Otherwise, do not notify listeners.
-			// - If a host is removed, check for an overlapping CIDR
-			//   and if it exists, notify the listeners with an upsert
-			//   for the CIDR's identity
-			// - If any other deletion case, notify listeners of
-			//   the deletion event.
-			switch event.Typ {
-			case kvstore.EventTypeListDone:
-				for _, listener := range listeners {
-					listener.OnIPIdentityCacheGC()
-				}
-			case kvstore.EventTypeCreate, kvstore.EventTypeModify:
-				err := json.Unmarshal(event.Value, &ipIDPair)
-				if err != nil {
-					scopedLog.WithError(err).Errorf("not adding entry to ip cache; error unmarshaling data from key-value store")
-					continue
-				}
-
-				ipStr := ipIDPair.PrefixString()
-				cachedIdentity, ipIsInCache = IPIdentityCache.LookupByIP(ipStr)
-
-				// Host IP identities take precedence over CIDR
-				// identities, so if this event is for a full
-				// CIDR prefix and there's an existing entry
-				// with a different ID, then break out.
-				if !ipIDPair.IsHost() {
-					ones, bits := ipIDPair.Mask.Size()
-					if ipIsInCache && ones == bits {
-						if cachedIdentity != ipIDPair.ID {
-							IPIdentityCache.Upsert(ipStr, ipIDPair.ID)
-							scopedLog.WithField(logfields.IPAddr, ipIDPair.IP).
-								Infof("Received KVstore update for CIDR overlapping with endpoint IP.")
-						}
-						continue
-					}
-				}
-
-				// Insert or update the IP -> ID mapping.
-				if !ipIsInCache || cachedIdentity != ipIDPair.ID {
-					IPIdentityCache.Upsert(ipStr, ipIDPair.ID)
-					cacheChanged = true
-					cacheModification = Upsert
-				}
-			case kvstore.EventTypeDelete:
-				// Value is not present in deletion event;
-				// need to convert kvstore key to IP.
-				ipnet, isHost, err := keyToIPNet(event.Key)
-				if err != nil {
-					scopedLog.Error("error parsing IP from key: %s", err)
-					continue
-				}
-
-				ipIDPair.IP = ipnet.IP
-				if isHost {
-					ipIDPair.Mask = nil
-				} else {
-					ipIDPair.Mask = ipnet.Mask
-				}
-				ipStr := ipIDPair.PrefixString()
-				cachedIdentity, ipIsInCache = IPIdentityCache.LookupByIP(ipStr)
-
-				if ipIsInCache {
-					cacheChanged = true
-					IPIdentityCache.delete(ipStr)
-
-					// Set up the IPIDPair and cacheModification for listener callbacks
-					prefixIdentity, shadowedCIDR := findShadowedCIDR(&ipIDPair)
-					if shadowedCIDR {
-						scopedLog.WithField(logfields.IPAddr, ipIDPair.IP).
-							Infof("Received KVstore deletion for endpoint IP shadowing CIDR, restoring CIDR.")
-						ipIDPair.ID = prefixIdentity
-						cacheModification = Upsert
-					} else {
-						ipIDPair.ID = cachedIdentity
-						cacheModification = Delete
-					}
-				}
-			}
-
-			if cacheChanged {
-				log.WithFields(logrus.Fields{
-					logfields.IPAddr:       ipIDPair.IP,
-					logfields.IPMask:       ipIDPair.Mask,
-					"cached-identity":      cachedIdentity,
-					logfields.Identity:     ipIDPair.ID,
-					logfields.Modification: cacheModification,
-				}).Debugf("endpoint IP cache state change")
-
-				var oldIPIDPair *identity.IPIdentityPair
-				if ipIsInCache && cacheModification == Upsert {
-					// If an existing mapping is updated,
-					// provide the existing mapping to the
-					// listener so it can easily clean up
-					// the old mapping.
-					pair := ipIDPair
-					pair.ID = cachedIdentity
-					oldIPIDPair = &pair
-				}
-				// Callback upon cache updates.
-				for _, listener := range listeners {
-					listener.OnIPIdentityCacheChange(cacheModification, oldIPIDPair, ipIDPair)
-				}
-			}
-		}
-
-		log.Debugf("%s closed, restarting watch", watcher.String())
-	}
-}
-
-// InitIPIdentityWatcher initializes the watcher for ip-identity mapping events
-// in the key-value store.
-func InitIPIdentityWatcher(listeners []IPIdentityMappingListener) {
-	setupIPIdentityWatcher.Do(func() {
-		go ipIdentityWatcher(listeners)
-	})
-}
@@ -0,0 +1,297 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipcache
+
+import (
+	"encoding/json"
+
+	"github.com/cilium/cilium/pkg/identity"
+	"github.com/cilium/cilium/pkg/kvstore"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// listeners is the list of listeners notified by all IP identity
+	// watchers about changes to the IP cache
+	listeners      []IPIdentityMappingListener
+	listenersMutex lock.RWMutex
+)
+
+// IPIdentityWatcher is a watcher that will notify when IP<->identity mappings
+// change in the kvstore
+type IPIdentityWatcher struct {
+	backend kvstore.BackendOperations
+	stop    chan struct{}
+
+	// notifyGC is true if the listeners are notified to garbage collect
+	// once all existing keys have been listed. Only the watcher of the
+	// local kvstore does so as the IP cache is not complete before.
+	notifyGC bool
+
+	// keys maps the kvstore keys which have been inserted into the IP
+	// cache by this watcher to the identity inserted
+	keys map[string]identity.NumericIdentity
+
+	// staleKeys is the set of keys inserted before the watch was
+	// restarted which have not been listed again yet
+	staleKeys map[string]struct{}
+}
+
+// NewIPIdentityWatcher creates a new IPIdentityWatcher using the specified
+// kvstore backend
+func NewIPIdentityWatcher(backend kvstore.BackendOperations) *IPIdentityWatcher {
+	return &IPIdentityWatcher{
+		backend: backend,
+		stop:    make(chan struct{}),
+		keys:    map[string]identity.NumericIdentity{},
+	}
+}
+
+func (iw *IPIdentityWatcher) getListeners() []IPIdentityMappingListener {
+	listenersMutex.RLock()
+	defer listenersMutex.RUnlock()
+	return listeners
+}
+
+// Watch starts the watcher and blocks waiting for events. When events are
+// received from the kvstore, all IPIdentityMappingListener are notified. The
+// function returns when Close() is called.
+func (iw *IPIdentityWatcher) Watch() {
+	log.Info("Starting IP identity watcher")
+
+	for {
+		iw.startSync()
+		watcher := iw.backend.ListAndWatch("endpointIPWatcher", IPIdentitiesPath, 512)
+
+	eventLoop:
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					break eventLoop
+				}
+				iw.handleEvent(event)
+
+			case <-iw.stop:
+				watcher.Stop()
+
+				// Remove all entries inserted by this
+				// watcher from the IP cache
+				for key := range iw.keys {
+					iw.handleEvent(kvstore.KeyValueEvent{
+						Typ: kvstore.EventTypeDelete,
+						Key: key,
+					})
+				}
+				return
+			}
+		}
+
+		log.Debugf("%s closed, restarting watch", watcher.String())
+	}
+}
+
+// startSync marks all keys inserted by the watcher as stale until they are
+// listed again, the keys which are not listed are removed from the IP cache
+// once the listing is done.
+func (iw *IPIdentityWatcher) startSync() {
+	iw.staleKeys = make(map[string]struct{}, len(iw.keys))
+	for key := range iw.keys {
+		iw.staleKeys[key] = struct{}{}
+	}
+}
+
+// Close stops the IPIdentityWatcher and removes all IP identity mappings
+// learned by the watcher from the IP cache
+func (iw *IPIdentityWatcher) Close() {
+	close(iw.stop)
+}
+
+func (iw *IPIdentityWatcher) handleEvent(event kvstore.KeyValueEvent) {
+	scopedLog := log.WithFields(logrus.Fields{"kvstore-event": event.Typ.String(), "key": event.Key})
+	scopedLog.Debug("received event")
+
+	var (
+		cacheChanged      bool
+		cacheModification CacheModification
+		ipIDPair          identity.IPIdentityPair
+		cachedIdentity    identity.NumericIdentity
+		ipIsInCache       bool
+	)
+
+	// Synchronize local caching of endpoint IP to ipIDPair mapping with
+	// operation key-value store has informed us about.
+	//
+	// To resolve conflicts between hosts and full CIDR prefixes:
+	// - Insert hosts into the cache as ".../w.x.y.z"
+	// - Insert CIDRS into the cache as ".../w.x.y.z/N"
+	// - If a host entry created, notify the listeners.
+	// - If a CIDR is created and there's no overlapping host
+	//   entry, ie it is a less than fully masked CIDR, OR
+	//   it is a fully masked CIDR and there is no corresponding
+	//   host entry, then:
+	//   - Notify the listeners.
+	//   - Otherwise, do not notify listeners.
+	// - If a host is removed, check for an overlapping CIDR
+	//   and if it exists, notify the listeners with an upsert
+	//   for the CIDR's identity
+	// - If any other deletion case, notify listeners of
+	//   the deletion event.
+	switch event.Typ {
+	case kvstore.EventTypeListDone:
+		// Remove the entries of keys deleted while the watch was
+		// restarted
+		staleKeys := iw.staleKeys
+		iw.staleKeys = nil
+		for key := range staleKeys {
+			iw.handleEvent(kvstore.KeyValueEvent{
+				Typ: kvstore.EventTypeDelete,
+				Key: key,
+			})
+		}
+
+		if iw.notifyGC {
+			for _, listener := range iw.getListeners() {
+				listener.OnIPIdentityCacheGC()
+			}
+		}
+	case kvstore.EventTypeCreate, kvstore.EventTypeModify:
+		err := json.Unmarshal(event.Value, &ipIDPair)
+		if err != nil {
+			scopedLog.WithError(err).Errorf("not adding entry to ip cache; error unmarshaling data from key-value store")
+			return
+		}
+
+		iw.keys[event.Key] = ipIDPair.ID
+		delete(iw.staleKeys, event.Key)
+
+		ipStr := ipIDPair.PrefixString()
+		cachedIdentity, ipIsInCache = IPIdentityCache.LookupByIP(ipStr)
+
+		// Host IP identities take precedence over CIDR
+		// identities, so if this event is for a full
+		// CIDR prefix and there's an existing entry
+		// with a different ID, then break out.
+		if !ipIDPair.IsHost() {
+			ones, bits := ipIDPair.Mask.Size()
+			if ipIsInCache && ones == bits {
+				if cachedIdentity != ipIDPair.ID {
+					IPIdentityCache.Upsert(ipStr, ipIDPair.ID)
+					scopedLog.WithField(logfields.IPAddr, ipIDPair.IP).
+						Infof("Received KVstore update for CIDR overlapping with endpoint IP.")
+				}
+				return
+			}
+		}
+
+		// Insert or update the IP -> ID mapping.
+		if !ipIsInCache || cachedIdentity != ipIDPair.ID {
+			IPIdentityCache.Upsert(ipStr, ipIDPair.ID)
+			cacheChanged = true
+			cacheModification = Upsert
+		}
+	case kvstore.EventTypeDelete:
+		// Only remove entries inserted by this watcher, the same IP
+		// may be provided by other clusters or sources
+		insertedIdentity, ok := iw.keys[event.Key]
+		if !ok {
+			scopedLog.Debug("Ignoring deletion of key not inserted by this watcher")
+			return
+		}
+		delete(iw.keys, event.Key)
+
+		// Value is not present in deletion event;
+		// need to convert kvstore key to IP.
+		ipnet, isHost, err := keyToIPNet(event.Key)
+		if err != nil {
+			scopedLog.WithError(err).Error("error parsing IP from key")
+			return
+		}
+
+		ipIDPair.IP = ipnet.IP
+		if isHost {
+			ipIDPair.Mask = nil
+		} else {
+			ipIDPair.Mask = ipnet.Mask
+		}
+		ipStr := ipIDPair.PrefixString()
+		cachedIdentity, ipIsInCache = IPIdentityCache.LookupByIP(ipStr)
+
+		if ipIsInCache && cachedIdentity != insertedIdentity {
+			scopedLog.WithField(logfields.Identity, cachedIdentity).
+				Debug("Not removing IP cache entry overwritten by another source")
+			return
+		}
+
+		if ipIsInCache {
+			cacheChanged = true
+			IPIdentityCache.delete(ipStr)
+
+			// Set up the IPIDPair and cacheModification for listener callbacks
+			prefixIdentity, shadowedCIDR := findShadowedCIDR(&ipIDPair)
+			if shadowedCIDR {
+				scopedLog.WithField(logfields.IPAddr, ipIDPair.IP).
+					Infof("Received KVstore deletion for endpoint IP shadowing CIDR, restoring CIDR.")
+				ipIDPair.ID = prefixIdentity
+				cacheModification = Upsert
+			} else {
+				ipIDPair.ID = cachedIdentity
+				cacheModification = Delete
+			}
+		}
+	}
+
+	if cacheChanged {
+		log.WithFields(logrus.Fields{
+			logfields.IPAddr:       ipIDPair.IP,
+			logfields.IPMask:       ipIDPair.Mask,
+			"cached-identity":      cachedIdentity,
+			logfields.Identity:     ipIDPair.ID,
+			logfields.Modification: cacheModification,
+		}).Debugf("endpoint IP cache state change")
+
+		var oldIPIDPair *identity.IPIdentityPair
+		if ipIsInCache && cacheModification == Upsert {
+			// If an existing mapping is updated,
+			// provide the existing mapping to the
+			// listener so it can easily clean up
+			// the old mapping.
+			pair := ipIDPair
+			pair.ID = cachedIdentity
+			oldIPIDPair = &pair
+		}
+		// Callback upon cache updates.
+		for _, listener := range iw.getListeners() {
+			listener.OnIPIdentityCacheChange(cacheModification, oldIPIDPair, ipIDPair)
+		}
+	}
+}
+
+// InitIPIdentityWatcher initializes the watcher for ip-identity mapping events
+// in the key-value store.
+func InitIPIdentityWatcher(l []IPIdentityMappingListener) {
+	setupIPIdentityWatcher.Do(func() {
+		listenersMutex.Lock()
+		listeners = l
+		listenersMutex.Unlock()
+
+		iw := NewIPIdentityWatcher(kvstore.Client())
+		iw.notifyGC = true
+		go iw.Watch()
+	})
+}
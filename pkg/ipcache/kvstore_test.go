@@ -0,0 +1,160 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipcache
+
+import (
+	"encoding/json"
+	"net"
+	"path"
+	"time"
+
+	"github.com/cilium/cilium/pkg/identity"
+	"github.com/cilium/cilium/pkg/kvstore"
+
+	. "gopkg.in/check.v1"
+)
+
+type IPIdentityWatcherSuite struct{}
+
+var _ = Suite(&IPIdentityWatcherSuite{})
+
+func (s *IPIdentityWatcherSuite) SetUpTest(c *C) {
+	kvstore.SetupDummy(kvstore.MemoryBackendName)
+}
+
+func (s *IPIdentityWatcherSuite) TearDownTest(c *C) {
+	kvstore.Close()
+}
+
+func waitForIdentity(c *C, ip string, expected identity.NumericIdentity, exists bool) {
+	for start := time.Now(); time.Since(start) < 5*time.Second; {
+		id, ok := IPIdentityCache.LookupByIP(ip)
+		if ok == exists && (!exists || id == expected) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.Fatalf("timeout while waiting for IP %s (identity %d, exists %t)", ip, expected, exists)
+}
+
+func (s *IPIdentityWatcherSuite) TestWatch(c *C) {
+	ip := net.ParseIP("10.20.30.40")
+
+	c.Assert(UpsertIPToKVStore(ip, identity.NumericIdentity(100), ""), IsNil)
+
+	iw := NewIPIdentityWatcher(kvstore.Client())
+	go iw.Watch()
+
+	// existing keys are listed
+	waitForIdentity(c, ip.String(), 100, true)
+
+	// modifications are propagated
+	c.Assert(UpsertIPToKVStore(ip, identity.NumericIdentity(200), ""), IsNil)
+	waitForIdentity(c, ip.String(), 200, true)
+
+	// deletions are propagated
+	c.Assert(DeleteIPFromKVStore(ip.String()), IsNil)
+	waitForIdentity(c, ip.String(), 0, false)
+
+	// closing the watcher removes all entries learned by it
+	c.Assert(UpsertIPToKVStore(ip, identity.NumericIdentity(300), ""), IsNil)
+	waitForIdentity(c, ip.String(), 300, true)
+	iw.Close()
+	waitForIdentity(c, ip.String(), 0, false)
+}
+
+func (s *IPIdentityWatcherSuite) TestDeleteNotInserted(c *C) {
+	ip := "10.20.30.41"
+	key := path.Join(IPIdentitiesPath, AddressSpace, ip)
+
+	// The entry is provided by another source
+	IPIdentityCache.Upsert(ip, identity.NumericIdentity(100))
+	defer IPIdentityCache.delete(ip)
+
+	iw := NewIPIdentityWatcher(kvstore.Client())
+	iw.handleEvent(kvstore.KeyValueEvent{Typ: kvstore.EventTypeDelete, Key: key})
+	waitForIdentity(c, ip, 100, true)
+
+	// The entry inserted by the watcher is overwritten by another source
+	value, err := json.Marshal(identity.IPIdentityPair{IP: net.ParseIP(ip), ID: 200})
+	c.Assert(err, IsNil)
+	iw.handleEvent(kvstore.KeyValueEvent{Typ: kvstore.EventTypeCreate, Key: key, Value: value})
+	waitForIdentity(c, ip, 200, true)
+	IPIdentityCache.Upsert(ip, identity.NumericIdentity(300))
+
+	iw.handleEvent(kvstore.KeyValueEvent{Typ: kvstore.EventTypeDelete, Key: key})
+	waitForIdentity(c, ip, 300, true)
+}
+
+type gcListener struct {
+	gcCount int
+}
+
+func (l *gcListener) OnIPIdentityCacheChange(modType CacheModification, oldIPIDPair *identity.IPIdentityPair, newIPIDPair identity.IPIdentityPair) {
+}
+
+func (l *gcListener) OnIPIdentityCacheGC() {
+	l.gcCount++
+}
+
+func (s *IPIdentityWatcherSuite) TestStaleKeys(c *C) {
+	listener := &gcListener{}
+	listenersMutex.Lock()
+	oldListeners := listeners
+	listeners = []IPIdentityMappingListener{listener}
+	listenersMutex.Unlock()
+	defer func() {
+		listenersMutex.Lock()
+		listeners = oldListeners
+		listenersMutex.Unlock()
+	}()
+
+	ips := []string{"10.20.30.42", "10.20.30.43"}
+	keys := []string{}
+	values := [][]byte{}
+	for _, ip := range ips {
+		value, err := json.Marshal(identity.IPIdentityPair{IP: net.ParseIP(ip), ID: 100})
+		c.Assert(err, IsNil)
+		keys = append(keys, path.Join(IPIdentitiesPath, AddressSpace, ip))
+		values = append(values, value)
+	}
+
+	iw := NewIPIdentityWatcher(kvstore.Client())
+	iw.startSync()
+	for i := range keys {
+		iw.handleEvent(kvstore.KeyValueEvent{Typ: kvstore.EventTypeCreate, Key: keys[i], Value: values[i]})
+	}
+	iw.handleEvent(kvstore.KeyValueEvent{Typ: kvstore.EventTypeListDone})
+	waitForIdentity(c, ips[0], 100, true)
+	waitForIdentity(c, ips[1], 100, true)
+
+	// Keys not listed again after a restart of the watch are removed
+	iw.startSync()
+	iw.handleEvent(kvstore.KeyValueEvent{Typ: kvstore.EventTypeCreate, Key: keys[0], Value: values[0]})
+	iw.handleEvent(kvstore.KeyValueEvent{Typ: kvstore.EventTypeListDone})
+	waitForIdentity(c, ips[0], 100, true)
+	waitForIdentity(c, ips[1], 0, false)
+
+	// Watchers of remote clusters do not garbage collect state of other
+	// sources
+	c.Assert(listener.gcCount, Equals, 0)
+
+	iw.notifyGC = true
+	iw.startSync()
+	iw.handleEvent(kvstore.KeyValueEvent{Typ: kvstore.EventTypeListDone})
+	c.Assert(listener.gcCount, Equals, 1)
+	waitForIdentity(c, ips[0], 0, false)
+}
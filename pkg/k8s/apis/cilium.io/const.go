@@ -24,6 +24,9 @@ const (
 	PolicyLabelName = "io.cilium.k8s.policy.name"
 	// PolicyLabelNamespace is the policy's namespace set in k8s.
 	PolicyLabelNamespace = "io.cilium.k8s.policy.namespace"
+	// PolicyLabelCluster is the label attached to all endpoints which
+	// specifies the name of the cluster the endpoint is part of.
+	PolicyLabelCluster = "io.cilium.k8s.policy.cluster"
 	// PodNamespaceMetaLabels is the label used to store the labels of the
 	// kubernetes namespace's labels.
 	PodNamespaceMetaLabels = "io.cilium.k8s.namespace.labels"
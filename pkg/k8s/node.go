@@ -20,6 +20,7 @@ import (
 	"github.com/cilium/cilium/pkg/annotation"
 	"github.com/cilium/cilium/pkg/logging/logfields"
 	"github.com/cilium/cilium/pkg/node"
+	"github.com/cilium/cilium/pkg/option"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/api/core/v1"
@@ -59,7 +60,9 @@ func ParseNode(k8sNode *v1.Node) *node.Node {
 
 	node := &node.Node{
 		Name:        k8sNode.Name,
+		Cluster:     option.Config.ClusterName,
 		IPAddresses: addrs,
+		Source:      node.FromKubernetes,
 	}
 
 	if len(k8sNode.Spec.PodCIDR) != 0 {
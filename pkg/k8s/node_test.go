@@ -16,6 +16,8 @@ package k8s
 
 import (
 	"github.com/cilium/cilium/pkg/annotation"
+	"github.com/cilium/cilium/pkg/node"
+
 	. "gopkg.in/check.v1"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -42,6 +44,7 @@ func (s *K8sSuite) TestParseNode(c *C) {
 	c.Assert(n.IPv4AllocCIDR.String(), Equals, "10.1.0.0/16")
 	c.Assert(n.IPv6AllocCIDR, NotNil)
 	c.Assert(n.IPv6AllocCIDR.String(), Equals, "f00d:aaaa:bbbb:cccc:dddd:eeee::/112")
+	c.Assert(n.Source, Equals, node.FromKubernetes)
 
 	// No IPv6 annotation
 	k8sNode = &v1.Node{
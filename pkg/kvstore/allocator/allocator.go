@@ -21,10 +21,10 @@ import (
 	"path"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/cilium/cilium/pkg/backoff"
+	"github.com/cilium/cilium/pkg/controller"
 	"github.com/cilium/cilium/pkg/kvstore"
 	"github.com/cilium/cilium/pkg/lock"
 	"github.com/cilium/cilium/pkg/logging/logfields"
@@ -141,21 +141,16 @@ type Allocator struct {
 	// keyType is an instance of the type to be used as allocator key.
 	keyType AllocatorKey
 
-	// mute protects the id to key mapping cache
-	mutex lock.RWMutex
+	// mainCache is the main cache, representing the allocator contents of
+	// the primary kvstore connection
+	mainCache cache
 
-	// cache is a local cache of all IDs allocated in the kvstore. It is
-	// being maintained by watching for kvstore events and can thus lag
-	// behind.
-	cache IDMap
+	// remoteCachesMutex protects accesse to remoteCaches
+	remoteCachesMutex lock.RWMutex
 
-	// nextCache is the cache is constantly being filled by startWatch(),
-	// when startWatch has successfully performed the initial fill using
-	// ListPrefix, the cache above will be pointed to nextCache. If the
-	// startWatch() fails to perform the initial list, then the cache is
-	// never pointed to nextCache. This guarantees that a valid cache is
-	// kept at all times.
-	nextCache IDMap
+	// remoteCaches is the list of additional remote caches being watched
+	// in addition to the main cache
+	remoteCaches map[*RemoteCache]struct{}
 
 	// basePrefix is the prefix in the kvstore that all keys share which
 	// are being managed by this allocator. The basePrefix typically
@@ -198,12 +193,8 @@ type Allocator struct {
 	// backoffTemplate is the backoff configuration while allocating
 	backoffTemplate backoff.Exponential
 
-	// idWatcherStop is the channel used to stop the kvstore watcher
-	idWatcherStop chan struct{}
-	idWatcherWg   sync.WaitGroup
-
-	// stopGC is the channel used to stop the garbage collector
-	stopGC chan struct{}
+	// controllers is the manager of the garbage collector controller
+	controllers *controller.Manager
 }
 
 func locklessCapability() bool {
@@ -234,25 +225,27 @@ func NewAllocator(basePath string, typ AllocatorKey, opts ...AllocatorOption) (*
 	}
 
 	a := &Allocator{
-		keyType:     typ,
-		basePrefix:  basePath,
-		idPrefix:    path.Join(basePath, "id"),
-		valuePrefix: path.Join(basePath, "value"),
-		lockPrefix:  path.Join(basePath, "locks"),
-		min:         1,
-		max:         ID(^uint64(0)),
-		localKeys:   newLocalKeys(),
-		stopGC:      make(chan struct{}, 0),
-		suffix:      uuid.NewUUID().String()[:10],
-		cache:       IDMap{},
-		lockless:    locklessCapability(),
-		Events:      make(AllocatorEventChan, 1024),
+		keyType:      typ,
+		basePrefix:   basePath,
+		idPrefix:     path.Join(basePath, "id"),
+		valuePrefix:  path.Join(basePath, "value"),
+		lockPrefix:   path.Join(basePath, "locks"),
+		min:          1,
+		max:          ID(^uint64(0)),
+		localKeys:    newLocalKeys(),
+		controllers:  controller.NewManager(),
+		suffix:       uuid.NewUUID().String()[:10],
+		remoteCaches: map[*RemoteCache]struct{}{},
+		lockless:     locklessCapability(),
+		Events:       make(AllocatorEventChan, 1024),
 		backoffTemplate: backoff.Exponential{
 			Min:    time.Duration(20) * time.Millisecond,
 			Factor: 2.0,
 		},
 	}
 
+	a.mainCache = newCache(a, kvstore.Client(), true)
+
 	for _, fn := range opts {
 		fn(a)
 	}
@@ -269,7 +262,7 @@ func NewAllocator(basePath string, typ AllocatorKey, opts ...AllocatorOption) (*
 		return nil, errors.New("Maximum ID must be greater than minimum ID")
 	}
 
-	if err := a.startWatchAndWait(); err != nil {
+	if err := a.mainCache.startAndWait(); err != nil {
 		return nil, err
 	}
 
@@ -295,8 +288,16 @@ func WithMax(id ID) AllocatorOption {
 
 // Delete deletes an allocator and stops the garbage collector
 func (a *Allocator) Delete() {
-	close(a.stopGC)
-	a.stopWatch()
+	a.controllers.RemoveAll()
+	a.mainCache.stop()
+
+	a.remoteCachesMutex.Lock()
+	for rc := range a.remoteCaches {
+		rc.cache.stop()
+	}
+	a.remoteCaches = map[*RemoteCache]struct{}{}
+	a.remoteCachesMutex.Unlock()
+
 	close(a.Events)
 }
 
@@ -315,13 +316,15 @@ func (a *Allocator) DeleteAllKeys() {
 type RangeFunc func(ID, AllocatorKey)
 
 // ForeachCache iterates over the allocator cache and calls RangeFunc on each
-// cached entry
+// cached entry. This includes the entries of all remote caches.
 func (a *Allocator) ForeachCache(cb RangeFunc) {
-	a.mutex.RLock()
-	for k, v := range a.cache {
-		cb(k, v)
+	a.mainCache.foreach(cb)
+
+	a.remoteCachesMutex.RLock()
+	for rc := range a.remoteCaches {
+		rc.cache.foreach(cb)
 	}
-	a.mutex.RUnlock()
+	a.remoteCachesMutex.RUnlock()
 }
 
 func invalidKey(key, prefix string, deleteInvalid bool) {
@@ -362,15 +365,12 @@ func (a *Allocator) selectAvailableID() (ID, string) {
 	idRandomizerMutex.Lock()
 	defer idRandomizerMutex.Unlock()
 
-	a.mutex.RLock()
-	defer a.mutex.RUnlock()
-
 	tried := 0
 
 	for _, r := range idRandomizer.Perm(int(a.max - a.min + 1)) {
 		id := ID(r) + a.min
 		tried++
-		if _, ok := a.cache[id]; !ok && a.localKeys.lookupID(id) == "" {
+		if !a.mainCache.exists(id) && a.localKeys.lookupID(id) == "" {
 			return id, id.String()
 		}
 	}
@@ -378,14 +378,32 @@ func (a *Allocator) selectAvailableID() (ID, string) {
 	return 0, ""
 }
 
+// valueNodeKey returns the node specific slave key /value/<key>/<node>
+func (a *Allocator) valueNodeKey(key string) string {
+	return path.Join(a.valuePrefix, key, a.suffix)
+}
+
+// createValueNodeKey adds a new key /value/<key>/<node> to account for the
+// reference. The key is protected with a TTL/lease and will expire after
+// LeaseTTL. The key is only created if the master key of newID still refers
+// to key.
 func (a *Allocator) createValueNodeKey(key string, newID ID) error {
-	// add a new key /value/<key>/<node> to account for the reference
-	// The key is protected with a TTL/lease and will expire after LeaseTTL
-	valueKey := path.Join(a.valuePrefix, key, a.suffix)
-	if err := kvstore.Update(valueKey, []byte(newID.String()), true); err != nil {
+	valueKey := a.valueNodeKey(key)
+	keyPath := path.Join(a.idPrefix, newID.String())
+
+	txn := kvstore.NewTxn().
+		IfValue(keyPath, []byte(key)).
+		Put(valueKey, []byte(newID.String()), true)
+
+	succeeded, err := kvstore.Commit(txn)
+	if err != nil {
 		return fmt.Errorf("unable to create value-node key '%s': %s", valueKey, err)
 	}
 
+	if !succeeded {
+		return fmt.Errorf("master key '%s' no longer refers to '%s'", keyPath, key)
+	}
+
 	return nil
 }
 
@@ -473,28 +491,29 @@ func (a *Allocator) lockedAllocate(key AllocatorKey) (ID, bool, error) {
 		return 0, false, fmt.Errorf("master key already exists")
 	}
 
-	// create /id/<ID> and fail if it already exists
+	// create /id/<ID> and /value/<key>/<node> in a single transaction
+	// and fail if /id/<ID> already exists. Either both keys are created
+	// or none of them.
 	keyPath := path.Join(a.idPrefix, strID)
-	err = kvstore.CreateOnly(keyPath, []byte(k), false)
-	if err != nil {
-		// Creation failed. Another agent most likely beat us to allocting this
-		// ID, retry.
+	txn := kvstore.NewTxn().
+		IfMissing(keyPath).
+		Put(keyPath, []byte(k), false).
+		Put(a.valueNodeKey(k), []byte(strID), true)
+
+	succeeded, err := kvstore.Commit(txn)
+	lock.Unlock()
+
+	switch {
+	case err != nil:
 		a.localKeys.release(k)
-		lock.Unlock()
 		return 0, false, fmt.Errorf("unable to create master key '%s': %s", keyPath, err)
-	}
-
-	if err = a.createValueNodeKey(k, id); err != nil {
-		// We will leak the master key here as the key has already been
-		// exposed and may be in use by other nodes. The garbage
-		// collector will release it again.
+	case !succeeded:
+		// Another agent most likely beat us to allocating this
+		// ID, retry.
 		a.localKeys.release(k)
-		lock.Unlock()
-		return 0, false, fmt.Errorf("slave key creation failed '%s': %s", k, err)
+		return 0, false, fmt.Errorf("master key '%s' already exists", keyPath)
 	}
 
-	lock.Unlock()
-
 	return id, true, nil
 }
 
@@ -520,9 +539,7 @@ func (a *Allocator) Allocate(key AllocatorKey) (ID, bool, error) {
 	// operation was performed for this allocation
 	if val := a.localKeys.use(k); val != NoID {
 		kvstore.Trace("Reusing local id", nil, logrus.Fields{fieldID: val, fieldKey: key})
-		a.mutex.Lock()
-		a.nextCache[val] = key
-		a.mutex.Unlock()
+		a.mainCache.insert(key, val)
 		return val, false, nil
 	}
 
@@ -536,9 +553,7 @@ func (a *Allocator) Allocate(key AllocatorKey) (ID, bool, error) {
 		// FIXME: Add non-locking variant
 		value, isNew, err = a.lockedAllocate(key)
 		if err == nil {
-			a.mutex.Lock()
-			a.nextCache[value] = key
-			a.mutex.Unlock()
+			a.mainCache.insert(key, value)
 			return value, isNew, nil
 		}
 
@@ -567,14 +582,9 @@ func (a *Allocator) Allocate(key AllocatorKey) (ID, bool, error) {
 // Get returns the ID which is allocated to a key. Returns an ID of NoID if no ID
 // has been allocated to this key yet.
 func (a *Allocator) Get(key AllocatorKey) (ID, error) {
-	a.mutex.RLock()
-	for k, v := range a.cache {
-		if v.GetKey() == key.GetKey() {
-			a.mutex.RUnlock()
-			return k, nil
-		}
+	if id := a.mainCache.get(key.GetKey()); id != NoID {
+		return id, nil
 	}
-	a.mutex.RUnlock()
 
 	return a.GetNoCache(key)
 }
@@ -597,14 +607,21 @@ func (a *Allocator) GetNoCache(key AllocatorKey) (ID, error) {
 }
 
 // GetByID returns the key associated with an ID. Returns nil if no key is
-// associated with the ID.
+// associated with the ID. The caches of all remote kvstores are consulted as
+// well before falling back to a lookup in the kvstore.
 func (a *Allocator) GetByID(id ID) (AllocatorKey, error) {
-	a.mutex.RLock()
-	if v, ok := a.cache[id]; ok {
-		a.mutex.RUnlock()
-		return v, nil
+	if key := a.mainCache.getByID(id); key != nil {
+		return key, nil
 	}
-	a.mutex.RUnlock()
+
+	a.remoteCachesMutex.RLock()
+	for rc := range a.remoteCaches {
+		if key := rc.cache.getByID(id); key != nil {
+			a.remoteCachesMutex.RUnlock()
+			return key, nil
+		}
+	}
+	a.remoteCachesMutex.RUnlock()
 
 	v, err := kvstore.Get(path.Join(a.idPrefix, id.String()))
 	if err != nil {
@@ -678,24 +695,16 @@ func (a *Allocator) runGC() error {
 	return nil
 }
 
+// startGC starts the garbage collector. It only runs on the leader to avoid
+// all nodes racing each other when releasing unused IDs.
 func (a *Allocator) startGC() {
-	go func(a *Allocator) {
-		for {
-			if err := a.runGC(); err != nil {
-				log.WithError(err).WithFields(logrus.Fields{fieldPrefix: a.idPrefix}).
-					Debug("Unable to run garbage collector")
-			}
-
-			select {
-			case <-a.stopGC:
-				log.WithFields(logrus.Fields{fieldPrefix: a.idPrefix}).
-					Debug("Stopped garbage collector")
-				return
-			case <-time.After(gcInterval):
-			}
-
-		}
-	}(a)
+	a.controllers.UpdateController(fmt.Sprintf("allocator-gc (%s)", a.basePrefix),
+		controller.ControllerParams{
+			DoFunc:      a.runGC,
+			RunInterval: gcInterval,
+			LeaderOnly:  true,
+		},
+	)
 }
 
 // AllocatorEventChan is a channel to receive allocator events on
@@ -713,114 +722,69 @@ type AllocatorEvent struct {
 	Key AllocatorKey
 }
 
-type waitChan chan bool
-
 func (a *Allocator) cleanCache() error {
 	// stop the watcher and wait for it to exit
-	a.stopWatch()
+	a.mainCache.stop()
 
-	return a.startWatchAndWait()
+	return a.mainCache.startAndWait()
 }
 
-// startWatch requests a LIST operation from the kvstore and starts watching
-// the prefix in a go subroutine.
-func (a *Allocator) startWatch() waitChan {
-	successChan := make(waitChan)
-
-	a.mutex.Lock()
-	a.idWatcherStop = make(chan struct{}, 0)
-
-	// start with a fresh nextCache
-	a.nextCache = IDMap{}
-	a.mutex.Unlock()
-
-	a.idWatcherWg.Add(1)
-
-	go func(a *Allocator) {
-		watcher := kvstore.ListAndWatch(a.idPrefix, a.idPrefix, 512)
-
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					goto abort
-				}
-				if event.Typ == kvstore.EventTypeListDone {
-					a.mutex.Lock()
-					// nextCache is valid, point the live cache to it
-					a.cache = a.nextCache
-					a.mutex.Unlock()
-
-					// report that the list operation has
-					// been completed and the allocator is
-					// ready to use
-					successChan <- true
-					continue
-				}
-
-				id := a.keyToID(event.Key, true)
-				if id != 0 {
-					a.mutex.Lock()
-
-					var key AllocatorKey
-
-					if len(event.Value) > 0 {
-						var err error
-						key, err = a.keyType.PutKey(string(event.Value))
-						if err != nil {
-							log.WithError(err).WithFields(logrus.Fields{fieldKey: event.Value}).
-								Warning("Unable to unmarshal allocator key")
-						}
-					}
-
-					switch event.Typ {
-					case kvstore.EventTypeCreate, kvstore.EventTypeModify:
-						kvstore.Trace("Adding id to cache", nil, logrus.Fields{fieldKey: key, fieldID: id})
-						a.nextCache[id] = key
-					case kvstore.EventTypeDelete:
-						kvstore.Trace("Removing id from cache", nil, logrus.Fields{fieldID: id})
-						delete(a.nextCache, id)
-					}
-					a.mutex.Unlock()
-
-					a.Events <- AllocatorEvent{
-						Typ: event.Typ,
-						ID:  ID(id),
-						Key: key,
-					}
-				}
-
-			case <-a.idWatcherStop:
-				goto abort
-			}
-		}
-
-	abort:
-		watcher.Stop()
-		// Signal that watcher is done
-		a.idWatcherWg.Done()
-	}(a)
-
-	return successChan
+// RemoteCache represents the cache content of an additional kvstore managing
+// identities. The contents are not directly accessible but will be merged
+// into the ForeachCache() function.
+type RemoteCache struct {
+	cache     cache
+	allocator *Allocator
 }
 
-func (a *Allocator) startWatchAndWait() error {
-	waitWatch := a.startWatch()
-
-	// Wait for watcher to be started and for list operation to succeed
-	select {
-	case <-waitWatch:
-	case <-time.After(listTimeout):
-		return fmt.Errorf("Time out while waiting for list operation to complete")
+// WatchRemoteKVStore starts watching an allocator base prefix the kvstore
+// represents by the provided backend. A local cache of all identities of that
+// kvstore will be maintained in the RemoteCache structure returned and will
+// start being reported in the identities returned by the ForeachCache()
+// function.
+func (a *Allocator) WatchRemoteKVStore(backend kvstore.BackendOperations) *RemoteCache {
+	rc := &RemoteCache{
+		cache:     newCache(a, backend, false),
+		allocator: a,
 	}
 
-	return nil
+	a.remoteCachesMutex.Lock()
+	a.remoteCaches[rc] = struct{}{}
+	a.remoteCachesMutex.Unlock()
+
+	rc.cache.start()
+
+	return rc
 }
 
-func (a *Allocator) stopWatch() {
-	close(a.idWatcherStop)
+// NumEntries returns the number of entries in the remote cache
+func (rc *RemoteCache) NumEntries() int {
+	if rc == nil {
+		return 0
+	}
 
-	// wait for all watcher to stop
-	a.idWatcherWg.Wait()
+	return rc.cache.numEntries()
+}
 
+// Close stops watching for identities in the kvstore associated with the
+// remote cache and will clear the local cache.
+func (rc *RemoteCache) Close() {
+	rc.allocator.remoteCachesMutex.Lock()
+	_, ok := rc.allocator.remoteCaches[rc]
+	delete(rc.allocator.remoteCaches, rc)
+	rc.allocator.remoteCachesMutex.Unlock()
+
+	if ok {
+		rc.cache.stop()
+
+		// Notify users of the allocator that all identities of the
+		// remote cache are no longer available
+		rc.cache.foreach(func(id ID, key AllocatorKey) {
+			rc.allocator.Events <- AllocatorEvent{
+				Typ: kvstore.EventTypeDelete,
+				ID:  id,
+				Key: key,
+			}
+		})
+	}
 }
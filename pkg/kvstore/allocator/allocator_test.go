@@ -65,6 +65,41 @@ func (e *AllocatorConsulSuite) TearDownTest(c *C) {
 	kvstore.Close()
 }
 
+type AllocatorMemorySuite struct {
+	AllocatorSuite
+}
+
+var _ = Suite(&AllocatorMemorySuite{})
+
+func (e *AllocatorMemorySuite) SetUpTest(c *C) {
+	kvstore.SetupDummy(kvstore.MemoryBackendName)
+}
+
+func (e *AllocatorMemorySuite) TearDownTest(c *C) {
+	kvstore.Close()
+}
+
+// AllocatorEncryptedMemorySuite runs all tests with the encryption of kvstore
+// values enabled
+type AllocatorEncryptedMemorySuite struct {
+	AllocatorSuite
+}
+
+var _ = Suite(&AllocatorEncryptedMemorySuite{})
+
+func (e *AllocatorEncryptedMemorySuite) SetUpTest(c *C) {
+	// Test key, do not use in production
+	keys, err := kvstore.ParseEncryptionKeys([]byte("test:AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="))
+	c.Assert(err, IsNil)
+	kvstore.SetEncryptionKeys(keys)
+	kvstore.SetupDummy(kvstore.MemoryBackendName)
+}
+
+func (e *AllocatorEncryptedMemorySuite) TearDownTest(c *C) {
+	kvstore.Close()
+	kvstore.SetEncryptionKeys(nil)
+}
+
 type TestType string
 
 func (t TestType) GetKey() string { return string(t) }
@@ -89,7 +124,7 @@ func (s *AllocatorSuite) TestSelectID(c *C) {
 		id, val := a.selectAvailableID()
 		c.Assert(id, Not(Equals), NoID)
 		c.Assert(val, Equals, id.String())
-		a.cache[id] = TestType(fmt.Sprintf("key-%d", i))
+		a.mainCache.cache[id] = TestType(fmt.Sprintf("key-%d", i))
 	}
 
 	// we should be out of IDs
@@ -311,3 +346,69 @@ func (s *AllocatorSuite) TestKeyToID(c *C) {
 //
 //	wg.Wait()
 //}
+
+func (s *AllocatorSuite) TestAllocateConflict(c *C) {
+	allocatorName := randomTestName()
+	a, err := NewAllocator(allocatorName, TestType(""), WithMin(ID(1)), WithMax(ID(2)), WithSuffix("a"))
+	c.Assert(err, IsNil)
+	c.Assert(a, Not(IsNil))
+	defer a.Delete()
+	defer a.DeleteAllKeys()
+
+	// Another agent has allocated all IDs but the local cache may not
+	// have learned about it yet
+	for _, id := range []ID{1, 2} {
+		keyPath := path.Join(a.idPrefix, id.String())
+		c.Assert(kvstore.CreateOnly(keyPath, []byte("other"), false), IsNil)
+	}
+
+	_, _, err = a.lockedAllocate(TestType("key"))
+	c.Assert(err, Not(IsNil))
+
+	// The slave key must not have been created and the master keys must
+	// be untouched
+	uses, err := kvstore.ListPrefix(path.Join(a.valuePrefix, "key"))
+	c.Assert(err, IsNil)
+	c.Assert(len(uses), Equals, 0)
+
+	ids, err := kvstore.ListPrefix(a.idPrefix)
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, kvstore.KeyValuePairs{
+		path.Join(a.idPrefix, "1"): []byte("other"),
+		path.Join(a.idPrefix, "2"): []byte("other"),
+	})
+}
+
+func (s *AllocatorMemorySuite) TestAllocateInjectedFailure(c *C) {
+	for n := 0; n < 3; n++ {
+		allocatorName := randomTestName()
+		a, err := NewAllocator(allocatorName, TestType(""), WithMax(ID(256)), WithSuffix("a"))
+		c.Assert(err, IsNil)
+		c.Assert(a, Not(IsNil))
+
+		kvstore.InjectMemoryFailure(n)
+		id, _, err := a.lockedAllocate(TestType("key"))
+		kvstore.InjectMemoryFailure(-1)
+
+		ids, err2 := kvstore.ListPrefix(a.idPrefix)
+		c.Assert(err2, IsNil)
+		uses, err2 := kvstore.ListPrefix(path.Join(a.valuePrefix, "key"))
+		c.Assert(err2, IsNil)
+
+		if err != nil {
+			// Neither the master nor the slave key may exist
+			c.Assert(len(ids), Equals, 0)
+			c.Assert(len(uses), Equals, 0)
+		} else {
+			c.Assert(ids, DeepEquals, kvstore.KeyValuePairs{
+				path.Join(a.idPrefix, id.String()): []byte("key"),
+			})
+			c.Assert(uses, DeepEquals, kvstore.KeyValuePairs{
+				path.Join(a.valuePrefix, "key", "a"): []byte(id.String()),
+			})
+		}
+
+		a.DeleteAllKeys()
+		a.Delete()
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cilium/cilium/pkg/kvstore"
+	"github.com/cilium/cilium/pkg/lock"
+
+	"github.com/sirupsen/logrus"
+)
+
+// cache is a local cache of all IDs allocated in a kvstore. It is being
+// maintained by watching for kvstore events and can thus lag behind.
+type cache struct {
+	allocator *Allocator
+
+	// backend is the kvstore backend being watched
+	backend kvstore.BackendOperations
+
+	// deleteInvalid is true if keys outside of the prefix should be
+	// removed from the kvstore when encountered
+	deleteInvalid bool
+
+	// stopChan is the channel used to stop the kvstore watcher
+	stopChan    chan struct{}
+	stopWatchWg sync.WaitGroup
+
+	// mutex protects the id to key mapping cache
+	mutex lock.RWMutex
+
+	// cache is the mapping of IDs to keys. It is pointed to nextCache
+	// once the initial list operation has completed.
+	cache IDMap
+
+	// nextCache is the cache is constantly being filled by start(), when
+	// start() has successfully performed the initial fill using
+	// ListPrefix, the cache above will be pointed to nextCache. If the
+	// start() fails to perform the initial list, then the cache is never
+	// pointed to nextCache. This guarantees that a valid cache is kept at
+	// all times.
+	nextCache IDMap
+}
+
+func newCache(a *Allocator, backend kvstore.BackendOperations, deleteInvalid bool) cache {
+	return cache{
+		allocator:     a,
+		backend:       backend,
+		deleteInvalid: deleteInvalid,
+		cache:         IDMap{},
+		stopChan:      make(chan struct{}, 0),
+	}
+}
+
+type waitChan chan bool
+
+// start requests a LIST operation from the kvstore and starts watching the
+// prefix in a go subroutine.
+func (c *cache) start() waitChan {
+	a := c.allocator
+
+	// The channel is buffered so the watcher does not block if nobody
+	// is waiting for the initial list to complete
+	successChan := make(waitChan, 1)
+
+	c.mutex.Lock()
+	c.stopChan = make(chan struct{}, 0)
+
+	// start with a fresh nextCache
+	c.nextCache = IDMap{}
+	c.mutex.Unlock()
+
+	c.stopWatchWg.Add(1)
+
+	go func(c *cache) {
+		watcher := c.backend.ListAndWatch(a.idPrefix, a.idPrefix, 512)
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					goto abort
+				}
+				if event.Typ == kvstore.EventTypeListDone {
+					c.mutex.Lock()
+					// nextCache is valid, point the live cache to it
+					c.cache = c.nextCache
+					c.mutex.Unlock()
+
+					// report that the list operation has
+					// been completed and the allocator is
+					// ready to use
+					successChan <- true
+					continue
+				}
+
+				id := a.keyToID(event.Key, c.deleteInvalid)
+				if id != 0 {
+					c.mutex.Lock()
+
+					var key AllocatorKey
+
+					if len(event.Value) > 0 {
+						var err error
+						key, err = a.keyType.PutKey(string(event.Value))
+						if err != nil {
+							log.WithError(err).WithFields(logrus.Fields{fieldKey: event.Value}).
+								Warning("Unable to unmarshal allocator key")
+						}
+					}
+
+					switch event.Typ {
+					case kvstore.EventTypeCreate, kvstore.EventTypeModify:
+						kvstore.Trace("Adding id to cache", nil, logrus.Fields{fieldKey: key, fieldID: id})
+						c.nextCache[id] = key
+					case kvstore.EventTypeDelete:
+						kvstore.Trace("Removing id from cache", nil, logrus.Fields{fieldID: id})
+						delete(c.nextCache, id)
+					}
+					c.mutex.Unlock()
+
+					a.Events <- AllocatorEvent{
+						Typ: event.Typ,
+						ID:  ID(id),
+						Key: key,
+					}
+				}
+
+			case <-c.stopChan:
+				goto abort
+			}
+		}
+
+	abort:
+		watcher.Stop()
+		// Signal that watcher is done
+		c.stopWatchWg.Done()
+	}(c)
+
+	return successChan
+}
+
+func (c *cache) startAndWait() error {
+	waitWatch := c.start()
+
+	// Wait for watcher to be started and for list operation to succeed
+	select {
+	case <-waitWatch:
+	case <-time.After(listTimeout):
+		return fmt.Errorf("Time out while waiting for list operation to complete")
+	}
+
+	return nil
+}
+
+func (c *cache) stop() {
+	close(c.stopChan)
+
+	// wait for all watcher to stop
+	c.stopWatchWg.Wait()
+}
+
+// get returns the ID associated with the key or NoID if the key is not in
+// the cache
+func (c *cache) get(key string) ID {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for k, v := range c.cache {
+		if v.GetKey() == key {
+			return k
+		}
+	}
+
+	return NoID
+}
+
+// getByID returns the key associated with the ID or nil if the ID is not in
+// the cache
+func (c *cache) getByID(id ID) AllocatorKey {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if v, ok := c.cache[id]; ok {
+		return v
+	}
+
+	return nil
+}
+
+// foreach calls cb for each entry in the cache
+func (c *cache) foreach(cb RangeFunc) {
+	c.mutex.RLock()
+	for k, v := range c.cache {
+		cb(k, v)
+	}
+	c.mutex.RUnlock()
+}
+
+// insert inserts a key into the next cache. After the initial list
+// operation has completed, the cache and next cache are identical.
+func (c *cache) insert(key AllocatorKey, val ID) {
+	c.mutex.Lock()
+	c.nextCache[val] = key
+	c.mutex.Unlock()
+}
+
+// exists returns true if the ID is in use in the cache
+func (c *cache) exists(id ID) bool {
+	c.mutex.RLock()
+	_, ok := c.cache[id]
+	c.mutex.RUnlock()
+	return ok
+}
+
+// numEntries returns the number of entries in the cache
+func (c *cache) numEntries() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.cache)
+}
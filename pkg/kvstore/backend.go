@@ -44,6 +44,11 @@ type backendModule interface {
 	// getName must return the name of the backend
 	getName() string
 
+	// createInstance must return a new, unconfigured instance of the
+	// backend module. It is used to create additional clients to other
+	// kvstore clusters in parallel to the default client.
+	createInstance() backendModule
+
 	// setConfig must configure the backend with the specified options.
 	// This function is called once before newClient().
 	setConfig(opts map[string]string) error
@@ -131,6 +136,11 @@ type BackendOperations interface {
 	// CreateIfExists creates a key with the value only if key condKey exists
 	CreateIfExists(condKey, key string, value []byte, lease bool) error
 
+	// Commit performs a transaction. All operations of the transaction
+	// are performed atomically if all conditions are met, see Commit()
+	// for details.
+	Commit(txn *Txn) (bool, error)
+
 	// ListPrefix returns a list of keys matching the prefix
 	ListPrefix(prefix string) (KeyValuePairs, error)
 
@@ -139,6 +149,11 @@ type BackendOperations interface {
 	// keys first.
 	Watch(w *Watcher)
 
+	// ListAndWatch creates a new watcher for the prefix which will list
+	// all existing keys first and then watch for changes, see
+	// ListAndWatch() for details.
+	ListAndWatch(name, prefix string, chanSize int) *Watcher
+
 	// CreateLease creates a lease with the specified ttl
 	CreateLease(ttl time.Duration) (interface{}, error)
 
@@ -247,3 +247,110 @@ func (s *BaseTests) TestListAndWatch(c *C) {
 
 	w.Stop()
 }
+
+func (s *BaseTests) TestCommit(c *C) {
+	prefix := "unit-test/"
+
+	DeletePrefix(prefix)
+	defer DeletePrefix(prefix)
+
+	key0, key1, key2 := testKey(prefix, 0), testKey(prefix, 1), testKey(prefix, 2)
+
+	// all conditions met
+	succeeded, err := Commit(NewTxn().IfMissing(key0).Put(key0, testValue(0), false).Put(key1, testValue(1), true))
+	c.Assert(err, IsNil)
+	c.Assert(succeeded, Equals, true)
+
+	val, err := Get(key0)
+	c.Assert(err, IsNil)
+	c.Assert(val, DeepEquals, testValue(0))
+
+	val, err = Get(key1)
+	c.Assert(err, IsNil)
+	c.Assert(val, DeepEquals, testValue(1))
+
+	// key0 exists, no operation may be performed
+	succeeded, err = Commit(NewTxn().IfMissing(key0).Put(key0, testValue(2), false).Put(key2, testValue(2), false))
+	c.Assert(err, IsNil)
+	c.Assert(succeeded, Equals, false)
+
+	val, err = Get(key0)
+	c.Assert(err, IsNil)
+	c.Assert(val, DeepEquals, testValue(0))
+
+	val, err = Get(key2)
+	c.Assert(err, IsNil)
+	c.Assert(val, IsNil)
+
+	// value mismatch
+	succeeded, err = Commit(NewTxn().IfValue(key0, testValue(1)).Delete(key0))
+	c.Assert(err, IsNil)
+	c.Assert(succeeded, Equals, false)
+
+	val, err = Get(key0)
+	c.Assert(err, IsNil)
+	c.Assert(val, DeepEquals, testValue(0))
+
+	// value match and existence
+	succeeded, err = Commit(NewTxn().IfValue(key0, testValue(0)).IfExists(key1).Delete(key0).Put(key2, testValue(2), false))
+	c.Assert(err, IsNil)
+	c.Assert(succeeded, Equals, true)
+
+	val, err = Get(key0)
+	c.Assert(err, IsNil)
+	c.Assert(val, IsNil)
+
+	val, err = Get(key2)
+	c.Assert(err, IsNil)
+	c.Assert(val, DeepEquals, testValue(2))
+}
+
+func (s *BaseTests) TestCommitRollback(c *C) {
+	prefix := "unit-test/"
+
+	DeletePrefix(prefix)
+	defer DeletePrefix(prefix)
+
+	key0, key1, key2 := testKey(prefix, 0), testKey(prefix, 1), testKey(prefix, 2)
+
+	lease, err := Client().CreateLease(time.Minute)
+	c.Assert(err, IsNil)
+
+	c.Assert(Update(key0, testValue(0), false), IsNil)
+
+	// key1 is attached to the lease and removed with the lease
+	succeeded, err := Commit(NewTxn().PutWithLease(key1, testValue(1), lease))
+	c.Assert(err, IsNil)
+	c.Assert(succeeded, Equals, true)
+
+	val, err := Get(key1)
+	c.Assert(err, IsNil)
+	c.Assert(val, DeepEquals, testValue(1))
+
+	c.Assert(Client().DeleteLease(lease), IsNil)
+
+	val, err = Get(key1)
+	c.Assert(err, IsNil)
+	c.Assert(val, IsNil)
+
+	// the lease no longer exists, the operations performed before the
+	// operation attaching key1 to the lease must be reverted
+	succeeded, err = Commit(NewTxn().
+		Put(key0, testValue(2), false).
+		Put(key2, testValue(2), false).
+		PutWithLease(key1, testValue(1), lease))
+	c.Assert(err, Not(IsNil))
+	c.Assert(succeeded, Equals, false)
+
+	val, err = Get(key0)
+	c.Assert(err, IsNil)
+	c.Assert(val, DeepEquals, testValue(0))
+
+	val, err = Get(key1)
+	c.Assert(err, IsNil)
+	c.Assert(val, IsNil)
+
+	val, err = Get(key2)
+	c.Assert(err, IsNil)
+	c.Assert(val, IsNil)
+}
@@ -15,6 +15,8 @@
 package kvstore
 
 import (
+	"fmt"
+
 	"github.com/cilium/cilium/pkg/controller"
 	"github.com/cilium/cilium/pkg/lock"
 )
@@ -37,7 +39,7 @@ func initClient(module backendModule) error {
 		return err
 	}
 
-	defaultClient = c
+	defaultClient = wrapClient(c)
 
 	deleteLegacyPrefixes()
 	if err := renewDefaultLease(); err != nil {
@@ -52,3 +54,41 @@ func initClient(module backendModule) error {
 func Client() BackendOperations {
 	return defaultClient
 }
+
+// NewClient returns a new kvstore client based on the configuration. Unlike
+// the default client set up with Setup(), the returned client does not
+// maintain the default lease and must be closed with CloseClient() when no
+// longer needed.
+func NewClient(selectedBackend string, opts map[string]string) (BackendOperations, error) {
+	module := getBackend(selectedBackend)
+	if module == nil {
+		return nil, fmt.Errorf("unknown key-value store type %q. See cilium.link/err-kvstore for details", selectedBackend)
+	}
+
+	module = module.createInstance()
+
+	if err := module.setConfig(opts); err != nil {
+		return nil, err
+	}
+
+	c, err := module.newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return wrapClient(c), nil
+}
+
+// wrapClient wraps a backend client with the encryption of values, if
+// encryption keys are configured, and with the rate limits and metrics
+func wrapClient(c BackendOperations) BackendOperations {
+	if keys := getEncryptionKeys(); keys != nil {
+		c = newEncryptedClient(c, keys)
+	}
+	return newInstrumentedClient(c)
+}
+
+// CloseClient closes a client previously created with NewClient()
+func CloseClient(c BackendOperations) {
+	c.closeClient()
+}
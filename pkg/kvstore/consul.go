@@ -53,18 +53,26 @@ var (
 	//consulDummyAddress can be overwritten from test invokers using ldflags
 	consulDummyAddress = "127.0.0.1:8501"
 
-	module = &consulModule{
+	module = newConsulModule()
+)
+
+func init() {
+	// register consul module for use
+	registerBackend(consulName, module)
+}
+
+func newConsulModule() backendModule {
+	return &consulModule{
 		opts: backendOptions{
 			optAddress: &backendOption{
 				description: "Addresses of consul cluster",
 			},
 		},
 	}
-)
+}
 
-func init() {
-	// register consul module for use
-	registerBackend(consulName, module)
+func (c *consulModule) createInstance() backendModule {
+	return newConsulModule()
 }
 
 func (c *consulModule) getName() string {
@@ -376,11 +384,23 @@ func (c *consulClient) GASNewL3n4AddrID(basePath string, baseID uint32, lAddrID
 	}
 }
 
-// Watch starts watching for changes in a prefix
+// ListAndWatch implements the BackendOperations.ListAndWatch using consul
+func (c *consulClient) ListAndWatch(name, prefix string, chanSize int) *Watcher {
+	w := newWatcher(name, prefix, chanSize)
+
+	go c.Watch(w)
+
+	return w
+}
+
+// Watch starts watching for changes in a prefix. A watch which fails is
+// resumed from the last index seen by the watcher. All keys are only listed
+// again if the index of consul went backwards, in which case the difference
+// to the previous list is emitted.
 func (c *consulClient) Watch(w *Watcher) {
 	// Last known state of all KVPairs matching the prefix
 	localState := map[string]consulAPI.KVPair{}
-	nextIndex := uint64(0)
+	listSignalSent := false
 
 	qo := consulAPI.QueryOptions{}
 
@@ -389,19 +409,36 @@ func (c *consulClient) Watch(w *Watcher) {
 		// want to sleep in between successful watch cycles
 		sleepTime := 1 * time.Millisecond
 
-		qo.WaitIndex = nextIndex
+		qo.WaitIndex = w.LastRevision()
 		pairs, q, err := c.KV().List(w.prefix, &qo)
-		if err != nil {
-			sleepTime = 5 * time.Second
+		switch {
+		case err != nil:
 			Trace("List of Watch failed", err, logrus.Fields{fieldPrefix: w.prefix, fieldWatcher: w.name})
-		}
 
-		if q != nil {
-			nextIndex = q.LastIndex
-		}
+			// Retry with the same index to resume the watch
+			w.restarted(true)
+			select {
+			case <-time.After(5 * time.Second):
+			case <-w.stopWatch:
+				close(w.Events)
+				return
+			}
+			continue
+
+		case q.LastIndex < qo.WaitIndex:
+			// The index went backwards, e.g. after consul has been
+			// restored from a snapshot. The index is no longer
+			// meaningful, list all keys again.
+			log.WithFields(logrus.Fields{
+				fieldWatcher: w,
+				fieldRev:     q.LastIndex,
+			}).Info("Consul index went backwards, listing all keys again")
+			w.setRevision(0)
+			w.restarted(false)
+			continue
 
-		// timeout while watching for changes, re-schedule
-		if qo.WaitIndex != 0 && (q == nil || q.LastIndex == qo.WaitIndex) {
+		case qo.WaitIndex != 0 && q.LastIndex == qo.WaitIndex:
+			// timeout while watching for changes, re-schedule
 			continue
 		}
 
@@ -448,9 +485,13 @@ func (c *consulClient) Watch(w *Watcher) {
 
 		}
 
+		w.setRevision(q.LastIndex)
+
 		// Initial list operation has been completed, signal this
-		if qo.WaitIndex == 0 {
+		// only once
+		if !listSignalSent {
 			w.Events <- KeyValueEvent{Typ: EventTypeListDone}
+			listSignalSent = true
 		}
 
 		select {
@@ -474,13 +515,13 @@ func (c *consulClient) DeletePrefix(path string) error {
 
 // Set sets value of key
 func (c *consulClient) Set(key string, value []byte) error {
-	_, err := c.KV().Put(&consulAPI.KVPair{Key: key, Value: value}, nil)
+	_, err := c.Commit(NewTxn().Put(key, value, false))
 	return err
 }
 
 // Delete deletes a key
 func (c *consulClient) Delete(key string) error {
-	_, err := c.KV().Delete(key, nil)
+	_, err := c.Commit(NewTxn().Delete(key))
 	return err
 }
 
@@ -512,43 +553,17 @@ func (c *consulClient) GetPrefix(prefix string) ([]byte, error) {
 
 // Update creates or updates a key with the value
 func (c *consulClient) Update(key string, value []byte, lease bool) error {
-	k := &consulAPI.KVPair{Key: key, Value: value}
-
-	if lease {
-		id, ok := leaseInstance.(string)
-		if !ok {
-			return fmt.Errorf("argument not a LeaseID")
-		}
-
-		k.Session = id
-	}
-
-	_, err := c.KV().Put(k, nil)
+	_, err := c.Commit(NewTxn().Put(key, value, lease))
 	return err
 }
 
 // CreateOnly creates a key with the value and will fail if the key already exists
 func (c *consulClient) CreateOnly(key string, value []byte, lease bool) error {
-	k := &consulAPI.KVPair{
-		Key:         key,
-		Value:       value,
-		CreateIndex: 0,
-	}
-
-	if lease {
-		id, ok := leaseInstance.(string)
-		if !ok {
-			return fmt.Errorf("argument not a LeaseID")
-		}
-
-		k.Session = id
-	}
-
-	success, _, err := c.KV().CAS(k, nil)
+	succeeded, err := c.Commit(NewTxn().IfMissing(key).Put(key, value, lease))
 	if err != nil {
 		return fmt.Errorf("unable to compare-and-swap: %s", err)
 	}
-	if !success {
+	if !succeeded {
 		return fmt.Errorf("compare-and-swap unsuccessful")
 	}
 
@@ -557,32 +572,151 @@ func (c *consulClient) CreateOnly(key string, value []byte, lease bool) error {
 
 // CreateIfExists creates a key with the value only if key condKey exists
 func (c *consulClient) CreateIfExists(condKey, key string, value []byte, lease bool) error {
-	// Consul does not support transactions which would allow to check for
-	// the presence of a conditional key if the key is not the key being
-	// manipulated
-	//
-	// Lock the conditional key to serialize all CreateIfExists() calls
-	l, err := LockPath(condKey)
+	succeeded, err := c.Commit(NewTxn().IfExists(condKey).IfMissing(key).Put(key, value, lease))
 	if err != nil {
-		return fmt.Errorf("unable to lock condKey for CreateIfExists: %s", err)
+		return err
+	}
+	if !succeeded {
+		return fmt.Errorf("conditional key not present or key already exists")
 	}
 
-	defer l.Unlock()
+	return nil
+}
 
-	// Create the key if it does not exist
-	if err := c.CreateOnly(key, value, lease); err != nil {
-		return err
+const (
+	// consulTxnEndpoint is the endpoint of the consul transaction API
+	consulTxnEndpoint = "/v1/txn"
+
+	// consulTxnConflict is the prefix of the error returned by the consul
+	// API when a transaction has been rolled back
+	consulTxnConflict = "Unexpected response code: 409 ("
+)
+
+// consulTxnKVOp is a key-value operation of the consul transaction API
+type consulTxnKVOp struct {
+	Verb    string
+	Key     string
+	Value   []byte `json:",omitempty"`
+	Index   uint64 `json:",omitempty"`
+	Session string `json:",omitempty"`
+}
+
+// consulTxnOp is an operation of the consul transaction API
+type consulTxnOp struct {
+	KV *consulTxnKVOp
+}
+
+// consulTxnError is an error reported by the consul transaction API for the
+// operation at index OpIndex
+type consulTxnError struct {
+	OpIndex int
+	What    string
+}
+
+// consulTxnResponse is the response of the consul transaction API
+type consulTxnResponse struct {
+	Errors []consulTxnError
+}
+
+// txnConflict returns the errors reported by consul if err is the result of
+// a transaction which has been rolled back
+func txnConflict(err error) ([]consulTxnError, bool) {
+	msg := err.Error()
+	if !strings.HasPrefix(msg, consulTxnConflict) || !strings.HasSuffix(msg, ")") {
+		return nil, false
 	}
 
-	// Consul does not support transactions which would allow to check for
-	// the presence of another key
-	masterKey, err := c.Get(condKey)
-	if err != nil || masterKey == nil {
-		c.Delete(key)
-		return fmt.Errorf("conditional key not present")
+	resp := consulTxnResponse{}
+	body := msg[len(consulTxnConflict) : len(msg)-1]
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return nil, false
 	}
 
-	return nil
+	return resp.Errors, true
+}
+
+// Commit performs a transaction with the consul transaction API. The
+// conditions of the transaction are translated into check operations which
+// precede the operations of the transaction:
+//  - TxnCompareMissing is checked with check-not-exists
+//  - TxnCompareExists is checked with get, which fails if the key is missing
+//  - TxnCompareValue is evaluated on the current value of the key and the
+//    modify index the value has been read at is checked with check-index
+// Keys attached to a lease are written with the lock operation as consul
+// ignores the session of regular writes. The key is removed when the session
+// is invalidated.
+func (c *consulClient) Commit(txn *Txn) (bool, error) {
+	ops := make([]consulTxnOp, 0, len(txn.Compares)+len(txn.Ops))
+
+	for _, cmp := range txn.Compares {
+		op := &consulTxnKVOp{Key: cmp.Key}
+
+		switch cmp.Typ {
+		case TxnCompareMissing:
+			op.Verb = "check-not-exists"
+		case TxnCompareExists:
+			op.Verb = "get"
+		case TxnCompareValue:
+			pair, _, err := c.KV().Get(cmp.Key, nil)
+			if err != nil {
+				return false, err
+			}
+			if pair == nil || !cmp.evaluate(pair.Value) {
+				return false, nil
+			}
+			op.Verb = "check-index"
+			op.Index = pair.ModifyIndex
+		default:
+			return false, fmt.Errorf("unknown comparison type %d", cmp.Typ)
+		}
+
+		ops = append(ops, consulTxnOp{KV: op})
+	}
+
+	// Operations at an index below numChecks are checks, a failure of
+	// one of them means that a condition has not been met
+	numChecks := len(ops)
+
+	for _, o := range txn.Ops {
+		op := &consulTxnKVOp{Key: o.Key}
+
+		switch o.Typ {
+		case TxnOpPut:
+			op.Value = o.Value
+			op.Verb = "set"
+			if lease, ok := o.lease(); ok {
+				id, ok := lease.(string)
+				if !ok {
+					return false, fmt.Errorf("argument not a LeaseID")
+				}
+				op.Verb = "lock"
+				op.Session = id
+			}
+		case TxnOpDelete:
+			op.Verb = "delete"
+		default:
+			return false, fmt.Errorf("unknown operation type %d", o.Typ)
+		}
+
+		ops = append(ops, consulTxnOp{KV: op})
+	}
+
+	if _, err := c.Raw().Write(consulTxnEndpoint, ops, nil, nil); err != nil {
+		txnErrs, ok := txnConflict(err)
+		if !ok {
+			return false, err
+		}
+
+		for _, txnErr := range txnErrs {
+			if txnErr.OpIndex >= numChecks {
+				return false, fmt.Errorf("transaction rolled back: %s", txnErr.What)
+			}
+		}
+
+		return false, nil
+	}
+
+	return true, nil
 }
 
 // ListPrefix returns a map of matching keys
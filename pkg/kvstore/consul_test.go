@@ -15,6 +15,7 @@
 package kvstore
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"os"
@@ -39,7 +40,7 @@ func (e *ConsulSuite) TearDownTest(c *C) {
 	Close()
 }
 
-var handler http.HandlerFunc
+var handler, txnHandler http.HandlerFunc
 
 func TestMain(m *testing.M) {
 	mux := http.NewServeMux()
@@ -47,6 +48,9 @@ func TestMain(m *testing.M) {
 	mux.HandleFunc("/v1/status/leader", func(w http.ResponseWriter, r *http.Request) {
 		handler(w, r)
 	})
+	mux.HandleFunc("/v1/txn", func(w http.ResponseWriter, r *http.Request) {
+		txnHandler(w, r)
+	})
 
 	srv := &http.Server{
 		Addr:    ":8000",
@@ -84,3 +88,70 @@ func TestConsulClientOk(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestConsulCommit(t *testing.T) {
+	maxRetries = 3
+	handler = func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "\"leader\"")
+	}
+
+	client, err := newConsulClient(&consulAPI.Config{
+		Address: ":8000",
+	})
+	if err != nil {
+		t.Fatalf("unable to create client: %s", err)
+	}
+
+	var ops []consulTxnOp
+	txnHandler = func(w http.ResponseWriter, r *http.Request) {
+		ops = nil
+		if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+			t.Errorf("unable to decode transaction: %s", err)
+		}
+		io.WriteString(w, "{}")
+	}
+
+	txn := NewTxn().IfMissing("a").IfExists("b").Put("a", []byte("1"), false).
+		PutWithLease("c", []byte("2"), "session").Delete("d")
+	succeeded, err := client.Commit(txn)
+	if err != nil || !succeeded {
+		t.Fatalf("transaction failed: %t, %v", succeeded, err)
+	}
+
+	verbs := []string{}
+	for _, op := range ops {
+		verbs = append(verbs, op.KV.Verb)
+	}
+	expected := []string{"check-not-exists", "get", "set", "lock", "delete"}
+	if len(verbs) != len(expected) {
+		t.Fatalf("unexpected operations %v, expected %v", verbs, expected)
+	}
+	for i := range expected {
+		if verbs[i] != expected[i] {
+			t.Fatalf("unexpected operations %v, expected %v", verbs, expected)
+		}
+	}
+	if ops[3].KV.Session != "session" {
+		t.Errorf("leased key not locked with session: %s", ops[3].KV.Session)
+	}
+
+	// A failed check is a condition which has not been met
+	txnHandler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, `{"Errors":[{"OpIndex":0,"What":"key already exists"}]}`)
+	}
+	succeeded, err = client.Commit(NewTxn().IfMissing("a").Put("a", []byte("1"), false))
+	if err != nil || succeeded {
+		t.Errorf("expected unmet condition, got %t, %v", succeeded, err)
+	}
+
+	// A failed operation is an error
+	txnHandler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, `{"Errors":[{"OpIndex":1,"What":"invalid session"}]}`)
+	}
+	succeeded, err = client.Commit(NewTxn().IfMissing("a").PutWithLease("a", []byte("1"), "session"))
+	if err == nil || succeeded {
+		t.Errorf("expected error, got %t, %v", succeeded, err)
+	}
+}
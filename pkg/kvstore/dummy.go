@@ -27,3 +27,18 @@ func SetupDummy(dummyBackend string) {
 		log.WithError(err).Panic("Unable to initialize kvstore client")
 	}
 }
+
+// InjectMemoryFailure makes all modifications performed via the default
+// client fail after n further modifications have succeeded. A transaction
+// counts as a single modification. A negative n disables failure injection.
+// The default client must use the in-memory backend.
+func InjectMemoryFailure(n int) {
+	c, ok := unwrapClient(defaultClient).(*memoryClient)
+	if !ok {
+		log.Panic("Failure injection requires the in-memory kvstore backend")
+	}
+
+	c.mutex.Lock()
+	c.failAfter = n
+	c.mutex.Unlock()
+}
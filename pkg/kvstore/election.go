@@ -0,0 +1,293 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/cilium/cilium/pkg/lock"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ElectionsPath is the kvstore prefix of all leader elections
+	ElectionsPath = path.Join(BaseKeyPrefix, "state", "leader", "v1")
+
+	// DefaultElectionTTL is the default time-to-live of the lease held by
+	// the leader. A leader which fails to renew its lease loses the
+	// leadership after the TTL has expired.
+	DefaultElectionTTL = 15 * time.Second
+)
+
+// ElectionConfig is the configuration of a leader election
+type ElectionConfig struct {
+	// Name is the name of the election. All candidates of an election
+	// must use the same name.
+	Name string
+
+	// Identity is the identity of the candidate, typically the node name.
+	// It must be unique across all candidates.
+	Identity string
+
+	// TTL is the time-to-live of the lease held by the leader. Defaults
+	// to DefaultElectionTTL.
+	TTL time.Duration
+
+	// OnStartedLeading, if set, is called when the candidate became the
+	// leader
+	OnStartedLeading func()
+
+	// OnStoppedLeading, if set, is called when the candidate lost the
+	// leadership or resigned
+	OnStoppedLeading func()
+
+	// OnNewLeader, if set, is called whenever the leader changes. The
+	// identity is empty if there is currently no leader.
+	OnNewLeader func(identity string)
+}
+
+// Election is a leader election among all candidates using the same
+// election name. The leader holds a key attached to a lease which is
+// renewed periodically. If the leader fails to renew the lease, the key
+// expires with the lease and another candidate takes over. Callbacks are
+// invoked sequentially from a single goroutine.
+type Election struct {
+	backend BackendOperations
+	config  ElectionConfig
+	key     string
+
+	mutex    lock.RWMutex
+	leader   string
+	isLeader bool
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewElection creates a new leader election and starts campaigning for
+// the leadership with backend. Stop() must be called to resign.
+func NewElection(backend BackendOperations, config ElectionConfig) (*Election, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("election name must be specified")
+	}
+
+	if config.Identity == "" {
+		return nil, fmt.Errorf("candidate identity must be specified")
+	}
+
+	if config.TTL == 0 {
+		config.TTL = DefaultElectionTTL
+	}
+
+	e := &Election{
+		backend: backend,
+		config:  config,
+		key:     path.Join(ElectionsPath, config.Name),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go e.run()
+
+	return e, nil
+}
+
+// IsLeader returns true if the candidate is currently the leader
+func (e *Election) IsLeader() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.isLeader
+}
+
+// Leader returns the identity of the current leader or an empty string if
+// the leader is unknown
+func (e *Election) Leader() string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.leader
+}
+
+// Name returns the name of the election
+func (e *Election) Name() string {
+	return e.config.Name
+}
+
+// Identity returns the identity of the candidate
+func (e *Election) Identity() string {
+	return e.config.Identity
+}
+
+// Stop stops campaigning and resigns the leadership if held
+func (e *Election) Stop() {
+	close(e.stop)
+	<-e.stopped
+}
+
+func (e *Election) getLogger() *logrus.Entry {
+	return log.WithFields(logrus.Fields{
+		fieldElection:  e.config.Name,
+		fieldCandidate: e.config.Identity,
+	})
+}
+
+// setLeader updates the current leader and invokes the callbacks
+func (e *Election) setLeader(leader string) {
+	isLeader := leader == e.config.Identity
+
+	e.mutex.Lock()
+	oldLeader, wasLeader := e.leader, e.isLeader
+	e.leader, e.isLeader = leader, isLeader
+	e.mutex.Unlock()
+
+	if oldLeader == leader {
+		return
+	}
+
+	e.getLogger().WithField(fieldLeader, leader).Info("Leader of election changed")
+
+	if wasLeader && e.config.OnStoppedLeading != nil {
+		e.config.OnStoppedLeading()
+	}
+
+	if e.config.OnNewLeader != nil {
+		e.config.OnNewLeader(leader)
+	}
+
+	if isLeader && e.config.OnStartedLeading != nil {
+		e.config.OnStartedLeading()
+	}
+}
+
+// campaign attempts to acquire the leadership with lease and returns the
+// identity of the current leader
+func (e *Election) campaign(lease interface{}) (string, error) {
+	txn := NewTxn().IfMissing(e.key).PutWithLease(e.key, []byte(e.config.Identity), lease)
+	succeeded, err := e.backend.Commit(txn)
+	if err != nil {
+		return "", err
+	}
+
+	if succeeded {
+		return e.config.Identity, nil
+	}
+
+	leader, err := e.backend.Get(e.key)
+	if err != nil {
+		return "", err
+	}
+
+	return string(leader), nil
+}
+
+// renew renews the lease of the leader and verifies that the leader key
+// is still held
+func (e *Election) renew(lease interface{}) error {
+	if err := e.backend.KeepAlive(lease); err != nil {
+		return fmt.Errorf("unable to renew lease: %s", err)
+	}
+
+	leader, err := e.backend.Get(e.key)
+	if err != nil {
+		return fmt.Errorf("unable to verify leadership: %s", err)
+	}
+
+	if string(leader) != e.config.Identity {
+		return fmt.Errorf("leader key is held by '%s'", string(leader))
+	}
+
+	return nil
+}
+
+// resign gives up the leadership by releasing the leader key, if still held
+// by the candidate, and by deleting the lease. Deleting the lease removes
+// the leader key as well but the key is released explicitly so that the
+// leadership is handed over right away even if the lease cannot be deleted.
+func (e *Election) resign(lease interface{}) {
+	if lease == nil {
+		return
+	}
+
+	if e.IsLeader() {
+		txn := NewTxn().IfValue(e.key, []byte(e.config.Identity)).Delete(e.key)
+		if _, err := e.backend.Commit(txn); err != nil {
+			e.getLogger().WithError(err).Debug("Unable to release leader key")
+		}
+	}
+
+	if err := e.backend.DeleteLease(lease); err != nil {
+		e.getLogger().WithError(err).Debug("Unable to delete election lease")
+	}
+}
+
+// step performs a single round of the election with lease and returns the
+// lease to use in the next round or nil if a new lease must be created
+func (e *Election) step(lease interface{}) interface{} {
+	if lease == nil {
+		l, err := e.backend.CreateLease(e.config.TTL)
+		if err != nil {
+			e.getLogger().WithError(err).Warning("Unable to create election lease")
+			return nil
+		}
+		lease = l
+	} else if !e.IsLeader() {
+		// Keep the lease of the candidate alive for the next campaign
+		if err := e.backend.KeepAlive(lease); err != nil {
+			e.getLogger().WithError(err).Debug("Unable to renew election lease")
+			return nil
+		}
+	}
+
+	if e.IsLeader() {
+		if err := e.renew(lease); err != nil {
+			e.getLogger().WithError(err).Warning("Lost leadership")
+			e.resign(lease)
+			e.setLeader("")
+			return nil
+		}
+		return lease
+	}
+
+	leader, err := e.campaign(lease)
+	if err != nil {
+		e.getLogger().WithError(err).Warning("Unable to campaign for leadership")
+		return lease
+	}
+	e.setLeader(leader)
+
+	return lease
+}
+
+func (e *Election) run() {
+	var lease interface{}
+
+	defer close(e.stopped)
+
+	for {
+		lease = e.step(lease)
+
+		select {
+		case <-e.stop:
+			e.resign(lease)
+			if e.IsLeader() {
+				e.setLeader("")
+			}
+			return
+		case <-time.After(e.config.TTL / 3):
+		}
+	}
+}
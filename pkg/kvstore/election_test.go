@@ -0,0 +1,195 @@
+// Copyright 2016-2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"time"
+
+	"github.com/cilium/cilium/pkg/lock"
+
+	consulAPI "github.com/hashicorp/consul/api"
+	. "gopkg.in/check.v1"
+)
+
+// electionEvents records the callbacks of an election
+type electionEvents struct {
+	mutex   lock.Mutex
+	started int
+	stopped int
+	leaders []string
+}
+
+func (e *electionEvents) config(name, identity string) ElectionConfig {
+	return ElectionConfig{
+		Name:     name,
+		Identity: identity,
+		TTL:      300 * time.Millisecond,
+		OnStartedLeading: func() {
+			e.mutex.Lock()
+			e.started++
+			e.mutex.Unlock()
+		},
+		OnStoppedLeading: func() {
+			e.mutex.Lock()
+			e.stopped++
+			e.mutex.Unlock()
+		},
+		OnNewLeader: func(identity string) {
+			e.mutex.Lock()
+			e.leaders = append(e.leaders, identity)
+			e.mutex.Unlock()
+		},
+	}
+}
+
+func (e *electionEvents) counts() (int, int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.started, e.stopped
+}
+
+func waitForLeader(c *C, elections ...*Election) *Election {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		for _, e := range elections {
+			if e.IsLeader() {
+				return e
+			}
+		}
+	}
+	c.Fatal("No leader elected")
+	return nil
+}
+
+func (s *MemorySuite) TestElection(c *C) {
+	eventsA, eventsB := &electionEvents{}, &electionEvents{}
+
+	a, err := NewElection(Client(), eventsA.config("test", "a"))
+	c.Assert(err, IsNil)
+	leader := waitForLeader(c, a)
+	c.Assert(leader.Leader(), Equals, "a")
+
+	b, err := NewElection(Client(), eventsB.config("test", "b"))
+	c.Assert(err, IsNil)
+	defer b.Stop()
+
+	for start := time.Now(); b.Leader() != "a"; time.Sleep(10 * time.Millisecond) {
+		c.Assert(time.Since(start) < 5*time.Second, Equals, true)
+	}
+	c.Assert(b.IsLeader(), Equals, false)
+
+	v, err := Get(ElectionsPath + "/test")
+	c.Assert(err, IsNil)
+	c.Assert(string(v), Equals, "a")
+
+	// Resigning hands the leadership over to the other candidate
+	a.Stop()
+	c.Assert(a.IsLeader(), Equals, false)
+	c.Assert(waitForLeader(c, b), Equals, b)
+
+	started, stopped := eventsA.counts()
+	c.Assert(started, Equals, 1)
+	c.Assert(stopped, Equals, 1)
+
+	started, stopped = eventsB.counts()
+	c.Assert(started, Equals, 1)
+	c.Assert(stopped, Equals, 0)
+	c.Assert(eventsB.leaders[len(eventsB.leaders)-1], Equals, "b")
+}
+
+func (s *MemorySuite) TestElectionLostLeadership(c *C) {
+	events := &electionEvents{}
+
+	e, err := NewElection(Client(), events.config("test", "a"))
+	c.Assert(err, IsNil)
+	defer e.Stop()
+	waitForLeader(c, e)
+
+	// Another candidate took over the key, the leadership is lost
+	c.Assert(Set(ElectionsPath+"/test", []byte("b")), IsNil)
+	for start := time.Now(); e.IsLeader(); time.Sleep(10 * time.Millisecond) {
+		c.Assert(time.Since(start) < 5*time.Second, Equals, true)
+	}
+
+	_, stopped := events.counts()
+	c.Assert(stopped, Equals, 1)
+
+	// The leadership is acquired again once the key is released
+	c.Assert(Delete(ElectionsPath+"/test"), IsNil)
+	waitForLeader(c, e)
+
+	started, _ := events.counts()
+	c.Assert(started, Equals, 2)
+}
+
+func (s *ConsulSuite) TestElectionSessionInvalidated(c *C) {
+	events := &electionEvents{}
+	config := events.config("consul-test", "a")
+	// consul does not accept session TTLs below 10 seconds
+	config.TTL = 10 * time.Second
+
+	e, err := NewElection(Client(), config)
+	c.Assert(err, IsNil)
+	defer e.Stop()
+	waitForLeader(c, e)
+
+	client, err := consulAPI.NewClient(&consulAPI.Config{Address: consulDummyAddress})
+	c.Assert(err, IsNil)
+
+	// The leader key must be held by the session of the leader
+	key := ElectionsPath + "/consul-test"
+	pair, _, err := client.KV().Get(key, nil)
+	c.Assert(err, IsNil)
+	c.Assert(pair, Not(IsNil))
+	c.Assert(string(pair.Value), Equals, "a")
+	c.Assert(pair.Session, Not(Equals), "")
+
+	// Invalidating the session removes the leader key and the leadership
+	// is lost
+	_, err = client.Session().Destroy(pair.Session, nil)
+	c.Assert(err, IsNil)
+
+	v, err := Get(key)
+	c.Assert(err, IsNil)
+	c.Assert(v, IsNil)
+
+	for start := time.Now(); ; time.Sleep(100 * time.Millisecond) {
+		c.Assert(time.Since(start) < 2*config.TTL, Equals, true)
+		if _, stopped := events.counts(); stopped == 1 {
+			break
+		}
+	}
+
+	// The leadership is acquired again with a new session
+	for start := time.Now(); ; time.Sleep(100 * time.Millisecond) {
+		c.Assert(time.Since(start) < 2*config.TTL, Equals, true)
+		if started, _ := events.counts(); started == 2 {
+			break
+		}
+	}
+
+	newPair, _, err := client.KV().Get(key, nil)
+	c.Assert(err, IsNil)
+	c.Assert(newPair, Not(IsNil))
+	c.Assert(newPair.Session, Not(Equals), "")
+	c.Assert(newPair.Session, Not(Equals), pair.Session)
+}
+
+func (s *independentSuite) TestElectionConfig(c *C) {
+	_, err := NewElection(nil, ElectionConfig{Identity: "a"})
+	c.Assert(err, Not(IsNil))
+
+	_, err = NewElection(nil, ElectionConfig{Name: "test"})
+	c.Assert(err, Not(IsNil))
+}
@@ -0,0 +1,273 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/cilium/cilium/common/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// encryptedClient wraps a backend client and encrypts all values written
+// with Set(), Update(), CreateOnly(), CreateIfExists() and Commit() as well
+// as with the obsolete API. Values are decrypted transparently when read
+// with Get(), GetPrefix(), ListPrefix(), GetValue() and when received by a
+// watcher. Values stored in plaintext are returned unmodified to allow
+// enabling encryption on an existing kvstore.
+//
+// Only values are encrypted. The keys remain in plaintext, including the
+// keys containing the labels of identities and the IPs of endpoints.
+type encryptedClient struct {
+	BackendOperations
+
+	keys *EncryptionKeys
+}
+
+func newEncryptedClient(c BackendOperations, keys *EncryptionKeys) *encryptedClient {
+	return &encryptedClient{
+		BackendOperations: c,
+		keys:              keys,
+	}
+}
+
+// decryptPair decrypts the value of key and logs a warning if the value
+// cannot be decrypted
+func (e *encryptedClient) decryptPair(key string, value []byte) ([]byte, bool) {
+	plaintext, err := e.keys.Decrypt(value)
+	if err != nil {
+		log.WithError(err).WithField(fieldKey, key).Warning("Ignoring value which cannot be decrypted")
+		return nil, false
+	}
+	return plaintext, true
+}
+
+func (e *encryptedClient) Get(key string) ([]byte, error) {
+	value, err := e.BackendOperations.Get(key)
+	if err != nil || value == nil {
+		return value, err
+	}
+	return e.keys.Decrypt(value)
+}
+
+func (e *encryptedClient) GetPrefix(prefix string) ([]byte, error) {
+	value, err := e.BackendOperations.GetPrefix(prefix)
+	if err != nil || value == nil {
+		return value, err
+	}
+	return e.keys.Decrypt(value)
+}
+
+func (e *encryptedClient) ListPrefix(prefix string) (KeyValuePairs, error) {
+	pairs, err := e.BackendOperations.ListPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range pairs {
+		if plaintext, ok := e.decryptPair(key, value); ok {
+			pairs[key] = plaintext
+		} else {
+			delete(pairs, key)
+		}
+	}
+
+	return pairs, nil
+}
+
+func (e *encryptedClient) Set(key string, value []byte) error {
+	ciphertext, err := e.keys.Encrypt(value)
+	if err != nil {
+		return err
+	}
+	return e.BackendOperations.Set(key, ciphertext)
+}
+
+func (e *encryptedClient) Update(key string, value []byte, lease bool) error {
+	ciphertext, err := e.keys.Encrypt(value)
+	if err != nil {
+		return err
+	}
+	return e.BackendOperations.Update(key, ciphertext, lease)
+}
+
+func (e *encryptedClient) CreateOnly(key string, value []byte, lease bool) error {
+	ciphertext, err := e.keys.Encrypt(value)
+	if err != nil {
+		return err
+	}
+	return e.BackendOperations.CreateOnly(key, ciphertext, lease)
+}
+
+func (e *encryptedClient) CreateIfExists(condKey, key string, value []byte, lease bool) error {
+	ciphertext, err := e.keys.Encrypt(value)
+	if err != nil {
+		return err
+	}
+	return e.BackendOperations.CreateIfExists(condKey, key, ciphertext, lease)
+}
+
+// GetValue decrypts the value of k written with SetValue()
+//
+// FIXME: Obsolete, remove
+func (e *encryptedClient) GetValue(k string) (json.RawMessage, error) {
+	value, err := e.Get(k)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return json.RawMessage(value), nil
+}
+
+// SetValue encrypts the JSON representation of v and stores it in k
+//
+// FIXME: Obsolete, remove
+func (e *encryptedClient) SetValue(k string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return e.Set(k, value)
+}
+
+// FIXME: Obsolete, remove
+func (e *encryptedClient) InitializeFreeID(path string, firstID uint32) error {
+	return initializeFreeID(e, path, firstID)
+}
+
+// FIXME: Obsolete, remove
+func (e *encryptedClient) GetMaxID(key string, firstID uint32) (uint32, error) {
+	return getMaxID(e, key, firstID)
+}
+
+// FIXME: Obsolete, remove
+func (e *encryptedClient) SetMaxID(key string, firstID, maxID uint32) error {
+	return setMaxID(e, key, firstID, maxID)
+}
+
+// GASNewL3n4AddrID allocates a service ID with the values encrypted by
+// SetValue()
+//
+// FIXME: Obsolete, remove
+func (e *encryptedClient) GASNewL3n4AddrID(basePath string, baseID uint32, lAddrID *types.L3n4AddrID) error {
+	return gasNewL3n4AddrID(e, basePath, baseID, lAddrID)
+}
+
+// Commit encrypts the values of all put operations of txn. As encrypted
+// values are not deterministic, value comparisons are evaluated against the
+// decrypted value first and then performed against the stored ciphertext so
+// the transaction fails if the value has been modified in the meantime.
+func (e *encryptedClient) Commit(txn *Txn) (bool, error) {
+	encrypted := &Txn{
+		Compares: make([]TxnCompare, 0, len(txn.Compares)),
+		Ops:      make([]TxnOp, 0, len(txn.Ops)),
+	}
+
+	for _, c := range txn.Compares {
+		if c.Typ == TxnCompareValue {
+			stored, err := e.BackendOperations.Get(c.Key)
+			if err != nil {
+				return false, err
+			}
+
+			if stored == nil {
+				return false, nil
+			}
+
+			value, err := e.keys.Decrypt(stored)
+			if err != nil {
+				return false, err
+			}
+
+			if !bytes.Equal(value, c.Value) {
+				return false, nil
+			}
+
+			c.Value = stored
+		}
+		encrypted.Compares = append(encrypted.Compares, c)
+	}
+
+	for _, o := range txn.Ops {
+		if o.Typ == TxnOpPut {
+			ciphertext, err := e.keys.Encrypt(o.Value)
+			if err != nil {
+				return false, err
+			}
+			o.Value = ciphertext
+		}
+		encrypted.Ops = append(encrypted.Ops, o)
+	}
+
+	return e.BackendOperations.Commit(encrypted)
+}
+
+// ListAndWatch implements the BackendOperations.ListAndWatch and decrypts
+// the values of all events
+func (e *encryptedClient) ListAndWatch(name, prefix string, chanSize int) *Watcher {
+	w := newWatcher(name, prefix, chanSize)
+
+	go e.Watch(w)
+
+	return w
+}
+
+// Watch starts a watcher of the wrapped client and forwards all events to w
+// with the values decrypted. Events with values which cannot be decrypted
+// are dropped.
+func (e *encryptedClient) Watch(w *Watcher) {
+	inner := newWatcher(w.name, w.prefix, cap(w.Events))
+	go e.BackendOperations.Watch(inner)
+
+	scopedLog := log.WithFields(logrus.Fields{
+		fieldWatcher: w,
+		fieldPrefix:  w.prefix,
+	})
+
+	defer func() {
+		inner.Stop()
+		close(w.Events)
+	}()
+
+	for {
+		select {
+		case event, ok := <-inner.Events:
+			if !ok {
+				scopedLog.Debug("Watcher of wrapped client stopped")
+				return
+			}
+
+			w.setRevision(inner.LastRevision())
+
+			if len(event.Value) > 0 {
+				value, ok := e.decryptPair(event.Key, event.Value)
+				if !ok {
+					continue
+				}
+				event.Value = value
+			}
+
+			select {
+			case w.Events <- event:
+			case <-w.stopWatch:
+				return
+			}
+
+		case <-w.stopWatch:
+			return
+		}
+	}
+}
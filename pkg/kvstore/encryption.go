@@ -0,0 +1,278 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/lock"
+)
+
+const (
+	// encryptedValuePrefix is the prefix of all encrypted values. Values
+	// without the prefix are considered to be stored in plaintext.
+	//
+	// WARNING - STABLE API: Changing the format of encrypted values will
+	// break backwards compatibility
+	encryptedValuePrefix = "cilium-enc-v1:"
+
+	// encryptionKeyLen is the length of all encryption keys, AES-256 is
+	// used for both the key encryption keys and the data encryption keys
+	encryptionKeyLen = 32
+)
+
+var encryptionKeyIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// encryptionKey is a key encryption key used to encrypt the data
+// encryption keys of values
+type encryptionKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// EncryptionKeys is a set of key encryption keys. Values are encrypted with
+// a random data encryption key which is stored next to the value, encrypted
+// with the primary key. Values encrypted with any of the keys can be
+// decrypted which allows to rotate the primary key.
+type EncryptionKeys struct {
+	primary *encryptionKey
+	keys    map[string]*encryptionKey
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ParseEncryptionKeys parses a list of encryption keys. Each line contains
+// a key in the format <id>:<base64 encoded 256 bit key>. Empty lines and
+// lines starting with '#' are ignored. The first key is the primary key used
+// to encrypt values, all keys are used to decrypt values.
+func ParseEncryptionKeys(data []byte) (*EncryptionKeys, error) {
+	k := &EncryptionKeys{keys: map[string]*encryptionKey{}}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected <id>:<key>", n)
+		}
+
+		id := parts[0]
+		if !encryptionKeyIDRegexp.MatchString(id) {
+			return nil, fmt.Errorf("line %d: invalid key id '%s'", n, id)
+		}
+
+		if _, ok := k.keys[id]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key id '%s'", n, id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: unable to decode key '%s': %s", n, id, err)
+		}
+
+		if len(key) != encryptionKeyLen {
+			return nil, fmt.Errorf("line %d: key '%s' must be %d bytes long", n, id, encryptionKeyLen)
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid key '%s': %s", n, id, err)
+		}
+
+		k.keys[id] = &encryptionKey{id: id, aead: aead}
+		if k.primary == nil {
+			k.primary = k.keys[id]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if k.primary == nil {
+		return nil, fmt.Errorf("no encryption key found")
+	}
+
+	return k, nil
+}
+
+// LoadEncryptionKeys reads the encryption keys from a file. See
+// ParseEncryptionKeys() for the format of the file.
+func LoadEncryptionKeys(path string) (*EncryptionKeys, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := ParseEncryptionKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key file %s: %s", path, err)
+	}
+
+	return k, nil
+}
+
+// PrimaryKeyID returns the id of the key used to encrypt values
+func (k *EncryptionKeys) PrimaryKeyID() string {
+	return k.primary.id
+}
+
+// sealWithNonce encrypts plaintext with aead and returns the random nonce
+// followed by the ciphertext
+func sealWithNonce(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// openWithNonce decrypts data previously encrypted with sealWithNonce()
+func openWithNonce(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	size := aead.NonceSize()
+	return aead.Open(nil, data[:size], data[size:], nil)
+}
+
+// wrappedKeyLen returns the length of a data encryption key encrypted with
+// aead
+func wrappedKeyLen(aead cipher.AEAD) int {
+	return aead.NonceSize() + encryptionKeyLen + aead.Overhead()
+}
+
+// Encrypt encrypts value with a new data encryption key and returns the
+// encrypted value in the format:
+//
+//     cilium-enc-v1:<key id>:<encrypted data key><encrypted value>
+func (k *EncryptionKeys) Encrypt(value []byte) ([]byte, error) {
+	dataKey := make([]byte, encryptionKeyLen)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := sealWithNonce(k.primary.aead, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := sealWithNonce(dataAEAD, value)
+	if err != nil {
+		return nil, err
+	}
+
+	header := encryptedValuePrefix + k.primary.id + ":"
+	out := make([]byte, 0, len(header)+len(wrappedKey)+len(ciphertext))
+	out = append(out, header...)
+	out = append(out, wrappedKey...)
+	return append(out, ciphertext...), nil
+}
+
+// IsEncrypted returns true if value has been encrypted
+func IsEncrypted(value []byte) bool {
+	return bytes.HasPrefix(value, []byte(encryptedValuePrefix))
+}
+
+// Decrypt decrypts a value previously encrypted with Encrypt() using any of
+// the keys. Values which are not encrypted are returned unmodified.
+func (k *EncryptionKeys) Decrypt(value []byte) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	data := value[len(encryptedValuePrefix):]
+	sep := bytes.IndexByte(data, ':')
+	if sep < 0 {
+		return nil, fmt.Errorf("invalid encrypted value: missing key id")
+	}
+
+	id := string(data[:sep])
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key '%s'", id)
+	}
+
+	data = data[sep+1:]
+	n := wrappedKeyLen(key.aead)
+	if len(data) < n {
+		return nil, fmt.Errorf("invalid encrypted value: data key too short")
+	}
+
+	dataKey, err := openWithNonce(key.aead, data[:n])
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt data key with key '%s': %s", id, err)
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := openWithNonce(dataAEAD, data[n:])
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt value: %s", err)
+	}
+
+	return plaintext, nil
+}
+
+var (
+	encryptionKeysMutex lock.RWMutex
+
+	// encryptionKeys are the keys used by all clients to encrypt values or
+	// nil if values are stored in plaintext
+	encryptionKeys *EncryptionKeys
+)
+
+// SetEncryptionKeys enables the encryption of values with keys. If keys is
+// nil, values are stored in plaintext. The keys apply to all clients created
+// afterwards and must therefore be set before Setup() is called.
+func SetEncryptionKeys(keys *EncryptionKeys) {
+	encryptionKeysMutex.Lock()
+	encryptionKeys = keys
+	encryptionKeysMutex.Unlock()
+}
+
+// getEncryptionKeys returns the configured encryption keys or nil
+func getEncryptionKeys() *EncryptionKeys {
+	encryptionKeysMutex.RLock()
+	defer encryptionKeysMutex.RUnlock()
+	return encryptionKeys
+}
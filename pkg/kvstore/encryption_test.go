@@ -0,0 +1,267 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cilium/cilium/common"
+	"github.com/cilium/cilium/common/types"
+
+	. "gopkg.in/check.v1"
+)
+
+// testEncryptionKey returns an encryption key entry with a key consisting
+// of the byte b
+func testEncryptionKey(id string, b byte) string {
+	key := bytes.Repeat([]byte{b}, encryptionKeyLen)
+	return fmt.Sprintf("%s:%s\n", id, base64.StdEncoding.EncodeToString(key))
+}
+
+func mustParseEncryptionKeys(c *C, data string) *EncryptionKeys {
+	keys, err := ParseEncryptionKeys([]byte(data))
+	c.Assert(err, IsNil)
+	return keys
+}
+
+func (s *independentSuite) TestParseEncryptionKeys(c *C) {
+	keys := mustParseEncryptionKeys(c, "# comment\n\n"+testEncryptionKey("key-2", 2)+testEncryptionKey("key-1", 1))
+	c.Assert(keys.PrimaryKeyID(), Equals, "key-2")
+	c.Assert(len(keys.keys), Equals, 2)
+
+	for _, data := range []string{
+		"",
+		"# no keys\n",
+		"key-1\n",
+		"key/1:" + base64.StdEncoding.EncodeToString(make([]byte, encryptionKeyLen)),
+		"key-1:invalid-base64!",
+		"key-1:" + base64.StdEncoding.EncodeToString(make([]byte, 16)),
+		testEncryptionKey("key-1", 1) + testEncryptionKey("key-1", 2),
+	} {
+		_, err := ParseEncryptionKeys([]byte(data))
+		c.Assert(err, Not(IsNil), Commentf("%q", data))
+	}
+}
+
+func (s *independentSuite) TestLoadEncryptionKeys(c *C) {
+	dir, err := ioutil.TempDir("", "kvstore-encryption")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "keys")
+	c.Assert(ioutil.WriteFile(path, []byte(testEncryptionKey("key-1", 1)), 0600), IsNil)
+
+	keys, err := LoadEncryptionKeys(path)
+	c.Assert(err, IsNil)
+	c.Assert(keys.PrimaryKeyID(), Equals, "key-1")
+
+	_, err = LoadEncryptionKeys(filepath.Join(dir, "missing"))
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *independentSuite) TestEncryptDecrypt(c *C) {
+	keys := mustParseEncryptionKeys(c, testEncryptionKey("key-1", 1))
+
+	value := []byte("k8s:io.kubernetes.pod.namespace=default")
+	ciphertext, err := keys.Encrypt(value)
+	c.Assert(err, IsNil)
+	c.Assert(IsEncrypted(ciphertext), Equals, true)
+	c.Assert(bytes.Contains(ciphertext, value), Equals, false)
+
+	// Each value is encrypted with a new data key
+	ciphertext2, err := keys.Encrypt(value)
+	c.Assert(err, IsNil)
+	c.Assert(ciphertext2, Not(DeepEquals), ciphertext)
+
+	plaintext, err := keys.Decrypt(ciphertext)
+	c.Assert(err, IsNil)
+	c.Assert(plaintext, DeepEquals, value)
+
+	// Values stored in plaintext are returned unmodified
+	plaintext, err = keys.Decrypt(value)
+	c.Assert(err, IsNil)
+	c.Assert(plaintext, DeepEquals, value)
+
+	// Tampered values are rejected
+	tampered := append([]byte{}, ciphertext...)
+	tampered[len(tampered)-1] ^= 0xff
+	_, err = keys.Decrypt(tampered)
+	c.Assert(err, Not(IsNil))
+
+	_, err = keys.Decrypt(ciphertext[:len(encryptedValuePrefix)+10])
+	c.Assert(err, Not(IsNil))
+
+	// A key with the same id but different key material cannot decrypt
+	_, err = mustParseEncryptionKeys(c, testEncryptionKey("key-1", 2)).Decrypt(ciphertext)
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *independentSuite) TestEncryptionKeyRotation(c *C) {
+	oldKeys := mustParseEncryptionKeys(c, testEncryptionKey("key-1", 1))
+	ciphertext1, err := oldKeys.Encrypt([]byte("old"))
+	c.Assert(err, IsNil)
+
+	// The new key is added as the primary key, the old key remains
+	// available for decryption
+	rotatedKeys := mustParseEncryptionKeys(c, testEncryptionKey("key-2", 2)+testEncryptionKey("key-1", 1))
+	ciphertext2, err := rotatedKeys.Encrypt([]byte("new"))
+	c.Assert(err, IsNil)
+
+	plaintext, err := rotatedKeys.Decrypt(ciphertext1)
+	c.Assert(err, IsNil)
+	c.Assert(string(plaintext), Equals, "old")
+
+	plaintext, err = rotatedKeys.Decrypt(ciphertext2)
+	c.Assert(err, IsNil)
+	c.Assert(string(plaintext), Equals, "new")
+
+	// Values encrypted with the new key cannot be read with the old keys
+	_, err = oldKeys.Decrypt(ciphertext2)
+	c.Assert(err, Not(IsNil))
+
+	// Once the old key is removed, values encrypted with it can no longer
+	// be read
+	newKeys := mustParseEncryptionKeys(c, testEncryptionKey("key-2", 2))
+	_, err = newKeys.Decrypt(ciphertext1)
+	c.Assert(err, Not(IsNil))
+}
+
+// EncryptedMemorySuite runs all base tests against the in-memory backend
+// with the encryption of values enabled
+type EncryptedMemorySuite struct {
+	BaseTests
+}
+
+var _ = Suite(&EncryptedMemorySuite{})
+
+func (s *EncryptedMemorySuite) SetUpTest(c *C) {
+	SetEncryptionKeys(mustParseEncryptionKeys(c, testEncryptionKey("key-1", 1)))
+	SetupDummy(MemoryBackendName)
+}
+
+func (s *EncryptedMemorySuite) TearDownTest(c *C) {
+	Close()
+	SetEncryptionKeys(nil)
+}
+
+func (s *EncryptedMemorySuite) TestValuesStoredEncrypted(c *C) {
+	prefix := "unit-test/"
+	DeletePrefix(prefix)
+	defer DeletePrefix(prefix)
+
+	backend := unwrapClient(Client())
+
+	c.Assert(Set(testKey(prefix, 0), testValue(0)), IsNil)
+	c.Assert(Update(testKey(prefix, 1), testValue(1), true), IsNil)
+	c.Assert(CreateOnly(testKey(prefix, 2), testValue(2), false), IsNil)
+	succeeded, err := Commit(NewTxn().Put(testKey(prefix, 3), testValue(3), false))
+	c.Assert(err, IsNil)
+	c.Assert(succeeded, Equals, true)
+
+	pairs, err := backend.ListPrefix(prefix)
+	c.Assert(err, IsNil)
+	c.Assert(len(pairs), Equals, 4)
+	for key, value := range pairs {
+		c.Assert(IsEncrypted(value), Equals, true, Commentf("%s", key))
+	}
+
+	pairs, err = ListPrefix(prefix)
+	c.Assert(err, IsNil)
+	for i := 0; i < 4; i++ {
+		c.Assert(pairs[testKey(prefix, i)], DeepEquals, testValue(i))
+	}
+}
+
+func (s *EncryptedMemorySuite) TestObsoleteValuesStoredEncrypted(c *C) {
+	prefix := "unit-test/"
+	DeletePrefix(prefix)
+	defer DeletePrefix(prefix)
+
+	backend := unwrapClient(Client())
+
+	addr := types.L3n4Addr{L4Addr: types.L4Addr{Protocol: types.TCP, Port: 80}}
+	c.Assert(Client().SetValue(testKey(prefix, 0), addr), IsNil)
+
+	addrID := &types.L3n4AddrID{L3n4Addr: addr}
+	c.Assert(Client().GASNewL3n4AddrID(prefix+"ids", 10, addrID), IsNil)
+	c.Assert(addrID.ID, Equals, types.ServiceID(10))
+
+	pairs, err := backend.ListPrefix(prefix)
+	c.Assert(err, IsNil)
+	c.Assert(len(pairs), Equals, 2)
+	for key, value := range pairs {
+		c.Assert(IsEncrypted(value), Equals, true, Commentf("%s", key))
+	}
+
+	maxID, err := backend.Get(common.LastFreeServiceIDKeyPath)
+	c.Assert(err, IsNil)
+	c.Assert(IsEncrypted(maxID), Equals, true)
+
+	id, err := Client().GetMaxID(common.LastFreeServiceIDKeyPath, common.FirstFreeServiceID)
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, uint32(11))
+
+	value, err := Client().GetValue(testKey(prefix, 0))
+	c.Assert(err, IsNil)
+	stored := types.L3n4Addr{}
+	c.Assert(json.Unmarshal(value, &stored), IsNil)
+	c.Assert(stored, DeepEquals, addr)
+
+	value, err = Client().GetValue(testKey(prefix, 1))
+	c.Assert(err, IsNil)
+	c.Assert(value, IsNil)
+}
+
+func (s *EncryptedMemorySuite) TestPlaintextAndUndecryptableValues(c *C) {
+	prefix := "unit-test/"
+	DeletePrefix(prefix)
+	defer DeletePrefix(prefix)
+
+	backend := unwrapClient(Client())
+
+	// Values written before the encryption was enabled remain readable
+	c.Assert(backend.Set(testKey(prefix, 0), testValue(0)), IsNil)
+
+	// Values encrypted with an unknown key are ignored when listing and
+	// watching
+	foreign, err := mustParseEncryptionKeys(c, testEncryptionKey("key-9", 9)).Encrypt(testValue(1))
+	c.Assert(err, IsNil)
+	c.Assert(backend.Set(testKey(prefix, 1), foreign), IsNil)
+
+	val, err := Get(testKey(prefix, 0))
+	c.Assert(err, IsNil)
+	c.Assert(val, DeepEquals, testValue(0))
+
+	_, err = Get(testKey(prefix, 1))
+	c.Assert(err, Not(IsNil))
+
+	pairs, err := ListPrefix(prefix)
+	c.Assert(err, IsNil)
+	c.Assert(pairs, DeepEquals, KeyValuePairs{testKey(prefix, 0): testValue(0)})
+
+	w := ListAndWatch("testWatcher", prefix, 100)
+	defer w.Stop()
+	expectEvent(c, w, EventTypeCreate, testKey(prefix, 0), testValue(0))
+	expectEvent(c, w, EventTypeListDone, "", nil)
+
+	c.Assert(Set(testKey(prefix, 2), testValue(2)), IsNil)
+	expectEvent(c, w, EventTypeCreate, testKey(prefix, 2), testValue(2))
+}
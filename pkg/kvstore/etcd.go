@@ -17,17 +17,12 @@ package kvstore
 import (
 	"encoding/json"
 	"fmt"
-	"path"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/cilium/cilium/common"
 	"github.com/cilium/cilium/common/types"
 	"github.com/cilium/cilium/pkg/controller"
 	"github.com/cilium/cilium/pkg/lock"
-	"github.com/cilium/cilium/pkg/logging/logfields"
 
 	client "github.com/coreos/etcd/clientv3"
 	"github.com/coreos/etcd/clientv3/concurrency"
@@ -39,10 +34,14 @@ import (
 )
 
 const (
-	etcdName = "etcd"
+	// EtcdBackendName is the backend name fo etcd
+	EtcdBackendName = "etcd"
 
 	addrOption = "etcd.address"
-	cfgOption  = "etcd.config"
+
+	// EtcdOptionConfig is the etcd option name to specify the path to the
+	// etcd configuration file
+	EtcdOptionConfig = "etcd.config"
 )
 
 type etcdModule struct {
@@ -68,27 +67,37 @@ var (
 	// statusCheckInterval is the interval in which the status is checked
 	statusCheckInterval = 5 * time.Second
 
+	// watchRetryInterval is the time waited before retrying a failed
+	// list or watch operation
+	watchRetryInterval = time.Second
+
 	minRequiredVersion, _ = version.NewConstraint(">= 3.1.0")
 
 	// etcdDummyAddress can be overwritten from test invokers using ldflags
 	etcdDummyAddress = "http://127.0.0.1:4002"
 
-	etcdInstance = &etcdModule{
+	etcdInstance = newEtcdModule()
+)
+
+func newEtcdModule() backendModule {
+	return &etcdModule{
 		opts: backendOptions{
 			addrOption: &backendOption{
 				description: "Addresses of etcd cluster",
 			},
-			cfgOption: &backendOption{
+			EtcdOptionConfig: &backendOption{
 				description: "Path to etcd configuration file",
 			},
 		},
 	}
+}
 
-	statusCheckerStarted sync.Once
-)
+func (e *etcdModule) createInstance() backendModule {
+	return newEtcdModule()
+}
 
 func (e *etcdModule) getName() string {
-	return etcdName
+	return EtcdBackendName
 }
 
 func (e *etcdModule) setConfigDummy() {
@@ -106,12 +115,12 @@ func (e *etcdModule) getConfig() map[string]string {
 
 func (e *etcdModule) newClient() (BackendOperations, error) {
 	endpointsOpt, endpointsSet := e.opts[addrOption]
-	configPathOpt, configSet := e.opts[cfgOption]
+	configPathOpt, configSet := e.opts[EtcdOptionConfig]
 	configPath := ""
 
 	if e.config == nil {
 		if !endpointsSet && !configSet {
-			return nil, fmt.Errorf("invalid etcd configuration, %s or %s must be specified", cfgOption, addrOption)
+			return nil, fmt.Errorf("invalid etcd configuration, %s or %s must be specified", EtcdOptionConfig, addrOption)
 		}
 
 		e.config = &client.Config{}
@@ -130,7 +139,7 @@ func (e *etcdModule) newClient() (BackendOperations, error) {
 
 func init() {
 	// register etcd module for use
-	registerBackend(etcdName, etcdInstance)
+	registerBackend(EtcdBackendName, etcdInstance)
 }
 
 type etcdClient struct {
@@ -141,6 +150,22 @@ type etcdClient struct {
 	session     *concurrency.Session
 	lockPathsMU lock.Mutex
 	lockPaths   map[string]*lock.Mutex
+
+	// controllers contains the controllers owned by this client
+	controllers *controller.Manager
+
+	// stopStatusChecker is closed when the client is closed to stop the
+	// status checker
+	stopStatusChecker chan struct{}
+
+	// statusLock protects latestStatusSnapshot and latestErrorStatus
+	statusLock lock.RWMutex
+
+	// latestStatusSnapshot is a snapshot of the latest etcd cluster status
+	latestStatusSnapshot string
+
+	// latestErrorStatus is the latest error condition of the etcd connection
+	latestErrorStatus error
 }
 
 type etcdMutex struct {
@@ -167,9 +192,12 @@ func (e *etcdClient) renewSession() error {
 
 	go e.checkMinVersion()
 
-	if err := renewDefaultLease(); err != nil {
-		e.client.Close()
-		return err
+	// Only the default client maintains the default lease
+	if defaultClient == e {
+		if err := renewDefaultLease(); err != nil {
+			e.client.Close()
+			return err
+		}
 	}
 
 	return nil
@@ -221,18 +249,19 @@ func newEtcdClient(config *client.Config, cfgPath string) (BackendOperations, er
 		return nil, fmt.Errorf("Unable to contact etcd: %s", err)
 	}
 	ec := &etcdClient{
-		client:    c,
-		session:   s,
-		lockPaths: map[string]*lock.Mutex{},
+		client:               c,
+		session:              s,
+		lockPaths:            map[string]*lock.Mutex{},
+		controllers:          controller.NewManager(),
+		stopStatusChecker:    make(chan struct{}),
+		latestStatusSnapshot: "No connection to etcd",
 	}
 
-	statusCheckerStarted.Do(func() {
-		go ec.statusChecker()
-	})
+	go ec.statusChecker()
 
 	go ec.checkMinVersion()
 
-	kvstoreControllers.UpdateController("kvstore-etcd-session-renew",
+	ec.controllers.UpdateController("kvstore-etcd-session-renew",
 		controller.ControllerParams{
 			DoFunc: func() error {
 				return ec.renewSession()
@@ -334,87 +363,17 @@ func (e *etcdClient) SetValue(k string, v interface{}) error {
 
 // FIXME: Obsolete, remove
 func (e *etcdClient) InitializeFreeID(path string, firstID uint32) error {
-	kvLocker, err := LockPath(path)
-	if err != nil {
-		return err
-	}
-	defer kvLocker.Unlock()
-
-	log.Debug("Trying to acquire free ID...")
-	k, err := e.GetValue(path)
-	if err != nil {
-		return err
-	}
-	if k != nil {
-		// FreeID already set
-		return nil
-	}
-	err = e.SetValue(path, firstID)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return initializeFreeID(e, path, firstID)
 }
 
 // FIXME: Obsolete, remove
 func (e *etcdClient) GetMaxID(key string, firstID uint32) (uint32, error) {
-	var (
-		attempts = 3
-		value    json.RawMessage
-		err      error
-		freeID   uint32
-	)
-	for {
-		switch value, err = e.GetValue(key); {
-		case attempts == 0:
-			err = fmt.Errorf("Unable to retrieve last free ID because key is always empty")
-			log.Error(err)
-			fallthrough
-		case err != nil:
-			return 0, err
-		case value == nil:
-			if err = e.InitializeFreeID(key, firstID); err != nil {
-				return 0, err
-			}
-			attempts--
-		case err == nil:
-			if err = json.Unmarshal(value, &freeID); err != nil {
-				return 0, err
-			}
-			return freeID, nil
-		}
-	}
+	return getMaxID(e, key, firstID)
 }
 
 // FIXME: Obsolete, remove
 func (e *etcdClient) SetMaxID(key string, firstID, maxID uint32) error {
-	value, err := e.GetValue(key)
-	if err != nil {
-		return err
-	}
-	if value == nil {
-		// FreeID is empty? We should set it out!
-		if err := e.InitializeFreeID(key, firstID); err != nil {
-			return err
-		}
-		k, err := e.GetValue(key)
-		if err != nil {
-			return err
-		}
-		if k == nil {
-			// Something is really wrong
-			errMsg := "Unable to set ID because the key is always empty"
-			log.Error(errMsg)
-			return fmt.Errorf("%s\n", errMsg)
-		}
-	}
-	return e.SetValue(key, maxID)
-}
-
-// FIXME: Obsolete, remove
-func (e *etcdClient) setMaxL3n4AddrID(maxID uint32) error {
-	return e.SetMaxID(common.LastFreeServiceIDKeyPath, common.FirstFreeServiceID, maxID)
+	return setMaxID(e, key, firstID, maxID)
 }
 
 // GASNewL3n4AddrID gets the next available ServiceID and sets it in lAddrID. After
@@ -423,60 +382,7 @@ func (e *etcdClient) setMaxL3n4AddrID(maxID uint32) error {
 //
 // FIXME: Obsolete, remove
 func (e *etcdClient) GASNewL3n4AddrID(basePath string, baseID uint32, lAddrID *types.L3n4AddrID) error {
-	setIDtoL3n4Addr := func(id uint32) error {
-		lAddrID.ID = types.ServiceID(id)
-		keyPath := path.Join(basePath, strconv.FormatUint(uint64(lAddrID.ID), 10))
-		if err := e.SetValue(keyPath, lAddrID); err != nil {
-			return err
-		}
-		return e.setMaxL3n4AddrID(id + 1)
-	}
-
-	acquireFreeID := func(firstID uint32, incID *uint32) (bool, error) {
-		keyPath := path.Join(basePath, strconv.FormatUint(uint64(*incID), 10))
-
-		locker, err := e.LockPath(getLockPath(keyPath))
-		if err != nil {
-			return false, err
-		}
-		defer locker.Unlock()
-
-		value, err := e.GetValue(keyPath)
-		if err != nil {
-			return false, err
-		}
-		if value == nil {
-			return false, setIDtoL3n4Addr(*incID)
-		}
-		var consulL3n4AddrID types.L3n4AddrID
-		if err := json.Unmarshal(value, &consulL3n4AddrID); err != nil {
-			return false, err
-		}
-		if consulL3n4AddrID.ID == 0 {
-			log.WithField(logfields.Identity, *incID).Info("Recycling Service ID")
-			return false, setIDtoL3n4Addr(*incID)
-		}
-
-		*incID++
-		if *incID > common.MaxSetOfServiceID {
-			*incID = common.FirstFreeServiceID
-		}
-		if firstID == *incID {
-			return false, fmt.Errorf("reached maximum set of serviceIDs available.")
-		}
-		// Only retry if we have incremented the service ID
-		return true, nil
-	}
-
-	beginning := baseID
-	for {
-		retry, err := acquireFreeID(beginning, &baseID)
-		if err != nil {
-			return err
-		} else if !retry {
-			return nil
-		}
-	}
+	return gasNewL3n4AddrID(e, basePath, baseID, lAddrID)
 }
 
 func (e *etcdClient) DeletePrefix(path string) error {
@@ -484,9 +390,20 @@ func (e *etcdClient) DeletePrefix(path string) error {
 	return err
 }
 
-// Watch starts watching for changes in a prefix
+// ListAndWatch implements the BackendOperations.ListAndWatch using etcd
+func (e *etcdClient) ListAndWatch(name, prefix string, chanSize int) *Watcher {
+	w := newWatcher(name, prefix, chanSize)
+
+	go e.Watch(w)
+
+	return w
+}
+
+// Watch starts watching for changes in a prefix. A watch which fails is
+// resumed from the last revision seen by the watcher. All keys are only
+// listed again if the revision has been compacted in the meantime, in which
+// case the difference to the previous list is emitted.
 func (e *etcdClient) Watch(w *Watcher) {
-	lastRev := int64(0)
 	localCache := watcherCache{}
 	listSignalSent := false
 
@@ -495,43 +412,55 @@ func (e *etcdClient) Watch(w *Watcher) {
 		fieldPrefix:  w.prefix,
 	})
 
+	// waitRetry waits before a failed operation is retried. It returns
+	// false and closes the events channel if the watcher was stopped.
+	waitRetry := func() bool {
+		select {
+		case <-time.After(watchRetryInterval):
+			return true
+		case <-w.stopWatch:
+			close(w.Events)
+			return false
+		}
+	}
+
 reList:
 	for {
 		res, err := e.client.Get(ctx.Background(), w.prefix, client.WithPrefix(),
-			client.WithRev(lastRev), client.WithSerializable())
+			client.WithSerializable())
 		if err != nil {
-			scopedLog.WithField(fieldRev, lastRev).WithError(err).Warn("Unable to list keys before starting watcher")
+			scopedLog.WithError(err).Warn("Unable to list keys before starting watcher")
+			if !waitRetry() {
+				return
+			}
 			continue
 		}
 
-		lastRev := res.Header.Revision
+		scopedLog.WithField(fieldRev, res.Header.Revision).Debugf("List response from etcd len=%d: %+v", res.Count, res)
 
-		scopedLog = scopedLog.WithField(fieldRev, lastRev)
-		scopedLog.Debugf("List response from etcd len=%d: %+v", res.Count, res)
+		for _, key := range res.Kvs {
+			// Keys which have not been modified since they were last
+			// seen are not emitted again when relisting
+			if !localCache.Changed(key.Key, uint64(key.ModRevision)) {
+				localCache.MarkInUse(key.Key, uint64(key.ModRevision))
+				continue
+			}
 
-		if res.Count > 0 {
-			for _, key := range res.Kvs {
-				t := EventTypeCreate
-				if localCache.Exists(key.Key) {
-					t = EventTypeModify
-				}
+			t := EventTypeCreate
+			if localCache.Exists(key.Key) {
+				t = EventTypeModify
+			}
 
-				localCache.MarkInUse(key.Key)
-				scopedLog.Debugf("Emitting list result as %v event for %s=%v", t, key.Key, key.Value)
+			localCache.MarkInUse(key.Key, uint64(key.ModRevision))
+			scopedLog.Debugf("Emitting list result as %v event for %s=%v", t, key.Key, key.Value)
 
-				w.Events <- KeyValueEvent{
-					Key:   string(key.Key),
-					Value: key.Value,
-					Typ:   t,
-				}
+			w.Events <- KeyValueEvent{
+				Key:   string(key.Key),
+				Value: key.Value,
+				Typ:   t,
 			}
 		}
 
-		// More keys to be read, call Get() again
-		if res.More {
-			continue
-		}
-
 		// Send out deletion events for all keys that were deleted
 		// between our last known revision and the latest revision
 		// received via Get
@@ -551,46 +480,58 @@ reList:
 			listSignalSent = true
 		}
 
+		w.setRevision(uint64(res.Header.Revision))
+
 	recreateWatcher:
-		lastRev++
+		nextRev := int64(w.LastRevision()) + 1
 
-		scopedLog.WithField(fieldRev, lastRev).Debug("Starting to watch a prefix")
-		etcdWatch := e.client.Watch(ctx.Background(), w.prefix,
-			client.WithPrefix(), client.WithRev(lastRev))
+		scopedLog.WithField(fieldRev, nextRev).Debug("Starting to watch a prefix")
+		watchCtx, cancel := ctx.WithCancel(ctx.Background())
+		etcdWatch := e.client.Watch(watchCtx, w.prefix,
+			client.WithPrefix(), client.WithRev(nextRev))
 		for {
 			select {
 			case <-w.stopWatch:
+				cancel()
 				close(w.Events)
 				return
 
 			case r, ok := <-etcdWatch:
 				if !ok {
+					cancel()
+					w.restarted(true)
+					if !waitRetry() {
+						return
+					}
 					goto recreateWatcher
 				}
 
 				scopedLog := scopedLog.WithField(fieldRev, r.Header.Revision)
 
 				if err := r.Err(); err != nil {
-					// We tried to watch on a compacted
-					// revision that may no longer exist,
-					// recreate the watcher and try to
-					// watch on the next possible revision
-					if err == v3rpcErrors.ErrCompacted {
-						scopedLog.WithError(err).Debug("Tried watching on compacted revision")
+					cancel()
+
+					// The revision to resume from has been
+					// compacted, the events in between are
+					// lost. Mark all local keys for deletion
+					// unless the upcoming GET marks them alive.
+					if err == v3rpcErrors.ErrCompacted || r.CompactRevision != 0 {
+						scopedLog.WithError(err).WithField(fieldCompactRev, r.CompactRevision).
+							Info("Tried watching on compacted revision, listing all keys again")
+						localCache.MarkAllForDeletion()
+						w.restarted(false)
+						continue reList
 					}
 
-					// Retrieve latest revision
-					lastRev = 0
+					scopedLog.WithError(err).Warn("Watcher failed, resuming from last seen revision")
+					w.restarted(true)
 
-					// mark all local keys in state for
-					// deletion unless the upcoming GET
-					// marks them alive
-					localCache.MarkAllForDeletion()
-
-					continue reList
+					if !waitRetry() {
+						return
+					}
+					goto recreateWatcher
 				}
 
-				lastRev = r.Header.Revision
 				scopedLog.Debugf("Received event from etcd: %+v", r)
 
 				for _, ev := range r.Events {
@@ -605,32 +546,23 @@ reList:
 						localCache.RemoveKey(ev.Kv.Key)
 					case ev.IsCreate():
 						event.Typ = EventTypeCreate
-						localCache.MarkInUse(ev.Kv.Key)
+						localCache.MarkInUse(ev.Kv.Key, uint64(ev.Kv.ModRevision))
 					default:
 						event.Typ = EventTypeModify
-						localCache.MarkInUse(ev.Kv.Key)
+						localCache.MarkInUse(ev.Kv.Key, uint64(ev.Kv.ModRevision))
 					}
 
 					scopedLog.Debugf("Emitting %v event for %s=%v", event.Typ, event.Key, event.Value)
 
 					w.Events <- event
 				}
+
+				w.setRevision(uint64(r.Header.Revision))
 			}
 		}
 	}
 }
 
-var (
-	// latestStatusSnapshot is a snapshot of the latest etcd cluster status
-	latestStatusSnapshot = "No connection to etcd"
-
-	// latestErrorStatus is the latest error condition of the etcd connection
-	latestErrorStatus error
-
-	// statusLock protects latestStatusSnapshot for read/write acess
-	statusLock lock.RWMutex
-)
-
 func (e *etcdClient) determineEndpointStatus(endpointAddress string) (string, error) {
 	ctxTimeout, cancel := ctx.WithTimeout(ctx.Background(), statusCheckTimeout)
 	defer cancel()
@@ -667,27 +599,31 @@ func (e *etcdClient) statusChecker() error {
 			newStatus = append(newStatus, st)
 		}
 
-		statusLock.Lock()
-		latestStatusSnapshot = fmt.Sprintf("etcd: %d/%d connected: %s", ok, len(endpoints), strings.Join(newStatus, "; "))
+		e.statusLock.Lock()
+		e.latestStatusSnapshot = fmt.Sprintf("etcd: %d/%d connected: %s", ok, len(endpoints), strings.Join(newStatus, "; "))
 
 		// Only mark the etcd health as unstable if no etcd endpoints can be reached
 		if len(endpoints) > 0 && ok == 0 {
-			latestErrorStatus = fmt.Errorf("Not able to connect to any etcd endpoints")
+			e.latestErrorStatus = fmt.Errorf("Not able to connect to any etcd endpoints")
 		} else {
-			latestErrorStatus = nil
+			e.latestErrorStatus = nil
 		}
 
-		statusLock.Unlock()
+		e.statusLock.Unlock()
 
-		time.Sleep(statusCheckInterval)
+		select {
+		case <-e.stopStatusChecker:
+			return nil
+		case <-time.After(statusCheckInterval):
+		}
 	}
 }
 
 func (e *etcdClient) Status() (string, error) {
-	statusLock.RLock()
-	defer statusLock.RUnlock()
+	e.statusLock.RLock()
+	defer e.statusLock.RUnlock()
 
-	return latestStatusSnapshot, latestErrorStatus
+	return e.latestStatusSnapshot, e.latestErrorStatus
 }
 
 // Get returns value of key
@@ -797,6 +733,52 @@ func (e *etcdClient) CreateIfExists(condKey, key string, value []byte, lease boo
 	return nil
 }
 
+// Commit performs a transaction using a native etcd transaction
+func (e *etcdClient) Commit(txn *Txn) (bool, error) {
+	cmps := make([]client.Cmp, 0, len(txn.Compares))
+	for _, c := range txn.Compares {
+		switch c.Typ {
+		case TxnCompareMissing:
+			cmps = append(cmps, client.Compare(client.Version(c.Key), "=", 0))
+		case TxnCompareExists:
+			cmps = append(cmps, client.Compare(client.Version(c.Key), "!=", 0))
+		case TxnCompareValue:
+			cmps = append(cmps, client.Compare(client.Value(c.Key), "=", string(c.Value)))
+		default:
+			return false, fmt.Errorf("unknown comparison type %d", c.Typ)
+		}
+	}
+
+	ops := make([]client.Op, 0, len(txn.Ops))
+	for _, o := range txn.Ops {
+		switch o.Typ {
+		case TxnOpPut:
+			lease, ok := o.lease()
+			if !ok {
+				ops = append(ops, client.OpPut(o.Key, string(o.Value)))
+				break
+			}
+
+			r, ok := lease.(*client.LeaseGrantResponse)
+			if !ok {
+				return false, fmt.Errorf("argument not a LeaseID")
+			}
+			ops = append(ops, client.OpPut(o.Key, string(o.Value), client.WithLease(r.ID)))
+		case TxnOpDelete:
+			ops = append(ops, client.OpDelete(o.Key))
+		default:
+			return false, fmt.Errorf("unknown operation type %d", o.Typ)
+		}
+	}
+
+	txnresp, err := e.client.Txn(ctx.TODO()).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return txnresp.Succeeded, nil
+}
+
 // FIXME: When we rebase to etcd 3.3
 //
 // DeleteOnZeroCount deletes the key if no matching keys for prefix exist
@@ -860,6 +842,8 @@ func (e *etcdClient) DeleteLease(lease interface{}) error {
 }
 
 func (e *etcdClient) closeClient() {
+	close(e.stopStatusChecker)
+	e.controllers.RemoveAll()
 	e.client.Close()
 }
 
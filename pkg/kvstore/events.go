@@ -14,6 +14,14 @@
 
 package kvstore
 
+import (
+	"sync/atomic"
+
+	"github.com/cilium/cilium/pkg/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
 // EventType defines the type of watch event that occurred
 type EventType int
 
@@ -76,6 +84,38 @@ type Watcher struct {
 	stopWatch stopChan
 
 	stopped bool
+
+	// revision is the last revision seen by the watcher. The meaning of
+	// the revision is backend specific, it is the revision of the last
+	// event for etcd and the index of the last blocking query for
+	// consul. It must be accessed atomically.
+	revision uint64
+}
+
+// LastRevision returns the last revision seen by the watcher. A watch
+// restarted after a disconnect resumes from this revision.
+func (w *Watcher) LastRevision() uint64 {
+	return atomic.LoadUint64(&w.revision)
+}
+
+// setRevision sets the last revision seen by the watcher
+func (w *Watcher) setRevision(revision uint64) {
+	atomic.StoreUint64(&w.revision, revision)
+}
+
+// restarted accounts for a restart of the watch. If resumed is false, all
+// keys had to be listed again.
+func (w *Watcher) restarted(resumed bool) {
+	typ := metrics.LabelValueWatchRelist
+	if resumed {
+		typ = metrics.LabelValueWatchResume
+	}
+
+	metrics.KVStoreWatchRestarts.WithLabelValues(w.name, typ).Inc()
+	log.WithFields(logrus.Fields{
+		fieldWatcher: w,
+		fieldRev:     w.LastRevision(),
+	}).Debugf("Restarting watcher (%s)", typ)
 }
 
 // String returns the name of the wather
@@ -93,6 +133,12 @@ func (w *Watcher) String() string {
 // Returns a watcher structure plus a channel that is closed when the initial
 // list operation has been completed
 func ListAndWatch(name, prefix string, chanSize int) *Watcher {
+	return Client().ListAndWatch(name, prefix, chanSize)
+}
+
+// newWatcher allocates a new watcher. The watcher must be started by the
+// backend by passing it to Watch()
+func newWatcher(name, prefix string, chanSize int) *Watcher {
 	w := &Watcher{
 		name:      name,
 		prefix:    prefix,
@@ -102,8 +148,6 @@ func ListAndWatch(name, prefix string, chanSize int) *Watcher {
 
 	log.WithField(fieldWatcher, w).Debug("Starting watcher...")
 
-	go Client().Watch(w)
-
 	return w
 }
 
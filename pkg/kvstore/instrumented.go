@@ -0,0 +1,371 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/metrics"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// Prefix labels of the kvstore operation metrics
+	prefixLabelAllocator = "allocator"
+	prefixLabelIPCache   = "ipcache"
+	prefixLabelLocks     = "locks"
+	prefixLabelOther     = "other"
+)
+
+var (
+	allocatorKeyPrefix = path.Join(BaseKeyPrefix, "state", "identities") + "/"
+	ipcacheKeyPrefix   = path.Join(BaseKeyPrefix, "state", "ip") + "/"
+)
+
+// prefixLabel returns the prefix label of key used to tag metrics
+func prefixLabel(key string) string {
+	switch {
+	case strings.HasSuffix(key, ".lock") || strings.Contains(key, "/locks/"):
+		return prefixLabelLocks
+	case strings.HasPrefix(key, allocatorKeyPrefix):
+		return prefixLabelAllocator
+	case strings.HasPrefix(key, ipcacheKeyPrefix):
+		return prefixLabelIPCache
+	default:
+		return prefixLabelOther
+	}
+}
+
+// OperationStatistics is the summary of all operations of an operation
+// class performed by a client
+type OperationStatistics struct {
+	// Class is the operation class
+	Class OperationClass
+
+	// Count is the number of operations performed
+	Count uint64
+
+	// Errors is the number of operations which failed
+	Errors uint64
+
+	// Throttled is the number of operations delayed by the rate limit
+	Throttled uint64
+
+	// Bytes is the number of bytes read or written
+	Bytes uint64
+
+	// Duration is the accumulated duration of all operations
+	Duration time.Duration
+
+	// RateLimit is the rate limit of the operation class or nil if the
+	// operation class is not limited
+	RateLimit *RateLimit
+}
+
+// MeanLatency returns the mean duration of an operation
+func (s *OperationStatistics) MeanLatency() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Duration / time.Duration(s.Count)
+}
+
+// instrumentedClient wraps a backend client, applies the client-side rate
+// limits and accounts for all operations in the kvstore metrics
+type instrumentedClient struct {
+	BackendOperations
+
+	limiters map[OperationClass]*rate.Limiter
+
+	mutex lock.Mutex
+	stats map[OperationClass]*OperationStatistics
+}
+
+func newInstrumentedClient(c BackendOperations) *instrumentedClient {
+	i := &instrumentedClient{
+		BackendOperations: c,
+		limiters:          newRateLimiters(),
+		stats:             map[OperationClass]*OperationStatistics{},
+	}
+
+	for _, class := range OperationClasses {
+		s := &OperationStatistics{Class: class}
+		if r, ok := GetRateLimit(class); ok {
+			s.RateLimit = &r
+		}
+		i.stats[class] = s
+	}
+
+	return i
+}
+
+// unwrapClient returns the backend client wrapped by c
+func unwrapClient(c BackendOperations) BackendOperations {
+	for {
+		switch w := c.(type) {
+		case *instrumentedClient:
+			c = w.BackendOperations
+		case *encryptedClient:
+			c = w.BackendOperations
+		default:
+			return c
+		}
+	}
+}
+
+// statistics returns a copy of the statistics of all operation classes
+func (i *instrumentedClient) statistics() []OperationStatistics {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	stats := make([]OperationStatistics, 0, len(OperationClasses))
+	for _, class := range OperationClasses {
+		stats = append(stats, *i.stats[class])
+	}
+	return stats
+}
+
+// throttle waits until the rate limit of the operation class allows another
+// operation
+func (i *instrumentedClient) throttle(class OperationClass) {
+	delay := i.limiters[class].Reserve().Delay()
+	if delay <= 0 {
+		return
+	}
+
+	metrics.KVStoreOperationsThrottled.WithLabelValues(string(class)).Inc()
+	i.mutex.Lock()
+	i.stats[class].Throttled++
+	i.mutex.Unlock()
+
+	time.Sleep(delay)
+}
+
+// do performs the operation f subject to the rate limit of class. f must
+// return the number of bytes read or written.
+func (i *instrumentedClient) do(class OperationClass, operation, key string, f func() (int, error)) {
+	i.throttle(class)
+
+	start := time.Now()
+	n, err := f()
+	duration := time.Since(start)
+
+	prefix := prefixLabel(key)
+	metrics.KVStoreOperationsDuration.WithLabelValues(operation, prefix).Observe(duration.Seconds())
+	metrics.KVStoreOperationsBytes.WithLabelValues(operation, prefix).Observe(float64(n))
+	if err != nil {
+		metrics.KVStoreOperationsErrors.WithLabelValues(operation, prefix).Inc()
+	}
+
+	i.mutex.Lock()
+	s := i.stats[class]
+	s.Count++
+	s.Bytes += uint64(n)
+	s.Duration += duration
+	if err != nil {
+		s.Errors++
+	}
+	i.mutex.Unlock()
+}
+
+func (i *instrumentedClient) GetValue(k string) (v json.RawMessage, err error) {
+	i.do(OperationClassRead, "GetValue", k, func() (int, error) {
+		v, err = i.BackendOperations.GetValue(k)
+		return len(v), err
+	})
+	return
+}
+
+func (i *instrumentedClient) SetValue(k string, v interface{}) (err error) {
+	i.do(OperationClassWrite, "SetValue", k, func() (int, error) {
+		err = i.BackendOperations.SetValue(k, v)
+		return 0, err
+	})
+	return
+}
+
+func (i *instrumentedClient) InitializeFreeID(path string, firstID uint32) (err error) {
+	i.do(OperationClassWrite, "InitializeFreeID", path, func() (int, error) {
+		err = i.BackendOperations.InitializeFreeID(path, firstID)
+		return 0, err
+	})
+	return
+}
+
+func (i *instrumentedClient) GetMaxID(key string, firstID uint32) (id uint32, err error) {
+	i.do(OperationClassRead, "GetMaxID", key, func() (int, error) {
+		id, err = i.BackendOperations.GetMaxID(key, firstID)
+		return 0, err
+	})
+	return
+}
+
+func (i *instrumentedClient) SetMaxID(key string, firstID, maxID uint32) (err error) {
+	i.do(OperationClassWrite, "SetMaxID", key, func() (int, error) {
+		err = i.BackendOperations.SetMaxID(key, firstID, maxID)
+		return 0, err
+	})
+	return
+}
+
+func (i *instrumentedClient) GASNewL3n4AddrID(basePath string, baseID uint32, lAddrID *types.L3n4AddrID) (err error) {
+	i.do(OperationClassWrite, "GASNewL3n4AddrID", basePath, func() (int, error) {
+		err = i.BackendOperations.GASNewL3n4AddrID(basePath, baseID, lAddrID)
+		return 0, err
+	})
+	return
+}
+
+func (i *instrumentedClient) LockPath(path string) (l kvLocker, err error) {
+	i.do(OperationClassLock, "LockPath", getLockPath(path), func() (int, error) {
+		l, err = i.BackendOperations.LockPath(path)
+		return 0, err
+	})
+	return
+}
+
+func (i *instrumentedClient) Get(key string) (v []byte, err error) {
+	i.do(OperationClassRead, "Get", key, func() (int, error) {
+		v, err = i.BackendOperations.Get(key)
+		return len(v), err
+	})
+	return
+}
+
+func (i *instrumentedClient) GetPrefix(prefix string) (v []byte, err error) {
+	i.do(OperationClassRead, "GetPrefix", prefix, func() (int, error) {
+		v, err = i.BackendOperations.GetPrefix(prefix)
+		return len(v), err
+	})
+	return
+}
+
+func (i *instrumentedClient) Set(key string, value []byte) (err error) {
+	i.do(OperationClassWrite, "Set", key, func() (int, error) {
+		err = i.BackendOperations.Set(key, value)
+		return len(value), err
+	})
+	return
+}
+
+func (i *instrumentedClient) Delete(key string) (err error) {
+	i.do(OperationClassWrite, "Delete", key, func() (int, error) {
+		err = i.BackendOperations.Delete(key)
+		return 0, err
+	})
+	return
+}
+
+func (i *instrumentedClient) DeletePrefix(path string) (err error) {
+	i.do(OperationClassWrite, "DeletePrefix", path, func() (int, error) {
+		err = i.BackendOperations.DeletePrefix(path)
+		return 0, err
+	})
+	return
+}
+
+func (i *instrumentedClient) Update(key string, value []byte, lease bool) (err error) {
+	i.do(OperationClassWrite, "Update", key, func() (int, error) {
+		err = i.BackendOperations.Update(key, value, lease)
+		return len(value), err
+	})
+	return
+}
+
+func (i *instrumentedClient) CreateOnly(key string, value []byte, lease bool) (err error) {
+	i.do(OperationClassWrite, "CreateOnly", key, func() (int, error) {
+		err = i.BackendOperations.CreateOnly(key, value, lease)
+		return len(value), err
+	})
+	return
+}
+
+func (i *instrumentedClient) CreateIfExists(condKey, key string, value []byte, lease bool) (err error) {
+	i.do(OperationClassWrite, "CreateIfExists", key, func() (int, error) {
+		err = i.BackendOperations.CreateIfExists(condKey, key, value, lease)
+		return len(value), err
+	})
+	return
+}
+
+func (i *instrumentedClient) Commit(txn *Txn) (succeeded bool, err error) {
+	// The prefix of a transaction is derived from the first key
+	// modified
+	key, n := "", 0
+	for _, op := range txn.Ops {
+		if key == "" {
+			key = op.Key
+		}
+		n += len(op.Value)
+	}
+
+	i.do(OperationClassWrite, "Commit", key, func() (int, error) {
+		succeeded, err = i.BackendOperations.Commit(txn)
+		return n, err
+	})
+	return
+}
+
+func (i *instrumentedClient) ListPrefix(prefix string) (v KeyValuePairs, err error) {
+	i.do(OperationClassRead, "ListPrefix", prefix, func() (int, error) {
+		v, err = i.BackendOperations.ListPrefix(prefix)
+
+		n := 0
+		for key, value := range v {
+			n += len(key) + len(value)
+		}
+		return n, err
+	})
+	return
+}
+
+func (i *instrumentedClient) CreateLease(ttl time.Duration) (lease interface{}, err error) {
+	i.do(OperationClassLease, "CreateLease", "", func() (int, error) {
+		lease, err = i.BackendOperations.CreateLease(ttl)
+		return 0, err
+	})
+	return
+}
+
+func (i *instrumentedClient) KeepAlive(lease interface{}) (err error) {
+	i.do(OperationClassLease, "KeepAlive", "", func() (int, error) {
+		err = i.BackendOperations.KeepAlive(lease)
+		return 0, err
+	})
+	return
+}
+
+func (i *instrumentedClient) DeleteLease(lease interface{}) (err error) {
+	i.do(OperationClassLease, "DeleteLease", "", func() (int, error) {
+		err = i.BackendOperations.DeleteLease(lease)
+		return 0, err
+	})
+	return
+}
+
+// GetOperationStatistics returns the statistics of all operation classes
+// performed by the default client
+func GetOperationStatistics() []OperationStatistics {
+	if i, ok := Client().(*instrumentedClient); ok {
+		return i.statistics()
+	}
+	return nil
+}
@@ -0,0 +1,497 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cilium/cilium/pkg/atomicfile"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// LocalBackendName is the name of the file-backed local backend
+	LocalBackendName = "local"
+
+	// LocalOptionPath is the local backend option name to specify the
+	// path to the database file
+	LocalOptionPath = "local.path"
+
+	// LocalDefaultPath is the default path to the database file of the
+	// local backend
+	LocalDefaultPath = "/var/lib/cilium/kvstore.db"
+
+	// localFormatVersion is the version of the on-disk format
+	localFormatVersion = 1
+
+	// localJournalSuffix is the suffix appended to the database path to
+	// form the path of the journal file
+	localJournalSuffix = ".journal"
+)
+
+var (
+	// localCompactionThreshold is the number of journal records after
+	// which the journal is compacted into a new snapshot
+	localCompactionThreshold = 1024
+
+	localInstance = newLocalModule()
+)
+
+// localModule is a kvstore backend for single node deployments which keeps
+// all keys in memory and persists them to a local database file.
+//
+// Modifications are appended to a journal file and synced to disk before they
+// become visible. The journal is periodically compacted into a snapshot which
+// is replaced atomically. A partially written journal record, e.g. caused by
+// a crash, is detected via its checksum and discarded on load.
+//
+// Leases are tied to the lifetime of the agent. Leases restored from disk are
+// not kept alive by anybody and thus expire after their TTL unless the keys
+// attached to them have been re-created with a new lease by then. This
+// allows the agent to restart without losing state.
+type localModule struct {
+	opts backendOptions
+}
+
+func newLocalModule() backendModule {
+	return &localModule{
+		opts: backendOptions{
+			LocalOptionPath: &backendOption{
+				description: "Path to the database file",
+			},
+		},
+	}
+}
+
+func (l *localModule) createInstance() backendModule {
+	return newLocalModule()
+}
+
+func (l *localModule) getName() string {
+	return LocalBackendName
+}
+
+func (l *localModule) setConfigDummy() {
+	dir, err := ioutil.TempDir("", "cilium-kvstore-local")
+	if err != nil {
+		log.WithError(err).Panic("Unable to create temporary directory")
+	}
+
+	l.opts[LocalOptionPath].value = filepath.Join(dir, "kvstore.db")
+}
+
+func (l *localModule) setConfig(opts map[string]string) error {
+	return setOpts(opts, l.opts)
+}
+
+func (l *localModule) getConfig() map[string]string {
+	return getOpts(l.opts)
+}
+
+func (l *localModule) newClient() (BackendOperations, error) {
+	path := l.opts[LocalOptionPath].value
+	if path == "" {
+		path = LocalDefaultPath
+	}
+
+	return newLocalClient(path)
+}
+
+func init() {
+	// register local backend with kvstore module
+	registerBackend(LocalBackendName, localInstance)
+}
+
+// newLocalClient returns an in-memory client which has been populated with
+// the state stored in the database at path and which persists all
+// modifications to it
+func newLocalClient(path string) (*memoryClient, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("unable to create directory for %s: %s", path, err)
+	}
+
+	c := newMemoryClient()
+	c.description = fmt.Sprintf("Local database %s", path)
+
+	s := &localStore{
+		path:   path,
+		client: c,
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := s.load(); err != nil {
+		c.closeClient()
+		return nil, err
+	}
+
+	// Start with a clean snapshot and an empty journal. This also drops
+	// any partially written journal record.
+	if err := s.compact(); err != nil {
+		c.closeClient()
+		return nil, err
+	}
+
+	c.persister = s
+
+	return c, nil
+}
+
+// localSnapshot is the on-disk representation of the complete state
+type localSnapshot struct {
+	Version   int                      `json:"version"`
+	Revision  uint64                   `json:"revision"`
+	NextLease int64                    `json:"next-lease"`
+	Leases    map[string]time.Duration `json:"leases"`
+	Keys      map[string]localKey      `json:"keys"`
+}
+
+// localKey is the on-disk representation of a key
+type localKey struct {
+	Value []byte `json:"value"`
+	Lease int64  `json:"lease,omitempty"`
+}
+
+const (
+	localOpPut         = "put"
+	localOpDelete      = "delete"
+	localOpCreateLease = "create-lease"
+	localOpDeleteLease = "delete-lease"
+	localOpTxn         = "txn"
+)
+
+// localRecord is a single modification stored in the journal
+type localRecord struct {
+	Revision uint64        `json:"revision"`
+	Op       string        `json:"op"`
+	Key      string        `json:"key,omitempty"`
+	Value    []byte        `json:"value,omitempty"`
+	Lease    int64         `json:"lease,omitempty"`
+	TTL      time.Duration `json:"ttl,omitempty"`
+
+	// Ops is the list of modifications of a transaction
+	Ops []localRecord `json:"ops,omitempty"`
+}
+
+// localStore implements memoryPersister by writing to a journal file and
+// periodic snapshots. All functions must be called with the client mutex
+// held.
+type localStore struct {
+	path   string
+	client *memoryClient
+
+	// journal is the open journal file
+	journal *os.File
+
+	// revision is the revision of the last record written
+	revision uint64
+
+	// numRecords is the number of records in the journal
+	numRecords int
+
+	// size is the size of the journal including the last record which
+	// has been synced successfully
+	size int64
+}
+
+func (s *localStore) journalPath() string {
+	return s.path + localJournalSuffix
+}
+
+// applyRecord applies a journal record to the client state
+func (s *localStore) applyRecord(r *localRecord) error {
+	c := s.client
+
+	switch r.Op {
+	case localOpPut:
+		c.kvs[r.Key] = &memoryEntry{value: r.Value, lease: r.Lease}
+	case localOpDelete:
+		delete(c.kvs, r.Key)
+	case localOpCreateLease:
+		c.leases[r.Lease] = &memoryLeaseEntry{ttl: r.TTL}
+		if r.Lease >= c.nextLease {
+			c.nextLease = r.Lease + 1
+		}
+	case localOpDeleteLease:
+		delete(c.leases, r.Lease)
+	case localOpTxn:
+		for i := range r.Ops {
+			if err := s.applyRecord(&r.Ops[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", r.Op)
+	}
+
+	return nil
+}
+
+// load reads the snapshot and replays the journal into the client
+func (s *localStore) load() error {
+	c := s.client
+	scopedLog := log.WithField(fieldPath, s.path)
+
+	snapshot := localSnapshot{}
+	data, err := ioutil.ReadFile(s.path)
+	switch {
+	case os.IsNotExist(err):
+		scopedLog.Info("Creating new local kvstore database")
+	case err != nil:
+		return fmt.Errorf("unable to read %s: %s", s.path, err)
+	default:
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return fmt.Errorf("unable to parse %s: %s", s.path, err)
+		}
+
+		if snapshot.Version != localFormatVersion {
+			return fmt.Errorf("unsupported version %d of %s", snapshot.Version, s.path)
+		}
+
+		s.revision = snapshot.Revision
+		if snapshot.NextLease > c.nextLease {
+			c.nextLease = snapshot.NextLease
+		}
+
+		for idStr, ttl := range snapshot.Leases {
+			id, err := strconv.ParseInt(idStr, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid lease %q in %s", idStr, s.path)
+			}
+			c.leases[id] = &memoryLeaseEntry{ttl: ttl}
+		}
+
+		for key, k := range snapshot.Keys {
+			c.kvs[key] = &memoryEntry{value: k.Value, lease: k.Lease}
+		}
+	}
+
+	journal, err := os.Open(s.journalPath())
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("unable to open journal: %s", err)
+	default:
+		defer journal.Close()
+
+		scanner := bufio.NewScanner(journal)
+		scanner.Buffer(nil, 64*1024*1024)
+		for scanner.Scan() {
+			r, err := parseLocalRecord(scanner.Bytes())
+			if err != nil {
+				// Records are synced one after another,
+				// everything following an invalid record
+				// has never been acknowledged
+				scopedLog.WithError(err).Warning("Discarding incomplete journal record")
+				break
+			}
+
+			// Records may already be contained in the snapshot
+			// if a crash occurred during compaction
+			if r.Revision <= s.revision {
+				continue
+			}
+
+			if err := s.applyRecord(r); err != nil {
+				return err
+			}
+			s.revision = r.Revision
+		}
+	}
+
+	// Restored leases expire unless the keys are re-created
+	now := time.Now()
+	for _, lease := range c.leases {
+		lease.expires = now.Add(lease.ttl)
+	}
+
+	// Remove keys referring to leases which no longer exist
+	for key, entry := range c.kvs {
+		if _, ok := c.leases[entry.lease]; entry.lease != 0 && !ok {
+			delete(c.kvs, key)
+		}
+	}
+
+	scopedLog.WithFields(logrus.Fields{
+		fieldRev:        s.revision,
+		fieldNumEntries: len(c.kvs),
+	}).Info("Restored local kvstore database")
+
+	return nil
+}
+
+// parseLocalRecord parses a journal line of the form "<crc32> <json>"
+func parseLocalRecord(line []byte) (*localRecord, error) {
+	idx := bytes.IndexByte(line, ' ')
+	if idx < 0 {
+		return nil, fmt.Errorf("missing checksum")
+	}
+
+	sum, err := strconv.ParseUint(string(line[:idx]), 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum: %s", err)
+	}
+
+	data := line[idx+1:]
+	if crc32.ChecksumIEEE(data) != uint32(sum) {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+
+	r := &localRecord{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// compact writes a snapshot of the complete client state and truncates the
+// journal
+func (s *localStore) compact() error {
+	c := s.client
+
+	snapshot := localSnapshot{
+		Version:   localFormatVersion,
+		Revision:  s.revision,
+		NextLease: c.nextLease,
+		Leases:    make(map[string]time.Duration, len(c.leases)),
+		Keys:      make(map[string]localKey, len(c.kvs)),
+	}
+
+	for id, lease := range c.leases {
+		snapshot.Leases[strconv.FormatInt(id, 10)] = lease.ttl
+	}
+
+	for key, entry := range c.kvs {
+		snapshot.Keys[key] = localKey{Value: entry.value, Lease: entry.lease}
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	if err := atomicfile.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("unable to write snapshot: %s", err)
+	}
+
+	// All records of the journal are now part of the snapshot
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+
+	journal, err := os.OpenFile(s.journalPath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open journal: %s", err)
+	}
+
+	if err := journal.Sync(); err != nil {
+		journal.Close()
+		return fmt.Errorf("unable to sync journal: %s", err)
+	}
+
+	s.journal = journal
+	s.numRecords = 0
+	s.size = 0
+
+	return nil
+}
+
+// append writes a record to the journal and syncs it to disk
+func (s *localStore) append(r localRecord) error {
+	if s.journal == nil {
+		return fmt.Errorf("local kvstore database %s is closed", s.path)
+	}
+
+	if s.numRecords >= localCompactionThreshold {
+		if err := s.compact(); err != nil {
+			return err
+		}
+	}
+
+	r.Revision = s.revision + 1
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	line := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(data), data)
+	_, err = s.journal.WriteString(line)
+	if err == nil {
+		err = s.journal.Sync()
+	}
+
+	if err != nil {
+		// Remove the partially written record, records appended
+		// after it would otherwise be discarded on load
+		s.journal.Truncate(s.size)
+		return fmt.Errorf("unable to write to journal: %s", err)
+	}
+
+	s.revision = r.Revision
+	s.numRecords++
+	s.size += int64(len(line))
+
+	return nil
+}
+
+func (s *localStore) put(key string, entry *memoryEntry) error {
+	return s.append(localRecord{Op: localOpPut, Key: key, Value: entry.value, Lease: entry.lease})
+}
+
+func (s *localStore) delete(key string) error {
+	return s.append(localRecord{Op: localOpDelete, Key: key})
+}
+
+func (s *localStore) createLease(id int64, ttl time.Duration) error {
+	return s.append(localRecord{Op: localOpCreateLease, Lease: id, TTL: ttl})
+}
+
+func (s *localStore) deleteLease(id int64) error {
+	return s.append(localRecord{Op: localOpDeleteLease, Lease: id})
+}
+
+func (s *localStore) commit(changes []memoryChange) error {
+	r := localRecord{Op: localOpTxn}
+	for _, change := range changes {
+		if change.entry != nil {
+			r.Ops = append(r.Ops, localRecord{Op: localOpPut, Key: change.key, Value: change.entry.value, Lease: change.entry.lease})
+		} else {
+			r.Ops = append(r.Ops, localRecord{Op: localOpDelete, Key: change.key})
+		}
+	}
+
+	return s.append(r)
+}
+
+func (s *localStore) close() {
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+}
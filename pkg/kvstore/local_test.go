@@ -0,0 +1,210 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type LocalSuite struct {
+	BaseTests
+}
+
+var _ = Suite(&LocalSuite{})
+
+func (s *LocalSuite) SetUpTest(c *C) {
+	SetupDummy(LocalBackendName)
+}
+
+func (s *LocalSuite) TearDownTest(c *C) {
+	Close()
+}
+
+// localTestDB is a local database in a temporary directory
+type localTestDB struct {
+	dir  string
+	path string
+}
+
+func newLocalTestDB(c *C) *localTestDB {
+	dir, err := ioutil.TempDir("", "cilium-kvstore-local-test")
+	c.Assert(err, IsNil)
+
+	return &localTestDB{dir: dir, path: filepath.Join(dir, "kvstore.db")}
+}
+
+func (db *localTestDB) open(c *C) *memoryClient {
+	client, err := newLocalClient(db.path)
+	c.Assert(err, IsNil)
+	return client
+}
+
+func (db *localTestDB) remove() {
+	os.RemoveAll(db.dir)
+}
+
+func (s *LocalSuite) TestPersistence(c *C) {
+	db := newLocalTestDB(c)
+	defer db.remove()
+
+	client := db.open(c)
+	for i := 0; i < 10; i++ {
+		c.Assert(client.Set(testKey("unit-test/", i), testValue(i)), IsNil)
+	}
+	c.Assert(client.Delete(testKey("unit-test/", 5)), IsNil)
+	CloseClient(client)
+
+	client = db.open(c)
+	defer CloseClient(client)
+
+	for i := 0; i < 10; i++ {
+		v, err := client.Get(testKey("unit-test/", i))
+		c.Assert(err, IsNil)
+		if i == 5 {
+			c.Assert(v, IsNil)
+		} else {
+			c.Assert(v, DeepEquals, testValue(i))
+		}
+	}
+}
+
+func (s *LocalSuite) TestCompaction(c *C) {
+	oldThreshold := localCompactionThreshold
+	localCompactionThreshold = 4
+	defer func() { localCompactionThreshold = oldThreshold }()
+
+	db := newLocalTestDB(c)
+	defer db.remove()
+
+	client := db.open(c)
+	for i := 0; i < 10; i++ {
+		c.Assert(client.Set(testKey("unit-test/", i), testValue(i)), IsNil)
+	}
+	c.Assert(client.persister.(*localStore).numRecords <= localCompactionThreshold, Equals, true)
+	CloseClient(client)
+
+	client = db.open(c)
+	defer CloseClient(client)
+
+	pairs, err := client.ListPrefix("unit-test/")
+	c.Assert(err, IsNil)
+	c.Assert(len(pairs), Equals, 10)
+}
+
+func (s *LocalSuite) TestIncompleteRecord(c *C) {
+	db := newLocalTestDB(c)
+	defer db.remove()
+
+	client := db.open(c)
+	c.Assert(client.Set("foo", []byte("bar")), IsNil)
+	CloseClient(client)
+
+	// Simulate a crash while writing a record
+	f, err := os.OpenFile(db.path+localJournalSuffix, os.O_WRONLY|os.O_APPEND, 0600)
+	c.Assert(err, IsNil)
+	_, err = f.WriteString(`01234567 {"revision":10,"op":"put","key":"ba`)
+	c.Assert(err, IsNil)
+	f.Close()
+
+	client = db.open(c)
+	defer CloseClient(client)
+
+	v, err := client.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(v, DeepEquals, []byte("bar"))
+
+	pairs, err := client.ListPrefix("")
+	c.Assert(err, IsNil)
+	c.Assert(len(pairs), Equals, 1)
+
+	// The journal must be usable after discarding the record
+	c.Assert(client.Set("foo2", []byte("bar2")), IsNil)
+}
+
+func (s *LocalSuite) TestLeaseRestore(c *C) {
+	oldInterval := memoryLeaseCheckInterval
+	memoryLeaseCheckInterval = 10 * time.Millisecond
+	defer func() { memoryLeaseCheckInterval = oldInterval }()
+
+	db := newLocalTestDB(c)
+	defer db.remove()
+
+	client := db.open(c)
+	lease, err := client.CreateLease(500 * time.Millisecond)
+	c.Assert(err, IsNil)
+	c.Assert(client.KeepAlive(lease), IsNil)
+
+	client.mutex.Lock()
+	c.Assert(client.putLocked("leased", []byte("value"), lease.(*memoryLease).ID), IsNil)
+	c.Assert(client.putLocked("permanent", []byte("value"), 0), IsNil)
+	client.mutex.Unlock()
+	CloseClient(client)
+
+	client = db.open(c)
+	defer CloseClient(client)
+
+	// Leased keys survive the restart
+	v, err := client.Get("leased")
+	c.Assert(err, IsNil)
+	c.Assert(v, DeepEquals, []byte("value"))
+
+	// New leases do not collide with restored leases
+	newLease, err := client.CreateLease(time.Minute)
+	c.Assert(err, IsNil)
+	c.Assert(newLease.(*memoryLease).ID, Not(Equals), lease.(*memoryLease).ID)
+
+	// The restored lease is not kept alive and expires
+	for start := time.Now(); time.Since(start) < 5*time.Second; {
+		if v, _ := client.Get("leased"); v == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	v, err = client.Get("leased")
+	c.Assert(err, IsNil)
+	c.Assert(v, IsNil)
+
+	v, err = client.Get("permanent")
+	c.Assert(err, IsNil)
+	c.Assert(v, DeepEquals, []byte("value"))
+}
+
+func (s *LocalSuite) TestCommitPersistence(c *C) {
+	db := newLocalTestDB(c)
+	defer db.remove()
+
+	client := db.open(c)
+	c.Assert(client.Set("foo", []byte("bar")), IsNil)
+	succeeded, err := client.Commit(NewTxn().IfExists("foo").Delete("foo").Put("foo2", []byte("bar2"), false))
+	c.Assert(err, IsNil)
+	c.Assert(succeeded, Equals, true)
+
+	// The transaction is persisted as a single record
+	c.Assert(client.persister.(*localStore).numRecords, Equals, 2)
+	CloseClient(client)
+
+	client = db.open(c)
+	defer CloseClient(client)
+
+	pairs, err := client.ListPrefix("")
+	c.Assert(err, IsNil)
+	c.Assert(pairs, DeepEquals, KeyValuePairs{"foo2": []byte("bar2")})
+}
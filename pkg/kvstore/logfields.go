@@ -25,6 +25,19 @@ const (
 	// key revision
 	fieldRev = "revision"
 
+	// fieldCompactRev refers to the revision up to which the kvstore has
+	// been compacted
+	fieldCompactRev = "compactRevision"
+
+	// fieldElection is the name of a leader election
+	fieldElection = "election"
+
+	// fieldCandidate is the identity of a leader election candidate
+	fieldCandidate = "candidate"
+
+	// fieldLeader is the identity of the leader of an election
+	fieldLeader = "leader"
+
 	// fieldSession refers to a connection/session with the kvstore
 	fieldSession = "session"
 
@@ -54,4 +67,13 @@ const (
 
 	// fieldEtcdEndpoint is the etcd endpoint we talk to
 	fieldEtcdEndpoint = "etcdEndpoint"
+
+	// fieldOps is the list of operations of a transaction
+	fieldOps = "operations"
+
+	// fieldSucceeded is true if the conditions of a transaction were met
+	fieldSucceeded = "succeeded"
+
+	// fieldPath is the path to the database file of the local backend
+	fieldPath = "path"
 )
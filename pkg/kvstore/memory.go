@@ -0,0 +1,777 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/lock"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// MemoryBackendName is the name of the in-memory backend
+	MemoryBackendName = "memory"
+)
+
+var (
+	// memoryLeaseCheckInterval is the interval in which the in-memory
+	// backend checks for expired leases
+	memoryLeaseCheckInterval = time.Second
+
+	memoryInstance = newMemoryModule()
+)
+
+// memoryModule is an in-process kvstore backend. All state is kept in memory
+// and is lost when the client is closed. It is primarily intended for
+// testing of kvstore users without requiring an external kvstore.
+type memoryModule struct {
+	opts backendOptions
+}
+
+func newMemoryModule() backendModule {
+	return &memoryModule{
+		opts: backendOptions{},
+	}
+}
+
+func (m *memoryModule) createInstance() backendModule {
+	return newMemoryModule()
+}
+
+func (m *memoryModule) getName() string {
+	return MemoryBackendName
+}
+
+func (m *memoryModule) setConfigDummy() {}
+
+func (m *memoryModule) setConfig(opts map[string]string) error {
+	return setOpts(opts, m.opts)
+}
+
+func (m *memoryModule) getConfig() map[string]string {
+	return getOpts(m.opts)
+}
+
+func (m *memoryModule) newClient() (BackendOperations, error) {
+	return newMemoryClient(), nil
+}
+
+func init() {
+	// register in-memory backend with kvstore module
+	registerBackend(MemoryBackendName, memoryInstance)
+}
+
+// memoryEntry is a single key in the in-memory kvstore
+type memoryEntry struct {
+	value []byte
+
+	// lease is the ID of the lease the key is attached to or 0 if the key
+	// is not attached to any lease
+	lease int64
+}
+
+// memoryLease is the lease object returned by CreateLease() of the in-memory
+// backend
+type memoryLease struct {
+	ID  int64
+	TTL time.Duration
+}
+
+// memoryLeaseEntry is the state of a lease kept by the in-memory kvstore
+type memoryLeaseEntry struct {
+	ttl     time.Duration
+	expires time.Time
+}
+
+// memoryChange is a modification of a single key. A nil entry represents the
+// removal of the key.
+type memoryChange struct {
+	key   string
+	entry *memoryEntry
+}
+
+// memoryPersister is implemented by backends which persist the state of the
+// in-memory kvstore. All functions are called with the client mutex held
+// before the in-memory state is modified. If an error is returned, the
+// modification is not performed.
+type memoryPersister interface {
+	// put persists the creation or modification of a key
+	put(key string, entry *memoryEntry) error
+
+	// delete persists the removal of a key
+	delete(key string) error
+
+	// createLease persists the creation of a lease
+	createLease(id int64, ttl time.Duration) error
+
+	// deleteLease persists the removal of a lease. Removal of the keys
+	// attached to the lease is persisted separately via delete().
+	deleteLease(id int64) error
+
+	// commit persists all changes of a transaction atomically
+	commit(changes []memoryChange) error
+
+	// close releases all resources of the persister
+	close()
+}
+
+// memoryWatch is a watcher registered with the in-memory backend. Events are
+// queued in an unbounded queue so that modifications of the kvstore never
+// block on slow watchers.
+type memoryWatch struct {
+	watcher *Watcher
+	queue   []KeyValueEvent
+	notify  chan struct{}
+}
+
+func (w *memoryWatch) enqueue(event KeyValueEvent) {
+	w.queue = append(w.queue, event)
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+type memoryClient struct {
+	// description is the human readable description of the backend used
+	// in the status
+	description string
+
+	// persister, if set, persists all modifications
+	persister memoryPersister
+
+	// mutex protects all members below
+	mutex lock.Mutex
+
+	kvs map[string]*memoryEntry
+
+	leases    map[int64]*memoryLeaseEntry
+	nextLease int64
+
+	// locks maps lock paths to a channel which is used as mutex
+	locks map[string]chan struct{}
+
+	watches map[*memoryWatch]struct{}
+
+	// failAfter is the number of modifications after which all
+	// modifications fail. A negative value disables failure injection.
+	failAfter int
+
+	stop chan struct{}
+}
+
+func newMemoryClient() *memoryClient {
+	c := &memoryClient{
+		description: "In-memory",
+		kvs:         map[string]*memoryEntry{},
+		leases:      map[int64]*memoryLeaseEntry{},
+		nextLease:   1,
+		locks:       map[string]chan struct{}{},
+		watches:     map[*memoryWatch]struct{}{},
+		failAfter:   -1,
+		stop:        make(chan struct{}),
+	}
+
+	go c.leaseExpirer()
+
+	return c
+}
+
+// leaseExpirer periodically removes all expired leases including all keys
+// attached to them
+func (c *memoryClient) leaseExpirer() {
+	ticker := time.NewTicker(memoryLeaseCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case now := <-ticker.C:
+			c.mutex.Lock()
+			for id, lease := range c.leases {
+				if now.After(lease.expires) {
+					log.WithField(fieldLease, id).Debug("Lease expired")
+					if err := c.deleteLeaseLocked(id); err != nil {
+						log.WithError(err).WithField(fieldLease, id).Warning("Unable to remove expired lease")
+					}
+				}
+			}
+			c.mutex.Unlock()
+		}
+	}
+}
+
+// notifyLocked distributes an event to all watchers with a matching prefix,
+// each watcher receives a private copy of the value. c.mutex must be held.
+func (c *memoryClient) notifyLocked(event KeyValueEvent) {
+	for w := range c.watches {
+		if strings.HasPrefix(event.Key, w.watcher.prefix) {
+			w.enqueue(KeyValueEvent{Typ: event.Typ, Key: event.Key, Value: copyValue(event.Value)})
+		}
+	}
+}
+
+// errInjectedFailure is the error returned by modifications failed by
+// failure injection
+var errInjectedFailure = fmt.Errorf("injected failure")
+
+// checkFailureLocked returns an error if the modification must fail due to
+// failure injection. c.mutex must be held.
+func (c *memoryClient) checkFailureLocked() error {
+	switch {
+	case c.failAfter < 0:
+		return nil
+	case c.failAfter == 0:
+		return errInjectedFailure
+	default:
+		c.failAfter--
+		return nil
+	}
+}
+
+// copyValue returns a private copy of value so the stored values cannot be
+// modified by the callers providing or retrieving them
+func copyValue(value []byte) []byte {
+	v := make([]byte, len(value))
+	copy(v, value)
+	return v
+}
+
+// newMemoryEntry returns a new entry with a private copy of value
+func newMemoryEntry(value []byte, lease int64) *memoryEntry {
+	return &memoryEntry{value: copyValue(value), lease: lease}
+}
+
+// applyLocked applies a change to the in-memory state without persisting it
+// and notifies all watchers. c.mutex must be held.
+func (c *memoryClient) applyLocked(change memoryChange) {
+	old, exists := c.kvs[change.key]
+
+	switch {
+	case change.entry != nil:
+		typ := EventTypeCreate
+		if exists {
+			typ = EventTypeModify
+		}
+
+		c.kvs[change.key] = change.entry
+		c.notifyLocked(KeyValueEvent{Typ: typ, Key: change.key, Value: change.entry.value})
+	case exists:
+		delete(c.kvs, change.key)
+		c.notifyLocked(KeyValueEvent{Typ: EventTypeDelete, Key: change.key, Value: old.value})
+	}
+}
+
+// putLocked creates or modifies a key. c.mutex must be held.
+func (c *memoryClient) putLocked(key string, value []byte, lease int64) error {
+	if err := c.checkFailureLocked(); err != nil {
+		return err
+	}
+
+	entry := newMemoryEntry(value, lease)
+
+	if c.persister != nil {
+		if err := c.persister.put(key, entry); err != nil {
+			return err
+		}
+	}
+
+	c.applyLocked(memoryChange{key: key, entry: entry})
+
+	return nil
+}
+
+// deleteLocked removes a key. c.mutex must be held.
+func (c *memoryClient) deleteLocked(key string) error {
+	if _, ok := c.kvs[key]; !ok {
+		return nil
+	}
+
+	if err := c.checkFailureLocked(); err != nil {
+		return err
+	}
+
+	if c.persister != nil {
+		if err := c.persister.delete(key); err != nil {
+			return err
+		}
+	}
+
+	c.applyLocked(memoryChange{key: key})
+
+	return nil
+}
+
+// deleteLeaseLocked removes a lease and all keys attached to it. c.mutex must
+// be held.
+func (c *memoryClient) deleteLeaseLocked(id int64) error {
+	for _, key := range c.sortedKeysLocked("") {
+		if c.kvs[key].lease == id {
+			if err := c.deleteLocked(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.persister != nil {
+		if err := c.persister.deleteLease(id); err != nil {
+			return err
+		}
+	}
+
+	delete(c.leases, id)
+
+	return nil
+}
+
+// getLeaseLocked returns the ID of the default lease if lease is true. An
+// error is returned if the default lease is not a lease of this client.
+// c.mutex must be held.
+func (c *memoryClient) getLeaseLocked(lease bool) (int64, error) {
+	if !lease {
+		return 0, nil
+	}
+
+	return c.getLeaseIDLocked(leaseInstance)
+}
+
+// getLeaseIDLocked returns the ID of lease. An error is returned if lease is
+// not a lease of this client. c.mutex must be held.
+func (c *memoryClient) getLeaseIDLocked(lease interface{}) (int64, error) {
+	l, ok := lease.(*memoryLease)
+	if !ok {
+		return 0, fmt.Errorf("argument not a memory lease")
+	}
+
+	if _, ok := c.leases[l.ID]; !ok {
+		return 0, fmt.Errorf("lease %d not found", l.ID)
+	}
+
+	return l.ID, nil
+}
+
+// sortedKeysLocked returns all keys matching prefix in lexicographical order.
+// c.mutex must be held.
+func (c *memoryClient) sortedKeysLocked(prefix string) []string {
+	keys := []string{}
+	for key := range c.kvs {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// FIXME: Obsolete, remove
+func (c *memoryClient) GetValue(k string) (json.RawMessage, error) {
+	v, err := c.Get(k)
+	if v == nil || err != nil {
+		return nil, err
+	}
+	return json.RawMessage(v), nil
+}
+
+// FIXME: Obsolete, remove
+func (c *memoryClient) SetValue(k string, v interface{}) error {
+	vByte, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Set(k, vByte)
+}
+
+// FIXME: Obsolete, remove
+func (c *memoryClient) InitializeFreeID(path string, firstID uint32) error {
+	return initializeFreeID(c, path, firstID)
+}
+
+// FIXME: Obsolete, remove
+func (c *memoryClient) GetMaxID(key string, firstID uint32) (uint32, error) {
+	return getMaxID(c, key, firstID)
+}
+
+// FIXME: Obsolete, remove
+func (c *memoryClient) SetMaxID(key string, firstID, maxID uint32) error {
+	return setMaxID(c, key, firstID, maxID)
+}
+
+// FIXME: Obsolete, remove
+func (c *memoryClient) GASNewL3n4AddrID(basePath string, baseID uint32, lAddrID *types.L3n4AddrID) error {
+	return gasNewL3n4AddrID(c, basePath, baseID, lAddrID)
+}
+
+// Status returns the status of the in-memory kvstore
+func (c *memoryClient) Status() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return fmt.Sprintf("%s: %d keys, %d leases", c.description, len(c.kvs), len(c.leases)), nil
+}
+
+type memoryMutex struct {
+	ch chan struct{}
+}
+
+func (m *memoryMutex) Unlock() error {
+	select {
+	case <-m.ch:
+		return nil
+	default:
+		return fmt.Errorf("lock is not held")
+	}
+}
+
+// LockPath locks the provided path
+func (c *memoryClient) LockPath(path string) (kvLocker, error) {
+	c.mutex.Lock()
+	ch, ok := c.locks[path]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		c.locks[path] = ch
+	}
+	c.mutex.Unlock()
+
+	select {
+	case ch <- struct{}{}:
+		return &memoryMutex{ch: ch}, nil
+	case <-c.stop:
+		return nil, fmt.Errorf("client closed")
+	}
+}
+
+// Get returns value of key
+func (c *memoryClient) Get(key string) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if entry, ok := c.kvs[key]; ok {
+		return copyValue(entry.value), nil
+	}
+
+	return nil, nil
+}
+
+// GetPrefix returns the first key which matches the prefix
+func (c *memoryClient) GetPrefix(prefix string) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if keys := c.sortedKeysLocked(prefix); len(keys) > 0 {
+		return copyValue(c.kvs[keys[0]].value), nil
+	}
+
+	return nil, nil
+}
+
+// Set sets value of key
+func (c *memoryClient) Set(key string, value []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.putLocked(key, value, 0)
+}
+
+// Delete deletes a key
+func (c *memoryClient) Delete(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.deleteLocked(key)
+}
+
+// DeletePrefix deletes all keys matching the prefix
+func (c *memoryClient) DeletePrefix(path string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, key := range c.sortedKeysLocked(path) {
+		if err := c.deleteLocked(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Update creates or updates a key
+func (c *memoryClient) Update(key string, value []byte, lease bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	id, err := c.getLeaseLocked(lease)
+	if err != nil {
+		return err
+	}
+
+	return c.putLocked(key, value, id)
+}
+
+// CreateOnly creates a key with the value and will fail if the key already exists
+func (c *memoryClient) CreateOnly(key string, value []byte, lease bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	id, err := c.getLeaseLocked(lease)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := c.kvs[key]; ok {
+		return fmt.Errorf("create was unsuccessful")
+	}
+
+	return c.putLocked(key, value, id)
+}
+
+// CreateIfExists creates a key with the value only if key condKey exists
+func (c *memoryClient) CreateIfExists(condKey, key string, value []byte, lease bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	id, err := c.getLeaseLocked(lease)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := c.kvs[condKey]; !ok {
+		return fmt.Errorf("create was unsuccessful")
+	}
+
+	return c.putLocked(key, value, id)
+}
+
+// Commit performs a transaction atomically
+func (c *memoryClient) Commit(txn *Txn) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, cmp := range txn.Compares {
+		var value []byte
+		if entry, ok := c.kvs[cmp.Key]; ok {
+			value = entry.value
+		}
+
+		if !cmp.evaluate(value) {
+			return false, nil
+		}
+	}
+
+	// Validate all operations before modifying anything
+	changes := make([]memoryChange, 0, len(txn.Ops))
+	for _, o := range txn.Ops {
+		switch o.Typ {
+		case TxnOpPut:
+			var id int64
+			if lease, ok := o.lease(); ok {
+				var err error
+				if id, err = c.getLeaseIDLocked(lease); err != nil {
+					return false, err
+				}
+			}
+			changes = append(changes, memoryChange{key: o.Key, entry: newMemoryEntry(o.Value, id)})
+		case TxnOpDelete:
+			changes = append(changes, memoryChange{key: o.Key})
+		default:
+			return false, fmt.Errorf("unknown operation type %d", o.Typ)
+		}
+	}
+
+	if err := c.checkFailureLocked(); err != nil {
+		return false, err
+	}
+
+	if c.persister != nil {
+		if err := c.persister.commit(changes); err != nil {
+			return false, err
+		}
+	}
+
+	for _, change := range changes {
+		c.applyLocked(change)
+	}
+
+	return true, nil
+}
+
+// ListPrefix returns a map of matching keys
+func (c *memoryClient) ListPrefix(prefix string) (KeyValuePairs, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	pairs := KeyValuePairs{}
+	for key, entry := range c.kvs {
+		if strings.HasPrefix(key, prefix) {
+			pairs[key] = copyValue(entry.value)
+		}
+	}
+
+	return pairs, nil
+}
+
+// ListAndWatch implements the BackendOperations.ListAndWatch using the
+// in-memory kvstore
+func (c *memoryClient) ListAndWatch(name, prefix string, chanSize int) *Watcher {
+	w := newWatcher(name, prefix, chanSize)
+
+	go c.Watch(w)
+
+	return w
+}
+
+// Watch starts watching for changes in a prefix. All existing keys are
+// reported as new keys first, followed by EventTypeListDone. The watcher
+// remains active until it is stopped, even if the client is closed.
+func (c *memoryClient) Watch(w *Watcher) {
+	mw := &memoryWatch{
+		watcher: w,
+		notify:  make(chan struct{}, 1),
+	}
+
+	scopedLog := log.WithFields(logrus.Fields{
+		fieldWatcher: w,
+		fieldPrefix:  w.prefix,
+	})
+
+	// The initial list and the registration of the watcher happen
+	// atomically so no event can be missed
+	c.mutex.Lock()
+	for _, key := range c.sortedKeysLocked(w.prefix) {
+		mw.queue = append(mw.queue, KeyValueEvent{
+			Typ:   EventTypeCreate,
+			Key:   key,
+			Value: copyValue(c.kvs[key].value),
+		})
+	}
+	mw.queue = append(mw.queue, KeyValueEvent{Typ: EventTypeListDone})
+	c.watches[mw] = struct{}{}
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		delete(c.watches, mw)
+		c.mutex.Unlock()
+		close(w.Events)
+	}()
+
+	for {
+		c.mutex.Lock()
+		events := mw.queue
+		mw.queue = nil
+		c.mutex.Unlock()
+
+		for _, event := range events {
+			scopedLog.Debugf("Emitting %v event for %s=%v", event.Typ, event.Key, event.Value)
+
+			select {
+			case w.Events <- event:
+			case <-w.stopWatch:
+				return
+			}
+		}
+
+		select {
+		case <-mw.notify:
+		case <-w.stopWatch:
+			return
+		}
+	}
+}
+
+// CreateLease creates a new lease with the given ttl
+func (c *memoryClient) CreateLease(ttl time.Duration) (interface{}, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	l := &memoryLease{ID: c.nextLease, TTL: ttl}
+
+	if c.persister != nil {
+		if err := c.persister.createLease(l.ID, ttl); err != nil {
+			return nil, err
+		}
+	}
+
+	c.nextLease++
+	c.leases[l.ID] = &memoryLeaseEntry{ttl: ttl, expires: time.Now().Add(ttl)}
+
+	return l, nil
+}
+
+// KeepAlive keeps a lease created with CreateLease alive
+func (c *memoryClient) KeepAlive(lease interface{}) error {
+	l, ok := lease.(*memoryLease)
+	if !ok {
+		return fmt.Errorf("argument not a memory lease")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.leases[l.ID]
+	if !ok {
+		return fmt.Errorf("lease %d not found", l.ID)
+	}
+
+	entry.expires = time.Now().Add(entry.ttl)
+	return nil
+}
+
+// DeleteLease deletes a lease and all keys attached to it
+func (c *memoryClient) DeleteLease(lease interface{}) error {
+	l, ok := lease.(*memoryLease)
+	if !ok {
+		return fmt.Errorf("argument not a memory lease")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.deleteLeaseLocked(l.ID)
+}
+
+func (c *memoryClient) closeClient() {
+	close(c.stop)
+
+	if c.persister != nil {
+		c.mutex.Lock()
+		c.persister.close()
+		c.mutex.Unlock()
+	}
+}
+
+// GetCapabilities returns the capabilities of the backend
+func (c *memoryClient) GetCapabilities() Capabilities {
+	return Capabilities(CapabilityCreateIfExists)
+}
+
+// Encode encodes a binary slice into a character set that the backend supports
+func (c *memoryClient) Encode(in []byte) string {
+	return string(in)
+}
+
+// Decode decodes a key previously encoded back into the original binary slice
+func (c *memoryClient) Decode(in string) ([]byte, error) {
+	return []byte(in), nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type MemorySuite struct {
+	BaseTests
+}
+
+var _ = Suite(&MemorySuite{})
+
+func (s *MemorySuite) SetUpTest(c *C) {
+	SetupDummy(MemoryBackendName)
+}
+
+func (s *MemorySuite) TearDownTest(c *C) {
+	Close()
+}
+
+func (s *MemorySuite) TestDeleteLease(c *C) {
+	prefix := "unit-test/"
+
+	c.Assert(Update(testKey(prefix, 0), testValue(0), true), IsNil)
+	c.Assert(CreateOnly(testKey(prefix, 1), testValue(1), true), IsNil)
+	c.Assert(CreateOnly(testKey(prefix, 2), testValue(2), false), IsNil)
+
+	w := ListAndWatch("testWatcher", prefix, 100)
+	for i := 0; i < 3; i++ {
+		expectEvent(c, w, EventTypeCreate, testKey(prefix, i), testValue(i))
+	}
+	expectEvent(c, w, EventTypeListDone, "", nil)
+
+	leaseMutex.RLock()
+	c.Assert(Client().DeleteLease(leaseInstance), IsNil)
+	leaseMutex.RUnlock()
+
+	// Only keys attached to the lease are removed
+	pairs, err := ListPrefix(prefix)
+	c.Assert(err, IsNil)
+	c.Assert(pairs, DeepEquals, KeyValuePairs{testKey(prefix, 2): testValue(2)})
+
+	expectEvent(c, w, EventTypeDelete, testKey(prefix, 0), testValue(0))
+	expectEvent(c, w, EventTypeDelete, testKey(prefix, 1), testValue(1))
+
+	// The lease no longer exists
+	c.Assert(Update(testKey(prefix, 3), testValue(3), true), Not(IsNil))
+
+	w.Stop()
+}
+
+func (s *MemorySuite) TestLeaseExpiry(c *C) {
+	oldInterval := memoryLeaseCheckInterval
+	memoryLeaseCheckInterval = 10 * time.Millisecond
+	defer func() { memoryLeaseCheckInterval = oldInterval }()
+
+	client, err := NewClient(MemoryBackendName, nil)
+	c.Assert(err, IsNil)
+	defer CloseClient(client)
+
+	lease, err := client.CreateLease(100 * time.Millisecond)
+	c.Assert(err, IsNil)
+	c.Assert(client.KeepAlive(lease), IsNil)
+
+	mc := unwrapClient(client).(*memoryClient)
+	mc.mutex.Lock()
+	mc.kvs["foo"] = &memoryEntry{value: []byte("bar"), lease: lease.(*memoryLease).ID}
+	mc.mutex.Unlock()
+
+	for start := time.Now(); time.Since(start) < 5*time.Second; {
+		if v, _ := client.Get("foo"); v == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	v, err := client.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(v, IsNil)
+	c.Assert(client.KeepAlive(lease), Not(IsNil))
+}
+
+func (s *MemorySuite) TestIndependentClients(c *C) {
+	client, err := NewClient(MemoryBackendName, nil)
+	c.Assert(err, IsNil)
+	defer CloseClient(client)
+
+	c.Assert(Set("foo", []byte("bar")), IsNil)
+
+	v, err := client.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(v, IsNil)
+}
+
+func (s *MemorySuite) TestWatchStop(c *C) {
+	w := ListAndWatch("testWatcher", "unit-test/", 1)
+	expectEvent(c, w, EventTypeListDone, "", nil)
+
+	// The watcher must not block writers even if nobody is reading
+	for i := 0; i < 10; i++ {
+		c.Assert(Set(testKey("unit-test/", i), testValue(i)), IsNil)
+	}
+
+	w.Stop()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-w.Events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			c.Fatal("events channel was not closed after stopping the watcher")
+		}
+	}
+}
+
+func (s *MemorySuite) TestCommitFailure(c *C) {
+	prefix := "unit-test/"
+	key0, key1 := testKey(prefix, 0), testKey(prefix, 1)
+
+	// Remove the default lease so the leased put fails
+	leaseMutex.RLock()
+	c.Assert(Client().DeleteLease(leaseInstance), IsNil)
+	leaseMutex.RUnlock()
+
+	succeeded, err := Commit(NewTxn().IfMissing(key0).Put(key0, testValue(0), false).Put(key1, testValue(1), true))
+	c.Assert(err, Not(IsNil))
+	c.Assert(succeeded, Equals, false)
+
+	pairs, err := ListPrefix(prefix)
+	c.Assert(err, IsNil)
+	c.Assert(len(pairs), Equals, 0)
+}
+
+func (s *MemorySuite) TestValueCopies(c *C) {
+	key := testKey("unit-test/", 0)
+	value := []byte("value")
+
+	c.Assert(Set(key, value), IsNil)
+	value[0] = 'V'
+
+	v, err := Get(key)
+	c.Assert(err, IsNil)
+	c.Assert(string(v), Equals, "value")
+	v[0] = 'V'
+
+	pairs, err := ListPrefix("unit-test/")
+	c.Assert(err, IsNil)
+	c.Assert(string(pairs[key]), Equals, "value")
+	pairs[key][0] = 'V'
+
+	v, err = GetPrefix("unit-test/")
+	c.Assert(err, IsNil)
+	c.Assert(string(v), Equals, "value")
+}
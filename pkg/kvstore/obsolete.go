@@ -0,0 +1,178 @@
+// Copyright 2017-2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/cilium/cilium/common"
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+)
+
+// The functions in this file implement the obsolete part of the
+// BackendOperations interface on top of GetValue(), SetValue() and LockPath()
+// so they can be shared by all backends which do not provide a native
+// implementation.
+
+// FIXME: Obsolete, remove
+func initializeFreeID(e BackendOperations, path string, firstID uint32) error {
+	kvLocker, err := LockPath(path)
+	if err != nil {
+		return err
+	}
+	defer kvLocker.Unlock()
+
+	log.Debug("Trying to acquire free ID...")
+	k, err := e.GetValue(path)
+	if err != nil {
+		return err
+	}
+	if k != nil {
+		// FreeID already set
+		return nil
+	}
+	err = e.SetValue(path, firstID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FIXME: Obsolete, remove
+func getMaxID(e BackendOperations, key string, firstID uint32) (uint32, error) {
+	var (
+		attempts = 3
+		value    json.RawMessage
+		err      error
+		freeID   uint32
+	)
+	for {
+		switch value, err = e.GetValue(key); {
+		case attempts == 0:
+			err = fmt.Errorf("Unable to retrieve last free ID because key is always empty")
+			log.Error(err)
+			fallthrough
+		case err != nil:
+			return 0, err
+		case value == nil:
+			if err = initializeFreeID(e, key, firstID); err != nil {
+				return 0, err
+			}
+			attempts--
+		case err == nil:
+			if err = json.Unmarshal(value, &freeID); err != nil {
+				return 0, err
+			}
+			return freeID, nil
+		}
+	}
+}
+
+// FIXME: Obsolete, remove
+func setMaxID(e BackendOperations, key string, firstID, maxID uint32) error {
+	value, err := e.GetValue(key)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		// FreeID is empty? We should set it out!
+		if err := initializeFreeID(e, key, firstID); err != nil {
+			return err
+		}
+		k, err := e.GetValue(key)
+		if err != nil {
+			return err
+		}
+		if k == nil {
+			// Something is really wrong
+			errMsg := "Unable to set ID because the key is always empty"
+			log.Error(errMsg)
+			return fmt.Errorf("%s\n", errMsg)
+		}
+	}
+	return e.SetValue(key, maxID)
+}
+
+// FIXME: Obsolete, remove
+func setMaxL3n4AddrID(e BackendOperations, maxID uint32) error {
+	return setMaxID(e, common.LastFreeServiceIDKeyPath, common.FirstFreeServiceID, maxID)
+}
+
+// gasNewL3n4AddrID gets the next available ServiceID and sets it in lAddrID. After
+// assigning the ServiceID to lAddrID it sets the ServiceID + 1 in
+// common.LastFreeServiceIDKeyPath path.
+//
+// FIXME: Obsolete, remove
+func gasNewL3n4AddrID(e BackendOperations, basePath string, baseID uint32, lAddrID *types.L3n4AddrID) error {
+	setIDtoL3n4Addr := func(id uint32) error {
+		lAddrID.ID = types.ServiceID(id)
+		keyPath := path.Join(basePath, strconv.FormatUint(uint64(lAddrID.ID), 10))
+		if err := e.SetValue(keyPath, lAddrID); err != nil {
+			return err
+		}
+		return setMaxL3n4AddrID(e, id+1)
+	}
+
+	acquireFreeID := func(firstID uint32, incID *uint32) (bool, error) {
+		keyPath := path.Join(basePath, strconv.FormatUint(uint64(*incID), 10))
+
+		locker, err := e.LockPath(getLockPath(keyPath))
+		if err != nil {
+			return false, err
+		}
+		defer locker.Unlock()
+
+		value, err := e.GetValue(keyPath)
+		if err != nil {
+			return false, err
+		}
+		if value == nil {
+			return false, setIDtoL3n4Addr(*incID)
+		}
+		var consulL3n4AddrID types.L3n4AddrID
+		if err := json.Unmarshal(value, &consulL3n4AddrID); err != nil {
+			return false, err
+		}
+		if consulL3n4AddrID.ID == 0 {
+			log.WithField(logfields.Identity, *incID).Info("Recycling Service ID")
+			return false, setIDtoL3n4Addr(*incID)
+		}
+
+		*incID++
+		if *incID > common.MaxSetOfServiceID {
+			*incID = common.FirstFreeServiceID
+		}
+		if firstID == *incID {
+			return false, fmt.Errorf("reached maximum set of serviceIDs available.")
+		}
+		// Only retry if we have incremented the service ID
+		return true, nil
+	}
+
+	beginning := baseID
+	for {
+		retry, err := acquireFreeID(beginning, &baseID)
+		if err != nil {
+			return err
+		} else if !retry {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/lock"
+
+	"golang.org/x/time/rate"
+)
+
+// OperationClass is a class of kvstore operations sharing a rate limit
+type OperationClass string
+
+const (
+	// OperationClassRead covers all operations reading keys
+	OperationClassRead OperationClass = "read"
+
+	// OperationClassWrite covers all operations creating, modifying or
+	// deleting keys including transactions
+	OperationClassWrite OperationClass = "write"
+
+	// OperationClassLock covers the acquiring of locks
+	OperationClassLock OperationClass = "lock"
+
+	// OperationClassLease covers the creation, renewal and deletion of
+	// leases
+	OperationClassLease OperationClass = "lease"
+)
+
+// OperationClasses is the list of all operation classes
+var OperationClasses = []OperationClass{
+	OperationClassRead,
+	OperationClassWrite,
+	OperationClassLock,
+	OperationClassLease,
+}
+
+// RateLimit is the client-side rate limit of an operation class
+type RateLimit struct {
+	// QPS is the number of operations per second allowed on average
+	QPS float64
+
+	// Burst is the number of operations allowed to exceed QPS
+	Burst int
+}
+
+// String returns the rate limit in the format accepted by ParseRateLimit
+func (r RateLimit) String() string {
+	return strconv.FormatFloat(r.QPS, 'f', -1, 64) + ":" + strconv.Itoa(r.Burst)
+}
+
+// ParseRateLimit parses a rate limit in the format <qps>[:<burst>]. If no
+// burst is specified, the burst defaults to the QPS rounded up.
+func ParseRateLimit(value string) (RateLimit, error) {
+	r := RateLimit{}
+	parts := strings.SplitN(value, ":", 2)
+
+	qps, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || qps <= 0 || math.IsInf(qps, 0) || math.IsNaN(qps) {
+		return r, fmt.Errorf("invalid QPS '%s': must be a positive number", parts[0])
+	}
+	r.QPS = qps
+	r.Burst = int(math.Ceil(qps))
+
+	if len(parts) == 2 {
+		burst, err := strconv.Atoi(parts[1])
+		if err != nil || burst <= 0 {
+			return r, fmt.Errorf("invalid burst '%s': must be a positive integer", parts[1])
+		}
+		r.Burst = burst
+	}
+
+	return r, nil
+}
+
+// validOperationClass returns true if class is a known operation class
+func validOperationClass(class OperationClass) bool {
+	for _, c := range OperationClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRateLimit validates a rate limit option in the format
+// <class>=<qps>[:<burst>]
+func ValidateRateLimit(value string) (string, error) {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 {
+		return "", fmt.Errorf("invalid rate limit '%s', expected <class>=<qps>[:<burst>]", value)
+	}
+
+	if !validOperationClass(OperationClass(kv[0])) {
+		classes := make([]string, 0, len(OperationClasses))
+		for _, c := range OperationClasses {
+			classes = append(classes, string(c))
+		}
+		sort.Strings(classes)
+		return "", fmt.Errorf("unknown operation class '%s', must be one of %s", kv[0], strings.Join(classes, ", "))
+	}
+
+	if _, err := ParseRateLimit(kv[1]); err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+var (
+	rateLimitsMutex lock.RWMutex
+
+	// rateLimits is the configured rate limit of each operation class.
+	// Operation classes without a rate limit are not limited.
+	rateLimits = map[OperationClass]RateLimit{}
+)
+
+// SetRateLimits configures the client-side rate limits of all operation
+// classes. limits maps operation classes to rate limits in the format
+// <qps>[:<burst>]. The rate limits apply to all clients created afterwards
+// and must therefore be set before Setup() is called.
+func SetRateLimits(limits map[string]string) error {
+	parsed := map[OperationClass]RateLimit{}
+	for class, value := range limits {
+		if !validOperationClass(OperationClass(class)) {
+			return fmt.Errorf("unknown operation class '%s'", class)
+		}
+
+		r, err := ParseRateLimit(value)
+		if err != nil {
+			return fmt.Errorf("invalid rate limit of operation class '%s': %s", class, err)
+		}
+		parsed[OperationClass(class)] = r
+	}
+
+	rateLimitsMutex.Lock()
+	rateLimits = parsed
+	rateLimitsMutex.Unlock()
+
+	return nil
+}
+
+// GetRateLimit returns the configured rate limit of an operation class and
+// false if the operation class is not limited
+func GetRateLimit(class OperationClass) (RateLimit, bool) {
+	rateLimitsMutex.RLock()
+	r, ok := rateLimits[class]
+	rateLimitsMutex.RUnlock()
+	return r, ok
+}
+
+// newRateLimiters returns a rate limiter for each operation class based on
+// the configured rate limits
+func newRateLimiters() map[OperationClass]*rate.Limiter {
+	limiters := map[OperationClass]*rate.Limiter{}
+	for _, class := range OperationClasses {
+		if r, ok := GetRateLimit(class); ok {
+			limiters[class] = rate.NewLimiter(rate.Limit(r.QPS), r.Burst)
+		} else {
+			limiters[class] = rate.NewLimiter(rate.Inf, 0)
+		}
+	}
+	return limiters
+}
@@ -0,0 +1,133 @@
+// Copyright 2016-2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *independentSuite) TestParseRateLimit(c *C) {
+	r, err := ParseRateLimit("10")
+	c.Assert(err, IsNil)
+	c.Assert(r, Equals, RateLimit{QPS: 10, Burst: 10})
+
+	r, err = ParseRateLimit("0.5")
+	c.Assert(err, IsNil)
+	c.Assert(r, Equals, RateLimit{QPS: 0.5, Burst: 1})
+
+	r, err = ParseRateLimit("2.5:20")
+	c.Assert(err, IsNil)
+	c.Assert(r, Equals, RateLimit{QPS: 2.5, Burst: 20})
+	c.Assert(r.String(), Equals, "2.5:20")
+
+	for _, invalid := range []string{"", "foo", "0", "-1", "10:", "10:0", "10:foo"} {
+		_, err = ParseRateLimit(invalid)
+		c.Assert(err, Not(IsNil), Commentf("%s", invalid))
+	}
+}
+
+func (s *independentSuite) TestValidateRateLimit(c *C) {
+	v, err := ValidateRateLimit("read=100:200")
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "read=100:200")
+
+	_, err = ValidateRateLimit("read")
+	c.Assert(err, Not(IsNil))
+
+	_, err = ValidateRateLimit("watch=100")
+	c.Assert(err, Not(IsNil))
+
+	_, err = ValidateRateLimit("write=foo")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *independentSuite) TestSetRateLimits(c *C) {
+	defer SetRateLimits(nil)
+
+	c.Assert(SetRateLimits(map[string]string{"watch": "10"}), Not(IsNil))
+	c.Assert(SetRateLimits(map[string]string{"read": "foo"}), Not(IsNil))
+
+	c.Assert(SetRateLimits(map[string]string{"read": "10:5"}), IsNil)
+	r, ok := GetRateLimit(OperationClassRead)
+	c.Assert(ok, Equals, true)
+	c.Assert(r, Equals, RateLimit{QPS: 10, Burst: 5})
+
+	_, ok = GetRateLimit(OperationClassWrite)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *MemorySuite) TestRateLimit(c *C) {
+	defer SetRateLimits(nil)
+	c.Assert(SetRateLimits(map[string]string{"write": "20:2"}), IsNil)
+
+	client, err := NewClient(MemoryBackendName, nil)
+	c.Assert(err, IsNil)
+	defer CloseClient(client)
+
+	// The burst is consumed by the first two writes, the remaining writes
+	// are delayed by 50ms each
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		c.Assert(client.Set(testKey("unit-test/", i), testValue(i)), IsNil)
+	}
+	c.Assert(time.Since(start) >= 90*time.Millisecond, Equals, true)
+
+	// Reads are not limited
+	for i := 0; i < 4; i++ {
+		_, err := client.Get(testKey("unit-test/", i))
+		c.Assert(err, IsNil)
+	}
+
+	stats := client.(*instrumentedClient).statistics()
+	c.Assert(stats[0].Class, Equals, OperationClassRead)
+	c.Assert(stats[0].Count, Equals, uint64(4))
+	c.Assert(stats[0].Throttled, Equals, uint64(0))
+	c.Assert(stats[0].RateLimit, IsNil)
+	c.Assert(stats[1].Class, Equals, OperationClassWrite)
+	c.Assert(stats[1].Count, Equals, uint64(4))
+	c.Assert(stats[1].Throttled, Equals, uint64(2))
+	c.Assert(*stats[1].RateLimit, Equals, RateLimit{QPS: 20, Burst: 2})
+}
+
+func (s *MemorySuite) TestOperationStatistics(c *C) {
+	// The setup of the client performs operations as well
+	before := GetOperationStatistics()
+
+	c.Assert(Set("unit-test/foo", []byte("bar")), IsNil)
+	_, err := Get("unit-test/foo")
+	c.Assert(err, IsNil)
+	_, err = Get("unit-test/missing")
+	c.Assert(err, IsNil)
+	c.Assert(Update("unit-test/foo", []byte("baz"), true), IsNil)
+
+	stats := GetOperationStatistics()
+	c.Assert(len(stats), Equals, len(OperationClasses))
+	c.Assert(stats[0].Count-before[0].Count, Equals, uint64(2))
+	c.Assert(stats[0].Bytes-before[0].Bytes, Equals, uint64(3))
+	c.Assert(stats[1].Count-before[1].Count, Equals, uint64(2))
+	c.Assert(stats[1].Bytes-before[1].Bytes, Equals, uint64(6))
+	c.Assert(stats[1].Errors-before[1].Errors, Equals, uint64(0))
+}
+
+func (s *independentSuite) TestPrefixLabel(c *C) {
+	c.Assert(prefixLabel("cilium/state/identities/v1/id/1000"), Equals, prefixLabelAllocator)
+	c.Assert(prefixLabel("cilium/state/identities/v1/locks/foo/bar"), Equals, prefixLabelLocks)
+	c.Assert(prefixLabel("cilium/state/ip/v1/default/10.0.0.1"), Equals, prefixLabelIPCache)
+	c.Assert(prefixLabel("cilium/state/ip/v1/default/10.0.0.1.lock"), Equals, prefixLabelLocks)
+	c.Assert(prefixLabel("cilium/state/nodes/v1/default/node1"), Equals, prefixLabelOther)
+	c.Assert(prefixLabel(""), Equals, prefixLabelOther)
+}
@@ -0,0 +1,42 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snapshot implements export and import of the state that Cilium
+// maintains in the kvstore. It is intended for disaster recovery: if the
+// kvstore cluster is lost, a snapshot taken earlier can be imported into a
+// new kvstore cluster to restore allocated identities without causing
+// identity churn across the cluster.
+//
+// A snapshot is a versioned JSON document containing all keys below
+// StatePrefix. Values which are JSON documents themselves are embedded in
+// decoded form, all other values are stored as text or base64 encoded
+// binary.
+//
+// On import, the snapshot is validated against itself and against the
+// current content of the kvstore:
+//
+// * Allocator master keys may not allocate the same ID twice or the same
+//   key under two different IDs, neither within the snapshot nor in
+//   combination with the master keys already present in the kvstore.
+// * Allocator value keys must refer to a master key allocating the same
+//   key.
+//
+// Keys already present in the kvstore are never overwritten. Keys which
+// are protected by a lease when written by Cilium agents are only attached
+// to the lease of the importing client if they are allocator value keys of
+// the importing node. All other leased keys are attached to a dedicated
+// import lease which is never renewed. They are attached to the lease of
+// their owner again when the owner refreshes them and are removed when the
+// import lease expires otherwise, e.g. if the owning node no longer exists.
+package snapshot
@@ -0,0 +1,383 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cilium/cilium/pkg/kvstore"
+)
+
+// ConflictError is returned by Import if the snapshot is inconsistent in
+// itself or conflicts with the content of the kvstore
+type ConflictError struct {
+	// Conflicts is the list of all conflicts found
+	Conflicts []string
+}
+
+// Error returns the human readable form of the conflicts
+func (c *ConflictError) Error() string {
+	return fmt.Sprintf("%d conflicts found: %s", len(c.Conflicts), strings.Join(c.Conflicts, "; "))
+}
+
+// InvalidError is returned by Import if the snapshot is malformed or of an
+// unsupported version
+type InvalidError struct {
+	err error
+}
+
+// Error returns the reason why the snapshot is invalid
+func (i *InvalidError) Error() string {
+	return i.err.Error()
+}
+
+func invalidf(format string, a ...interface{}) error {
+	return &InvalidError{err: fmt.Errorf(format, a...)}
+}
+
+// DefaultImportLeaseTTL is the default time-to-live of the lease which keys
+// owned by other nodes are attached to on import
+const DefaultImportLeaseTTL = 15 * time.Minute
+
+// ImportOptions are the options of an import
+type ImportOptions struct {
+	// DryRun performs all consistency checks without writing any keys
+	DryRun bool
+
+	// Owner is the node specific suffix of the allocator keys of the
+	// importing agent. Allocator value keys carrying this suffix are
+	// attached to the lease of the importing client. All other leased
+	// keys are owned by other nodes and are attached to a dedicated import
+	// lease instead.
+	Owner string
+
+	// LeaseTTL is the time-to-live of the import lease. The import lease
+	// is never renewed, keys attached to it are removed when it expires
+	// unless their owner has written them again in the meantime. Defaults
+	// to DefaultImportLeaseTTL.
+	LeaseTTL time.Duration
+}
+
+// ImportResult is the result of an import
+type ImportResult struct {
+	// Created is the number of keys created
+	Created int
+
+	// Unchanged is the number of keys which already existed in the
+	// kvstore with the same value
+	Unchanged int
+
+	// Skipped is the number of keys which already existed in the kvstore
+	// with a different value. Only keys not owned by an allocator can be
+	// skipped, allocator keys with a different value are conflicts.
+	Skipped int
+}
+
+// allocatorState is the allocator master and value keys of a single
+// allocator
+type allocatorState struct {
+	// keys maps each ID to the allocated key
+	keys map[uint64]string
+
+	// ids maps each allocated key to the ID
+	ids map[string]uint64
+}
+
+func newAllocatorState() *allocatorState {
+	return &allocatorState{
+		keys: map[uint64]string{},
+		ids:  map[string]uint64{},
+	}
+}
+
+// add adds the allocation of id to key and returns a description of the
+// conflict if the ID or the key is already allocated differently
+func (a *allocatorState) add(id uint64, key string) string {
+	if existing, ok := a.keys[id]; ok && existing != key {
+		return fmt.Sprintf("ID %d is allocated to both '%s' and '%s'", id, existing, key)
+	}
+
+	if existing, ok := a.ids[key]; ok && existing != id {
+		return fmt.Sprintf("key '%s' is allocated to both ID %d and ID %d", key, existing, id)
+	}
+
+	a.keys[id] = key
+	a.ids[key] = id
+
+	return ""
+}
+
+// parseMasterKey parses an allocator master key <prefix>/id/<id>
+func parseMasterKey(prefix, key string) (uint64, bool) {
+	idPrefix := path.Join(prefix, "id") + "/"
+	if !strings.HasPrefix(key, idPrefix) {
+		return 0, false
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(key, idPrefix), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return id, true
+}
+
+// parseValueKey parses an allocator value key <prefix>/value/<key>/<suffix>
+// and returns the allocated key
+func parseValueKey(prefix, key string) (string, bool) {
+	valuePrefix := path.Join(prefix, "value") + "/"
+	if !strings.HasPrefix(key, valuePrefix) {
+		return "", false
+	}
+
+	key = strings.TrimPrefix(key, valuePrefix)
+	i := strings.LastIndex(key, "/")
+	if i <= 0 {
+		return "", false
+	}
+
+	return key[:i], true
+}
+
+// check validates the snapshot and compares the allocator keys with the
+// allocator keys present in the kvstore. The returned list contains all
+// conflicts found.
+func (s *Snapshot) check(backend kvstore.BackendOperations) ([]string, error) {
+	if s.Version != Version {
+		return nil, invalidf("unsupported snapshot version %d, expected %d", s.Version, Version)
+	}
+
+	if s.Prefix != StatePrefix {
+		return nil, invalidf("unsupported snapshot prefix '%s', expected '%s'", s.Prefix, StatePrefix)
+	}
+
+	conflicts := []string{}
+	seen := map[string]struct{}{}
+	allocators := map[string]*allocatorState{}
+
+	for _, prefix := range allocatorPrefixes {
+		allocators[prefix] = newAllocatorState()
+	}
+
+	for i := range s.Entries {
+		e := &s.Entries[i]
+		if err := e.validate(); err != nil {
+			return nil, &InvalidError{err: err}
+		}
+
+		if _, ok := seen[e.Key]; ok {
+			return nil, invalidf("key '%s' is contained more than once", e.Key)
+		}
+		seen[e.Key] = struct{}{}
+
+		if isLock(e.Key) {
+			return nil, invalidf("key '%s' is a lock", e.Key)
+		}
+
+		prefix := allocatorPrefix(e.Key)
+		if prefix == "" {
+			continue
+		}
+
+		if id, ok := parseMasterKey(prefix, e.Key); ok {
+			if c := allocators[prefix].add(id, string(e.Bytes())); c != "" {
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+
+	// Verify that the master keys of the snapshot do not conflict with the
+	// master keys already allocated in the kvstore
+	for _, prefix := range allocatorPrefixes {
+		idPrefix := path.Join(prefix, "id") + "/"
+		pairs, err := backend.ListPrefix(idPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list keys below '%s': %s", idPrefix, err)
+		}
+
+		for key, value := range pairs {
+			id, ok := parseMasterKey(prefix, key)
+			if !ok {
+				continue
+			}
+
+			if c := allocators[prefix].add(id, string(value)); c != "" {
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+
+	// Every value key must refer to a master key allocating the same key
+	for i := range s.Entries {
+		e := &s.Entries[i]
+		prefix := allocatorPrefix(e.Key)
+		if prefix == "" {
+			continue
+		}
+
+		key, ok := parseValueKey(prefix, e.Key)
+		if !ok {
+			continue
+		}
+
+		id, err := strconv.ParseUint(string(e.Bytes()), 10, 64)
+		if err != nil {
+			conflicts = append(conflicts, fmt.Sprintf("value key '%s' refers to invalid ID '%s'", e.Key, string(e.Bytes())))
+			continue
+		}
+
+		switch master, ok := allocators[prefix].keys[id]; {
+		case !ok:
+			conflicts = append(conflicts, fmt.Sprintf("value key '%s' refers to unallocated ID %d", e.Key, id))
+		case master != key:
+			conflicts = append(conflicts, fmt.Sprintf("value key '%s' refers to ID %d allocated to '%s'", e.Key, id, master))
+		}
+	}
+
+	return conflicts, nil
+}
+
+// isOwned returns true if key is an allocator value key of the node with the
+// node specific suffix owner
+func isOwned(key, owner string) bool {
+	if owner == "" {
+		return false
+	}
+
+	prefix := allocatorPrefix(key)
+	if prefix == "" {
+		return false
+	}
+
+	allocated, ok := parseValueKey(prefix, key)
+	if !ok {
+		return false
+	}
+
+	return key == path.Join(prefix, "value", allocated, owner)
+}
+
+// importTxn returns the transaction to import e. Leased keys are attached to
+// the lease of the importing client if they are owned by owner and to
+// importLease otherwise.
+func importTxn(e *Entry, owner string, importLease interface{}) *kvstore.Txn {
+	value := e.Bytes()
+	txn := kvstore.NewTxn().IfMissing(e.Key)
+
+	if prefix := allocatorPrefix(e.Key); prefix != "" {
+		if key, ok := parseValueKey(prefix, e.Key); ok {
+			// Mirror the allocator and only create the value key if the
+			// master key still refers to the key
+			txn.IfValue(path.Join(prefix, "id", string(value)), []byte(key))
+		}
+	}
+
+	switch {
+	case !isLeased(e.Key):
+		return txn.Put(e.Key, value, false)
+	case isOwned(e.Key, owner):
+		return txn.Put(e.Key, value, true)
+	default:
+		return txn.PutWithLease(e.Key, value, importLease)
+	}
+}
+
+// Import imports all keys of the snapshot into the kvstore. The import is
+// refused with an InvalidError if the snapshot is malformed and with a
+// ConflictError if the snapshot fails any of the consistency checks
+// described in the package documentation. Keys which already exist
+// are left untouched. Allocator value keys owned by opts.Owner are attached
+// to the lease of backend, all other leased keys are attached to an import
+// lease created with opts.LeaseTTL which is never renewed.
+func Import(backend kvstore.BackendOperations, s *Snapshot, opts ImportOptions) (*ImportResult, error) {
+	conflicts, err := s.check(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(conflicts) > 0 {
+		return nil, &ConflictError{Conflicts: conflicts}
+	}
+
+	existing, err := backend.ListPrefix(StatePrefix + "/")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list keys below '%s': %s", StatePrefix, err)
+	}
+
+	result := &ImportResult{}
+
+	// Master keys are imported first so that the conditions of the value
+	// keys are met
+	entries := make([]*Entry, 0, len(s.Entries))
+	for i := range s.Entries {
+		if !isLeased(s.Entries[i].Key) {
+			entries = append(entries, &s.Entries[i])
+		}
+	}
+	for i := range s.Entries {
+		if isLeased(s.Entries[i].Key) {
+			entries = append(entries, &s.Entries[i])
+		}
+	}
+
+	var importLease interface{}
+
+	for _, e := range entries {
+		if value, ok := existing[e.Key]; ok {
+			if string(value) == string(e.Bytes()) {
+				result.Unchanged++
+			} else {
+				result.Skipped++
+			}
+			continue
+		}
+
+		if opts.DryRun {
+			result.Created++
+			continue
+		}
+
+		if importLease == nil && isLeased(e.Key) && !isOwned(e.Key, opts.Owner) {
+			ttl := opts.LeaseTTL
+			if ttl == 0 {
+				ttl = DefaultImportLeaseTTL
+			}
+
+			importLease, err = backend.CreateLease(ttl)
+			if err != nil {
+				return result, fmt.Errorf("unable to create import lease: %s", err)
+			}
+		}
+
+		succeeded, err := backend.Commit(importTxn(e, opts.Owner, importLease))
+		if err != nil {
+			return result, fmt.Errorf("unable to import key '%s': %s", e.Key, err)
+		}
+
+		if succeeded {
+			result.Created++
+		} else {
+			// The key has been created or the master key has been
+			// changed concurrently
+			result.Skipped++
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,305 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/cilium/cilium/pkg/identity"
+	"github.com/cilium/cilium/pkg/kvstore"
+
+	"github.com/go-openapi/strfmt"
+)
+
+const (
+	// Version is the version of the snapshot format. It must be bumped
+	// whenever the format changes in an incompatible way.
+	Version = 1
+)
+
+var (
+	// StatePrefix is the kvstore prefix containing all state managed by
+	// Cilium
+	StatePrefix = path.Join(kvstore.BaseKeyPrefix, "state")
+
+	// allocatorPrefixes is the list of base prefixes of all allocators
+	// storing state below StatePrefix. The keys of allocators are subject
+	// to additional consistency checks on import.
+	allocatorPrefixes = []string{
+		identity.IdentitiesPath,
+	}
+)
+
+// Entry is a single key of a snapshot. Exactly one of Value, Text and Binary
+// is set unless the value of the key is empty.
+type Entry struct {
+	// Key is the full path of the key
+	Key string `json:"key"`
+
+	// Value is the value of the key if the value is a JSON document
+	Value json.RawMessage `json:"value,omitempty"`
+
+	// Text is the value of the key if the value is valid UTF-8 but not
+	// a JSON document
+	Text string `json:"text,omitempty"`
+
+	// Binary is the value of the key if the value is not valid UTF-8
+	Binary []byte `json:"binary,omitempty"`
+
+	// Lease is true if the key is attached to a lease. It is
+	// informational only, whether a key is attached to a lease on import
+	// is derived from the key.
+	Lease bool `json:"lease,omitempty"`
+}
+
+// newEntry returns a new entry for key with the value encoded in the most
+// readable form which still allows to restore the value byte by byte
+func newEntry(key string, value []byte) Entry {
+	e := Entry{Key: key, Lease: isLeased(key)}
+
+	compacted := &bytes.Buffer{}
+	switch {
+	case len(value) == 0:
+	case json.Compact(compacted, value) == nil && bytes.Equal(compacted.Bytes(), value):
+		e.Value = json.RawMessage(value)
+	case utf8.Valid(value):
+		e.Text = string(value)
+	default:
+		e.Binary = value
+	}
+
+	return e
+}
+
+// Bytes returns the raw value of the entry as stored in the kvstore
+func (e *Entry) Bytes() []byte {
+	switch {
+	case len(e.Value) > 0:
+		return []byte(e.Value)
+	case e.Text != "":
+		return []byte(e.Text)
+	case len(e.Binary) > 0:
+		return e.Binary
+	default:
+		return []byte{}
+	}
+}
+
+// validate returns an error if the entry is malformed
+func (e *Entry) validate() error {
+	if !strings.HasPrefix(e.Key, StatePrefix+"/") {
+		return fmt.Errorf("key '%s' is outside of prefix '%s'", e.Key, StatePrefix)
+	}
+
+	set := 0
+	if len(e.Value) > 0 {
+		set++
+	}
+	if e.Text != "" {
+		set++
+	}
+	if len(e.Binary) > 0 {
+		set++
+	}
+	if set > 1 {
+		return fmt.Errorf("key '%s' has more than one value", e.Key)
+	}
+
+	return nil
+}
+
+// Snapshot is a point in time copy of all keys below Prefix
+type Snapshot struct {
+	// Version is the version of the snapshot format
+	Version int `json:"version"`
+
+	// Created is the time the snapshot was created
+	Created time.Time `json:"created"`
+
+	// Prefix is the kvstore prefix covered by the snapshot
+	Prefix string `json:"prefix"`
+
+	// Entries is the list of keys sorted by key
+	Entries []Entry `json:"entries"`
+}
+
+// Export returns a snapshot of all keys below StatePrefix. Keys used for
+// locking are excluded as they are only meaningful while held by a client.
+func Export(backend kvstore.BackendOperations) (*Snapshot, error) {
+	pairs, err := backend.ListPrefix(StatePrefix + "/")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list keys below '%s': %s", StatePrefix, err)
+	}
+
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		if !isLock(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	s := &Snapshot{
+		Version: Version,
+		Created: time.Now().UTC(),
+		Prefix:  StatePrefix,
+		Entries: make([]Entry, 0, len(keys)),
+	}
+
+	for _, key := range keys {
+		s.Entries = append(s.Entries, newEntry(key, pairs[key]))
+	}
+
+	return s, nil
+}
+
+// Write writes the snapshot in JSON format to w
+func (s *Snapshot) Write(w io.Writer) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// Read reads a snapshot in JSON format from r
+func Read(r io.Reader) (*Snapshot, error) {
+	s := &Snapshot{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, fmt.Errorf("unable to decode snapshot: %s", err)
+	}
+
+	// Values are indented by Write, compact them again to restore the
+	// original value
+	for i := range s.Entries {
+		if len(s.Entries[i].Value) > 0 {
+			compacted := &bytes.Buffer{}
+			if err := json.Compact(compacted, s.Entries[i].Value); err != nil {
+				return nil, fmt.Errorf("invalid value of key '%s': %s", s.Entries[i].Key, err)
+			}
+			s.Entries[i].Value = json.RawMessage(compacted.Bytes())
+		}
+	}
+
+	return s, nil
+}
+
+// GetModel returns the API model of the snapshot
+func (s *Snapshot) GetModel() (*models.KvstoreSnapshot, error) {
+	m := &models.KvstoreSnapshot{
+		Version: int64(s.Version),
+		Created: s.Created.Format(time.RFC3339Nano),
+		Prefix:  s.Prefix,
+		Entries: make([]*models.KvstoreSnapshotEntry, 0, len(s.Entries)),
+	}
+
+	for _, e := range s.Entries {
+		me := &models.KvstoreSnapshotEntry{
+			Key:    e.Key,
+			Text:   e.Text,
+			Binary: strfmt.Base64(e.Binary),
+			Lease:  e.Lease,
+		}
+
+		if len(e.Value) > 0 {
+			d := json.NewDecoder(bytes.NewReader(e.Value))
+			d.UseNumber()
+			if err := d.Decode(&me.Value); err != nil {
+				return nil, fmt.Errorf("unable to decode value of key '%s': %s", e.Key, err)
+			}
+		}
+
+		m.Entries = append(m.Entries, me)
+	}
+
+	return m, nil
+}
+
+// NewFromModel returns a snapshot from its API model
+func NewFromModel(m *models.KvstoreSnapshot) (*Snapshot, error) {
+	s := &Snapshot{
+		Version: int(m.Version),
+		Prefix:  m.Prefix,
+		Entries: make([]Entry, 0, len(m.Entries)),
+	}
+
+	if m.Created != "" {
+		created, err := time.Parse(time.RFC3339Nano, m.Created)
+		if err != nil {
+			return nil, fmt.Errorf("invalid creation time '%s': %s", m.Created, err)
+		}
+		s.Created = created
+	}
+
+	for _, me := range m.Entries {
+		if me == nil {
+			continue
+		}
+
+		e := Entry{
+			Key:    me.Key,
+			Text:   me.Text,
+			Binary: []byte(me.Binary),
+			Lease:  me.Lease,
+		}
+
+		if me.Value != nil {
+			b, err := json.Marshal(me.Value)
+			if err != nil {
+				return nil, fmt.Errorf("unable to encode value of key '%s': %s", me.Key, err)
+			}
+			e.Value = json.RawMessage(b)
+		}
+
+		s.Entries = append(s.Entries, e)
+	}
+
+	return s, nil
+}
+
+// allocatorPrefix returns the base prefix of the allocator which owns key
+// or an empty string if the key is not owned by an allocator
+func allocatorPrefix(key string) string {
+	for _, prefix := range allocatorPrefixes {
+		if strings.HasPrefix(key, prefix+"/") {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// isLock returns true if key is an allocator lock
+func isLock(key string) bool {
+	prefix := allocatorPrefix(key)
+	return prefix != "" && strings.HasPrefix(key, path.Join(prefix, "locks")+"/")
+}
+
+// isLeased returns true if key is attached to a lease when created by Cilium.
+// All keys with the exception of allocator master keys are leased.
+func isLeased(key string) bool {
+	prefix := allocatorPrefix(key)
+	return prefix == "" || !strings.HasPrefix(key, path.Join(prefix, "id")+"/")
+}
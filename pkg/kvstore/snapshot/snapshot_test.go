@@ -0,0 +1,285 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"bytes"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/cilium/cilium/pkg/identity"
+	"github.com/cilium/cilium/pkg/kvstore"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type SnapshotSuite struct{}
+
+var _ = Suite(&SnapshotSuite{})
+
+func (s *SnapshotSuite) SetUpTest(c *C) {
+	kvstore.SetupDummy(kvstore.MemoryBackendName)
+}
+
+func (s *SnapshotSuite) TearDownTest(c *C) {
+	kvstore.Close()
+}
+
+var (
+	masterKey = path.Join(identity.IdentitiesPath, "id", "1000")
+	valueKey  = path.Join(identity.IdentitiesPath, "value", "k8s:app=foo;", "node1")
+	lockKey   = path.Join(identity.IdentitiesPath, "locks", "k8s:app=foo;", "lock1")
+	ipKey     = path.Join(StatePrefix, "ip", "v1", "default", "10.0.0.1")
+	binaryKey = path.Join(StatePrefix, "binary")
+
+	testState = kvstore.KeyValuePairs{
+		masterKey: []byte("k8s:app=foo;"),
+		valueKey:  []byte("1000"),
+		ipKey:     []byte(`{"IP":"10.0.0.1","ID":1000}`),
+		binaryKey: []byte{0xff, 0x00, 0xfe},
+	}
+)
+
+func setState(c *C, pairs kvstore.KeyValuePairs) {
+	for key, value := range pairs {
+		c.Assert(kvstore.Set(key, value), IsNil)
+	}
+}
+
+func getState(c *C) kvstore.KeyValuePairs {
+	pairs, err := kvstore.ListPrefix(StatePrefix + "/")
+	c.Assert(err, IsNil)
+	return pairs
+}
+
+// resetKvstore replaces the kvstore with a new empty kvstore
+func resetKvstore() {
+	kvstore.Close()
+	kvstore.SetupDummy(kvstore.MemoryBackendName)
+}
+
+func (s *SnapshotSuite) TestEntryEncoding(c *C) {
+	e := newEntry(ipKey, testState[ipKey])
+	c.Assert(string(e.Value), Equals, string(testState[ipKey]))
+	c.Assert(e.Lease, Equals, true)
+
+	e = newEntry(masterKey, testState[masterKey])
+	c.Assert(e.Text, Equals, "k8s:app=foo;")
+	c.Assert(e.Lease, Equals, false)
+
+	e = newEntry(binaryKey, testState[binaryKey])
+	c.Assert(e.Binary, DeepEquals, testState[binaryKey])
+
+	// JSON which is not compact is stored as text to preserve the value
+	e = newEntry(ipKey, []byte(`{ "IP": "10.0.0.1" }`))
+	c.Assert(e.Value, IsNil)
+	c.Assert(e.Text, Equals, `{ "IP": "10.0.0.1" }`)
+
+	e = newEntry(ipKey, []byte{})
+	c.Assert(e.Bytes(), DeepEquals, []byte{})
+}
+
+func (s *SnapshotSuite) TestExportImport(c *C) {
+	setState(c, testState)
+	c.Assert(kvstore.Set(lockKey, []byte("")), IsNil)
+	c.Assert(kvstore.Set("unrelated", []byte("value")), IsNil)
+
+	snap, err := Export(kvstore.Client())
+	c.Assert(err, IsNil)
+	c.Assert(snap.Version, Equals, Version)
+	c.Assert(len(snap.Entries), Equals, len(testState))
+
+	buf := &bytes.Buffer{}
+	c.Assert(snap.Write(buf), IsNil)
+
+	snap, err = Read(buf)
+	c.Assert(err, IsNil)
+
+	resetKvstore()
+	result, err := Import(kvstore.Client(), snap, ImportOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(*result, Equals, ImportResult{Created: len(testState)})
+	c.Assert(getState(c), DeepEquals, testState)
+
+	// A second import does not modify any keys
+	c.Assert(kvstore.Set(ipKey, []byte(`{"IP":"10.0.0.1","ID":2000}`)), IsNil)
+	result, err = Import(kvstore.Client(), snap, ImportOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(*result, Equals, ImportResult{Unchanged: len(testState) - 1, Skipped: 1})
+
+	v, err := kvstore.Get(ipKey)
+	c.Assert(err, IsNil)
+	c.Assert(string(v), Equals, `{"IP":"10.0.0.1","ID":2000}`)
+}
+
+func (s *SnapshotSuite) TestModel(c *C) {
+	setState(c, testState)
+
+	snap, err := Export(kvstore.Client())
+	c.Assert(err, IsNil)
+
+	m, err := snap.GetModel()
+	c.Assert(err, IsNil)
+
+	snap, err = NewFromModel(m)
+	c.Assert(err, IsNil)
+
+	resetKvstore()
+	_, err = Import(kvstore.Client(), snap, ImportOptions{})
+	c.Assert(err, IsNil)
+
+	state := getState(c)
+	c.Assert(len(state), Equals, len(testState))
+	c.Assert(state[masterKey], DeepEquals, testState[masterKey])
+	c.Assert(state[binaryKey], DeepEquals, testState[binaryKey])
+}
+
+func (s *SnapshotSuite) TestDryRun(c *C) {
+	setState(c, testState)
+
+	snap, err := Export(kvstore.Client())
+	c.Assert(err, IsNil)
+
+	resetKvstore()
+	result, err := Import(kvstore.Client(), snap, ImportOptions{DryRun: true})
+	c.Assert(err, IsNil)
+	c.Assert(result.Created, Equals, len(testState))
+	c.Assert(len(getState(c)), Equals, 0)
+}
+
+func (s *SnapshotSuite) TestInvalid(c *C) {
+	snap := &Snapshot{Version: Version + 1, Prefix: StatePrefix}
+	_, err := Import(kvstore.Client(), snap, ImportOptions{})
+	c.Assert(err, FitsTypeOf, &InvalidError{})
+
+	snap = &Snapshot{Version: Version, Prefix: StatePrefix, Entries: []Entry{{Key: "foo"}}}
+	_, err = Import(kvstore.Client(), snap, ImportOptions{})
+	c.Assert(err, FitsTypeOf, &InvalidError{})
+
+	snap = &Snapshot{Version: Version, Prefix: StatePrefix, Entries: []Entry{
+		newEntry(ipKey, []byte("a")),
+		newEntry(ipKey, []byte("b")),
+	}}
+	_, err = Import(kvstore.Client(), snap, ImportOptions{})
+	c.Assert(err, FitsTypeOf, &InvalidError{})
+}
+
+func (s *SnapshotSuite) TestConflicts(c *C) {
+	newSnapshot := func(pairs kvstore.KeyValuePairs) *Snapshot {
+		snap := &Snapshot{Version: Version, Prefix: StatePrefix}
+		for key, value := range pairs {
+			snap.Entries = append(snap.Entries, newEntry(key, value))
+		}
+		return snap
+	}
+
+	otherMasterKey := path.Join(identity.IdentitiesPath, "id", "2000")
+
+	// ID allocated twice within the snapshot
+	_, err := Import(kvstore.Client(), newSnapshot(kvstore.KeyValuePairs{
+		masterKey:      []byte("k8s:app=foo;"),
+		otherMasterKey: []byte("k8s:app=foo;"),
+	}), ImportOptions{})
+	c.Assert(err, FitsTypeOf, &ConflictError{})
+
+	// Value key referring to an unallocated ID
+	_, err = Import(kvstore.Client(), newSnapshot(kvstore.KeyValuePairs{
+		valueKey: []byte("1000"),
+	}), ImportOptions{})
+	c.Assert(err, FitsTypeOf, &ConflictError{})
+
+	// Value key referring to an ID allocated to a different key
+	_, err = Import(kvstore.Client(), newSnapshot(kvstore.KeyValuePairs{
+		masterKey: []byte("k8s:app=bar;"),
+		valueKey:  []byte("1000"),
+	}), ImportOptions{})
+	c.Assert(err, FitsTypeOf, &ConflictError{})
+
+	// The same ID is allocated to a different key in the kvstore
+	c.Assert(kvstore.Set(masterKey, []byte("k8s:app=bar;")), IsNil)
+	_, err = Import(kvstore.Client(), newSnapshot(testState), ImportOptions{})
+	c.Assert(err, FitsTypeOf, &ConflictError{})
+
+	// The same key is allocated to a different ID in the kvstore
+	c.Assert(kvstore.Delete(masterKey), IsNil)
+	c.Assert(kvstore.Set(otherMasterKey, []byte("k8s:app=foo;")), IsNil)
+	_, err = Import(kvstore.Client(), newSnapshot(testState), ImportOptions{})
+	c.Assert(err, FitsTypeOf, &ConflictError{})
+
+	// No keys have been written
+	c.Assert(getState(c), DeepEquals, kvstore.KeyValuePairs{otherMasterKey: []byte("k8s:app=foo;")})
+}
+
+func (s *SnapshotSuite) TestImportLease(c *C) {
+	otherValueKey := path.Join(identity.IdentitiesPath, "value", "k8s:app=foo;", "node2")
+
+	c.Assert(isOwned(valueKey, "node1"), Equals, true)
+	c.Assert(isOwned(valueKey, ""), Equals, false)
+	c.Assert(isOwned(otherValueKey, "node1"), Equals, false)
+	c.Assert(isOwned(masterKey, "node1"), Equals, false)
+	c.Assert(isOwned(path.Join(StatePrefix, "ip", "v1", "default", "node1"), "node1"), Equals, false)
+
+	importLease := "import-lease"
+
+	for _, tt := range []struct {
+		key         string
+		owner       string
+		lease       bool
+		importLease bool
+	}{
+		{key: valueKey, owner: "node1", lease: true},
+		{key: valueKey, owner: "", importLease: true},
+		{key: otherValueKey, owner: "node1", importLease: true},
+		{key: ipKey, owner: "node1", importLease: true},
+		{key: masterKey, owner: "node1"},
+	} {
+		e := newEntry(tt.key, testState[valueKey])
+		txn := importTxn(&e, tt.owner, importLease)
+		c.Assert(txn.Ops, HasLen, 1)
+		c.Assert(txn.Ops[0].Lease, Equals, tt.lease, Commentf("key %s owner %s", tt.key, tt.owner))
+		c.Assert(txn.Ops[0].LeaseInstance != nil, Equals, tt.importLease, Commentf("key %s owner %s", tt.key, tt.owner))
+	}
+}
+
+func (s *SnapshotSuite) TestImportLeaseExpiry(c *C) {
+	setState(c, testState)
+
+	snap, err := Export(kvstore.Client())
+	c.Assert(err, IsNil)
+
+	resetKvstore()
+	_, err = Import(kvstore.Client(), snap, ImportOptions{Owner: "node1", LeaseTTL: time.Second})
+	c.Assert(err, IsNil)
+	c.Assert(getState(c), DeepEquals, testState)
+
+	// Keys owned by other nodes are removed with the import lease, the
+	// master keys and the keys of the importing node remain
+	expected := kvstore.KeyValuePairs{
+		masterKey: testState[masterKey],
+		valueKey:  testState[valueKey],
+	}
+	for i := 0; i < 50; i++ {
+		if len(getState(c)) == len(expected) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	c.Assert(getState(c), DeepEquals, expected)
+}
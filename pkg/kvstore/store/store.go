@@ -69,6 +69,10 @@ type Configuration struct {
 	// KeyCreator is called to allocate a Key instance when a new shared
 	// key is discovered. This parameter is required.
 	KeyCreator KeyCreator
+
+	// Backend is the kvstore to use as a backend. If no backend is
+	// specified, kvstore.Client() is being used.
+	Backend kvstore.BackendOperations
 }
 
 // validate is invoked by JoinSharedStore to validate and complete the
@@ -86,6 +90,10 @@ func (c *Configuration) validate() error {
 		c.SynchronizationInterval = synchronizationIntervalDefault
 	}
 
+	if c.Backend == nil {
+		c.Backend = kvstore.Client()
+	}
+
 	return nil
 }
 
@@ -206,7 +214,7 @@ func (s *SharedStore) Close() {
 	controllers.RemoveController(s.controllerName)
 
 	for name, key := range s.localKeys {
-		if err := kvstore.Delete(s.keyPath(key)); err != nil {
+		if err := s.conf.Backend.Delete(s.keyPath(key)); err != nil {
 			s.getLogger().WithError(err).Warning("Unable to delete key in kvstore")
 		}
 
@@ -231,7 +239,7 @@ func (s *SharedStore) syncLocalKey(key LocalKey) error {
 
 	// Update key in kvstore, overwrite an eventual existing key, attach
 	// lease to expire entry when agent dies and never comes back up.
-	if err := kvstore.Update(s.keyPath(key), jsonValue, true); err != nil {
+	if err := s.conf.Backend.Update(s.keyPath(key), jsonValue, true); err != nil {
 		return err
 	}
 
@@ -279,9 +287,18 @@ func (s *SharedStore) UpdateLocalKeySync(key LocalKey) error {
 	return nil
 }
 
+// ReplaceLocalKeySync replaces a local key and synchronously synchronizes it
+// with the kvstore. Unlike UpdateLocalKeySync(), the key is not removed if the
+// synchronization fails, it is synchronized again by the controller of the
+// store instead.
+func (s *SharedStore) ReplaceLocalKeySync(key LocalKey) error {
+	s.UpdateLocalKey(key)
+	return s.syncLocalKey(key)
+}
+
 // DeleteLocalKey removes a key from being synchronized with the kvstore
 func (s *SharedStore) DeleteLocalKey(key LocalKey) {
-	err := kvstore.Delete(s.keyPath(key))
+	err := s.conf.Backend.Delete(s.keyPath(key))
 	name := key.GetKeyName()
 
 	s.mutex.Lock()
@@ -298,6 +315,16 @@ func (s *SharedStore) DeleteLocalKey(key LocalKey) {
 	}
 }
 
+// ReleaseLocalKeys stops synchronizing all local keys with the kvstore
+// without deleting them. The keys remain in the kvstore until another
+// collaborator updates or deletes them or until the lease they are attached
+// to expires.
+func (s *SharedStore) ReleaseLocalKeys() {
+	s.mutex.Lock()
+	s.localKeys = map[string]LocalKey{}
+	s.mutex.Unlock()
+}
+
 // getLocalKeys returns all local keys
 func (s *SharedStore) getLocalKeys() []Key {
 	s.mutex.RLock()
@@ -388,7 +415,7 @@ func (s *SharedStore) listAndStartWatcher() error {
 }
 
 func (s *SharedStore) watcher(listDone chan bool) {
-	s.kvstoreWatcher = kvstore.ListAndWatch(s.name+"-watcher", s.conf.Prefix, watcherChanSize)
+	s.kvstoreWatcher = s.conf.Backend.ListAndWatch(s.name+"-watcher", s.conf.Prefix, watcherChanSize)
 
 	for {
 		select {
@@ -66,6 +66,41 @@ func (e *StoreConsulSuite) TearDownTest(c *C) {
 	kvstore.Close()
 }
 
+type StoreMemorySuite struct {
+	StoreSuite
+}
+
+var _ = Suite(&StoreMemorySuite{})
+
+func (e *StoreMemorySuite) SetUpTest(c *C) {
+	kvstore.SetupDummy(kvstore.MemoryBackendName)
+}
+
+func (e *StoreMemorySuite) TearDownTest(c *C) {
+	kvstore.Close()
+}
+
+// StoreEncryptedMemorySuite runs all tests with the encryption of kvstore
+// values enabled
+type StoreEncryptedMemorySuite struct {
+	StoreSuite
+}
+
+var _ = Suite(&StoreEncryptedMemorySuite{})
+
+func (e *StoreEncryptedMemorySuite) SetUpTest(c *C) {
+	// Test key, do not use in production
+	keys, err := kvstore.ParseEncryptionKeys([]byte("test:AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="))
+	c.Assert(err, IsNil)
+	kvstore.SetEncryptionKeys(keys)
+	kvstore.SetupDummy(kvstore.MemoryBackendName)
+}
+
+func (e *StoreEncryptedMemorySuite) TearDownTest(c *C) {
+	kvstore.Close()
+	kvstore.SetEncryptionKeys(nil)
+}
+
 type TestType struct {
 	Name string
 
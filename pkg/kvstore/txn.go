@@ -0,0 +1,222 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TxnCompareType is the type of a transaction comparison
+type TxnCompareType int
+
+const (
+	// TxnCompareMissing requires the key to not exist
+	TxnCompareMissing TxnCompareType = iota
+
+	// TxnCompareExists requires the key to exist
+	TxnCompareExists
+
+	// TxnCompareValue requires the key to exist and to have the specified
+	// value
+	TxnCompareValue
+)
+
+// String returns the human readable format of a comparison type
+func (t TxnCompareType) String() string {
+	switch t {
+	case TxnCompareMissing:
+		return "missing"
+	case TxnCompareExists:
+		return "exists"
+	case TxnCompareValue:
+		return "value"
+	default:
+		return "unknown"
+	}
+}
+
+// TxnCompare is a condition which must be met for a transaction to be
+// performed
+type TxnCompare struct {
+	Typ   TxnCompareType
+	Key   string
+	Value []byte
+}
+
+// String returns the human readable format of a comparison
+func (c TxnCompare) String() string {
+	if c.Typ == TxnCompareValue {
+		return fmt.Sprintf("%s(%s=%s)", c.Typ, c.Key, string(c.Value))
+	}
+	return fmt.Sprintf("%s(%s)", c.Typ, c.Key)
+}
+
+// TxnOpType is the type of a transaction operation
+type TxnOpType int
+
+const (
+	// TxnOpPut creates or updates a key
+	TxnOpPut TxnOpType = iota
+
+	// TxnOpDelete deletes a key
+	TxnOpDelete
+)
+
+// TxnOp is a single operation performed as part of a transaction
+type TxnOp struct {
+	Typ   TxnOpType
+	Key   string
+	Value []byte
+
+	// Lease is true if the key must be attached to the default lease
+	Lease bool
+
+	// LeaseInstance, if set, is the lease the key must be attached to
+	// instead of the default lease. It must have been created with
+	// CreateLease() of the client performing the transaction.
+	LeaseInstance interface{}
+}
+
+// lease returns the lease the key must be attached to and false if the key
+// is not attached to any lease
+func (o TxnOp) lease() (interface{}, bool) {
+	switch {
+	case o.LeaseInstance != nil:
+		return o.LeaseInstance, true
+	case o.Lease:
+		return leaseInstance, true
+	default:
+		return nil, false
+	}
+}
+
+// String returns the human readable format of an operation
+func (o TxnOp) String() string {
+	if o.Typ == TxnOpDelete {
+		return fmt.Sprintf("delete(%s)", o.Key)
+	}
+	_, lease := o.lease()
+	return fmt.Sprintf("put(%s=%s, lease=%t)", o.Key, string(o.Value), lease)
+}
+
+// Txn is a transaction consisting of a list of conditions and a list of
+// operations. The operations are performed atomically if and only if all
+// conditions are met.
+type Txn struct {
+	Compares []TxnCompare
+	Ops      []TxnOp
+}
+
+// NewTxn returns a new empty transaction. Conditions and operations can be
+// added by chaining the methods of the returned transaction, e.g.:
+//
+//     kvstore.NewTxn().IfMissing(a).Put(a, v, false).Put(b, v, true)
+func NewTxn() *Txn {
+	return &Txn{}
+}
+
+// IfMissing requires key to not exist
+func (t *Txn) IfMissing(key string) *Txn {
+	t.Compares = append(t.Compares, TxnCompare{Typ: TxnCompareMissing, Key: key})
+	return t
+}
+
+// IfExists requires key to exist
+func (t *Txn) IfExists(key string) *Txn {
+	t.Compares = append(t.Compares, TxnCompare{Typ: TxnCompareExists, Key: key})
+	return t
+}
+
+// IfValue requires key to exist with the specified value
+func (t *Txn) IfValue(key string, value []byte) *Txn {
+	t.Compares = append(t.Compares, TxnCompare{Typ: TxnCompareValue, Key: key, Value: value})
+	return t
+}
+
+// Put creates or updates key with value. If lease is true, the key is
+// attached to the default lease.
+func (t *Txn) Put(key string, value []byte, lease bool) *Txn {
+	t.Ops = append(t.Ops, TxnOp{Typ: TxnOpPut, Key: key, Value: value, Lease: lease})
+	return t
+}
+
+// PutWithLease creates or updates key with value and attaches the key to
+// lease. The lease must have been created with CreateLease() of the client
+// performing the transaction.
+func (t *Txn) PutWithLease(key string, value []byte, lease interface{}) *Txn {
+	t.Ops = append(t.Ops, TxnOp{Typ: TxnOpPut, Key: key, Value: value, LeaseInstance: lease})
+	return t
+}
+
+// Delete deletes key
+func (t *Txn) Delete(key string) *Txn {
+	t.Ops = append(t.Ops, TxnOp{Typ: TxnOpDelete, Key: key})
+	return t
+}
+
+// keys returns all keys referenced by the transaction
+func (t *Txn) keys() []string {
+	keys := []string{}
+	seen := map[string]struct{}{}
+
+	add := func(key string) {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+
+	for _, c := range t.Compares {
+		add(c.Key)
+	}
+	for _, o := range t.Ops {
+		add(o.Key)
+	}
+
+	return keys
+}
+
+// evaluate returns true if the comparison is met by the value. A nil value
+// represents a missing key.
+func (c TxnCompare) evaluate(value []byte) bool {
+	switch c.Typ {
+	case TxnCompareMissing:
+		return value == nil
+	case TxnCompareExists:
+		return value != nil
+	case TxnCompareValue:
+		return value != nil && bytes.Equal(value, c.Value)
+	default:
+		return false
+	}
+}
+
+// Commit performs the transaction. If all conditions are met, all operations
+// are performed atomically and true is returned. If any condition is not met,
+// no operation is performed and false is returned. An error is returned if
+// the transaction could not be performed, in which case none of the
+// operations have been applied.
+func Commit(txn *Txn) (bool, error) {
+	succeeded, err := Client().Commit(txn)
+	Trace("Commit", err, logrus.Fields{
+		fieldCondition: txn.Compares,
+		fieldOps:       txn.Ops,
+		fieldSucceeded: succeeded,
+	})
+	return succeeded, err
+}
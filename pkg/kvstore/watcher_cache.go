@@ -16,6 +16,9 @@ package kvstore
 
 type watchState struct {
 	deletionMark bool
+
+	// revision is the revision at which the key was last modified
+	revision uint64
 }
 
 type watcherCache map[string]watchState
@@ -28,6 +31,13 @@ func (wc watcherCache) Exists(key []byte) bool {
 	return false
 }
 
+// Changed returns true if the key is unknown or has been modified at a
+// different revision than the revision last seen
+func (wc watcherCache) Changed(key []byte, revision uint64) bool {
+	state, ok := wc[string(key)]
+	return !ok || state.revision != revision
+}
+
 func (wc watcherCache) RemoveDeleted(f func(string)) {
 	for k, localKey := range wc {
 		if localKey.deletionMark {
@@ -38,13 +48,14 @@ func (wc watcherCache) RemoveDeleted(f func(string)) {
 }
 
 func (wc watcherCache) MarkAllForDeletion() {
-	for k := range wc {
-		wc[k] = watchState{deletionMark: true}
+	for k, state := range wc {
+		state.deletionMark = true
+		wc[k] = state
 	}
 }
 
-func (wc watcherCache) MarkInUse(key []byte) {
-	wc[string(key)] = watchState{deletionMark: false}
+func (wc watcherCache) MarkInUse(key []byte, revision uint64) {
+	wc[string(key)] = watchState{deletionMark: false, revision: revision}
 }
 
 func (wc watcherCache) RemoveKey(key []byte) {
@@ -0,0 +1,61 @@
+// Copyright 2016-2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"sort"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *independentSuite) TestWatcherCacheRelist(c *C) {
+	wc := watcherCache{}
+	wc.MarkInUse([]byte("foo"), 1)
+	wc.MarkInUse([]byte("bar"), 2)
+	wc.MarkInUse([]byte("baz"), 3)
+
+	// Relist after foo has been modified and bar has been deleted
+	wc.MarkAllForDeletion()
+	c.Assert(wc.Changed([]byte("foo"), 4), Equals, true)
+	wc.MarkInUse([]byte("foo"), 4)
+	c.Assert(wc.Changed([]byte("baz"), 3), Equals, false)
+	wc.MarkInUse([]byte("baz"), 3)
+	c.Assert(wc.Changed([]byte("qux"), 5), Equals, true)
+	wc.MarkInUse([]byte("qux"), 5)
+
+	deleted := []string{}
+	wc.RemoveDeleted(func(k string) { deleted = append(deleted, k) })
+	c.Assert(deleted, DeepEquals, []string{"bar"})
+
+	keys := []string{}
+	for k := range wc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	c.Assert(keys, DeepEquals, []string{"baz", "foo", "qux"})
+	c.Assert(wc.Changed([]byte("foo"), 4), Equals, false)
+}
+
+func (s *independentSuite) TestWatcherRevision(c *C) {
+	w := newWatcher("test", "foo", 0)
+	c.Assert(w.LastRevision(), Equals, uint64(0))
+
+	w.setRevision(10)
+	c.Assert(w.LastRevision(), Equals, uint64(10))
+
+	w.restarted(true)
+	w.restarted(false)
+	c.Assert(w.LastRevision(), Equals, uint64(10))
+}
@@ -161,6 +161,7 @@ func defaultLabelPrefixCfg() *labelPrefixCfg {
 	expressions := []string{
 		k8sConst.PodNamespaceLabel,                                 // include io.kubernetes.pod.namspace
 		k8sConst.PodNamespaceMetaLabels,                            // include all namespace labels
+		k8sConst.PolicyLabelCluster,                                // include io.cilium.k8s.policy.cluster
 		"!io.kubernetes",                                           // ignore all other io.kubernetes labels
 		"!.*kubernetes.io",                                         // ignore all other kubernetes.io labels (annotation.*.k8s.io)
 		"!pod-template-generation",                                 // ignore pod-template-generation
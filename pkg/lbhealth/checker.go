@@ -0,0 +1,295 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lbhealth implements active health checking of the backends of
+// load balanced services.
+package lbhealth
+
+import (
+	"sync"
+
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/controller"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/logging"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.DefaultLogger.WithField(logfields.LogSubsys, "lb-health")
+
+const (
+	// UnhealthyThreshold is the number of consecutive failed probes after
+	// which a backend is considered unhealthy
+	UnhealthyThreshold = 3
+
+	// HealthyThreshold is the number of consecutive successful probes
+	// after which an unhealthy backend is considered healthy again
+	HealthyThreshold = 2
+)
+
+// ProbeFunc checks the health of a backend, it returns an error if the
+// backend is unhealthy.
+type ProbeFunc func(config types.HealthCheckConfig, be types.L3n4Addr) error
+
+// NotifyFunc is called with the service identifier whenever the health of
+// one of the backends of the service has changed.
+type NotifyFunc func(svc string)
+
+type backend struct {
+	addr      types.L3n4Addr
+	health    types.BackendHealth
+	failures  int
+	successes int
+}
+
+// update records the result of a probe and returns true if the health of
+// the backend has changed.
+func (b *backend) update(err error) bool {
+	old := b.health
+
+	if err != nil {
+		b.successes = 0
+		b.failures++
+		if b.failures >= UnhealthyThreshold {
+			b.health = types.BackendHealthUnhealthy
+		}
+	} else {
+		b.failures = 0
+		b.successes++
+		if b.health != types.BackendHealthUnhealthy || b.successes >= HealthyThreshold {
+			b.health = types.BackendHealthHealthy
+		}
+	}
+
+	return b.health != old
+}
+
+type service struct {
+	config   types.HealthCheckConfig
+	backends map[string]*backend
+}
+
+// Checker periodically probes the backends of all health checked services.
+type Checker struct {
+	mutex    lock.RWMutex
+	services map[string]*service
+
+	probe       ProbeFunc
+	notify      NotifyFunc
+	controllers *controller.Manager
+}
+
+// NewChecker returns a new health checker which probes backends with probe
+// and calls notify whenever the health of a backend changes. notify is never
+// called with internal locks held, so it may call back into the checker.
+func NewChecker(probe ProbeFunc, notify NotifyFunc) *Checker {
+	return &Checker{
+		services:    map[string]*service{},
+		probe:       probe,
+		notify:      notify,
+		controllers: controller.NewManager(),
+	}
+}
+
+func controllerName(svc string) string {
+	return "lb-health-check-" + svc
+}
+
+// Update starts or updates the health checking of the backends of the
+// service svc. The health of backends which were already checked is kept.
+// Health checking is stopped if config is disabled.
+func (c *Checker) Update(svc string, config types.HealthCheckConfig, backends []types.L3n4Addr) {
+	if !config.IsEnabled() {
+		c.Remove(svc)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	old, ok := c.services[svc]
+	newSvc := &service{
+		config:   config,
+		backends: map[string]*backend{},
+	}
+
+	for _, addr := range backends {
+		key := addr.String()
+		if ok {
+			if be, found := old.backends[key]; found {
+				newSvc.backends[key] = be
+				continue
+			}
+		}
+		newSvc.backends[key] = &backend{
+			addr:   *addr.DeepCopy(),
+			health: types.BackendHealthUnknown,
+		}
+	}
+
+	if ok {
+		for key, be := range old.backends {
+			if _, found := newSvc.backends[key]; !found && be.health == types.BackendHealthUnhealthy {
+				metrics.ServicesBackendsUnhealthy.Dec()
+			}
+		}
+	}
+
+	c.services[svc] = newSvc
+
+	if ok && old.config == config {
+		return
+	}
+
+	c.controllers.UpdateController(controllerName(svc),
+		controller.ControllerParams{
+			DoFunc: func() error {
+				c.probeService(svc)
+				return nil
+			},
+			RunInterval: config.Interval,
+		},
+	)
+}
+
+// Remove stops the health checking of the service svc.
+func (c *Checker) Remove(svc string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	old, ok := c.services[svc]
+	if !ok {
+		return
+	}
+
+	for _, be := range old.backends {
+		if be.health == types.BackendHealthUnhealthy {
+			metrics.ServicesBackendsUnhealthy.Dec()
+		}
+	}
+
+	delete(c.services, svc)
+	c.controllers.RemoveController(controllerName(svc))
+}
+
+// RemoveAll stops the health checking of all services.
+func (c *Checker) RemoveAll() {
+	c.mutex.Lock()
+	svcs := make([]string, 0, len(c.services))
+	for svc := range c.services {
+		svcs = append(svcs, svc)
+	}
+	c.mutex.Unlock()
+
+	for _, svc := range svcs {
+		c.Remove(svc)
+	}
+}
+
+// GetHealth returns the health of the backend be of the service svc, or an
+// empty health if the service is not health checked.
+func (c *Checker) GetHealth(svc string, be types.L3n4Addr) types.BackendHealth {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	s, ok := c.services[svc]
+	if !ok {
+		return ""
+	}
+
+	if b, ok := s.backends[be.String()]; ok {
+		return b.health
+	}
+	return types.BackendHealthUnknown
+}
+
+// probeService probes all backends of the service svc in parallel and
+// notifies about health changes once all probes have completed.
+func (c *Checker) probeService(svc string) {
+	c.mutex.RLock()
+	s, ok := c.services[svc]
+	if !ok {
+		c.mutex.RUnlock()
+		return
+	}
+	backends := make([]*backend, 0, len(s.backends))
+	for _, be := range s.backends {
+		backends = append(backends, be)
+	}
+	c.mutex.RUnlock()
+
+	errs := make([]error, len(backends))
+	var wg sync.WaitGroup
+	for i, be := range backends {
+		wg.Add(1)
+		go func(i int, addr types.L3n4Addr) {
+			defer wg.Done()
+			errs[i] = c.probe(s.config, addr)
+		}(i, be.addr)
+	}
+	wg.Wait()
+
+	changed := false
+
+	c.mutex.Lock()
+	// The service may have been removed or updated while probing, the
+	// results of removed backends must then be discarded.
+	cur, ok := c.services[svc]
+	if !ok {
+		c.mutex.Unlock()
+		return
+	}
+
+	for i, be := range backends {
+		if cur.backends[be.addr.String()] != be {
+			continue
+		}
+
+		outcome := metrics.LabelValueOutcomeSuccess
+		if errs[i] != nil {
+			outcome = metrics.LabelValueOutcomeFail
+		}
+		metrics.ServicesHealthChecks.WithLabelValues(string(s.config.Type), outcome).Inc()
+
+		old := be.health
+		if !be.update(errs[i]) {
+			continue
+		}
+		changed = true
+
+		scopedLog := log.WithFields(logrus.Fields{
+			logfields.SHA: svc,
+			"backend":     be.addr.String(),
+			"health":      be.health,
+		})
+		switch {
+		case be.health == types.BackendHealthUnhealthy:
+			metrics.ServicesBackendsUnhealthy.Inc()
+			scopedLog.WithError(errs[i]).Info("Service backend is unhealthy, removing it from the load balancer")
+		case old == types.BackendHealthUnhealthy:
+			metrics.ServicesBackendsUnhealthy.Dec()
+			scopedLog.Info("Service backend has recovered, adding it back to the load balancer")
+		default:
+			scopedLog.Debug("Service backend health changed")
+		}
+	}
+	c.mutex.Unlock()
+
+	if changed && c.notify != nil {
+		c.notify(svc)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbhealth
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/lock"
+
+	"gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type LBHealthSuite struct{}
+
+var _ = check.Suite(&LBHealthSuite{})
+
+func backendAddr(c *check.C, hostPort string) types.L3n4Addr {
+	host, portStr, err := net.SplitHostPort(hostPort)
+	c.Assert(err, check.IsNil)
+	port, err := strconv.Atoi(portStr)
+	c.Assert(err, check.IsNil)
+
+	return types.L3n4Addr{
+		IP:     net.ParseIP(host),
+		L4Addr: types.L4Addr{Protocol: types.TCP, Port: uint16(port)},
+	}
+}
+
+func (s *LBHealthSuite) TestProbeTCP(c *check.C) {
+	config := types.HealthCheckConfig{Type: types.HealthCheckTCP, Timeout: time.Second}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	addr := backendAddr(c, l.Addr().String())
+
+	c.Assert(Probe(config, addr), check.IsNil)
+
+	l.Close()
+	c.Assert(Probe(config, addr), check.Not(check.IsNil))
+}
+
+func (s *LBHealthSuite) TestProbeHTTP(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.WriteHeader(http.StatusOK)
+		case "/moved":
+			http.Redirect(w, r, "http://192.0.2.1/", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	addr := backendAddr(c, server.Listener.Addr().String())
+	config := types.HealthCheckConfig{Type: types.HealthCheckHTTP, Path: "/healthz", Timeout: time.Second}
+	c.Assert(Probe(config, addr), check.IsNil)
+
+	config.Path = "/moved"
+	c.Assert(Probe(config, addr), check.IsNil)
+
+	config.Path = "/down"
+	c.Assert(Probe(config, addr), check.Not(check.IsNil))
+}
+
+func (s *LBHealthSuite) TestBackendUpdate(c *check.C) {
+	be := &backend{health: types.BackendHealthUnknown}
+	failure := errors.New("failure")
+
+	c.Assert(be.update(nil), check.Equals, true)
+	c.Assert(be.health, check.Equals, types.BackendHealthHealthy)
+
+	for i := 1; i < UnhealthyThreshold; i++ {
+		c.Assert(be.update(failure), check.Equals, false)
+	}
+	c.Assert(be.update(failure), check.Equals, true)
+	c.Assert(be.health, check.Equals, types.BackendHealthUnhealthy)
+
+	for i := 1; i < HealthyThreshold; i++ {
+		c.Assert(be.update(nil), check.Equals, false)
+	}
+	c.Assert(be.update(nil), check.Equals, true)
+	c.Assert(be.health, check.Equals, types.BackendHealthHealthy)
+}
+
+func (s *LBHealthSuite) TestChecker(c *check.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer l.Close()
+	healthy := backendAddr(c, l.Addr().String())
+
+	// Reserve a port and close the listener to get a backend refusing
+	// connections
+	l2, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	down := backendAddr(c, l2.Addr().String())
+	l2.Close()
+
+	var mutex lock.Mutex
+	notified := 0
+	checker := NewChecker(Probe, func(svc string) {
+		mutex.Lock()
+		notified++
+		mutex.Unlock()
+	})
+	defer checker.RemoveAll()
+
+	config := types.HealthCheckConfig{
+		Type:     types.HealthCheckTCP,
+		Interval: 10 * time.Millisecond,
+		Timeout:  10 * time.Millisecond,
+	}
+
+	c.Assert(checker.GetHealth("svc", healthy), check.Equals, types.BackendHealth(""))
+	checker.Update("svc", config, []types.L3n4Addr{healthy, down})
+
+	waitForHealth := func(be types.L3n4Addr, health types.BackendHealth) {
+		for i := 0; i < 100; i++ {
+			if checker.GetHealth("svc", be) == health {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		c.Fatalf("backend %s did not become %s", be.String(), health)
+	}
+
+	waitForHealth(healthy, types.BackendHealthHealthy)
+	waitForHealth(down, types.BackendHealthUnhealthy)
+
+	mutex.Lock()
+	c.Assert(notified > 0, check.Equals, true)
+	mutex.Unlock()
+
+	// The recovered backend is added back
+	l2, err = net.Listen("tcp", down.String())
+	c.Assert(err, check.IsNil)
+	defer l2.Close()
+	waitForHealth(down, types.BackendHealthHealthy)
+
+	// Disabling health checks removes the service
+	checker.Update("svc", types.HealthCheckConfig{}, []types.L3n4Addr{healthy, down})
+	c.Assert(checker.GetHealth("svc", healthy), check.Equals, types.BackendHealth(""))
+}
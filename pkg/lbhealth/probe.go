@@ -0,0 +1,64 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbhealth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/cilium/cilium/common/types"
+)
+
+// Probe checks the health of the backend be according to config and returns
+// an error if the backend is unhealthy.
+func Probe(config types.HealthCheckConfig, be types.L3n4Addr) error {
+	addr := net.JoinHostPort(be.IP.String(), strconv.Itoa(int(be.Port)))
+
+	switch config.Type {
+	case types.HealthCheckTCP:
+		conn, err := net.DialTimeout("tcp", addr, config.Timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case types.HealthCheckHTTP:
+		client := http.Client{
+			Timeout: config.Timeout,
+			// A redirect is a valid response of a healthy
+			// backend, it must not be followed to another host
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+			Transport: &http.Transport{DisableKeepAlives: true},
+		}
+
+		resp, err := client.Get("http://" + addr + config.Path)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("unexpected HTTP status %d", resp.StatusCode)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown health check type %q", config.Type)
+	}
+}
@@ -117,9 +117,11 @@ func NewKey(ip net.IP, mask net.IPMask) Key {
 
 // RemoteEndpointInfo implements the bpf.MapValue interface. It contains the
 // security identity of a remote endpoint.
+//
+// Must be in sync with struct remote_endpoint_info in <bpf/lib/common.h>
 type RemoteEndpointInfo struct {
-	SecurityIdentity uint16
-	Pad              [3]uint16
+	SecurityIdentity uint32
+	Pad              uint32
 }
 
 func (v *RemoteEndpointInfo) String() string {
@@ -0,0 +1,63 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"unsafe"
+
+	"github.com/cilium/cilium/pkg/bpf"
+	"github.com/cilium/cilium/pkg/byteorder"
+	"github.com/cilium/cilium/pkg/identity"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type IPCacheMapTestSuite struct{}
+
+var _ = Suite(&IPCacheMapTestSuite{})
+
+func (s *IPCacheMapTestSuite) TestRemoteEndpointInfoSize(c *C) {
+	// Must match struct remote_endpoint_info in "bpf/lib/common.h"
+	c.Assert(unsafe.Sizeof(RemoteEndpointInfo{}), Equals, uintptr(8))
+}
+
+func (s *IPCacheMapTestSuite) TestRemoteEndpointInfoClusterID(c *C) {
+	min1, _ := identity.GetAllocationRange(1)
+	min2, _ := identity.GetAllocationRange(2)
+	c.Assert(min1, Not(Equals), min2)
+
+	key := NewKey(net.ParseIP("10.0.0.1"), nil)
+	for _, id := range []identity.NumericIdentity{min1, min2} {
+		value := RemoteEndpointInfo{SecurityIdentity: id.Uint32()}
+
+		var keyBuf, valueBuf bytes.Buffer
+		c.Assert(binary.Write(&keyBuf, byteorder.Native, key), IsNil)
+		c.Assert(binary.Write(&valueBuf, byteorder.Native, value), IsNil)
+		c.Assert(valueBuf.Len(), Equals, int(unsafe.Sizeof(value)))
+
+		k, v := Key{}, RemoteEndpointInfo{}
+		c.Assert(bpf.ConvertKeyValue(keyBuf.Bytes(), valueBuf.Bytes(), &k, &v), IsNil)
+		c.Assert(identity.NumericIdentity(v.SecurityIdentity), Equals, id)
+		c.Assert(identity.NumericIdentity(v.SecurityIdentity).ClusterID(), Equals, id.ClusterID())
+	}
+}
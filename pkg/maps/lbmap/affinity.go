@@ -0,0 +1,214 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbmap
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"github.com/cilium/cilium/pkg/bpf"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxAffinityEntries is the maximum number of client to backend
+	// mappings of services with session affinity. The least recently
+	// used mappings are evicted first.
+	MaxAffinityEntries = 65536
+)
+
+var (
+	affinityMutex lock.RWMutex
+	affinity      bool
+)
+
+// SetSessionAffinity enables or disables session affinity of services. It
+// must be set before services are added to the BPF maps. The session
+// affinity maps are LRU maps, session affinity can only be enabled if the
+// kernel supports them.
+func SetSessionAffinity(enabled bool) {
+	affinityMutex.Lock()
+	affinity = enabled
+	affinityMutex.Unlock()
+}
+
+// IsSessionAffinityEnabled returns true if session affinity of services is
+// enabled
+func IsSessionAffinityEnabled() bool {
+	affinityMutex.RLock()
+	defer affinityMutex.RUnlock()
+	return affinity
+}
+
+// AffinityKey is the interface describing protocol independent key for the
+// session affinity map.
+type AffinityKey interface {
+	bpf.MapKey
+
+	// Returns the master key of the service in network byte order
+	ServiceKey() ServiceKey
+}
+
+// AffinityValue is the interface describing protocol independent value for
+// the session affinity map.
+type AffinityValue interface {
+	bpf.MapValue
+
+	// Returns true if the client is mapped to the given backend
+	MatchesBackend(ServiceValue) bool
+
+	// Set the backend index the client is mapped to
+	SetSlave(int)
+
+	// Returns the time in seconds since boot the mapping was last used
+	GetLastUsed() uint32
+}
+
+// AffinityMatchValue must match 'struct lb_affinity_match' in
+// "bpf/lib/common.h".
+type AffinityMatchValue struct {
+	// Timeout in seconds after which an idle client is no longer mapped
+	// to its backend
+	Timeout uint32
+}
+
+// GetValuePtr returns the unsafe pointer to the BPF value
+func (v *AffinityMatchValue) GetValuePtr() unsafe.Pointer { return unsafe.Pointer(v) }
+
+func (v *AffinityMatchValue) String() string {
+	return fmt.Sprintf("timeout=%ds", v.Timeout)
+}
+
+// UpdateSessionAffinity enables session affinity with the given timeout in
+// seconds for the service fe, or disables it if timeout is 0. Clients which
+// are already mapped to one of the backends stay mapped to it, even if its
+// backend index has changed. The session affinity of the service is ignored
+// if session affinity is disabled.
+func UpdateSessionAffinity(fe ServiceKey, backends []ServiceValue, timeout uint32) error {
+	if !IsSessionAffinityEnabled() {
+		if timeout != 0 {
+			log.WithField("frontend", fe).Warning("Ignoring session affinity of service, session affinity is disabled")
+		}
+		return nil
+	}
+
+	if timeout == 0 {
+		return deleteSessionAffinity(fe)
+	}
+
+	svcKey := fe.ToNetwork()
+	svcKey.SetBackend(0)
+
+	log.WithFields(logrus.Fields{
+		"frontend": fe,
+		"timeout":  timeout,
+	}).Debug("updating session affinity")
+
+	if _, err := fe.AffinityMatchMap().OpenOrCreate(); err != nil {
+		return err
+	}
+
+	if err := fe.AffinityMatchMap().Update(svcKey, &AffinityMatchValue{Timeout: timeout}); err != nil {
+		return err
+	}
+
+	return remapClients(svcKey.AffinityMap(), svcKey, backends)
+}
+
+// LookupSessionAffinity returns the session affinity timeout in seconds of
+// the service fe, or 0 if session affinity is disabled for the service.
+func LookupSessionAffinity(fe ServiceKey) uint32 {
+	if !IsSessionAffinityEnabled() {
+		return 0
+	}
+
+	svcKey := fe.ToNetwork()
+	svcKey.SetBackend(0)
+
+	val, err := fe.AffinityMatchMap().Lookup(svcKey)
+	if err != nil {
+		return 0
+	}
+
+	return val.(*AffinityMatchValue).Timeout
+}
+
+// deleteSessionAffinity disables session affinity for the service fe. The
+// client to backend mappings are left to expire, they are reused if
+// session affinity is enabled again for the same backends.
+func deleteSessionAffinity(fe ServiceKey) error {
+	svcKey := fe.ToNetwork()
+	svcKey.SetBackend(0)
+
+	if _, err := fe.AffinityMatchMap().Lookup(svcKey); err != nil {
+		// Ignore if entry is not found.
+		return nil
+	}
+
+	return fe.AffinityMatchMap().Delete(svcKey)
+}
+
+// remapClients updates the backend index of all clients of the service
+// svcKey in the map m to the index of their backend in backends, and removes
+// the clients of backends which no longer exist. It is used for the session
+// affinity and the connection maps which share the same value layout.
+func remapClients(m *bpf.Map, svcKey ServiceKey, backends []ServiceValue) error {
+	if _, err := m.OpenOrCreate(); err != nil {
+		return err
+	}
+
+	keys := []AffinityKey{}
+	values := []AffinityValue{}
+	err := m.DumpWithCallback(func(key bpf.MapKey, value bpf.MapValue) {
+		affKey := key.(AffinityKey)
+		if reflect.DeepEqual(affKey.ServiceKey(), svcKey) {
+			keys = append(keys, affKey)
+			values = append(values, value.(AffinityValue))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Dumping a map locks it, so the entries are updated after the dump
+	for i, key := range keys {
+		slave := 0
+		for j, be := range backends {
+			if values[i].MatchesBackend(be) {
+				// Slave 0 is reserved for the master slot
+				slave = j + 1
+				break
+			}
+		}
+
+		if slave == 0 {
+			if err := m.Delete(key); err != nil {
+				log.WithError(err).WithField(logfields.BPFMapKey, key).Debug("unable to delete client of removed backend")
+			}
+			continue
+		}
+
+		values[i].SetSlave(slave)
+		if err := m.Update(key, values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbmap
+
+import (
+	"net"
+	"reflect"
+	"unsafe"
+
+	"github.com/cilium/cilium/pkg/byteorder"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LBMapTestSuite) TestAffinitySizes(c *C) {
+	// Must match the packed structs in "bpf/lib/common.h"
+	c.Assert(unsafe.Sizeof(Affinity4Key{}), Equals, uintptr(12))
+	c.Assert(unsafe.Sizeof(Affinity4Value{}), Equals, uintptr(12))
+	c.Assert(unsafe.Sizeof(Affinity6Key{}), Equals, uintptr(36))
+	c.Assert(unsafe.Sizeof(Affinity6Value{}), Equals, uintptr(24))
+	c.Assert(unsafe.Sizeof(AffinityMatchValue{}), Equals, uintptr(4))
+}
+
+func (s *LBMapTestSuite) TestAffinityMatchesBackend(c *C) {
+	be := NewService4Value(0, net.ParseIP("10.0.0.1"), 80, 1, 0)
+	other := NewService4Value(0, net.ParseIP("10.0.0.2"), 80, 1, 0)
+
+	// Affinity values are written by the datapath in network byte order
+	val := &Affinity4Value{Port: byteorder.HostToNetwork(uint16(80)).(uint16), Slave: 2}
+	copy(val.Target[:], net.ParseIP("10.0.0.1").To4())
+
+	c.Assert(val.MatchesBackend(be), Equals, true)
+	c.Assert(val.MatchesBackend(other), Equals, false)
+	c.Assert(val.MatchesBackend(NewService6Value(0, net.ParseIP("f00d::1"), 80, 1, 0)), Equals, false)
+}
+
+func (s *LBMapTestSuite) TestAffinityServiceKey(c *C) {
+	fe := NewService4Key(net.ParseIP("10.96.0.1"), 80, 3)
+	svcKey := fe.ToNetwork()
+	svcKey.SetBackend(0)
+
+	key := &Affinity4Key{Service: *svcKey.(*Service4Key)}
+	copy(key.Client[:], net.ParseIP("192.168.0.1").To4())
+
+	c.Assert(reflect.DeepEqual(key.ServiceKey(), svcKey), Equals, true)
+}
+
+func (s *LBMapTestSuite) TestSetSessionAffinity(c *C) {
+	c.Assert(IsSessionAffinityEnabled(), Equals, false)
+	SetSessionAffinity(true)
+	c.Assert(IsSessionAffinityEnabled(), Equals, true)
+	SetSessionAffinity(false)
+	c.Assert(IsSessionAffinityEnabled(), Equals, false)
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbmap
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/cilium/cilium/pkg/bpf"
+	"github.com/cilium/cilium/pkg/lock"
+)
+
+const (
+	// MaxConnEntries is the maximum number of connections to services
+	// tracked to keep them on their backend while it is draining. The
+	// least recently used connections are evicted first.
+	MaxConnEntries = 65536
+
+	// ConnIdleTimeout is the duration after which a tracked connection
+	// without any traffic is no longer considered active. It must be
+	// larger than LB_CONN_REFRESH_INTERVAL in "bpf/lib/lb.h", the interval
+	// in which the datapath records the last use of a connection.
+	ConnIdleTimeout = 60 * time.Second
+)
+
+var (
+	drainingMutex lock.RWMutex
+	draining      bool
+)
+
+// SetDraining enables or disables the draining of removed backends. It must
+// be set before services are added to the BPF maps. The connection maps are
+// LRU maps, draining can only be enabled if the kernel supports them.
+func SetDraining(enabled bool) {
+	drainingMutex.Lock()
+	draining = enabled
+	drainingMutex.Unlock()
+}
+
+// IsDrainingEnabled returns true if removed backends are drained
+func IsDrainingEnabled() bool {
+	drainingMutex.RLock()
+	defer drainingMutex.RUnlock()
+	return draining
+}
+
+// CountConnections returns the number of active connections of the service
+// fe to each of the backends, a connection is active if it has seen traffic
+// within ConnIdleTimeout.
+func CountConnections(fe ServiceKey, backends []ServiceValue) ([]int, error) {
+	counts := make([]int, len(backends))
+
+	now, err := bpf.GetMtime()
+	if err != nil {
+		return nil, err
+	}
+	nowSec := uint32(now / uint64(time.Second))
+	idleSec := uint32(ConnIdleTimeout / time.Second)
+
+	if _, err := fe.ConnMap().OpenOrCreate(); err != nil {
+		return nil, err
+	}
+
+	svcKey := fe.ToNetwork()
+	svcKey.SetBackend(0)
+
+	err = fe.ConnMap().DumpWithCallback(func(key bpf.MapKey, value bpf.MapValue) {
+		if !reflect.DeepEqual(key.(AffinityKey).ServiceKey(), svcKey) {
+			return
+		}
+
+		val := value.(AffinityValue)
+		if nowSec-val.GetLastUsed() > idleSec {
+			return
+		}
+
+		for i, be := range backends {
+			if val.MatchesBackend(be) {
+				counts[i]++
+				break
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbmap
+
+import (
+	"net"
+	"reflect"
+	"unsafe"
+
+	"github.com/cilium/cilium/pkg/u8proto"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LBMapTestSuite) TestConnSizes(c *C) {
+	// Must match the packed structs in "bpf/lib/common.h"
+	c.Assert(unsafe.Sizeof(Conn4Key{}), Equals, uintptr(16))
+	c.Assert(unsafe.Sizeof(Conn6Key{}), Equals, uintptr(40))
+}
+
+func (s *LBMapTestSuite) TestConnServiceKey(c *C) {
+	fe := NewService6Key(net.ParseIP("f00d::1"), 443, 2)
+	svcKey := fe.ToNetwork()
+	svcKey.SetBackend(0)
+
+	key := &Conn6Key{Service: *svcKey.(*Service6Key), Nexthdr: u8proto.TCP}
+	copy(key.Client[:], net.ParseIP("f00d::2"))
+
+	c.Assert(reflect.DeepEqual(key.ServiceKey(), svcKey), Equals, true)
+	c.Assert(key.NewValue(), FitsTypeOf, &Affinity6Value{})
+}
+
+func (s *LBMapTestSuite) TestSetDraining(c *C) {
+	c.Assert(IsDrainingEnabled(), Equals, false)
+	SetDraining(true)
+	c.Assert(IsDrainingEnabled(), Equals, true)
+	SetDraining(false)
+	c.Assert(IsDrainingEnabled(), Equals, false)
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbmap
+
+import (
+	"unsafe"
+
+	"github.com/cilium/cilium/pkg/lock"
+)
+
+const (
+	// MaxDSREntries is the maximum number of connections to local
+	// backends of services in direct server return mode whose replies
+	// are translated to the frontend. The least recently used
+	// connections are evicted first.
+	MaxDSREntries = 65536
+)
+
+var (
+	dsrMutex lock.RWMutex
+	dsr      bool
+)
+
+// SetDSR enables or disables direct server return of services. It must be
+// set before services are added to the BPF maps. The frontends learned by
+// backend nodes are stored in LRU maps, direct server return can only be
+// enabled if the kernel supports them.
+func SetDSR(enabled bool) {
+	dsrMutex.Lock()
+	dsr = enabled
+	dsrMutex.Unlock()
+}
+
+// IsDSREnabled returns true if direct server return of services is enabled
+func IsDSREnabled() bool {
+	dsrMutex.RLock()
+	defer dsrMutex.RUnlock()
+	return dsr
+}
+
+// DSRMatchValue must match 'struct lb_dsr_match' in "bpf/lib/common.h".
+type DSRMatchValue struct {
+	// Flags is reserved and must be 0
+	Flags uint32
+}
+
+// GetValuePtr returns the unsafe pointer to the BPF value
+func (v *DSRMatchValue) GetValuePtr() unsafe.Pointer { return unsafe.Pointer(v) }
+
+func (v *DSRMatchValue) String() string {
+	return "dsr"
+}
+
+// UpdateDSR enables or disables direct server return for the service fe.
+// In direct server return mode, the frontend is carried to the backend node
+// which translates the replies itself so they are sent directly to the
+// client. The forwarding mode of the service is ignored if direct server
+// return is disabled.
+func UpdateDSR(fe ServiceKey, enabled bool) error {
+	if !IsDSREnabled() {
+		if enabled {
+			log.WithField("frontend", fe).Warning("Ignoring direct server return of service, direct server return is disabled")
+		}
+		return nil
+	}
+
+	if !enabled {
+		return deleteDSR(fe)
+	}
+
+	svcKey := fe.ToNetwork()
+	svcKey.SetBackend(0)
+
+	log.WithField("frontend", fe).Debug("enabling direct server return")
+
+	if _, err := fe.DSRMatchMap().OpenOrCreate(); err != nil {
+		return err
+	}
+
+	return fe.DSRMatchMap().Update(svcKey, &DSRMatchValue{})
+}
+
+// LookupDSR returns true if direct server return is enabled for the service
+// fe.
+func LookupDSR(fe ServiceKey) bool {
+	if !IsDSREnabled() {
+		return false
+	}
+
+	svcKey := fe.ToNetwork()
+	svcKey.SetBackend(0)
+
+	_, err := fe.DSRMatchMap().Lookup(svcKey)
+	return err == nil
+}
+
+// deleteDSR disables direct server return for the service fe. The
+// connections which were established in direct server return mode keep
+// being translated by the backend node.
+func deleteDSR(fe ServiceKey) error {
+	svcKey := fe.ToNetwork()
+	svcKey.SetBackend(0)
+
+	if _, err := fe.DSRMatchMap().Lookup(svcKey); err != nil {
+		// Ignore if entry is not found.
+		return nil
+	}
+
+	return fe.DSRMatchMap().Delete(svcKey)
+}
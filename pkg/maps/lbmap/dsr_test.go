@@ -0,0 +1,34 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbmap
+
+import (
+	"unsafe"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LBMapTestSuite) TestDSRSizes(c *C) {
+	// Must match 'struct lb_dsr_match' in "bpf/lib/common.h"
+	c.Assert(unsafe.Sizeof(DSRMatchValue{}), Equals, uintptr(4))
+}
+
+func (s *LBMapTestSuite) TestSetDSR(c *C) {
+	c.Assert(IsDSREnabled(), Equals, false)
+	SetDSR(true)
+	c.Assert(IsDSREnabled(), Equals, true)
+	SetDSR(false)
+	c.Assert(IsDSREnabled(), Equals, false)
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/cilium/cilium/common/types"
 	"github.com/cilium/cilium/pkg/bpf"
 	"github.com/cilium/cilium/pkg/byteorder"
+	"github.com/cilium/cilium/pkg/u8proto"
 )
 
 var (
@@ -73,6 +74,100 @@ var (
 
 			return svcKey.ToNetwork(), &svcVal, nil
 		})
+	Maglev4Map = bpf.NewMap("cilium_lb4_maglev",
+		bpf.MapTypeHash,
+		int(unsafe.Sizeof(Service4Key{})),
+		int(unsafe.Sizeof(MaglevTable{})),
+		MaxMaglevEntries,
+		bpf.BPF_F_NO_PREALLOC,
+		func(key []byte, value []byte) (bpf.MapKey, bpf.MapValue, error) {
+			svcKey, table := Service4Key{}, MaglevTable{}
+
+			if err := bpf.ConvertKeyValue(key, value, &svcKey, &table); err != nil {
+				return nil, nil, err
+			}
+
+			return svcKey.ToNetwork(), &table, nil
+		})
+	AffinityMatch4Map = bpf.NewMap("cilium_lb4_affinity_match",
+		bpf.MapTypeHash,
+		int(unsafe.Sizeof(Service4Key{})),
+		int(unsafe.Sizeof(AffinityMatchValue{})),
+		MaxEntries,
+		bpf.BPF_F_NO_PREALLOC,
+		func(key []byte, value []byte) (bpf.MapKey, bpf.MapValue, error) {
+			svcKey, match := Service4Key{}, AffinityMatchValue{}
+
+			if err := bpf.ConvertKeyValue(key, value, &svcKey, &match); err != nil {
+				return nil, nil, err
+			}
+
+			return svcKey.ToNetwork(), &match, nil
+		})
+	Affinity4Map = bpf.NewMap("cilium_lb4_affinity",
+		bpf.MapTypeLRUHash,
+		int(unsafe.Sizeof(Affinity4Key{})),
+		int(unsafe.Sizeof(Affinity4Value{})),
+		MaxAffinityEntries,
+		0,
+		func(key []byte, value []byte) (bpf.MapKey, bpf.MapValue, error) {
+			affKey, affValue := Affinity4Key{}, Affinity4Value{}
+
+			if err := bpf.ConvertKeyValue(key, value, &affKey, &affValue); err != nil {
+				return nil, nil, err
+			}
+
+			return &affKey, &affValue, nil
+		})
+	// DSRMatch4Map represents the BPF map of services in direct server
+	// return mode in IPv4 load balancer
+	DSRMatch4Map = bpf.NewMap("cilium_lb4_dsr_match",
+		bpf.MapTypeHash,
+		int(unsafe.Sizeof(Service4Key{})),
+		int(unsafe.Sizeof(DSRMatchValue{})),
+		MaxEntries,
+		bpf.BPF_F_NO_PREALLOC,
+		func(key []byte, value []byte) (bpf.MapKey, bpf.MapValue, error) {
+			svcKey, match := Service4Key{}, DSRMatchValue{}
+
+			if err := bpf.ConvertKeyValue(key, value, &svcKey, &match); err != nil {
+				return nil, nil, err
+			}
+
+			return svcKey.ToNetwork(), &match, nil
+		})
+	Conn4Map = bpf.NewMap("cilium_lb4_conn",
+		bpf.MapTypeLRUHash,
+		int(unsafe.Sizeof(Conn4Key{})),
+		int(unsafe.Sizeof(Affinity4Value{})),
+		MaxConnEntries,
+		0,
+		func(key []byte, value []byte) (bpf.MapKey, bpf.MapValue, error) {
+			connKey, connValue := Conn4Key{}, Affinity4Value{}
+
+			if err := bpf.ConvertKeyValue(key, value, &connKey, &connValue); err != nil {
+				return nil, nil, err
+			}
+
+			return &connKey, &connValue, nil
+		})
+	// Stats4Map represents the BPF map of the traffic statistics of the
+	// frontends and backends in IPv4 load balancer
+	Stats4Map = bpf.NewMap("cilium_lb4_stats",
+		bpf.MapTypeHash,
+		int(unsafe.Sizeof(Stats4Key{})),
+		int(unsafe.Sizeof(StatsValue{})),
+		MaxStatsEntries,
+		bpf.BPF_F_NO_PREALLOC,
+		func(key []byte, value []byte) (bpf.MapKey, bpf.MapValue, error) {
+			statsKey, statsValue := Stats4Key{}, StatsValue{}
+
+			if err := bpf.ConvertKeyValue(key, value, &statsKey, &statsValue); err != nil {
+				return nil, nil, err
+			}
+
+			return &statsKey, &statsValue, nil
+		})
 )
 
 // Service4Key must match 'struct lb4_key' in "bpf/lib/common.h".
@@ -85,6 +180,12 @@ type Service4Key struct {
 func (k Service4Key) IsIPv6() bool               { return false }
 func (k Service4Key) Map() *bpf.Map              { return Service4Map }
 func (k Service4Key) RRMap() *bpf.Map            { return RRSeq4Map }
+func (k Service4Key) MaglevMap() *bpf.Map        { return Maglev4Map }
+func (k Service4Key) AffinityMatchMap() *bpf.Map { return AffinityMatch4Map }
+func (k Service4Key) AffinityMap() *bpf.Map      { return Affinity4Map }
+func (k Service4Key) ConnMap() *bpf.Map          { return Conn4Map }
+func (k Service4Key) DSRMatchMap() *bpf.Map      { return DSRMatch4Map }
+func (k Service4Key) StatsMap() *bpf.Map         { return Stats4Map }
 func (k Service4Key) NewValue() bpf.MapValue     { return &Service4Value{} }
 func (k *Service4Key) GetKeyPtr() unsafe.Pointer { return unsafe.Pointer(k) }
 func (k *Service4Key) GetPort() uint16           { return k.Port }
@@ -246,3 +347,92 @@ func NewRevNat4Value(ip net.IP, port uint16) *RevNat4Value {
 
 	return &revNat
 }
+
+// Affinity4Key must match 'struct lb4_affinity_key' in "bpf/lib/common.h".
+type Affinity4Key struct {
+	Service Service4Key
+	Client  types.IPv4
+}
+
+func (k *Affinity4Key) GetKeyPtr() unsafe.Pointer { return unsafe.Pointer(k) }
+func (k *Affinity4Key) NewValue() bpf.MapValue    { return &Affinity4Value{} }
+func (k *Affinity4Key) ServiceKey() ServiceKey    { return &k.Service }
+
+func (k *Affinity4Key) String() string {
+	return fmt.Sprintf("%s -> %s", k.Client, k.Service.ToHost())
+}
+
+// Affinity4Value must match 'struct lb4_affinity_val' in "bpf/lib/common.h".
+type Affinity4Value struct {
+	Target   types.IPv4
+	Port     uint16
+	Slave    uint16
+	LastUsed uint32
+}
+
+func (v *Affinity4Value) GetValuePtr() unsafe.Pointer { return unsafe.Pointer(v) }
+func (v *Affinity4Value) SetSlave(slave int)          { v.Slave = uint16(slave) }
+func (v *Affinity4Value) GetLastUsed() uint32         { return v.LastUsed }
+
+// MatchesBackend returns true if the client is mapped to the backend be
+func (v *Affinity4Value) MatchesBackend(be ServiceValue) bool {
+	n, ok := be.ToNetwork().(*Service4Value)
+	return ok && n.Address == v.Target && n.Port == v.Port
+}
+
+func (v *Affinity4Value) String() string {
+	return fmt.Sprintf("%s:%d (%d)", v.Target, byteorder.NetworkToHost(v.Port), v.Slave)
+}
+
+// Conn4Key must match 'struct lb4_conn_key' in "bpf/lib/common.h".
+type Conn4Key struct {
+	Service Service4Key
+	Client  types.IPv4
+	SPort   uint16
+	Nexthdr u8proto.U8proto
+	Pad     uint8
+}
+
+func (k *Conn4Key) GetKeyPtr() unsafe.Pointer { return unsafe.Pointer(k) }
+func (k *Conn4Key) NewValue() bpf.MapValue    { return &Affinity4Value{} }
+func (k *Conn4Key) ServiceKey() ServiceKey    { return &k.Service }
+
+func (k *Conn4Key) String() string {
+	return fmt.Sprintf("%s:%d -> %s (%s)", k.Client, byteorder.NetworkToHost(k.SPort),
+		k.Service.ToHost(), k.Nexthdr)
+}
+
+// Stats4Key must match 'struct lb4_stats_key' in "bpf/lib/common.h".
+type Stats4Key struct {
+	Address     types.IPv4
+	Backend     types.IPv4
+	Port        uint16
+	BackendPort uint16
+}
+
+func (k *Stats4Key) GetKeyPtr() unsafe.Pointer { return unsafe.Pointer(k) }
+func (k *Stats4Key) NewValue() bpf.MapValue    { return &StatsValue{} }
+
+// ServiceKey returns the master key of the frontend in network byte order
+func (k *Stats4Key) ServiceKey() ServiceKey {
+	return &Service4Key{Address: k.Address, Port: k.Port}
+}
+
+// GetFrontend returns the address and port of the frontend
+func (k *Stats4Key) GetFrontend() (net.IP, uint16) {
+	return k.Address.IP(), byteorder.NetworkToHost(k.Port).(uint16)
+}
+
+// GetBackend returns the address and port of the backend, the address is nil
+// if k holds the totals of the frontend
+func (k *Stats4Key) GetBackend() (net.IP, uint16) {
+	if k.Backend == (types.IPv4{}) {
+		return nil, 0
+	}
+	return k.Backend.IP(), byteorder.NetworkToHost(k.BackendPort).(uint16)
+}
+
+func (k *Stats4Key) String() string {
+	return fmt.Sprintf("%s:%d -> %s:%d", k.Address, byteorder.NetworkToHost(k.Port),
+		k.Backend, byteorder.NetworkToHost(k.BackendPort))
+}
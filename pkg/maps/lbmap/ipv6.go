@@ -22,6 +22,7 @@ import (
 	"github.com/cilium/cilium/common/types"
 	"github.com/cilium/cilium/pkg/bpf"
 	"github.com/cilium/cilium/pkg/byteorder"
+	"github.com/cilium/cilium/pkg/u8proto"
 )
 
 var (
@@ -76,6 +77,109 @@ var (
 
 			return svcKey.ToNetwork(), &svcVal, nil
 		})
+	// Maglev6Map represents the BPF map for Maglev lookup tables in IPv6 load
+	// balancer
+	Maglev6Map = bpf.NewMap("cilium_lb6_maglev",
+		bpf.MapTypeHash,
+		int(unsafe.Sizeof(Service6Key{})),
+		int(unsafe.Sizeof(MaglevTable{})),
+		MaxMaglevEntries,
+		bpf.BPF_F_NO_PREALLOC,
+		func(key []byte, value []byte) (bpf.MapKey, bpf.MapValue, error) {
+			svcKey, table := Service6Key{}, MaglevTable{}
+
+			if err := bpf.ConvertKeyValue(key, value, &svcKey, &table); err != nil {
+				return nil, nil, err
+			}
+
+			return svcKey.ToNetwork(), &table, nil
+		})
+	// AffinityMatch6Map represents the BPF map of services with session
+	// affinity in IPv6 load balancer
+	AffinityMatch6Map = bpf.NewMap("cilium_lb6_affinity_match",
+		bpf.MapTypeHash,
+		int(unsafe.Sizeof(Service6Key{})),
+		int(unsafe.Sizeof(AffinityMatchValue{})),
+		MaxEntries,
+		bpf.BPF_F_NO_PREALLOC,
+		func(key []byte, value []byte) (bpf.MapKey, bpf.MapValue, error) {
+			svcKey, match := Service6Key{}, AffinityMatchValue{}
+
+			if err := bpf.ConvertKeyValue(key, value, &svcKey, &match); err != nil {
+				return nil, nil, err
+			}
+
+			return svcKey.ToNetwork(), &match, nil
+		})
+	// Affinity6Map represents the BPF map for client to backend mappings of
+	// services with session affinity in IPv6 load balancer
+	Affinity6Map = bpf.NewMap("cilium_lb6_affinity",
+		bpf.MapTypeLRUHash,
+		int(unsafe.Sizeof(Affinity6Key{})),
+		int(unsafe.Sizeof(Affinity6Value{})),
+		MaxAffinityEntries,
+		0,
+		func(key []byte, value []byte) (bpf.MapKey, bpf.MapValue, error) {
+			affKey, affValue := Affinity6Key{}, Affinity6Value{}
+
+			if err := bpf.ConvertKeyValue(key, value, &affKey, &affValue); err != nil {
+				return nil, nil, err
+			}
+
+			return &affKey, &affValue, nil
+		})
+	// DSRMatch6Map represents the BPF map of services in direct server
+	// return mode in IPv6 load balancer
+	DSRMatch6Map = bpf.NewMap("cilium_lb6_dsr_match",
+		bpf.MapTypeHash,
+		int(unsafe.Sizeof(Service6Key{})),
+		int(unsafe.Sizeof(DSRMatchValue{})),
+		MaxEntries,
+		bpf.BPF_F_NO_PREALLOC,
+		func(key []byte, value []byte) (bpf.MapKey, bpf.MapValue, error) {
+			svcKey, match := Service6Key{}, DSRMatchValue{}
+
+			if err := bpf.ConvertKeyValue(key, value, &svcKey, &match); err != nil {
+				return nil, nil, err
+			}
+
+			return svcKey.ToNetwork(), &match, nil
+		})
+	// Conn6Map represents the BPF map of connections to services used to
+	// serve established connections from draining backends in IPv6 load
+	// balancer
+	Conn6Map = bpf.NewMap("cilium_lb6_conn",
+		bpf.MapTypeLRUHash,
+		int(unsafe.Sizeof(Conn6Key{})),
+		int(unsafe.Sizeof(Affinity6Value{})),
+		MaxConnEntries,
+		0,
+		func(key []byte, value []byte) (bpf.MapKey, bpf.MapValue, error) {
+			connKey, connValue := Conn6Key{}, Affinity6Value{}
+
+			if err := bpf.ConvertKeyValue(key, value, &connKey, &connValue); err != nil {
+				return nil, nil, err
+			}
+
+			return &connKey, &connValue, nil
+		})
+	// Stats6Map represents the BPF map of the traffic statistics of the
+	// frontends and backends in IPv6 load balancer
+	Stats6Map = bpf.NewMap("cilium_lb6_stats",
+		bpf.MapTypeHash,
+		int(unsafe.Sizeof(Stats6Key{})),
+		int(unsafe.Sizeof(StatsValue{})),
+		MaxStatsEntries,
+		bpf.BPF_F_NO_PREALLOC,
+		func(key []byte, value []byte) (bpf.MapKey, bpf.MapValue, error) {
+			statsKey, statsValue := Stats6Key{}, StatsValue{}
+
+			if err := bpf.ConvertKeyValue(key, value, &statsKey, &statsValue); err != nil {
+				return nil, nil, err
+			}
+
+			return &statsKey, &statsValue, nil
+		})
 )
 
 // Service6Key must match 'struct lb6_key' in "bpf/lib/common.h".
@@ -99,6 +203,12 @@ func NewService6Key(ip net.IP, port uint16, slave uint16) *Service6Key {
 func (k Service6Key) IsIPv6() bool               { return true }
 func (k Service6Key) Map() *bpf.Map              { return Service6Map }
 func (k Service6Key) RRMap() *bpf.Map            { return RRSeq6Map }
+func (k Service6Key) MaglevMap() *bpf.Map        { return Maglev6Map }
+func (k Service6Key) AffinityMatchMap() *bpf.Map { return AffinityMatch6Map }
+func (k Service6Key) AffinityMap() *bpf.Map      { return Affinity6Map }
+func (k Service6Key) ConnMap() *bpf.Map          { return Conn6Map }
+func (k Service6Key) DSRMatchMap() *bpf.Map      { return DSRMatch6Map }
+func (k Service6Key) StatsMap() *bpf.Map         { return Stats6Map }
 func (k Service6Key) NewValue() bpf.MapValue     { return &Service6Value{} }
 func (k *Service6Key) GetKeyPtr() unsafe.Pointer { return unsafe.Pointer(k) }
 func (k *Service6Key) GetPort() uint16           { return k.Port }
@@ -239,3 +349,92 @@ func (v *RevNat6Value) ToNetwork() RevNatValue {
 	n.Port = byteorder.HostToNetwork(n.Port).(uint16)
 	return &n
 }
+
+// Affinity6Key must match 'struct lb6_affinity_key' in "bpf/lib/common.h".
+type Affinity6Key struct {
+	Service Service6Key
+	Client  types.IPv6
+}
+
+func (k *Affinity6Key) GetKeyPtr() unsafe.Pointer { return unsafe.Pointer(k) }
+func (k *Affinity6Key) NewValue() bpf.MapValue    { return &Affinity6Value{} }
+func (k *Affinity6Key) ServiceKey() ServiceKey    { return &k.Service }
+
+func (k *Affinity6Key) String() string {
+	return fmt.Sprintf("%s -> %s", k.Client, k.Service.ToHost())
+}
+
+// Affinity6Value must match 'struct lb6_affinity_val' in "bpf/lib/common.h".
+type Affinity6Value struct {
+	Target   types.IPv6
+	Port     uint16
+	Slave    uint16
+	LastUsed uint32
+}
+
+func (v *Affinity6Value) GetValuePtr() unsafe.Pointer { return unsafe.Pointer(v) }
+func (v *Affinity6Value) SetSlave(slave int)          { v.Slave = uint16(slave) }
+func (v *Affinity6Value) GetLastUsed() uint32         { return v.LastUsed }
+
+// MatchesBackend returns true if the client is mapped to the backend be
+func (v *Affinity6Value) MatchesBackend(be ServiceValue) bool {
+	n, ok := be.ToNetwork().(*Service6Value)
+	return ok && n.Address == v.Target && n.Port == v.Port
+}
+
+func (v *Affinity6Value) String() string {
+	return fmt.Sprintf("%s:%d (%d)", v.Target, byteorder.NetworkToHost(v.Port), v.Slave)
+}
+
+// Conn6Key must match 'struct lb6_conn_key' in "bpf/lib/common.h".
+type Conn6Key struct {
+	Service Service6Key
+	Client  types.IPv6
+	SPort   uint16
+	Nexthdr u8proto.U8proto
+	Pad     uint8
+}
+
+func (k *Conn6Key) GetKeyPtr() unsafe.Pointer { return unsafe.Pointer(k) }
+func (k *Conn6Key) NewValue() bpf.MapValue    { return &Affinity6Value{} }
+func (k *Conn6Key) ServiceKey() ServiceKey    { return &k.Service }
+
+func (k *Conn6Key) String() string {
+	return fmt.Sprintf("%s:%d -> %s (%s)", k.Client, byteorder.NetworkToHost(k.SPort),
+		k.Service.ToHost(), k.Nexthdr)
+}
+
+// Stats6Key must match 'struct lb6_stats_key' in "bpf/lib/common.h".
+type Stats6Key struct {
+	Address     types.IPv6
+	Backend     types.IPv6
+	Port        uint16
+	BackendPort uint16
+}
+
+func (k *Stats6Key) GetKeyPtr() unsafe.Pointer { return unsafe.Pointer(k) }
+func (k *Stats6Key) NewValue() bpf.MapValue    { return &StatsValue{} }
+
+// ServiceKey returns the master key of the frontend in network byte order
+func (k *Stats6Key) ServiceKey() ServiceKey {
+	return &Service6Key{Address: k.Address, Port: k.Port}
+}
+
+// GetFrontend returns the address and port of the frontend
+func (k *Stats6Key) GetFrontend() (net.IP, uint16) {
+	return k.Address.IP(), byteorder.NetworkToHost(k.Port).(uint16)
+}
+
+// GetBackend returns the address and port of the backend, the address is nil
+// if k holds the totals of the frontend
+func (k *Stats6Key) GetBackend() (net.IP, uint16) {
+	if k.Backend == (types.IPv6{}) {
+		return nil, 0
+	}
+	return k.Backend.IP(), byteorder.NetworkToHost(k.BackendPort).(uint16)
+}
+
+func (k *Stats6Key) String() string {
+	return fmt.Sprintf("%s:%d -> %s:%d", k.Address, byteorder.NetworkToHost(k.Port),
+		k.Backend, byteorder.NetworkToHost(k.BackendPort))
+}
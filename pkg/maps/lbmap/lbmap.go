@@ -50,6 +50,26 @@ type ServiceKey interface {
 	// Returns the BPF Weighted Round Robin map matching the key type
 	RRMap() *bpf.Map
 
+	// Returns the BPF Maglev lookup table map matching the key type
+	MaglevMap() *bpf.Map
+
+	// Returns the BPF map of services with session affinity matching the
+	// key type
+	AffinityMatchMap() *bpf.Map
+
+	// Returns the BPF session affinity map matching the key type
+	AffinityMap() *bpf.Map
+
+	// Returns the BPF map of connections matching the key type
+	ConnMap() *bpf.Map
+
+	// Returns the BPF map of services in direct server return mode
+	// matching the key type
+	DSRMatchMap() *bpf.Map
+
+	// Returns the BPF map of traffic statistics matching the key type
+	StatsMap() *bpf.Map
+
 	// Returns a RevNatValue matching a ServiceKey
 	RevNatValue() RevNatValue
 
@@ -139,6 +159,19 @@ func DeleteService(key ServiceKey) error {
 	if err != nil {
 		return err
 	}
+	if GetAlgorithm() == AlgorithmMaglev {
+		if err := deleteMaglevTable(key); err != nil {
+			return err
+		}
+	}
+	if key.GetBackend() == 0 {
+		if err := deleteSessionAffinity(key); err != nil {
+			return err
+		}
+		if err := deleteDSR(key); err != nil {
+			return err
+		}
+	}
 	return LookupAndDeleteServiceWeights(key)
 }
 
@@ -316,8 +349,11 @@ func UpdateWrrSeq(fe ServiceKey, weights []uint16) error {
 	return UpdateServiceWeights(fe, svcRRSeq)
 }
 
-// AddSVC2BPFMap adds the given bpf service to the bpf maps.
-func AddSVC2BPFMap(fe ServiceKey, besValues []ServiceValue, addRevNAT bool, revNATID int) error {
+// AddSVC2BPFMap adds the given bpf service to the bpf maps. The draining
+// backends are added after the backends in besValues but are not counted as
+// backends of the service, they only serve established connections.
+func AddSVC2BPFMap(fe ServiceKey, besValues []ServiceValue, drainingValues []ServiceValue,
+	addRevNAT bool, revNATID int) error {
 	var err error
 	var weights []uint16
 	// Put all the backend services first
@@ -336,6 +372,13 @@ func AddSVC2BPFMap(fe ServiceKey, besValues []ServiceValue, addRevNAT bool, revN
 		nSvcs++
 	}
 
+	for i, be := range drainingValues {
+		fe.SetBackend(nSvcs + i)
+		if err = UpdateService(fe, be); err != nil {
+			return fmt.Errorf("unable to update service %+v with the draining value %+v: %s", fe, be, err)
+		}
+	}
+
 	if addRevNAT {
 		zeroValue := fe.NewValue().(ServiceValue)
 		zeroValue.SetRevNat(revNATID)
@@ -367,6 +410,23 @@ func AddSVC2BPFMap(fe ServiceKey, besValues []ServiceValue, addRevNAT bool, revN
 		return fmt.Errorf("unable to update service weights for %s with value %+v: %s", fe.String(), weights, err)
 	}
 
+	if GetAlgorithm() == AlgorithmMaglev {
+		err = UpdateMaglevTable(fe, besValues)
+		if err != nil {
+			return fmt.Errorf("unable to update Maglev lookup table for %s: %s", fe.String(), err)
+		}
+	}
+
+	if IsDrainingEnabled() {
+		svcKey := fe.ToNetwork()
+		svcKey.SetBackend(0)
+		slots := append(append([]ServiceValue{}, besValues...), drainingValues...)
+		err = remapClients(fe.ConnMap(), svcKey, slots)
+		if err != nil {
+			return fmt.Errorf("unable to update connections of %s: %s", fe.String(), err)
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,261 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbmap
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"unsafe"
+
+	"github.com/cilium/cilium/pkg/lock"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Algorithm is the algorithm used by the datapath to select the backend of
+// a new connection
+type Algorithm string
+
+const (
+	// AlgorithmRandom selects the backend based on the hash of the
+	// connection modulo the number of backends. Changes to the list of
+	// backends affect the backend selection of most connections.
+	AlgorithmRandom Algorithm = "random"
+
+	// AlgorithmMaglev selects the backend using a Maglev lookup table per
+	// service. Changes to the list of backends only affect the connections
+	// of the backends added or removed.
+	AlgorithmMaglev Algorithm = "maglev"
+)
+
+const (
+	// MaglevTableSize is the number of entries of a Maglev lookup table.
+	// It must be a prime number and should be significantly larger than
+	// the number of backends of a service to balance evenly.
+	MaglevTableSize = 16381
+
+	// MaxMaglevEntries is the maximum number of services with a Maglev
+	// lookup table
+	MaxMaglevEntries = 4096
+)
+
+var (
+	algorithmMutex lock.RWMutex
+	algorithm      = AlgorithmRandom
+)
+
+// ParseAlgorithm parses the name of a backend selection algorithm
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch a := Algorithm(name); a {
+	case AlgorithmRandom, AlgorithmMaglev:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unknown load balancing algorithm '%s', must be one of %s, %s",
+			name, AlgorithmRandom, AlgorithmMaglev)
+	}
+}
+
+// SetAlgorithm sets the backend selection algorithm. It must be set before
+// services are added to the BPF maps.
+func SetAlgorithm(a Algorithm) {
+	algorithmMutex.Lock()
+	algorithm = a
+	algorithmMutex.Unlock()
+}
+
+// GetAlgorithm returns the backend selection algorithm
+func GetAlgorithm() Algorithm {
+	algorithmMutex.RLock()
+	defer algorithmMutex.RUnlock()
+	return algorithm
+}
+
+// MaglevTable must match 'struct lb_maglev' in "bpf/lib/common.h". Each
+// entry contains the backend index (slave) of the service to select for a
+// connection hash, 0 if the entry is unused.
+type MaglevTable struct {
+	Slaves [MaglevTableSize]uint16
+}
+
+// GetValuePtr returns the unsafe pointer to the BPF value
+func (t *MaglevTable) GetValuePtr() unsafe.Pointer { return unsafe.Pointer(t) }
+
+// String returns the number of entries of each backend index
+func (t *MaglevTable) String() string {
+	counts := map[uint16]int{}
+	for _, slave := range t.Slaves {
+		counts[slave]++
+	}
+	return fmt.Sprintf("%v", counts)
+}
+
+// maglevPermutation returns the offset and the skip of the preference list
+// of a backend. The preference list of the backend is (offset + j * skip) %
+// m.
+func maglevPermutation(name string, m uint64) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte("offset/" + name))
+	offset := h.Sum64() % m
+
+	h.Reset()
+	h.Write([]byte("skip/" + name))
+	skip := h.Sum64()%(m-1) + 1
+
+	return offset, skip
+}
+
+// GenerateMaglevTable generates a Maglev lookup table of size m for the
+// backends identified by the unique names. m must be a prime number. The
+// weights are relative to each other, if all weights are 0 all backends
+// are weighted equally. Otherwise, backends with weight 0 are excluded.
+// Each entry of the returned table is the index of the backend in names.
+// The table is independent of the order of the backends.
+func GenerateMaglevTable(names []string, weights []uint16, m uint64) ([]int, error) {
+	if len(names) != len(weights) {
+		return nil, fmt.Errorf("number of backends (%d) and weights (%d) do not match", len(names), len(weights))
+	}
+
+	if m < 2 {
+		return nil, fmt.Errorf("table size must be at least 2")
+	}
+
+	// Backends are processed in the order of their names so the table
+	// does not depend on the order of the backends
+	order := make([]int, 0, len(names))
+	seen := map[string]struct{}{}
+	for i, name := range names {
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("duplicate backend %s", name)
+		}
+		seen[name] = struct{}{}
+		order = append(order, i)
+	}
+	sort.Slice(order, func(i, j int) bool { return names[order[i]] < names[order[j]] })
+
+	maxWeight := uint64(0)
+	for _, w := range weights {
+		if uint64(w) > maxWeight {
+			maxWeight = uint64(w)
+		}
+	}
+
+	turns := make([]uint64, len(names))
+	for i, w := range weights {
+		if maxWeight == 0 {
+			turns[i] = 1
+		} else {
+			turns[i] = uint64(w)
+		}
+	}
+	if maxWeight == 0 {
+		maxWeight = 1
+	}
+
+	offsets := make([]uint64, len(names))
+	skips := make([]uint64, len(names))
+	next := make([]uint64, len(names))
+	for i, name := range names {
+		offsets[i], skips[i] = maglevPermutation(name, m)
+	}
+
+	table := make([]int, m)
+	for i := range table {
+		table[i] = -1
+	}
+
+	if len(names) == 0 {
+		return table, nil
+	}
+
+	// Each backend fills the next free entry of its preference list in
+	// turns. A backend with weight w takes w turns out of maxWeight
+	// rounds.
+	filled := uint64(0)
+	for round := uint64(0); filled < m; round++ {
+		for _, i := range order {
+			if round%maxWeight >= turns[i] {
+				continue
+			}
+
+			c := (offsets[i] + next[i]*skips[i]) % m
+			for table[c] >= 0 {
+				next[i]++
+				c = (offsets[i] + next[i]*skips[i]) % m
+			}
+
+			table[c] = i
+			next[i]++
+			filled++
+
+			if filled == m {
+				break
+			}
+		}
+	}
+
+	return table, nil
+}
+
+// UpdateMaglevTable generates the Maglev lookup table of the service fe for
+// the backends and writes it into the BPF map
+func UpdateMaglevTable(fe ServiceKey, backends []ServiceValue) error {
+	if len(backends) == 0 {
+		return deleteMaglevTable(fe)
+	}
+
+	names := make([]string, 0, len(backends))
+	weights := make([]uint16, 0, len(backends))
+	for _, be := range backends {
+		lbBackend, err := ServiceValue2LBBackEnd(fe, be)
+		if err != nil {
+			return err
+		}
+		names = append(names, lbBackend.String())
+		weights = append(weights, be.GetWeight())
+	}
+
+	lut, err := GenerateMaglevTable(names, weights, MaglevTableSize)
+	if err != nil {
+		return err
+	}
+
+	table := &MaglevTable{}
+	for i, backend := range lut {
+		// Slave 0 is reserved for the master slot
+		table.Slaves[i] = uint16(backend + 1)
+	}
+
+	log.WithFields(logrus.Fields{
+		"frontend": fe,
+		"backends": names,
+	}).Debug("updating Maglev lookup table")
+
+	if _, err := fe.MaglevMap().OpenOrCreate(); err != nil {
+		return err
+	}
+
+	return fe.MaglevMap().Update(fe.ToNetwork(), table)
+}
+
+// deleteMaglevTable deletes the Maglev lookup table of the service fe
+func deleteMaglevTable(fe ServiceKey) error {
+	if _, err := fe.MaglevMap().Lookup(fe.ToNetwork()); err != nil {
+		// Ignore if entry is not found.
+		return nil
+	}
+
+	return fe.MaglevMap().Delete(fe.ToNetwork())
+}
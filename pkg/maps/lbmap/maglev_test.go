@@ -0,0 +1,151 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbmap
+
+import (
+	"fmt"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type LBMapTestSuite struct{}
+
+var _ = Suite(&LBMapTestSuite{})
+
+func testBackends(n int) ([]string, []uint16) {
+	names := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		names = append(names, fmt.Sprintf("10.0.%d.%d:80", i/256, i%256))
+	}
+	return names, make([]uint16, n)
+}
+
+// tableNames resolves the backend indices of a lookup table to names
+func tableNames(c *C, names []string, weights []uint16) []string {
+	table, err := GenerateMaglevTable(names, weights, MaglevTableSize)
+	c.Assert(err, IsNil)
+
+	resolved := make([]string, len(table))
+	for i, backend := range table {
+		c.Assert(backend >= 0 && backend < len(names), Equals, true)
+		resolved[i] = names[backend]
+	}
+	return resolved
+}
+
+// moved returns the number of entries which changed between two tables and
+// were neither owned by a removed backend nor taken over by an added
+// backend
+func moved(before, after []string, changed map[string]bool) int {
+	n := 0
+	for i := range before {
+		if before[i] != after[i] && !changed[before[i]] && !changed[after[i]] {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *LBMapTestSuite) TestParseAlgorithm(c *C) {
+	a, err := ParseAlgorithm("maglev")
+	c.Assert(err, IsNil)
+	c.Assert(a, Equals, AlgorithmMaglev)
+
+	a, err = ParseAlgorithm("random")
+	c.Assert(err, IsNil)
+	c.Assert(a, Equals, AlgorithmRandom)
+
+	_, err = ParseAlgorithm("roundrobin")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *LBMapTestSuite) TestGenerateMaglevTableBalance(c *C) {
+	names, weights := testBackends(10)
+	counts := map[string]int{}
+	for _, name := range tableNames(c, names, weights) {
+		counts[name]++
+	}
+
+	c.Assert(len(counts), Equals, 10)
+	for name, n := range counts {
+		// Each backend owns roughly 1/10 of the table
+		c.Assert(n > MaglevTableSize/10*9/10 && n < MaglevTableSize/10*11/10, Equals, true,
+			Commentf("%s owns %d entries", name, n))
+	}
+}
+
+func (s *LBMapTestSuite) TestGenerateMaglevTableWeights(c *C) {
+	names := []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80"}
+	counts := map[string]int{}
+	for _, name := range tableNames(c, names, []uint16{1, 3, 0}) {
+		counts[name]++
+	}
+
+	// Backends with weight 0 are excluded if other backends have a weight
+	c.Assert(counts["10.0.0.3:80"], Equals, 0)
+	ratio := float64(counts["10.0.0.2:80"]) / float64(counts["10.0.0.1:80"])
+	c.Assert(ratio > 2.5 && ratio < 3.5, Equals, true, Commentf("ratio %f", ratio))
+}
+
+func (s *LBMapTestSuite) TestGenerateMaglevTableOrder(c *C) {
+	names, weights := testBackends(5)
+	reversed := make([]string, len(names))
+	for i := range names {
+		reversed[len(names)-1-i] = names[i]
+	}
+
+	c.Assert(tableNames(c, reversed, weights), DeepEquals, tableNames(c, names, weights))
+}
+
+func (s *LBMapTestSuite) TestGenerateMaglevTableErrors(c *C) {
+	_, err := GenerateMaglevTable([]string{"a"}, []uint16{}, MaglevTableSize)
+	c.Assert(err, Not(IsNil))
+
+	_, err = GenerateMaglevTable([]string{"a", "a"}, []uint16{0, 0}, MaglevTableSize)
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *LBMapTestSuite) TestMaglevMinimalDisruption(c *C) {
+	names, weights := testBackends(20)
+	before := tableNames(c, names, weights)
+
+	// Removing a backend only moves the entries of the removed backend,
+	// all other entries are almost entirely preserved
+	removed := names[7]
+	after := tableNames(c, append(append([]string{}, names[:7]...), names[8:]...), weights[1:])
+	n := moved(before, after, map[string]bool{removed: true})
+	c.Assert(n < MaglevTableSize/100, Equals, true, Commentf("%d entries moved", n))
+
+	// Adding a backend only takes over entries for the new backend
+	added := "10.0.1.0:80"
+	after = tableNames(c, append(append([]string{}, names...), added), append(weights, 0))
+	n = moved(before, after, map[string]bool{added: true})
+	c.Assert(n < MaglevTableSize/100, Equals, true, Commentf("%d entries moved", n))
+
+	// The new backend receives its share of the table
+	owned := 0
+	for _, name := range after {
+		if name == added {
+			owned++
+		}
+	}
+	c.Assert(owned > MaglevTableSize/21*9/10, Equals, true, Commentf("%d entries owned", owned))
+}
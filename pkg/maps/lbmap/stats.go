@@ -0,0 +1,126 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbmap
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"unsafe"
+
+	"github.com/cilium/cilium/pkg/bpf"
+	"github.com/cilium/cilium/pkg/lock"
+)
+
+const (
+	// MaxStatsEntries is the maximum number of frontends and backends
+	// whose traffic statistics are collected
+	MaxStatsEntries = 65536
+)
+
+var (
+	statsMutex lock.RWMutex
+	stats      bool
+)
+
+// SetStats enables or disables the collection of the traffic statistics of
+// services. It must be set before the datapath is compiled, the statistics
+// are updated by the datapath for every packet sent to a service.
+func SetStats(enabled bool) {
+	statsMutex.Lock()
+	stats = enabled
+	statsMutex.Unlock()
+}
+
+// IsStatsEnabled returns true if the traffic statistics of services are
+// collected
+func IsStatsEnabled() bool {
+	statsMutex.RLock()
+	defer statsMutex.RUnlock()
+	return stats
+}
+
+// StatsKey is the key of the traffic statistics of a frontend or of one of
+// its backends
+type StatsKey interface {
+	bpf.MapKey
+
+	// ServiceKey returns the master key of the frontend in network byte
+	// order
+	ServiceKey() ServiceKey
+
+	// GetFrontend returns the address and port of the frontend
+	GetFrontend() (net.IP, uint16)
+
+	// GetBackend returns the address and port of the backend, the
+	// address is nil if the key holds the totals of the frontend
+	GetBackend() (net.IP, uint16)
+}
+
+// StatsValue must match 'struct lb_stats' in "bpf/lib/common.h".
+type StatsValue struct {
+	Packets     uint64
+	Bytes       uint64
+	Connections uint64
+}
+
+// GetValuePtr returns the unsafe pointer to the BPF value
+func (v *StatsValue) GetValuePtr() unsafe.Pointer { return unsafe.Pointer(v) }
+
+func (v *StatsValue) String() string {
+	return fmt.Sprintf("packets=%d bytes=%d connections=%d", v.Packets, v.Bytes, v.Connections)
+}
+
+// DumpStats calls cb with the traffic statistics of all frontends and
+// backends in the statistics map m.
+func DumpStats(m *bpf.Map, cb func(key StatsKey, value *StatsValue)) error {
+	if _, err := m.OpenOrCreate(); err != nil {
+		return err
+	}
+
+	return m.DumpWithCallback(func(key bpf.MapKey, value bpf.MapValue) {
+		cb(key.(StatsKey), value.(*StatsValue))
+	})
+}
+
+// DeleteStatsEntry removes the traffic statistics with the key key.
+func DeleteStatsEntry(key StatsKey) error {
+	return key.ServiceKey().StatsMap().Delete(key)
+}
+
+// DeleteStats removes the traffic statistics of the service fe and of all of
+// its backends.
+func DeleteStats(fe ServiceKey) error {
+	svcKey := fe.ToNetwork()
+	svcKey.SetBackend(0)
+
+	keys := []StatsKey{}
+	err := DumpStats(fe.StatsMap(), func(key StatsKey, value *StatsValue) {
+		if reflect.DeepEqual(key.ServiceKey(), svcKey) {
+			keys = append(keys, key)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := fe.StatsMap().Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbmap
+
+import (
+	"net"
+	"reflect"
+	"unsafe"
+
+	"github.com/cilium/cilium/pkg/byteorder"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LBMapTestSuite) TestStatsSizes(c *C) {
+	// Must match the packed structs in "bpf/lib/common.h"
+	c.Assert(unsafe.Sizeof(Stats4Key{}), Equals, uintptr(12))
+	c.Assert(unsafe.Sizeof(Stats6Key{}), Equals, uintptr(36))
+	c.Assert(unsafe.Sizeof(StatsValue{}), Equals, uintptr(24))
+}
+
+func (s *LBMapTestSuite) TestStatsKey(c *C) {
+	fe := NewService4Key(net.ParseIP("10.0.0.1"), 80, 2)
+	svcKey := fe.ToNetwork()
+	svcKey.SetBackend(0)
+
+	key := &Stats4Key{Port: byteorder.HostToNetwork(uint16(80)).(uint16)}
+	copy(key.Address[:], net.ParseIP("10.0.0.1").To4())
+
+	c.Assert(reflect.DeepEqual(key.ServiceKey(), svcKey), Equals, true)
+
+	ip, port := key.GetFrontend()
+	c.Assert(ip.String(), Equals, "10.0.0.1")
+	c.Assert(port, Equals, uint16(80))
+
+	// The key holds the totals of the frontend
+	ip, _ = key.GetBackend()
+	c.Assert(ip, IsNil)
+
+	copy(key.Backend[:], net.ParseIP("10.1.0.1").To4())
+	key.BackendPort = byteorder.HostToNetwork(uint16(8080)).(uint16)
+	ip, port = key.GetBackend()
+	c.Assert(ip.String(), Equals, "10.1.0.1")
+	c.Assert(port, Equals, uint16(8080))
+}
+
+func (s *LBMapTestSuite) TestSetStats(c *C) {
+	c.Assert(IsStatsEnabled(), Equals, false)
+	SetStats(true)
+	c.Assert(IsStatsEnabled(), Equals, true)
+	SetStats(false)
+	c.Assert(IsStatsEnabled(), Equals, false)
+}
@@ -107,12 +107,13 @@ type EndpointFrontend interface {
 // Must be in sync with struct endpoint_info in <bpf/lib/common.h>
 type EndpointInfo struct {
 	IfIndex    uint32
-	SecLabelID uint16
+	Unused     uint16 // Used to hold a 16 bit security identity
 	LxcID      uint16
 	Flags      uint32
 	MAC        MAC
 	NodeMAC    MAC
-	Pad        [4]uint32
+	SecLabelID uint32 // In network byte-order
+	Pad        [3]uint32
 }
 
 // GetValuePtr returns the unsafe pointer to the BPF value
@@ -56,6 +56,14 @@ var (
 	// LabelEventSourceContainerd marks event-related metrics that come from docker
 	LabelEventSourceContainerd = "docker"
 
+	// LabelValueWatchResume marks a kvstore watch restart which resumed
+	// from the last seen revision
+	LabelValueWatchResume = "resume"
+
+	// LabelValueWatchRelist marks a kvstore watch restart which required
+	// to list all keys again
+	LabelValueWatchRelist = "relist"
+
 	// Endpoint
 
 	// EndpointCount is a function used to collect this metric.
@@ -180,6 +188,102 @@ var (
 		Help:      "Total forwarded packets, tagged by ingress/egress direction",
 	},
 		[]string{"direction"})
+
+	// KVStore
+
+	// KVStoreWatchRestarts is the number of times a kvstore watch has been
+	// restarted, tagged by watcher and by whether the watch was resumed or
+	// all keys had to be listed again
+	KVStoreWatchRestarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "kvstore_watch_restarts_total",
+		Help:      "Number of kvstore watch restarts, tagged by watcher and restart type",
+	},
+		[]string{"watcher", "type"})
+
+	// KVStoreOperationsDuration is the duration of kvstore operations,
+	// tagged by operation and by the prefix of the key
+	KVStoreOperationsDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "kvstore_operations_duration_seconds",
+		Help:      "Duration of kvstore operations, tagged by operation and prefix",
+	},
+		[]string{"operation", "prefix"})
+
+	// KVStoreOperationsErrors is the number of failed kvstore operations,
+	// tagged by operation and by the prefix of the key
+	KVStoreOperationsErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "kvstore_operations_errors_total",
+		Help:      "Number of failed kvstore operations, tagged by operation and prefix",
+	},
+		[]string{"operation", "prefix"})
+
+	// KVStoreOperationsBytes is the number of bytes read or written by
+	// kvstore operations, tagged by operation and by the prefix of the key
+	KVStoreOperationsBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "kvstore_operations_bytes",
+		Help:      "Number of bytes read or written by kvstore operations, tagged by operation and prefix",
+		Buckets:   prometheus.ExponentialBuckets(16, 4, 8),
+	},
+		[]string{"operation", "prefix"})
+
+	// KVStoreOperationsThrottled is the number of kvstore operations which
+	// have been delayed by the client-side rate limit, tagged by operation
+	// class
+	KVStoreOperationsThrottled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "kvstore_operations_throttled_total",
+		Help:      "Number of kvstore operations delayed by the client-side rate limit, tagged by operation class",
+	},
+		[]string{"class"})
+
+	// Services
+
+	// ServicesHealthChecks is the number of health check probes of service
+	// backends, tagged by probe type and outcome
+	ServicesHealthChecks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "services_health_checks_total",
+		Help:      "Number of health check probes of service backends, tagged by probe type and outcome",
+	},
+		[]string{"type", "outcome"})
+
+	// ServicesBackendsUnhealthy is the number of service backends currently
+	// removed from the load balancer because they fail their health checks
+	ServicesBackendsUnhealthy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "services_backends_unhealthy",
+		Help:      "Number of service backends removed from the load balancer by failing health checks",
+	})
+
+	// ServicesPackets is the number of packets forwarded to service
+	// backends, tagged by service and namespace
+	ServicesPackets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "services_packets_total",
+		Help:      "Number of packets forwarded to service backends, tagged by service and namespace",
+	},
+		[]string{"service", "namespace"})
+
+	// ServicesBytes is the number of bytes forwarded to service backends,
+	// tagged by service and namespace
+	ServicesBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "services_bytes_total",
+		Help:      "Number of bytes forwarded to service backends, tagged by service and namespace",
+	},
+		[]string{"service", "namespace"})
+
+	// ServicesConnections is the number of new connections to service
+	// backends, tagged by service and namespace
+	ServicesConnections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "services_connections_total",
+		Help:      "Number of new connections to service backends, tagged by service and namespace",
+	},
+		[]string{"service", "namespace"})
 )
 
 func init() {
@@ -205,6 +309,18 @@ func init() {
 
 	MustRegister(DropCount)
 	MustRegister(ForwardCount)
+
+	MustRegister(KVStoreWatchRestarts)
+	MustRegister(KVStoreOperationsDuration)
+	MustRegister(KVStoreOperationsErrors)
+	MustRegister(KVStoreOperationsBytes)
+	MustRegister(KVStoreOperationsThrottled)
+
+	MustRegister(ServicesHealthChecks)
+	MustRegister(ServicesBackendsUnhealthy)
+	MustRegister(ServicesPackets)
+	MustRegister(ServicesBytes)
+	MustRegister(ServicesConnections)
 }
 
 // MustRegister adds the collector to the registry, exposing this metric to
@@ -16,27 +16,58 @@ package node
 
 import (
 	"net"
+	"path"
 
 	"github.com/cilium/cilium/api/v1/models"
+	"github.com/cilium/cilium/pkg/option"
 
 	"k8s.io/api/core/v1"
 )
 
 // Identity represents the node identity of a node.
 type Identity struct {
-	Name string
+	Name    string
+	Cluster string
 }
 
-// String returns the string representation on NodeIdentity.
+// String returns the string representation on NodeIdentity. The cluster
+// name is only included for nodes of remote clusters.
 func (nn Identity) String() string {
-	return nn.Name
+	if nn.Cluster == "" || nn.Cluster == option.Config.ClusterName {
+		return nn.Name
+	}
+
+	return path.Join(nn.Cluster, nn.Name)
 }
 
+// Source is the source from which the information about a node was learned
+type Source string
+
+const (
+	// FromLocalNode is the source of the local node
+	FromLocalNode Source = "local"
+
+	// FromKubernetes is the source of nodes learned from the Kubernetes
+	// Node resources
+	FromKubernetes Source = "kubernetes"
+
+	// FromKVStore is the source of nodes learned from the node store in
+	// the kvstore
+	FromKVStore Source = "kvstore"
+
+	// FromClusterMesh is the source of nodes of remote clusters learned
+	// via ClusterMesh
+	FromClusterMesh Source = "clustermesh"
+)
+
 // Node contains the nodes name, the list of addresses to this address
 type Node struct {
 	Name        string
 	IPAddresses []Address
 
+	// Cluster is the name of the cluster the node is part of
+	Cluster string
+
 	// IPv4AllocCIDR if set, is the IPv4 address pool out of which the node
 	// allocates IPs for local endpoints from
 	IPv4AllocCIDR *net.IPNet
@@ -55,6 +86,9 @@ type Node struct {
 	// IPv6HealthIP if not nil, this is the IPv6 address of the
 	// cilium-health endpoint located on the node.
 	IPv6HealthIP net.IP
+
+	// Source is the source from which the node was learned
+	Source Source
 }
 
 // Address is a node address which contains an IP and the address type.
@@ -166,20 +200,33 @@ func (n *Node) getHealthAddresses(ipv4 bool) *models.NodeAddressing {
 	}
 }
 
+// Identity returns the identity of the node
+func (n *Node) Identity() Identity {
+	return Identity{Name: n.Name, Cluster: n.Cluster}
+}
+
+// Fullname returns the node's name including the cluster name if the node
+// is part of a remote cluster
+func (n *Node) Fullname() string {
+	return n.Identity().String()
+}
+
 // GetModel returns the API model representation of a node.
 func (n *Node) GetModel(ipv4 bool) *models.NodeElement {
 	return &models.NodeElement{
-		Name:                  n.Name,
+		Name:                  n.Fullname(),
 		PrimaryAddress:        n.getPrimaryAddress(ipv4),
 		SecondaryAddresses:    n.getSecondaryAddresses(ipv4),
 		HealthEndpointAddress: n.getHealthAddresses(ipv4),
+		Source:                string(n.Source),
 	}
 }
 
 // GetLocalNode returns the identity and node spec for the local node
 func GetLocalNode() (Identity, *Node) {
-	return Identity{Name: nodeName}, &Node{
-		Name: nodeName,
+	return Identity{Name: nodeName, Cluster: option.Config.ClusterName}, &Node{
+		Name:    nodeName,
+		Cluster: option.Config.ClusterName,
 		IPAddresses: []Address{
 			{
 				AddressType: v1.NodeInternalIP,
@@ -190,6 +237,7 @@ func GetLocalNode() (Identity, *Node) {
 		IPv6AllocCIDR: GetIPv6AllocRange(),
 		IPv4HealthIP:  GetIPv4HealthIP(),
 		IPv6HealthIP:  GetIPv6HealthIP(),
+		Source:        FromLocalNode,
 	}
 
 }
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -110,6 +111,86 @@ func findIPv6NodeAddr() net.IP {
 	return nil
 }
 
+// isCiliumDevice returns true if name is a network device managed by cilium,
+// i.e. cilium_host, cilium_net, the encapsulation devices or the host side
+// of the veth pair of an endpoint
+func isCiliumDevice(name string) bool {
+	return strings.HasPrefix(name, "cilium_") || strings.HasPrefix(name, "lxc")
+}
+
+// getLocalAddresses returns the addresses with global scope of the given
+// netlink address family indexed by the name of the network device they are
+// assigned to. The devices managed by cilium are ignored.
+func getLocalAddresses(family int) (map[string][]net.IP, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list network devices: %s", err)
+	}
+
+	addrs := map[string][]net.IP{}
+	for _, link := range links {
+		name := link.Attrs().Name
+		if isCiliumDevice(name) {
+			continue
+		}
+
+		linkAddrs, err := netlink.AddrList(link, family)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list addresses of network device %s: %s", name, err)
+		}
+		for _, a := range linkAddrs {
+			if a.Scope == unix.RT_SCOPE_UNIVERSE {
+				addrs[name] = append(addrs[name], a.IP)
+			}
+		}
+	}
+
+	return addrs, nil
+}
+
+// sortedDevices returns the names of the network devices of addrs in
+// alphabetical order
+func sortedDevices(addrs map[string][]net.IP) []string {
+	devices := make([]string, 0, len(addrs))
+	for device := range addrs {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+// GetLocalAddresses returns the addresses with global scope of the given
+// address family assigned to the network devices of the node, the devices
+// managed by cilium are ignored
+func GetLocalAddresses(ipv4 bool) ([]net.IP, error) {
+	family := netlink.FAMILY_V6
+	if ipv4 {
+		family = netlink.FAMILY_V4
+	}
+
+	byDevice, err := getLocalAddresses(family)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := []net.IP{}
+	for _, device := range sortedDevices(byDevice) {
+		addrs = append(addrs, byDevice[device]...)
+	}
+	return addrs, nil
+}
+
+// GetLocalAddressDevices returns the names of the network devices of the
+// node with an address with global scope, the devices managed by cilium are
+// ignored
+func GetLocalAddressDevices() ([]string, error) {
+	byDevice, err := getLocalAddresses(netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+	return sortedDevices(byDevice), nil
+}
+
 // SetIPv4ClusterCidrMaskSize sets the size of the mask of the IPv4 cluster prefix
 func SetIPv4ClusterCidrMaskSize(size int) {
 	ipv4ClusterCidrMaskSize = size
@@ -504,6 +585,12 @@ func getCiliumHostIPsFromNetDev(devName string) (ipv4GW, ipv6Router net.IP) {
 	return nil, nil
 }
 
+// GetRestoredCiliumHostIPs returns the IPv4 gateway and IPv6 router address
+// used by the previous cilium instance, if known.
+func GetRestoredCiliumHostIPs() (ipv4GW, ipv6Router net.IP) {
+	return getCiliumHostIPs()
+}
+
 // getCiliumHostIPs returns the Cilium IPv4 gateway and router IPv6 address from
 // the node_config.h file if is present; or by deriving it from cilium_host
 // interface, on which only the IPv4 is possible to derive.
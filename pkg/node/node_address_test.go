@@ -49,6 +49,32 @@ func (s *NodeSuite) TestMaskCheck(c *C) {
 	c.Assert(IsHostIPv6(GetIPv6()), Equals, true)
 }
 
+func (s *NodeSuite) TestIsCiliumDevice(c *C) {
+	c.Assert(isCiliumDevice("cilium_host"), Equals, true)
+	c.Assert(isCiliumDevice("cilium_vxlan"), Equals, true)
+	c.Assert(isCiliumDevice("lxc12ab34cd"), Equals, true)
+	c.Assert(isCiliumDevice("eth0"), Equals, false)
+	c.Assert(isCiliumDevice("docker0"), Equals, false)
+}
+
+func (s *NodeSuite) TestGetLocalAddresses(c *C) {
+	for _, ipv4 := range []bool{true, false} {
+		addrs, err := GetLocalAddresses(ipv4)
+		c.Assert(err, IsNil)
+		for _, addr := range addrs {
+			c.Assert(addr.To4() != nil, Equals, ipv4)
+			c.Assert(addr.IsLoopback(), Equals, false)
+			c.Assert(addr.IsLinkLocalUnicast(), Equals, false)
+		}
+	}
+
+	devices, err := GetLocalAddressDevices()
+	c.Assert(err, IsNil)
+	for _, device := range devices {
+		c.Assert(isCiliumDevice(device), Equals, false)
+	}
+}
+
 func (s *NodeSuite) Test_getCiliumHostIPsFromFile(c *C) {
 	tmpDir := c.MkDir()
 	allIPsCorrect := filepath.Join(tmpDir, "node_config.h")
@@ -0,0 +1,179 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+
+	"github.com/cilium/cilium/pkg/kvstore"
+	"github.com/cilium/cilium/pkg/kvstore/store"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/node"
+)
+
+var (
+	// NodeStorePrefix is the kvstore prefix of the shared store
+	//
+	// WARNING - STABLE API: Changing the structure or values of this will
+	// break backwards compatibility
+	NodeStorePrefix = path.Join(kvstore.BaseKeyPrefix, "state", "nodes", "v1")
+)
+
+// NodeObserver is the interface to implement in order to get notified about
+// nodes appearing and disappearing in a node store
+type NodeObserver interface {
+	// NodeUpdated is called when a node has been created or updated
+	NodeUpdated(n node.Node)
+
+	// NodeDeleted is called when a node has been deleted
+	NodeDeleted(n node.Node)
+}
+
+// nodeKey is the representation of a node in the shared store. It
+// implements the store.Key interface.
+type nodeKey struct {
+	// mutex protects node
+	mutex lock.RWMutex
+	node  node.Node
+
+	// observer is notified on changes, it may be nil
+	observer NodeObserver
+}
+
+// GetKeyName returns the kvstore key name of the node. The key name includes
+// the cluster name to guarantee uniqueness across clusters.
+//
+// WARNING - STABLE API: Changing the structure of the key may break
+// backwards compatibility
+func (k *nodeKey) GetKeyName() string {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return path.Join(k.node.Cluster, k.node.Name)
+}
+
+// Marshal returns the JSON representation of the node
+func (k *nodeKey) Marshal() ([]byte, error) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return json.Marshal(k.node)
+}
+
+// Unmarshal parses the JSON representation of a node and updates the key
+func (k *nodeKey) Unmarshal(data []byte) error {
+	newNode := node.Node{}
+	if err := json.Unmarshal(data, &newNode); err != nil {
+		return err
+	}
+
+	k.mutex.Lock()
+	k.node = newNode
+	k.mutex.Unlock()
+
+	return nil
+}
+
+func (k *nodeKey) getNode() node.Node {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.node
+}
+
+// OnUpdate is called when the node has been created or updated in the store
+func (k *nodeKey) OnUpdate() {
+	if k.observer != nil {
+		k.observer.NodeUpdated(k.getNode())
+	}
+}
+
+// OnDelete is called when the node has been removed from the store
+func (k *nodeKey) OnDelete() {
+	if k.observer != nil {
+		k.observer.NodeDeleted(k.getNode())
+	}
+}
+
+// JoinNodeStore joins the node store in the kvstore represented by backend.
+// The observer is notified about all nodes in the store. If backend is nil,
+// the default kvstore client is used.
+func JoinNodeStore(backend kvstore.BackendOperations, observer NodeObserver) (*store.SharedStore, error) {
+	return store.JoinSharedStore(store.Configuration{
+		Prefix:  NodeStorePrefix,
+		Backend: backend,
+		KeyCreator: func() store.Key {
+			return &nodeKey{observer: observer}
+		},
+	})
+}
+
+// NodeRegistrar is a wrapper around store.SharedStore which publishes the
+// local node into the node store
+type NodeRegistrar struct {
+	*store.SharedStore
+
+	// mutex protects registered
+	mutex lock.Mutex
+
+	// registered is the node published last, it is nil if no node has
+	// been registered
+	registered *node.Node
+}
+
+// RegisterNode joins the node store of the default kvstore client and
+// publishes the node n. The node is kept up to date in the kvstore until
+// Close() is called. The observer, if not nil, is notified about all other
+// nodes in the store.
+func (nr *NodeRegistrar) RegisterNode(n node.Node, observer NodeObserver) error {
+	s, err := JoinNodeStore(nil, observer)
+	if err != nil {
+		return err
+	}
+
+	nr.SharedStore = s
+
+	if err := nr.UpdateLocalNodeSync(n); err != nil {
+		return err
+	}
+
+	nr.mutex.Lock()
+	nr.registered = &n
+	nr.mutex.Unlock()
+
+	return nil
+}
+
+// UpdateLocalNode publishes the node n again if it differs from the node
+// published last, e.g. because the addresses or the allocation ranges of the
+// node have changed. It returns true if n has been published. If the node
+// cannot be written to the kvstore, the write is retried by the store. Nodes
+// are only published after RegisterNode() succeeded.
+func (nr *NodeRegistrar) UpdateLocalNode(n node.Node) (bool, error) {
+	nr.mutex.Lock()
+	defer nr.mutex.Unlock()
+
+	if nr.registered == nil || reflect.DeepEqual(*nr.registered, n) {
+		return false, nil
+	}
+
+	nr.registered = &n
+
+	return true, nr.ReplaceLocalKeySync(&nodeKey{node: n})
+}
+
+// UpdateLocalNodeSync synchronously updates the local node in the kvstore
+func (nr *NodeRegistrar) UpdateLocalNodeSync(n node.Node) error {
+	return nr.UpdateLocalKeySync(&nodeKey{node: n})
+}
@@ -0,0 +1,210 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cilium/cilium/pkg/kvstore"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/node"
+
+	. "gopkg.in/check.v1"
+	"k8s.io/api/core/v1"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type NodeStoreSuite struct{}
+
+type NodeStoreEtcdSuite struct {
+	NodeStoreSuite
+}
+
+var _ = Suite(&NodeStoreEtcdSuite{})
+
+func (e *NodeStoreEtcdSuite) SetUpTest(c *C) {
+	kvstore.SetupDummy("etcd")
+}
+
+func (e *NodeStoreEtcdSuite) TearDownTest(c *C) {
+	kvstore.DeletePrefix(NodeStorePrefix)
+	kvstore.Close()
+}
+
+type NodeStoreConsulSuite struct {
+	NodeStoreSuite
+}
+
+var _ = Suite(&NodeStoreConsulSuite{})
+
+func (e *NodeStoreConsulSuite) SetUpTest(c *C) {
+	kvstore.SetupDummy("consul")
+}
+
+func (e *NodeStoreConsulSuite) TearDownTest(c *C) {
+	kvstore.DeletePrefix(NodeStorePrefix)
+	kvstore.Close()
+}
+
+type NodeStoreMemorySuite struct {
+	NodeStoreSuite
+}
+
+var _ = Suite(&NodeStoreMemorySuite{})
+
+func (e *NodeStoreMemorySuite) SetUpTest(c *C) {
+	kvstore.SetupDummy(kvstore.MemoryBackendName)
+}
+
+func (e *NodeStoreMemorySuite) TearDownTest(c *C) {
+	kvstore.Close()
+}
+
+// testObserver records all nodes known to a node store
+type testObserver struct {
+	mutex lock.Mutex
+	nodes map[node.Identity]node.Node
+}
+
+func (o *testObserver) NodeUpdated(n node.Node) {
+	o.mutex.Lock()
+	o.nodes[n.Identity()] = n
+	o.mutex.Unlock()
+}
+
+func (o *testObserver) NodeDeleted(n node.Node) {
+	o.mutex.Lock()
+	delete(o.nodes, n.Identity())
+	o.mutex.Unlock()
+}
+
+func (o *testObserver) getNode(ni node.Identity) (node.Node, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	n, ok := o.nodes[ni]
+	return n, ok
+}
+
+func waitForNode(c *C, o *testObserver, ni node.Identity, exists bool) node.Node {
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+		if n, ok := o.getNode(ni); ok == exists {
+			return n
+		}
+	}
+	c.Fatalf("Timeout while waiting for node %s (exists=%t)", ni, exists)
+	return node.Node{}
+}
+
+func (s *NodeStoreSuite) TestNodeDiscovery(c *C) {
+	_, cidr1, _ := net.ParseCIDR("10.1.0.0/16")
+	_, cidr2, _ := net.ParseCIDR("10.2.0.0/16")
+
+	node1 := node.Node{
+		Name:    "node1",
+		Cluster: "default",
+		IPAddresses: []node.Address{
+			{AddressType: v1.NodeInternalIP, IP: net.ParseIP("192.168.0.1")},
+		},
+		IPv4AllocCIDR: cidr1,
+		IPv4HealthIP:  net.ParseIP("10.1.0.2"),
+	}
+	node2 := node.Node{
+		Name:          "node2",
+		Cluster:       "default",
+		IPv4AllocCIDR: cidr2,
+	}
+
+	observer1 := &testObserver{nodes: map[node.Identity]node.Node{}}
+	registrar1 := NodeRegistrar{}
+	c.Assert(registrar1.RegisterNode(node1, observer1), IsNil)
+	defer registrar1.Close()
+
+	observer2 := &testObserver{nodes: map[node.Identity]node.Node{}}
+	registrar2 := NodeRegistrar{}
+	c.Assert(registrar2.RegisterNode(node2, observer2), IsNil)
+
+	// Each registrar observes the other node but not its own node
+	n := waitForNode(c, observer2, node1.Identity(), true)
+	c.Assert(n.GetNodeIP(false).String(), Equals, "192.168.0.1")
+	c.Assert(n.IPv4AllocCIDR.String(), Equals, "10.1.0.0/16")
+	c.Assert(n.IPv4HealthIP.String(), Equals, "10.1.0.2")
+
+	n = waitForNode(c, observer1, node2.Identity(), true)
+	c.Assert(n.IPv4AllocCIDR.String(), Equals, "10.2.0.0/16")
+
+	_, ok := observer1.getNode(node1.Identity())
+	c.Assert(ok, Equals, false)
+	_, ok = observer2.getNode(node2.Identity())
+	c.Assert(ok, Equals, false)
+
+	// Nodes leaving the store are removed
+	registrar2.Close()
+	waitForNode(c, observer1, node2.Identity(), false)
+}
+
+func (s *NodeStoreSuite) TestUpdateLocalNode(c *C) {
+	_, cidr1, _ := net.ParseCIDR("10.1.0.0/16")
+	_, cidr2, _ := net.ParseCIDR("10.2.0.0/16")
+
+	node1 := node.Node{
+		Name:          "node1",
+		Cluster:       "default",
+		IPv4AllocCIDR: cidr1,
+	}
+
+	registrar1 := NodeRegistrar{}
+
+	// Nodes are only published after the registration
+	updated, err := registrar1.UpdateLocalNode(node1)
+	c.Assert(err, IsNil)
+	c.Assert(updated, Equals, false)
+
+	c.Assert(registrar1.RegisterNode(node1, nil), IsNil)
+	defer registrar1.Close()
+
+	observer2 := &testObserver{nodes: map[node.Identity]node.Node{}}
+	registrar2 := NodeRegistrar{}
+	c.Assert(registrar2.RegisterNode(node.Node{Name: "node2", Cluster: "default"}, observer2), IsNil)
+	defer registrar2.Close()
+
+	n := waitForNode(c, observer2, node1.Identity(), true)
+	c.Assert(n.IPv4AllocCIDR.String(), Equals, "10.1.0.0/16")
+	c.Assert(n.IPv4HealthIP, IsNil)
+
+	updated, err = registrar1.UpdateLocalNode(node1)
+	c.Assert(err, IsNil)
+	c.Assert(updated, Equals, false)
+
+	// The allocation range and the health IP changed
+	node1.IPv4AllocCIDR = cidr2
+	node1.IPv4HealthIP = net.ParseIP("10.2.0.2")
+	updated, err = registrar1.UpdateLocalNode(node1)
+	c.Assert(err, IsNil)
+	c.Assert(updated, Equals, true)
+
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		c.Assert(time.Since(start) < 10*time.Second, Equals, true)
+		if n, _ = observer2.getNode(node1.Identity()); n.IPv4HealthIP != nil {
+			break
+		}
+	}
+	c.Assert(n.IPv4AllocCIDR.String(), Equals, "10.2.0.0/16")
+	c.Assert(n.IPv4HealthIP.String(), Equals, "10.2.0.2")
+}
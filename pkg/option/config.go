@@ -15,13 +15,16 @@
 package option
 
 import (
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/cilium/cilium/api/v1/models"
 	"github.com/cilium/cilium/common"
+	"github.com/cilium/cilium/daemon/defaults"
 	"github.com/cilium/cilium/pkg/lock"
 )
 
@@ -44,6 +47,22 @@ const (
 
 	// ModePreFilterGeneric for loading progs with xdpgeneric
 	ModePreFilterGeneric = "generic"
+
+	// ClusterIDMin is the minimum value of the cluster ID
+	ClusterIDMin = 0
+
+	// ClusterIDMax is the maximum value of the cluster ID. Identities
+	// including the cluster ID must fit into the 20 bit IPv6 flow label,
+	// see identity.ClusterIDBits.
+	ClusterIDMax = 15
+
+	// NodePortMinDefault is the minimum port of the default NodePort
+	// range, it matches the Kubernetes default
+	NodePortMinDefault = 30000
+
+	// NodePortMaxDefault is the maximum port of the default NodePort
+	// range, it matches the Kubernetes default
+	NodePortMaxDefault = 32767
 )
 
 // daemonConfig is the configuration used by Daemon.
@@ -89,12 +108,61 @@ type daemonConfig struct {
 
 	// AgentLabels contains additional labels to identify this agent in monitor events.
 	AgentLabels []string
+
+	// ClusterName is the name of the cluster this agent is part of. It is
+	// attached to all endpoints as label and allows to select endpoints
+	// of a particular cluster in policies.
+	ClusterName string
+
+	// ClusterID is the unique identifier of the cluster this agent is
+	// part of. It is encoded into all security identities allocated by
+	// this agent to avoid collisions between clusters.
+	ClusterID int
+
+	// ClusterMeshConfig is the path to the clustermesh configuration
+	// directory. Each file in the directory contains the etcd
+	// configuration of a remote cluster, the file name is the name of
+	// the remote cluster.
+	ClusterMeshConfig string
+
+	// EnableNodePort enables the NodePort frontends of Kubernetes
+	// services on all node addresses. The frontends of services are
+	// translated for traffic entering the node as well, it requires
+	// direct server return.
+	EnableNodePort bool
+
+	// NodePortMin is the minimum port of the range of node ports
+	NodePortMin int
+
+	// NodePortMax is the maximum port of the range of node ports
+	NodePortMax int
+
+	// EnableDSR enables direct server return of services, Kubernetes
+	// services are forwarded in DSR mode. It requires kernel support for
+	// LRU maps.
+	EnableDSR bool
+
+	// EnableLBStats enables the collection of the traffic statistics of
+	// services by the datapath.
+	EnableLBStats bool
+
+	// EnableSessionAffinity enables ClientIP session affinity of services.
+	// It requires kernel support for LRU maps.
+	EnableSessionAffinity bool
+
+	// LBDrainTimeout is the maximum duration a backend removed from a
+	// service keeps serving its established connections. Removed
+	// backends are not drained if it is zero.
+	LBDrainTimeout time.Duration
 }
 
 var (
 	Config = &daemonConfig{
-		Opts:    NewBoolOptions(&daemonLibrary),
-		Monitor: &models.MonitorStatus{Cpus: int64(runtime.NumCPU()), Npages: 64, Pagesize: int64(os.Getpagesize()), Lost: 0, Unknown: 0},
+		Opts:        NewBoolOptions(&daemonLibrary),
+		Monitor:     &models.MonitorStatus{Cpus: int64(runtime.NumCPU()), Npages: 64, Pagesize: int64(os.Getpagesize()), Lost: 0, Unknown: 0},
+		ClusterName: defaults.ClusterName,
+		NodePortMin: NodePortMinDefault,
+		NodePortMax: NodePortMaxDefault,
 	}
 )
 
@@ -102,6 +170,12 @@ func (c *daemonConfig) IsLBEnabled() bool {
 	return c.LBInterface != ""
 }
 
+// IsLBDrainingEnabled returns true if backends removed from services are
+// drained.
+func (c *daemonConfig) IsLBDrainingEnabled() bool {
+	return c.LBDrainTimeout > 0
+}
+
 // GetNodeConfigPath returns the full path of the NodeConfigFile.
 func (c *daemonConfig) GetNodeConfigPath() string {
 	return filepath.Join(c.GetGlobalsDir(), common.NodeConfigFile)
@@ -125,6 +199,37 @@ func (c *daemonConfig) AlwaysAllowLocalhost() bool {
 	}
 }
 
+// ValidateClusterID returns an error if the configured cluster ID is outside
+// of the supported range
+func (c *daemonConfig) ValidateClusterID() error {
+	if c.ClusterID < ClusterIDMin || c.ClusterID > ClusterIDMax {
+		return fmt.Errorf("invalid cluster id %d: must be in range %d..%d",
+			c.ClusterID, ClusterIDMin, ClusterIDMax)
+	}
+
+	return nil
+}
+
+// ParseNodePortRange parses the range of node ports in the "min-max" format
+func (c *daemonConfig) ParseNodePortRange(portRange string) error {
+	var min, max int
+	if _, err := fmt.Sscanf(portRange, "%d-%d", &min, &max); err != nil {
+		return fmt.Errorf("invalid node port range %q: must be in the format min-max", portRange)
+	}
+
+	if min <= 0 || max > 65535 || min > max {
+		return fmt.Errorf("invalid node port range %q", portRange)
+	}
+
+	c.NodePortMin, c.NodePortMax = min, max
+	return nil
+}
+
+// IsNodePortInRange returns true if port is in the range of node ports
+func (c *daemonConfig) IsNodePortInRange(port int) bool {
+	return port >= c.NodePortMin && port <= c.NodePortMax
+}
+
 // TracingEnabled returns if tracing policy (outlining which rules apply to a
 // specific set of labels) is enabled.
 func (c *daemonConfig) TracingEnabled() bool {
@@ -93,3 +93,34 @@ func (s *OptionSuite) TestGetFmtOpt(c *C) {
 	c.Assert(o.getFmtOpt("BAZ"), Equals, "#undef BAZ")
 	o.optsMU.Unlock()
 }
+
+func (s *OptionSuite) TestParseNodePortRange(c *C) {
+	cfg := &daemonConfig{}
+
+	c.Assert(cfg.ParseNodePortRange("20000-20100"), IsNil)
+	c.Assert(cfg.NodePortMin, Equals, 20000)
+	c.Assert(cfg.NodePortMax, Equals, 20100)
+	c.Assert(cfg.IsNodePortInRange(20000), Equals, true)
+	c.Assert(cfg.IsNodePortInRange(20100), Equals, true)
+	c.Assert(cfg.IsNodePortInRange(20101), Equals, false)
+
+	c.Assert(cfg.ParseNodePortRange("20100-20000"), Not(IsNil))
+	c.Assert(cfg.ParseNodePortRange("0-100"), Not(IsNil))
+	c.Assert(cfg.ParseNodePortRange("30000-70000"), Not(IsNil))
+	c.Assert(cfg.ParseNodePortRange("30000"), Not(IsNil))
+}
+
+func (s *OptionSuite) TestValidateClusterID(c *C) {
+	cfg := &daemonConfig{}
+
+	for _, id := range []int{ClusterIDMin, 1, ClusterIDMax} {
+		cfg.ClusterID = id
+		c.Assert(cfg.ValidateClusterID(), IsNil)
+	}
+
+	// cluster IDs exceeding the IPv6 flow label are rejected
+	for _, id := range []int{-1, ClusterIDMax + 1, 255} {
+		cfg.ClusterID = id
+		c.Assert(cfg.ValidateClusterID(), Not(IsNil))
+	}
+}
@@ -14,32 +14,33 @@
 
 package proxy
 
-// Magic markers are attached to each packet. The lower 16 bits are used to
-// identify packets which have gone through the proxy and to determine whether
-// the packet is coming from a proxy at ingress or egress, or from the host.
-// The marking is compatible with Kubernetes's use of the packet mark.  The
-// upper 16 bits can be used to carry the security identity.
+// Magic markers are attached to each packet. Bits 8-11 are used to identify
+// packets which have gone through the proxy and to determine whether the
+// packet is coming from a proxy at ingress or egress, or from the host. The
+// marking is compatible with Kubernetes's use of the packet mark. The upper 16
+// bits plus the lower 8 bits can be used to carry the security identity, the
+// lower 8 bits carry the cluster ID portion of the identity.
 const (
 	// MagicMarkHostMask can be used to fetch the host/proxy-relevant magic
 	// bits from a mark.
-	MagicMarkHostMask int = 0x0FFF
+	MagicMarkHostMask int = 0x0F00
 	// MagicMarkProxyMask can be used to fetch the proxy-relevant magic
 	// bits from a mark.
-	MagicMarkProxyMask int = 0x0FFE
+	MagicMarkProxyMask int = 0x0E00
 	// MagicMarkIsProxy can be used in conjunction with MagicMarkProxyMask
 	// to determine whether the mark is indicating that traffic is peering
 	// with a proxy.
-	MagicMarkIsProxy int = 0x0FEA
+	MagicMarkIsProxy int = 0x0A00
 
 	// MagicMarkIngress determines that the traffic is sourced from the
 	// proxy which is applying Ingress policy
-	MagicMarkIngress int = 0x0FEA
+	MagicMarkIngress int = 0x0A00
 	// MagicMarkEgress determines that the traffic is sourced from the
 	// proxy which is applying Egress policy
-	MagicMarkEgress int = 0x0FEB
+	MagicMarkEgress int = 0x0B00
 	// MagicMarkHost determines that the traffic is sourced from the local
 	// host and not from a proxy.
-	MagicMarkHost int = 0x0FEC
+	MagicMarkHost int = 0x0C00
 	// MagicMarkK8sMasq determines that the traffic should be masqueraded
 	// by kube-proxy in kubernetes environments.
 	MagicMarkK8sMasq int = 0x4000
@@ -61,7 +62,7 @@ func getMagicMark(isIngress bool, identity int) int {
 	}
 
 	if identity != 0 {
-		mark |= identity << 16
+		mark |= (identity&0xFFFF)<<16 | (identity>>16)&0xFF
 	}
 
 	return mark
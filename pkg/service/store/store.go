@@ -0,0 +1,296 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/kvstore"
+	"github.com/cilium/cilium/pkg/kvstore/store"
+	"github.com/cilium/cilium/pkg/lock"
+)
+
+var (
+	// ServiceStorePrefix is the kvstore prefix of the shared store
+	//
+	// WARNING - STABLE API: Changing the structure or values of this will
+	// break backwards compatibility
+	ServiceStorePrefix = path.Join(kvstore.BaseKeyPrefix, "state", "services", "v1")
+)
+
+// ClusterService is the representation of the backends of a global service
+// of a cluster in the shared store.
+//
+// WARNING - STABLE API: Changing the structure of the service may break
+// backwards compatibility
+type ClusterService struct {
+	// Cluster is the name of the cluster the backends belong to
+	Cluster string `json:"cluster"`
+
+	// Namespace is the namespace of the service
+	Namespace string `json:"namespace"`
+
+	// Name is the name of the service
+	Name string `json:"name"`
+
+	// Backends are the IP addresses of the backends
+	Backends []string `json:"backends"`
+
+	// Ports are the ports of the backends indexed by the name of the
+	// service port
+	Ports map[string]*types.L4Addr `json:"ports"`
+}
+
+// NewClusterService returns the cluster service of the cluster with the
+// backends of the service svc in ep.
+func NewClusterService(cluster string, svc types.K8sServiceNamespace, ep *types.K8sServiceEndpoint) ClusterService {
+	cs := ClusterService{
+		Cluster:   cluster,
+		Namespace: svc.Namespace,
+		Name:      svc.ServiceName,
+		Backends:  make([]string, 0, len(ep.BEIPs)),
+		Ports:     make(map[string]*types.L4Addr, len(ep.Ports)),
+	}
+
+	for ip := range ep.BEIPs {
+		cs.Backends = append(cs.Backends, ip)
+	}
+	// Keep the representation stable to avoid needless kvstore updates
+	sort.Strings(cs.Backends)
+
+	for name, port := range ep.Ports {
+		cs.Ports[string(name)] = port.DeepCopy()
+	}
+
+	return cs
+}
+
+// K8sServiceNamespace returns the service and namespace of the service
+func (s *ClusterService) K8sServiceNamespace() types.K8sServiceNamespace {
+	return types.K8sServiceNamespace{
+		ServiceName: s.Name,
+		Namespace:   s.Namespace,
+	}
+}
+
+// K8sServiceEndpoint returns the backends of the service as k8s service
+// endpoint
+func (s *ClusterService) K8sServiceEndpoint() *types.K8sServiceEndpoint {
+	ep := types.NewK8sServiceEndpoint()
+	for _, ip := range s.Backends {
+		ep.BEIPs[ip] = true
+	}
+	for name, port := range s.Ports {
+		if port != nil {
+			ep.Ports[types.FEPortName(name)] = port.DeepCopy()
+		}
+	}
+	return ep
+}
+
+// String returns the name of the service including its cluster
+func (s *ClusterService) String() string {
+	return path.Join(s.Cluster, s.Namespace, s.Name)
+}
+
+// ServiceObserver is the interface to implement in order to get notified
+// about global services appearing and disappearing in a service store
+type ServiceObserver interface {
+	// ServiceUpdated is called when a service has been created or updated
+	ServiceUpdated(svc ClusterService)
+
+	// ServiceDeleted is called when a service has been deleted
+	ServiceDeleted(svc ClusterService)
+}
+
+// serviceKey is the representation of a service in the shared store. It
+// implements the store.Key interface.
+type serviceKey struct {
+	// mutex protects service
+	mutex   lock.RWMutex
+	service ClusterService
+
+	// observer is notified on changes, it may be nil
+	observer ServiceObserver
+}
+
+// GetKeyName returns the kvstore key name of the service. The key name
+// includes the cluster name to guarantee uniqueness across clusters.
+//
+// WARNING - STABLE API: Changing the structure of the key may break
+// backwards compatibility
+func (k *serviceKey) GetKeyName() string {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.service.String()
+}
+
+// Marshal returns the JSON representation of the service
+func (k *serviceKey) Marshal() ([]byte, error) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return json.Marshal(k.service)
+}
+
+// Unmarshal parses the JSON representation of a service and updates the key
+func (k *serviceKey) Unmarshal(data []byte) error {
+	newService := ClusterService{}
+	if err := json.Unmarshal(data, &newService); err != nil {
+		return err
+	}
+
+	k.mutex.Lock()
+	k.service = newService
+	k.mutex.Unlock()
+
+	return nil
+}
+
+func (k *serviceKey) getService() ClusterService {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.service
+}
+
+// OnUpdate is called when the service has been created or updated in the
+// store
+func (k *serviceKey) OnUpdate() {
+	if k.observer != nil {
+		k.observer.ServiceUpdated(k.getService())
+	}
+}
+
+// OnDelete is called when the service has been removed from the store
+func (k *serviceKey) OnDelete() {
+	if k.observer != nil {
+		k.observer.ServiceDeleted(k.getService())
+	}
+}
+
+// JoinServiceStore joins the service store in the kvstore represented by
+// backend. The observer is notified about all services in the store which are
+// not published by the local agent. If backend is nil, the default kvstore
+// client is used.
+func JoinServiceStore(backend kvstore.BackendOperations, observer ServiceObserver) (*store.SharedStore, error) {
+	return store.JoinSharedStore(store.Configuration{
+		Prefix:  ServiceStorePrefix,
+		Backend: backend,
+		KeyCreator: func() store.Key {
+			return &serviceKey{observer: observer}
+		},
+	})
+}
+
+// ServiceRegistrar is a wrapper around store.SharedStore which publishes the
+// global services of the local cluster into the service store. Each service
+// key is written by a single agent of the cluster only, the leader, so that
+// the key is not overwritten by agents with a different view of the backends
+// and does not disappear with the lease of an arbitrary agent.
+type ServiceRegistrar struct {
+	*store.SharedStore
+}
+
+// Join joins the service store of the default kvstore client. The observer,
+// if not nil, is notified about all services in the store which are not
+// published by the local agent.
+func (sr *ServiceRegistrar) Join(observer ServiceObserver) error {
+	s, err := JoinServiceStore(nil, observer)
+	if err != nil {
+		return err
+	}
+
+	sr.SharedStore = s
+	return nil
+}
+
+// RegisterService synchronously publishes the service svc. The service is
+// kept up to date in the kvstore until it is deregistered, released or
+// Close() is called, even if the initial publication failed. It is a no-op if
+// the registrar has not joined the store.
+func (sr *ServiceRegistrar) RegisterService(svc ClusterService) error {
+	if sr.SharedStore == nil {
+		return nil
+	}
+
+	key := &serviceKey{service: svc}
+	if err := sr.UpdateLocalKeySync(key); err != nil {
+		// Retry with the next synchronization of the store
+		sr.UpdateLocalKey(key)
+		return err
+	}
+
+	return nil
+}
+
+// DeregisterService removes the service svc from the kvstore. It is a no-op
+// if the registrar has not joined the store.
+func (sr *ServiceRegistrar) DeregisterService(svc ClusterService) {
+	if sr.SharedStore != nil {
+		sr.DeleteLocalKey(&serviceKey{service: svc})
+	}
+}
+
+// SyncServices publishes all global services of the cluster and removes all
+// other services of the cluster from the kvstore, including the ones
+// published by a previous writer. It is a no-op if the registrar has not
+// joined the store.
+func (sr *ServiceRegistrar) SyncServices(cluster string, services []ClusterService) error {
+	if sr.SharedStore == nil {
+		return nil
+	}
+
+	names := map[string]struct{}{}
+	for _, svc := range services {
+		if svc.Cluster != cluster {
+			return fmt.Errorf("service %s is not a service of cluster %s", svc.String(), cluster)
+		}
+		names[svc.String()] = struct{}{}
+		if err := sr.RegisterService(svc); err != nil {
+			return err
+		}
+	}
+
+	// Trailing separator to not match clusters sharing the prefix
+	pairs, err := kvstore.Client().ListPrefix(path.Join(ServiceStorePrefix, cluster) + "/")
+	if err != nil {
+		return err
+	}
+
+	for _, value := range pairs {
+		svc := ClusterService{}
+		if err := json.Unmarshal(value, &svc); err != nil || svc.Cluster != cluster {
+			continue
+		}
+		if _, ok := names[svc.String()]; !ok {
+			sr.DeregisterService(svc)
+		}
+	}
+
+	return nil
+}
+
+// ReleaseServices stops publishing all services without removing them from
+// the kvstore, so that they remain available until the next writer has
+// taken them over. It is a no-op if the registrar has not
+// joined the store.
+func (sr *ServiceRegistrar) ReleaseServices() {
+	if sr.SharedStore != nil {
+		sr.ReleaseLocalKeys()
+	}
+}
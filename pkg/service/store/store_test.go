@@ -0,0 +1,181 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/kvstore"
+	"github.com/cilium/cilium/pkg/lock"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type ServiceStoreSuite struct{}
+
+var _ = Suite(&ServiceStoreSuite{})
+
+func (s *ServiceStoreSuite) SetUpTest(c *C) {
+	kvstore.SetupDummy(kvstore.MemoryBackendName)
+}
+
+func (s *ServiceStoreSuite) TearDownTest(c *C) {
+	kvstore.Close()
+}
+
+func newTestEndpoint(c *C, ips ...string) *types.K8sServiceEndpoint {
+	ep := types.NewK8sServiceEndpoint()
+	for _, ip := range ips {
+		ep.BEIPs[ip] = true
+	}
+	port, err := types.NewL4Addr(types.TCP, 8080)
+	c.Assert(err, IsNil)
+	ep.Ports["http"] = port
+	return ep
+}
+
+func (s *ServiceStoreSuite) TestClusterService(c *C) {
+	svc := types.K8sServiceNamespace{ServiceName: "foo", Namespace: "bar"}
+	ep := newTestEndpoint(c, "10.0.0.2", "10.0.0.1")
+
+	cs := NewClusterService("c1", svc, ep)
+	c.Assert(cs.String(), Equals, "c1/bar/foo")
+	c.Assert(cs.Backends, DeepEquals, []string{"10.0.0.1", "10.0.0.2"})
+	c.Assert(cs.K8sServiceNamespace(), Equals, svc)
+	c.Assert(cs.K8sServiceEndpoint(), DeepEquals, ep)
+}
+
+// testObserver records all services known to a service store
+type testObserver struct {
+	mutex    lock.Mutex
+	services map[string]ClusterService
+}
+
+func (o *testObserver) ServiceUpdated(svc ClusterService) {
+	o.mutex.Lock()
+	o.services[svc.String()] = svc
+	o.mutex.Unlock()
+}
+
+func (o *testObserver) ServiceDeleted(svc ClusterService) {
+	o.mutex.Lock()
+	delete(o.services, svc.String())
+	o.mutex.Unlock()
+}
+
+func (o *testObserver) getService(name string) (ClusterService, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	svc, ok := o.services[name]
+	return svc, ok
+}
+
+func waitForService(c *C, o *testObserver, name string, backends int) ClusterService {
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+		svc, ok := o.getService(name)
+		if (backends < 0 && !ok) || (ok && len(svc.Backends) == backends) {
+			return svc
+		}
+	}
+	c.Fatalf("Timeout while waiting for service %s (backends=%d)", name, backends)
+	return ClusterService{}
+}
+
+func (s *ServiceStoreSuite) TestServiceDiscovery(c *C) {
+	svc := types.K8sServiceNamespace{ServiceName: "foo", Namespace: "bar"}
+
+	registrar1 := ServiceRegistrar{}
+	c.Assert(registrar1.Join(nil), IsNil)
+	defer registrar1.Close()
+
+	observer := &testObserver{services: map[string]ClusterService{}}
+	registrar2 := ServiceRegistrar{}
+	c.Assert(registrar2.Join(observer), IsNil)
+	defer registrar2.Close()
+
+	c.Assert(registrar1.RegisterService(NewClusterService("c1", svc, newTestEndpoint(c, "10.0.0.1"))), IsNil)
+	c.Assert(registrar2.RegisterService(NewClusterService("c2", svc, newTestEndpoint(c, "10.1.0.1"))), IsNil)
+
+	// The service of the other cluster is observed, the own one is not
+	cs := waitForService(c, observer, "c1/bar/foo", 1)
+	c.Assert(cs.Ports["http"].Port, Equals, uint16(8080))
+	_, ok := observer.getService("c2/bar/foo")
+	c.Assert(ok, Equals, false)
+
+	// Updates of the backends are propagated
+	c.Assert(registrar1.RegisterService(NewClusterService("c1", svc, newTestEndpoint(c, "10.0.0.1", "10.0.0.2"))), IsNil)
+	waitForService(c, observer, "c1/bar/foo", 2)
+
+	// Deregistered services are removed
+	registrar1.DeregisterService(ClusterService{Cluster: "c1", Namespace: "bar", Name: "foo"})
+	waitForService(c, observer, "c1/bar/foo", -1)
+}
+
+func (s *ServiceStoreSuite) TestNotJoined(c *C) {
+	registrar := ServiceRegistrar{}
+	svc := NewClusterService("c1", types.K8sServiceNamespace{ServiceName: "foo"}, newTestEndpoint(c))
+	c.Assert(registrar.RegisterService(svc), IsNil)
+	registrar.DeregisterService(svc)
+}
+
+func (s *ServiceStoreSuite) TestWriterHandover(c *C) {
+	foo := types.K8sServiceNamespace{ServiceName: "foo", Namespace: "bar"}
+	baz := types.K8sServiceNamespace{ServiceName: "baz", Namespace: "bar"}
+
+	observer := &testObserver{services: map[string]ClusterService{}}
+	remote := ServiceRegistrar{}
+	c.Assert(remote.Join(observer), IsNil)
+	defer remote.Close()
+
+	writer1 := ServiceRegistrar{}
+	c.Assert(writer1.Join(nil), IsNil)
+	defer writer1.Close()
+
+	c.Assert(writer1.RegisterService(NewClusterService("c1", foo, newTestEndpoint(c, "10.0.0.1"))), IsNil)
+	c.Assert(writer1.RegisterService(NewClusterService("c1", baz, newTestEndpoint(c, "10.0.0.2"))), IsNil)
+	waitForService(c, observer, "c1/bar/foo", 1)
+	waitForService(c, observer, "c1/bar/baz", 1)
+
+	// Released services remain in the kvstore, even once the previous
+	// writer leaves the store
+	writer1.ReleaseServices()
+	writer1.Close()
+	_, ok := observer.getService("c1/bar/foo")
+	c.Assert(ok, Equals, true)
+
+	// The next writer takes over the services still existing and removes
+	// the others, services of other clusters are left alone
+	c.Assert(remote.RegisterService(NewClusterService("c10", baz, newTestEndpoint(c, "10.1.0.1"))), IsNil)
+	writer2 := ServiceRegistrar{}
+	c.Assert(writer2.Join(nil), IsNil)
+	defer writer2.Close()
+
+	services := []ClusterService{NewClusterService("c1", foo, newTestEndpoint(c, "10.0.0.1", "10.0.0.3"))}
+	c.Assert(writer2.SyncServices("c1", services), IsNil)
+	waitForService(c, observer, "c1/bar/foo", 2)
+	waitForService(c, observer, "c1/bar/baz", -1)
+
+	pairs, err := kvstore.Client().ListPrefix(ServiceStorePrefix + "/c10/")
+	c.Assert(err, IsNil)
+	c.Assert(len(pairs), Equals, 1)
+
+	c.Assert(writer2.SyncServices("c2", services), Not(IsNil))
+}
@@ -0,0 +1,269 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vip
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/ip"
+	"github.com/cilium/cilium/pkg/kvstore"
+)
+
+var (
+	// VIPPrefix is the kvstore prefix of the virtual IPs of services
+	//
+	// WARNING - STABLE API: Changing the structure or values of this will
+	// break backwards compatibility
+	VIPPrefix = path.Join(kvstore.BaseKeyPrefix, "state", "servicevips", "v1")
+
+	// usersPrefix holds a key per virtual IP and service using it
+	usersPrefix = path.Join(VIPPrefix, "users")
+
+	// locksPrefix holds the locks of the virtual IPs, they must not be
+	// below usersPrefix as some backends create keys below the locked
+	// path.
+	locksPrefix = path.Join(VIPPrefix, "locks")
+)
+
+// User is a service using a virtual IP.
+//
+// WARNING - STABLE API: Changing the structure of the user may break
+// backwards compatibility
+type User struct {
+	// Node is the name of the node which recorded the user
+	Node string `json:"node"`
+
+	// Allocated is true if the virtual IP was allocated to the service,
+	// false if it was chosen for the service by the user
+	Allocated bool `json:"allocated"`
+}
+
+// ErrInUse is the error returned if a virtual IP cannot be used by a service
+// because it has been allocated to another service.
+type ErrInUse struct {
+	IP        net.IP
+	ServiceID types.ServiceID
+}
+
+func (e ErrInUse) Error() string {
+	return fmt.Sprintf("virtual IP %s is allocated to service %d", e.IP, e.ServiceID)
+}
+
+// Allocator allocates virtual IPs from the service ranges and records the
+// services using virtual IPs of the service ranges in the kvstore.
+type Allocator struct {
+	node   string
+	ranges []*net.IPNet
+}
+
+// NewAllocator returns an allocator of virtual IPs from ranges which records
+// the users as belonging to the node.
+func NewAllocator(node string, ranges ...*net.IPNet) *Allocator {
+	return &Allocator{
+		node:   node,
+		ranges: ranges,
+	}
+}
+
+// Contains returns true if ip is part of one of the service ranges.
+func (a *Allocator) Contains(ip net.IP) bool {
+	for _, r := range a.ranges {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func usersPath(ip net.IP) string {
+	return path.Join(usersPrefix, ip.String())
+}
+
+func userPath(ip net.IP, id types.ServiceID) string {
+	return path.Join(usersPath(ip), strconv.FormatUint(uint64(id), 10))
+}
+
+func lockPath(ip net.IP) string {
+	return path.Join(locksPrefix, ip.String())
+}
+
+// getUsers returns the users of the virtual IP ip indexed by service ID.
+//
+// The lock of ip must be held.
+func getUsers(ip net.IP) (map[types.ServiceID]User, error) {
+	pairs, err := kvstore.ListPrefix(usersPath(ip) + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[types.ServiceID]User, len(pairs))
+	for key, value := range pairs {
+		id, err := strconv.ParseUint(path.Base(key), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user key %s: %s", key, err)
+		}
+
+		var user User
+		if err := json.Unmarshal(value, &user); err != nil {
+			return nil, fmt.Errorf("invalid user %s: %s", key, err)
+		}
+		users[types.ServiceID(id)] = user
+	}
+
+	return users, nil
+}
+
+// reserve records the service id as user of the virtual IP ip. If allocate
+// is true, ip is only reserved if it has no other user and false is returned
+// otherwise. If allocate is false, ErrInUse is returned if ip has been
+// allocated to another service.
+func (a *Allocator) reserve(ip net.IP, id types.ServiceID, allocate bool) (bool, error) {
+	lock, err := kvstore.LockPath(lockPath(ip))
+	if err != nil {
+		return false, err
+	}
+	defer lock.Unlock()
+
+	users, err := getUsers(ip)
+	if err != nil {
+		return false, err
+	}
+
+	for userID, user := range users {
+		if userID == id {
+			continue
+		}
+		if allocate {
+			return false, nil
+		}
+		if user.Allocated {
+			return false, ErrInUse{IP: ip, ServiceID: userID}
+		}
+	}
+
+	value, err := json.Marshal(User{
+		Node:      a.node,
+		Allocated: allocate || users[id].Allocated,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if err := kvstore.Set(userPath(ip, id), value); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Reserve records the service id as user of the virtual IP ip which was
+// chosen by the user of the service. It returns ErrInUse if ip has been
+// allocated to another service. IPs outside of the service ranges are
+// ignored.
+func (a *Allocator) Reserve(ip net.IP, id types.ServiceID) error {
+	if !a.Contains(ip) {
+		return nil
+	}
+
+	_, err := a.reserve(ip, id, false)
+	return err
+}
+
+// Release removes the service id from the users of the virtual IP ip. IPs
+// outside of the service ranges are ignored.
+func (a *Allocator) Release(ip net.IP, id types.ServiceID) error {
+	if !a.Contains(ip) {
+		return nil
+	}
+
+	lock, err := kvstore.LockPath(lockPath(ip))
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	return kvstore.Delete(userPath(ip, id))
+}
+
+// isReservedIP returns true if ip is the network or the broadcast address of
+// the range r, which are never allocated.
+func isReservedIP(r *net.IPNet, addr net.IP) bool {
+	if addr.Equal(r.IP) {
+		return true
+	}
+	if addr.To4() == nil {
+		return false
+	}
+	next := ip.GetNextIP(addr)
+	return next.Equal(addr) || !r.Contains(next)
+}
+
+// Allocate allocates a virtual IP of the IPv6 or IPv4 service range to the
+// service id. Only IPs without any user in the cluster and for which inUse
+// returns false are allocated.
+func (a *Allocator) Allocate(ipv6 bool, id types.ServiceID, inUse func(ip net.IP) bool) (net.IP, error) {
+	family := "IPv4"
+	if ipv6 {
+		family = "IPv6"
+	}
+
+	pairs, err := kvstore.ListPrefix(usersPrefix + "/")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list virtual IPs: %s", err)
+	}
+
+	used := map[string]bool{}
+	for key := range pairs {
+		used[path.Dir(strings.TrimPrefix(key, usersPrefix+"/"))] = true
+	}
+
+	found := false
+	for _, r := range a.ranges {
+		if (r.IP.To4() == nil) != ipv6 {
+			continue
+		}
+		found = true
+
+		for addr := r.IP; r.Contains(addr); {
+			if !isReservedIP(r, addr) && !used[addr.String()] && !inUse(addr) {
+				ok, err := a.reserve(addr, id, true)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					return addr, nil
+				}
+			}
+
+			next := ip.GetNextIP(addr)
+			if next.Equal(addr) {
+				break
+			}
+			addr = next
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no %s service range configured", family)
+	}
+
+	return nil, fmt.Errorf("no free virtual IP left in %s service range", family)
+}
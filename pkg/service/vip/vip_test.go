@@ -0,0 +1,119 @@
+// Copyright 2018 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vip
+
+import (
+	"net"
+	"testing"
+
+	"github.com/cilium/cilium/common/types"
+	"github.com/cilium/cilium/pkg/kvstore"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type VIPSuite struct{}
+
+var _ = Suite(&VIPSuite{})
+
+func (s *VIPSuite) SetUpTest(c *C) {
+	kvstore.SetupDummy(kvstore.MemoryBackendName)
+}
+
+func (s *VIPSuite) TearDownTest(c *C) {
+	kvstore.Close()
+}
+
+func newTestAllocator(node string, cidrs ...string) *Allocator {
+	ranges := []*net.IPNet{}
+	for _, cidr := range cidrs {
+		_, r, _ := net.ParseCIDR(cidr)
+		ranges = append(ranges, r)
+	}
+	return NewAllocator(node, ranges...)
+}
+
+func notInUse(ip net.IP) bool {
+	return false
+}
+
+func (s *VIPSuite) TestAllocate(c *C) {
+	a1 := newTestAllocator("node1", "10.96.0.0/30", "fd03::/126")
+	a2 := newTestAllocator("node2", "10.96.0.0/30", "fd03::/126")
+
+	// The network and broadcast addresses are skipped
+	ip, err := a1.Allocate(false, 1, notInUse)
+	c.Assert(err, IsNil)
+	c.Assert(ip.String(), Equals, "10.96.0.1")
+
+	// Allocations of other nodes are taken into account
+	ip, err = a2.Allocate(false, 2, notInUse)
+	c.Assert(err, IsNil)
+	c.Assert(ip.String(), Equals, "10.96.0.2")
+
+	_, err = a1.Allocate(false, 3, notInUse)
+	c.Assert(err, Not(IsNil))
+
+	ip, err = a1.Allocate(true, 3, func(ip net.IP) bool {
+		return ip.Equal(net.ParseIP("fd03::1"))
+	})
+	c.Assert(err, IsNil)
+	c.Assert(ip.String(), Equals, "fd03::2")
+
+	c.Assert(a2.Release(net.ParseIP("10.96.0.2"), 2), IsNil)
+	ip, err = a1.Allocate(false, 4, notInUse)
+	c.Assert(err, IsNil)
+	c.Assert(ip.String(), Equals, "10.96.0.2")
+
+	_, err = newTestAllocator("node1").Allocate(false, 5, notInUse)
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *VIPSuite) TestReserve(c *C) {
+	a1 := newTestAllocator("node1", "10.96.0.0/24")
+	a2 := newTestAllocator("node2", "10.96.0.0/24")
+
+	ip, err := a1.Allocate(false, 1, notInUse)
+	c.Assert(err, IsNil)
+
+	// The service may reserve its own allocation again
+	c.Assert(a2.Reserve(ip, 1), IsNil)
+
+	err = a2.Reserve(ip, 2)
+	c.Assert(err, DeepEquals, ErrInUse{IP: ip, ServiceID: 1})
+
+	// IPs chosen by the user may be shared but are not allocated
+	manual := net.ParseIP("10.96.0.100")
+	c.Assert(a1.Reserve(manual, 3), IsNil)
+	c.Assert(a2.Reserve(manual, 4), IsNil)
+
+	users, err := getUsers(manual)
+	c.Assert(err, IsNil)
+	c.Assert(users, DeepEquals, map[types.ServiceID]User{
+		3: {Node: "node1"},
+		4: {Node: "node2"},
+	})
+
+	// IPs outside of the service ranges are not recorded
+	outside := net.ParseIP("192.168.0.1")
+	c.Assert(a1.Reserve(outside, 5), IsNil)
+	users, err = getUsers(outside)
+	c.Assert(err, IsNil)
+	c.Assert(users, HasLen, 0)
+}
@@ -15,6 +15,9 @@
 package workloads
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/cilium/cilium/api/v1/models"
 	"github.com/cilium/cilium/pkg/endpoint"
 )
@@ -50,6 +53,16 @@ func IsRunning(ep *endpoint.Endpoint) bool {
 	return Client().IsRunning(ep)
 }
 
+// WorkloadIDsList returns the IDs of the running workloads. The runtime must be
+// reachable to retrieve the list.
+func WorkloadIDsList(ctx context.Context) ([]string, error) {
+	if Client() == nil {
+		return nil, fmt.Errorf("no container runtime configured")
+	}
+
+	return Client().workloadIDsList(ctx)
+}
+
 // Status returns the status of the workload runtime
 func Status() *models.Status {
 	return Client().Status()
@@ -89,6 +89,31 @@ type NetworkInfo struct {
 	} `json:"labels,omitempty"`
 }
 
+// K8sArgs are the arguments passed by the kubelet in CNI_ARGS
+type K8sArgs struct {
+	cniTypes.CommonArgs
+	K8S_POD_NAME               cniTypes.UnmarshallableString
+	K8S_POD_NAMESPACE          cniTypes.UnmarshallableString
+	K8S_POD_INFRA_CONTAINER_ID cniTypes.UnmarshallableString
+}
+
+// ipamOwner returns the owner of the addresses to allocate for the container
+// of the CNI invocation args, including the pod if args describe one.
+func ipamOwner(args *skel.CmdArgs) *models.IPAMOwner {
+	owner := &models.IPAMOwner{ContainerID: args.ContainerID}
+
+	k8sArgs := K8sArgs{}
+	if err := cniTypes.LoadArgs(args.Args, &k8sArgs); err != nil {
+		log.WithError(err).Debug("Unable to parse CNI arguments")
+		return owner
+	}
+
+	owner.Namespace = string(k8sArgs.K8S_POD_NAMESPACE)
+	owner.PodName = string(k8sArgs.K8S_POD_NAME)
+
+	return owner
+}
+
 func main() {
 	skel.PluginMain(cmdAdd, cmdDel, version.All)
 }
@@ -356,7 +381,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return nil
 	})
 
-	ipam, err := client.IPAMAllocate("")
+	ipam, err := client.IPAMAllocate("", ipamOwner(args))
 	if err != nil {
 		return err
 	}